@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
 
 	"fusionmail/config"
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+	"fusionmail/pkg/crypto"
 	"fusionmail/pkg/database"
+	"fusionmail/pkg/storage"
+
+	"gorm.io/gorm"
 )
 
 func main() {
 	// 定义命令行参数
-	action := flag.String("action", "up", "Migration action: up (migrate) or status (check)")
+	action := flag.String("action", "up", "Migration action: up (migrate), status (check), threads (rebuild conversation threads), counters (recompute email counters), encrypt (encrypt existing email bodies and stored files) or reparse (rebuild parsed fields from archived raw messages)")
+	accountUID := flag.String("account", "", "Only process emails of this account UID (reparse)")
 	flag.Parse()
 
 	log.Println("FusionMail Database Migration Tool")
@@ -60,9 +69,9 @@ func main() {
 
 		// 检查表是否存在
 		tables := []string{
-			"users", "accounts", "emails", "email_attachments",
+			"users", "accounts", "emails", "email_attachments", "attachment_blobs",
 			"email_labels", "email_label_relations", "email_rules",
-			"webhooks", "webhook_logs", "sync_logs", "api_keys",
+			"webhooks", "webhook_logs", "sync_logs", "backfill_jobs", "threads", "thread_refs", "mailbox_counters", "data_keys", "email_search_tokens", "api_keys",
 		}
 
 		for _, table := range tables {
@@ -77,9 +86,109 @@ func main() {
 			}
 		}
 
+	case "threads":
+		// 按 JWZ 算法重建全部会话（会话功能上线后首次执行，或归并规则调整后执行）
+		log.Println("Rebuilding conversation threads...")
+		db := database.GetDB()
+		cipher := newFieldCipher(db, cfg)
+		threadService := service.NewThreadService(repository.NewThreadRepository(db, cipher), repository.NewEmailRepository(db, cipher), nil)
+		count, err := threadService.Rebuild(context.Background())
+		if err != nil {
+			log.Fatalf("Thread rebuild failed: %v", err)
+		}
+		log.Printf("Rebuilt %d threads", count)
+
+	case "counters":
+		// 根据邮件表重算计数器和账户统计（计数器与实际邮件不一致时执行）
+		log.Println("Recomputing email counters...")
+		if err := repository.NewCounterRepository(database.GetDB()).Recompute(context.Background()); err != nil {
+			log.Fatalf("Counter recompute failed: %v", err)
+		}
+		log.Println("Email counters recomputed")
+
+	case "encrypt":
+		// 静态加密上线前的邮件正文和附件文件就地加密（可重复执行，已加密的数据跳过）
+		if !cfg.Security.EncryptAtRest {
+			log.Fatalf("Set ENCRYPTION_AT_REST=true before encrypting existing data")
+		}
+		ctx := context.Background()
+		db := database.GetDB()
+
+		log.Println("Encrypting email bodies...")
+		emailRepo := repository.NewEmailRepository(db, newFieldCipher(db, cfg))
+		var afterID int64
+		total := 0
+		for {
+			lastID, count, err := emailRepo.EncryptExisting(ctx, afterID, 500)
+			if err != nil {
+				log.Fatalf("Email encryption failed after id %d: %v", afterID, err)
+			}
+			if lastID == 0 {
+				break
+			}
+			total += count
+			afterID = lastID
+		}
+		log.Printf("Encrypted %d email bodies", total)
+
+		log.Println("Encrypting attachment files...")
+		storageProvider, err := storage.NewProvider(cfg.Storage.ProviderConfig())
+		if err != nil {
+			log.Fatalf("Failed to create storage provider: %v", err)
+		}
+		encrypted := storage.NewEncryptedProvider(storageProvider, newKeyring(db), true)
+		attachmentService := service.NewAttachmentService(repository.NewAttachmentRepository(db), encrypted, service.AttachmentOptions{StorageType: cfg.Storage.Type})
+		files, err := attachmentService.EncryptStoredFiles(ctx, encrypted)
+		if err != nil {
+			log.Fatalf("Attachment encryption failed: %v", err)
+		}
+		log.Printf("Encrypted %d attachment files", files)
+
+		log.Println("Encrypting archived raw messages...")
+		rawFiles, err := service.NewRawMessageService(emailRepo, encrypted).EncryptStoredFiles(ctx, encrypted)
+		if err != nil {
+			log.Fatalf("Raw message encryption failed: %v", err)
+		}
+		log.Printf("Encrypted %d raw message files", rawFiles)
+
+	case "reparse":
+		// 解析器修复后从归档的原文重建邮件的解析字段（主题、地址、正文、摘要等）
+		log.Println("Reparsing archived raw messages...")
+		db := database.GetDB()
+		storageProvider, err := storage.NewProvider(cfg.Storage.ProviderConfig())
+		if err != nil {
+			log.Fatalf("Failed to create storage provider: %v", err)
+		}
+		storageProvider = storage.NewEncryptedProvider(storageProvider, newKeyring(db), cfg.Security.EncryptAtRest)
+		rawMessageService := service.NewRawMessageService(repository.NewEmailRepository(db, newFieldCipher(db, cfg)), storageProvider)
+		result, err := rawMessageService.ReparseAll(context.Background(), *accountUID, func(email *model.Email, err error) {
+			log.Printf("Failed to reparse email %d: %v", email.ID, err)
+		})
+		if err != nil {
+			log.Fatalf("Reparse failed: %v", err)
+		}
+		log.Printf("Reparsed %d emails, %d failed", result.Reparsed, result.Failed)
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+
 	default:
-		log.Fatalf("Unknown action: %s (use 'up' or 'status')", *action)
+		log.Fatalf("Unknown action: %s (use 'up', 'status', 'threads', 'counters', 'encrypt' or 'reparse')", *action)
 	}
 
 	os.Exit(0)
 }
+
+// newKeyring 创建数据密钥管理器（主密钥来自 ENCRYPTION_KEY）
+func newKeyring(db *gorm.DB) *crypto.Keyring {
+	masterKey, err := crypto.NewEncryptor()
+	if err != nil {
+		log.Fatalf("Failed to create encryptor: %v", err)
+	}
+	return crypto.NewKeyring(masterKey, repository.NewDataKeyRepository(db))
+}
+
+// newFieldCipher 创建邮件正文加密器
+func newFieldCipher(db *gorm.DB, cfg *config.Config) *crypto.FieldCipher {
+	return crypto.NewFieldCipher(newKeyring(db), cfg.Security.EncryptAtRest)
+}
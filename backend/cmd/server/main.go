@@ -17,8 +17,12 @@ import (
 	"fusionmail/internal/repository"
 	"fusionmail/internal/router"
 	"fusionmail/internal/service"
+	"fusionmail/pkg/crypto"
 	"fusionmail/pkg/database"
+	"fusionmail/pkg/event"
 	"fusionmail/pkg/logger"
+	"fusionmail/pkg/queue"
+	"fusionmail/pkg/storage"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -56,28 +60,32 @@ func main() {
 
 	log.Println("Database initialization completed successfully")
 
-	// 创建服务实例
+	// 静态加密：每个账户一个数据密钥，由主密钥（ENCRYPTION_KEY）加密后保存；未启用时已加密的数据仍可读取
 	db := database.GetDB()
+	masterKey, err := crypto.NewEncryptor()
+	if err != nil {
+		log.Fatalf("Failed to create encryptor: %v", err)
+	}
+	keyring := crypto.NewKeyring(masterKey, repository.NewDataKeyRepository(db))
+	bodyCipher := crypto.NewFieldCipher(keyring, cfg.Security.EncryptAtRest)
+
+	// 创建服务实例
 	accountRepo := repository.NewAccountRepository(db)
-	emailRepo := repository.NewEmailRepository(db)
+	emailRepo := repository.NewEmailRepository(db, bodyCipher)
 	ruleRepo := repository.NewRuleRepository(db)
 	webhookRepo := repository.NewWebhookRepository(db)
 	webhookLogRepo := repository.NewWebhookLogRepository(db)
 	syncLogRepo := repository.NewSyncLogRepository(db)
-	adapterFactory := adapter.NewFactory()
-
-	// 创建账户服务
-	accountService, err := service.NewAccountService(accountRepo, adapterFactory)
-	if err != nil {
-		log.Fatalf("Failed to create account service: %v", err)
+	counterRepo := repository.NewCounterRepository(db)
+	adapterFactory := adapter.NewFactory(adapterOptions(&cfg.Demo))
+
+	// 计数器上线前已有邮件时先重算一次（之后由邮件写入事务维护，漂移时用 cmd/migrate -action=counters 修复）
+	if recomputed, err := counterRepo.InitializeIfEmpty(context.Background()); err != nil {
+		log.Printf("Warning: failed to initialize email counters: %v", err)
+	} else if recomputed {
+		log.Println("Email counters initialized from existing emails")
 	}
 
-	// 创建邮件服务
-	emailService := service.NewEmailService(emailRepo, accountRepo)
-
-	// 创建规则服务
-	ruleService := service.NewRuleService(ruleRepo, emailRepo)
-
 	// 初始化 Redis 客户端
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
@@ -85,16 +93,117 @@ func main() {
 		DB:       cfg.Redis.DB,
 	})
 
+	// 创建规则服务
+	ruleService := service.NewRuleService(ruleRepo, emailRepo)
+
+	// 创建 Webhook 服务
+	logger := logger.New()
+	webhookService := service.NewWebhookService(webhookRepo, webhookLogRepo, logger)
+
 	// 测试 Redis 连接
+	executorOptions := service.ExecutorOptions{Workers: cfg.Sync.WorkerCount}
+	var eventService service.EventService
+	var events service.EventPublisher // Redis 不可用时为 nil，不发布事件
+	var sharedRedis *redis.Client     // Redis 不可用时为 nil，一次性下载链接只在进程内记录
 	if err := redisClient.Ping(context.Background()).Err(); err != nil {
 		log.Printf("Warning: Redis connection failed: %v", err)
+		if cfg.Sync.Mode == "queue" {
+			log.Println("Warning: SYNC_MODE=queue requires Redis, falling back to in-process sync")
+		}
 	} else {
 		log.Println("Redis connection established successfully")
+		sharedRedis = redisClient
+		syncQueue := queue.NewRedisQueue(redisClient, service.SyncQueueName)
+		executorOptions.Locker = syncQueue
+		// 同步任务进度写入 Redis，多实例和 worker 之间共享
+		executorOptions.Progress = service.NewSyncProgressTracker(redisClient)
+		if cfg.Sync.Mode == "queue" {
+			// 同步任务投递到 Redis 队列，由 cmd/worker 执行
+			executorOptions.TaskQueue = service.NewSyncTaskQueue(syncQueue)
+			log.Println("Sync mode: queue (run cmd/worker to execute sync tasks)")
+		}
+
+		// 事件服务：订阅同步、账户和邮件事件，触发规则和 Webhook
+		eventService = service.NewEventService(event.NewRedisBus(redisClient, logger), ruleService, webhookService, logger)
+		if err := eventService.Start(context.Background()); err != nil {
+			log.Printf("Warning: failed to start event service: %v", err)
+		} else {
+			events = eventService
+		}
 	}
 
-	// 创建 Webhook 服务
-	logger := logger.New()
-	webhookService := service.NewWebhookService(webhookRepo, webhookLogRepo, logger)
+	// 创建会话服务（同步时把新邮件归入会话，邮件状态变化时维护会话统计）
+	threadService := service.NewThreadService(repository.NewThreadRepository(db, bodyCipher), emailRepo, events)
+
+	// 创建附件服务（同步时通过存储提供者保存附件内容）
+	storageProvider, err := storage.NewProvider(cfg.Storage.ProviderConfig())
+	if err != nil {
+		log.Fatalf("Failed to create storage provider: %v", err)
+	}
+	storageProvider = storage.NewEncryptedProvider(storageProvider, keyring, cfg.Security.EncryptAtRest)
+	attachmentService := service.NewAttachmentService(repository.NewAttachmentRepository(db), storageProvider, attachmentOptions(&cfg.Storage))
+
+	// 创建附件下载链接服务（签名链接无需登录即可下载，用于 <img> 和分享）
+	downloadLinkSecret := cfg.Security.DownloadLinkSecret
+	if downloadLinkSecret == "" {
+		downloadLinkSecret = cfg.JWT.Secret
+	}
+	downloadLinks, err := service.NewDownloadLinkService(attachmentService, sharedRedis, service.DownloadLinkOptions{
+		Secret:        downloadLinkSecret,
+		BaseURL:       cfg.Server.PublicURL,
+		DefaultExpiry: time.Duration(cfg.Security.DownloadLinkExpiryMinutes) * time.Minute,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create download link service: %v", err)
+	}
+
+	// 创建外部图片代理和邮件正文渲染服务（关闭代理时外部图片始终被阻止）
+	var imageProxy *service.ImageProxyService
+	var renderImageProxy service.ImageProxy
+	if cfg.Proxy.Enabled {
+		imageProxy, err = service.NewImageProxyService(storageProvider, service.ImageProxyOptions{
+			Secret:   downloadLinkSecret,
+			BaseURL:  cfg.Server.PublicURL,
+			MaxSize:  int64(cfg.Proxy.MaxSizeMB) << 20,
+			Timeout:  time.Duration(cfg.Proxy.TimeoutSeconds) * time.Second,
+			CacheTTL: time.Duration(cfg.Proxy.CacheHours) * time.Hour,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create image proxy: %v", err)
+		}
+		renderImageProxy = imageProxy
+	}
+	renderService := service.NewEmailRenderService(emailRepo, attachmentService, downloadLinks, renderImageProxy)
+
+	// 创建原始邮件归档服务（关闭归档时同步不再保存原文，已归档的原文仍可下载）
+	rawMessageService := service.NewRawMessageService(emailRepo, storageProvider)
+	var syncRawMessages *service.RawMessageService
+	if cfg.Storage.ArchiveRaw {
+		syncRawMessages = rawMessageService
+	}
+
+	// 创建同步管理器（按账户同步间隔调度，Redis 锁保证多实例下同一账户不会并发同步）
+	syncManager, err := service.NewSyncManager(adapterFactory, schedulerOptions(&cfg.Sync), executorOptions, reconcileOptions(&cfg.Sync), service.BackfillOptions{
+		PageSize:  cfg.Sync.BackfillPageSize,
+		PageDelay: time.Duration(cfg.Sync.BackfillPageDelayMs) * time.Millisecond,
+	}, events, attachmentService, threadService, bodyCipher, syncRawMessages)
+	if err != nil {
+		log.Fatalf("Failed to create sync manager: %v", err)
+	}
+
+	// 创建保留策略清理服务（定期永久删除过期的回收站邮件、清理过期归档邮件和大附件、删除旧日志）
+	emailPurger := service.NewEmailPurger(emailRepo, attachmentService, rawMessageService, threadService)
+	retentionService := service.NewRetentionService(accountRepo, emailRepo, attachmentService, emailPurger, threadService,
+		syncLogRepo, webhookLogRepo, retentionOptions(&cfg.Retention, executorOptions.Locker))
+
+	// 创建账户服务
+	accountService, err := service.NewAccountService(accountRepo, adapterFactory, syncManager, events)
+	if err != nil {
+		log.Fatalf("Failed to create account service: %v", err)
+	}
+
+	// 创建邮件服务
+	emailService := service.NewEmailService(emailRepo, accountRepo, counterRepo, events, threadService, emailPurger)
 
 	// 创建系统管理服务
 	systemService := service.NewSystemService(
@@ -105,6 +214,7 @@ func main() {
 		ruleRepo,
 		webhookRepo,
 		syncLogRepo,
+		syncManager,
 		logger,
 	)
 
@@ -116,15 +226,21 @@ func main() {
 	ruleHandler := handler.NewRuleHandler(ruleService)
 	webhookHandler := handler.NewWebhookHandler(webhookService, webhookLogRepo)
 	systemHandler := handler.NewSystemHandler(systemService)
-
-	// 创建并启动同步管理器
-	syncManager := service.NewSyncManager()
+	backfillHandler := handler.NewBackfillHandler(syncManager.Backfill())
+	syncHandler := handler.NewSyncHandler(syncManager)
+	attachmentHandler := handler.NewAttachmentHandler(attachmentService, downloadLinks)
+	threadHandler := handler.NewThreadHandler(threadService)
+	rawMessageHandler := handler.NewRawMessageHandler(rawMessageService)
+	renderHandler := handler.NewEmailRenderHandler(renderService, imageProxy)
+
+	// 启动同步管理器
 	ctx := context.Background()
 	if err := syncManager.Start(ctx); err != nil {
 		log.Printf("Failed to start sync manager: %v", err)
 	} else {
 		log.Println("Sync manager started successfully")
 	}
+	retentionService.Start(ctx)
 
 	// 设置 Gin 模式
 	if os.Getenv("GIN_MODE") == "" {
@@ -139,6 +255,12 @@ func main() {
 		ruleHandler,
 		webhookHandler,
 		systemHandler,
+		backfillHandler,
+		syncHandler,
+		attachmentHandler,
+		threadHandler,
+		rawMessageHandler,
+		renderHandler,
 		syncManager,
 		redisClient,
 		jwtSecret,
@@ -195,10 +317,18 @@ func main() {
 
 	log.Println("Shutting down server...")
 
-	// 停止同步管理器
+	// 停止同步管理器和定期清理
 	if err := syncManager.Stop(); err != nil {
 		log.Printf("Failed to stop sync manager: %v", err)
 	}
+	retentionService.Stop()
+
+	// 停止事件服务（同步结束后再停止，保证最后的同步事件已发布）
+	if events != nil {
+		if err := eventService.Stop(); err != nil {
+			log.Printf("Failed to stop event service: %v", err)
+		}
+	}
 
 	// 优雅关闭服务器
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -211,6 +341,73 @@ func main() {
 	log.Println("Server exited")
 }
 
+// schedulerOptions 根据配置构建同步调度器选项
+func schedulerOptions(cfg *config.SyncConfig) service.SchedulerOptions {
+	location := time.Local
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			log.Printf("Warning: invalid SYNC_TIMEZONE %q, using local time: %v", cfg.Timezone, err)
+		} else {
+			location = loc
+		}
+	}
+
+	return service.SchedulerOptions{
+		QuietHours: cfg.QuietHours,
+		Location:   location,
+		Jitter:     time.Duration(cfg.JitterSeconds) * time.Second,
+
+		MaxBackoff:       time.Duration(cfg.MaxBackoffMinutes) * time.Minute,
+		FailureThreshold: cfg.FailureThreshold,
+	}
+}
+
+// retentionOptions 根据配置构建保留策略清理选项
+func retentionOptions(cfg *config.RetentionConfig, locker service.SyncLocker) service.RetentionOptions {
+	return service.RetentionOptions{
+		Policy: service.RetentionPolicy{
+			TrashDays:      cfg.TrashDays,
+			ArchiveDays:    cfg.ArchiveDays,
+			AttachmentDays: cfg.AttachmentDays,
+			AttachmentMB:   cfg.AttachmentMB,
+		},
+		SyncLogDays:    cfg.SyncLogDays,
+		WebhookLogDays: cfg.WebhookLogDays,
+
+		Interval: time.Duration(cfg.IntervalMinutes) * time.Minute,
+		DryRun:   cfg.DryRun,
+		Locker:   locker,
+	}
+}
+
+// reconcileOptions 根据配置构建源邮箱对账选项
+func reconcileOptions(cfg *config.SyncConfig) service.ReconcileOptions {
+	return service.ReconcileOptions{
+		Interval: time.Duration(cfg.ReconcileIntervalMinutes) * time.Minute,
+		Policy:   cfg.SourceDeletePolicy,
+	}
+}
+
+// adapterOptions 根据配置构建邮箱适配器工厂选项
+func adapterOptions(cfg *config.DemoConfig) adapter.FactoryOptions {
+	return adapter.FactoryOptions{
+		Demo: adapter.DemoOptions{
+			Interval: cfg.Interval,
+			History:  cfg.History,
+			Seed:     cfg.Seed,
+		},
+	}
+}
+
+// attachmentOptions 根据配置构建附件服务选项
+func attachmentOptions(cfg *config.StorageConfig) service.AttachmentOptions {
+	return service.AttachmentOptions{
+		StorageType:    cfg.Type,
+		DefaultMaxSize: int64(cfg.MaxAttachmentMB) << 20,
+	}
+}
+
 // getStaticPath 获取静态文件路径
 func getStaticPath() string {
 	// 优先使用环境变量
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"fusionmail/config"
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+	"fusionmail/pkg/crypto"
+	"fusionmail/pkg/database"
+	"fusionmail/pkg/storage"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+)
+
+// 附件存储维护工具
+func main() {
+	// 定义命令行参数
+	action := flag.String("action", "gc", "Storage action: gc (reclaim unreferenced attachment blobs), migrate (copy stored files to another provider), verify (check stored files against database records) or retention (apply retention policies once)")
+	grace := flag.Duration("grace", time.Hour, "Skip blobs registered or referenced within this period (gc)")
+	dryRun := flag.Bool("dry-run", false, "Report what would be reclaimed or purged without deleting anything (gc, retention)")
+	to := flag.String("to", "", "Target storage type: local or s3, configured by the STORAGE_* variables (migrate)")
+	toPath := flag.String("to-path", "", "Target directory when migrating to local storage, defaults to STORAGE_LOCAL_PATH (migrate)")
+	flag.Parse()
+
+	log.Println("FusionMail Storage Tool")
+	log.Printf("Action: %s", *action)
+
+	// 加载环境变量和配置
+	_ = godotenv.Load()
+	cfg := config.Load()
+
+	// 初始化数据库连接
+	if err := database.Initialize(&cfg.Database); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+	db := database.GetDB()
+
+	storageProvider, err := storage.NewProvider(cfg.Storage.ProviderConfig())
+	if err != nil {
+		log.Fatalf("Failed to create storage provider: %v", err)
+	}
+	attachmentRepo := repository.NewAttachmentRepository(db)
+	attachmentService := service.NewAttachmentService(attachmentRepo, storageProvider, service.AttachmentOptions{StorageType: cfg.Storage.Type})
+
+	switch *action {
+	case "gc":
+		// 回收没有附件记录引用的内容（邮件删除后遗留、保存失败遗留）
+		if *dryRun {
+			log.Println("Dry run: nothing will be deleted")
+		}
+		result, err := attachmentService.CollectGarbage(context.Background(), *grace, *dryRun)
+		if err != nil {
+			log.Fatalf("Attachment garbage collection failed: %v", err)
+		}
+		log.Printf("Recounted %d blobs, reclaimed %d blobs (%d bytes), %d failed",
+			result.Recounted, result.Reclaimed, result.ReclaimedBytes, result.Failed)
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+
+	case "migrate":
+		// 把附件和邮件原文从当前存储（STORAGE_TYPE）原样复制到目标存储，加密文件保持加密
+		// 可重复执行：已迁移的附件记录和目标中内容相同的文件跳过；完成后把 STORAGE_TYPE 改为目标类型并重启服务
+		if *to == "" {
+			log.Fatalf("Specify the target storage type with -to")
+		}
+		targetConfig := cfg.Storage.ProviderConfig()
+		targetConfig.Type = *to
+		if *toPath != "" {
+			targetConfig.LocalPath = *toPath
+		}
+		if targetConfig.Type == cfg.Storage.Type && targetConfig.LocalPath == cfg.Storage.LocalPath {
+			log.Fatalf("Target storage is the same as the current storage")
+		}
+		target, err := storage.NewProvider(targetConfig)
+		if err != nil {
+			log.Fatalf("Failed to create target storage provider: %v", err)
+		}
+		log.Printf("Migrating stored files from %s to %s", cfg.Storage.Type, *to)
+
+		onError := func(storagePath string, err error) {
+			log.Printf("Failed to migrate %s: %v", storagePath, err)
+		}
+		attachments, err := attachmentService.MigrateStoredFiles(context.Background(), target, *to, onError)
+		if err != nil {
+			log.Fatalf("Attachment migration failed: %v", err)
+		}
+		log.Printf("Attachments: copied %d files (%d bytes), %d already present, %d failed",
+			attachments.Copied, attachments.Bytes, attachments.Skipped, attachments.Failed)
+
+		rawMessageService := service.NewRawMessageService(repository.NewEmailRepository(db, newFieldCipher(db, cfg)), storageProvider)
+		raw, err := rawMessageService.MigrateStoredFiles(context.Background(), target, onError)
+		if err != nil {
+			log.Fatalf("Raw message migration failed: %v", err)
+		}
+		log.Printf("Raw messages: copied %d files (%d bytes), %d already present, %d failed",
+			raw.Copied, raw.Bytes, raw.Skipped, raw.Failed)
+
+		if attachments.Failed > 0 || raw.Failed > 0 {
+			log.Println("Some files failed to migrate; run the command again to retry them")
+			os.Exit(1)
+		}
+		log.Printf("Migration completed; set STORAGE_TYPE=%s and restart the services", *to)
+
+	case "verify":
+		// 按数据库记录检查当前存储中的附件和邮件原文（静态加密的文件解密后校验）
+		keyring := newKeyring(db)
+		verifyProvider := storage.NewEncryptedProvider(storageProvider, keyring, cfg.Security.EncryptAtRest)
+		report := func(issue service.StorageIssue) {
+			if issue.Err != nil {
+				log.Printf("%s %s %s (%s): %v", issue.Problem, issue.Kind, issue.ID, issue.Path, issue.Err)
+			} else {
+				log.Printf("%s %s %s (%s)", issue.Problem, issue.Kind, issue.ID, issue.Path)
+			}
+		}
+
+		verifyAttachments := service.NewAttachmentService(attachmentRepo, verifyProvider, service.AttachmentOptions{StorageType: cfg.Storage.Type})
+		attachments, err := verifyAttachments.VerifyStoredFiles(context.Background(), report)
+		if err != nil {
+			log.Fatalf("Attachment verification failed: %v", err)
+		}
+		log.Printf("Attachments: checked %d files, %d problems", attachments.Checked, attachments.Issues)
+
+		emailRepo := repository.NewEmailRepository(db, crypto.NewFieldCipher(keyring, cfg.Security.EncryptAtRest))
+		raw, err := service.NewRawMessageService(emailRepo, verifyProvider).VerifyStoredFiles(context.Background(), report)
+		if err != nil {
+			log.Fatalf("Raw message verification failed: %v", err)
+		}
+		log.Printf("Raw messages: checked %d files, %d problems", raw.Checked, raw.Issues)
+
+		if attachments.Issues > 0 || raw.Issues > 0 {
+			os.Exit(1)
+		}
+
+	case "retention":
+		// 按保留策略（RETENTION_* 和账户设置）立即执行一次清理，与 API 服务的定期清理相同
+		if *dryRun {
+			log.Println("Dry run: nothing will be deleted")
+		}
+		cipher := newFieldCipher(db, cfg)
+		emailRepo := repository.NewEmailRepository(db, cipher)
+		threadService := service.NewThreadService(repository.NewThreadRepository(db, cipher), emailRepo, nil)
+		rawMessageService := service.NewRawMessageService(emailRepo, storageProvider)
+		retentionService := service.NewRetentionService(
+			repository.NewAccountRepository(db),
+			emailRepo,
+			attachmentService,
+			service.NewEmailPurger(emailRepo, attachmentService, rawMessageService, threadService),
+			threadService,
+			repository.NewSyncLogRepository(db),
+			repository.NewWebhookLogRepository(db),
+			retentionOptions(&cfg.Retention),
+		)
+
+		report, err := retentionService.Run(context.Background(), *dryRun)
+		for _, account := range report.Accounts {
+			log.Printf("Account %s (%s): trash %d days, archive %d days, attachments >= %d MB after %d days",
+				account.Email, account.AccountUID, account.Policy.TrashDays, account.Policy.ArchiveDays, account.Policy.AttachmentMB, account.Policy.AttachmentDays)
+			log.Printf("  purged %d trashed emails, moved %d archived emails to trash, released %d attachments (%d bytes), %d failed",
+				account.TrashPurged, account.ArchiveTrashed, account.AttachmentsReleased, account.AttachmentBytes, account.Failed)
+		}
+		if err != nil {
+			log.Fatalf("Retention failed: %v", err)
+		}
+		log.Printf("Deleted %d sync logs and %d webhook logs", report.SyncLogsDeleted, report.WebhookLogsDeleted)
+		if report.Failed() > 0 {
+			os.Exit(1)
+		}
+
+	default:
+		log.Fatalf("Unknown action: %s (use 'gc', 'migrate', 'verify' or 'retention')", *action)
+	}
+}
+
+// retentionOptions 根据配置构建保留策略（手动执行，不需要定期清理的间隔和锁）
+func retentionOptions(cfg *config.RetentionConfig) service.RetentionOptions {
+	return service.RetentionOptions{
+		Policy: service.RetentionPolicy{
+			TrashDays:      cfg.TrashDays,
+			ArchiveDays:    cfg.ArchiveDays,
+			AttachmentDays: cfg.AttachmentDays,
+			AttachmentMB:   cfg.AttachmentMB,
+		},
+		SyncLogDays:    cfg.SyncLogDays,
+		WebhookLogDays: cfg.WebhookLogDays,
+	}
+}
+
+// newKeyring 创建静态加密密钥环（数据密钥由 ENCRYPTION_KEY 加密保存在数据库中）
+func newKeyring(db *gorm.DB) *crypto.Keyring {
+	masterKey, err := crypto.NewEncryptor()
+	if err != nil {
+		log.Fatalf("Failed to create encryptor: %v", err)
+	}
+	return crypto.NewKeyring(masterKey, repository.NewDataKeyRepository(db))
+}
+
+// newFieldCipher 创建邮件正文加密器
+func newFieldCipher(db *gorm.DB, cfg *config.Config) *crypto.FieldCipher {
+	return crypto.NewFieldCipher(newKeyring(db), cfg.Security.EncryptAtRest)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"fusionmail/config"
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+	"fusionmail/pkg/crypto"
+	"fusionmail/pkg/database"
+	"fusionmail/pkg/event"
+	"fusionmail/pkg/logger"
+	"fusionmail/pkg/queue"
+	"fusionmail/pkg/storage"
+
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+)
+
+// 同步 worker：从 Redis 队列消费同步任务，与 HTTP 服务分开部署和扩容
+// API 服务需设置 SYNC_MODE=queue 才会把同步投递到队列
+func main() {
+	log.Println("Starting FusionMail sync worker...")
+
+	// 加载 .env 文件（如果存在）
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables or defaults")
+	}
+
+	// 加载配置
+	cfg := config.Load()
+
+	// 初始化数据库连接
+	if err := database.Initialize(&cfg.Database); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	// 初始化 Redis 客户端（worker 必须连接 Redis）
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+	defer redisClient.Close()
+
+	if err := redisClient.Ping(context.Background()).Err(); err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	// 静态加密（worker 与 API 服务需使用同一主密钥和加密配置）
+	db := database.GetDB()
+	masterKey, err := crypto.NewEncryptor()
+	if err != nil {
+		log.Fatalf("Failed to create encryptor: %v", err)
+	}
+	keyring := crypto.NewKeyring(masterKey, repository.NewDataKeyRepository(db))
+
+	// 创建附件服务（worker 与 API 服务需使用同一存储）
+	storageProvider, err := storage.NewProvider(cfg.Storage.ProviderConfig())
+	if err != nil {
+		log.Fatalf("Failed to create storage provider: %v", err)
+	}
+	storageProvider = storage.NewEncryptedProvider(storageProvider, keyring, cfg.Security.EncryptAtRest)
+	attachmentService := service.NewAttachmentService(
+		repository.NewAttachmentRepository(db),
+		storageProvider,
+		service.AttachmentOptions{
+			StorageType:    cfg.Storage.Type,
+			DefaultMaxSize: int64(cfg.Storage.MaxAttachmentMB) << 20,
+		},
+	)
+
+	// worker 只发布事件，由 API 服务的事件服务订阅并触发规则和 Webhook
+	events := service.NewEventService(event.NewRedisBus(redisClient, logger.New()), nil, nil, logger.New())
+
+	// 创建同步服务
+	bodyCipher := crypto.NewFieldCipher(keyring, cfg.Security.EncryptAtRest)
+	emailRepo := repository.NewEmailRepository(db, bodyCipher)
+	syncService, err := service.NewSyncService(
+		repository.NewAccountRepository(db),
+		emailRepo,
+		repository.NewSyncLogRepository(db),
+		adapter.NewFactory(adapterOptions(&cfg.Demo)),
+		service.SchedulerOptions{FailureThreshold: cfg.Sync.FailureThreshold},
+		service.ExecutorOptions{Progress: service.NewSyncProgressTracker(redisClient)},
+		service.ReconcileOptions{
+			Interval: time.Duration(cfg.Sync.ReconcileIntervalMinutes) * time.Minute,
+			Policy:   cfg.Sync.SourceDeletePolicy,
+		},
+		events,
+		attachmentService,
+		service.NewThreadService(repository.NewThreadRepository(db, bodyCipher), emailRepo, events),
+		rawMessageArchive(&cfg.Storage, emailRepo, storageProvider),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create sync service: %v", err)
+	}
+
+	// 启动 worker
+	worker := service.NewSyncWorker(
+		syncService,
+		queue.NewRedisQueue(redisClient, service.SyncQueueName),
+		redisClient,
+		service.SyncWorkerOptions{
+			ID:      workerID(),
+			Workers: cfg.Sync.WorkerCount,
+		},
+	)
+	worker.Start(context.Background())
+
+	// 等待中断信号
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down sync worker...")
+	worker.Stop()
+	log.Println("Sync worker exited")
+}
+
+// workerID 生成 worker 标识（主机名 + 进程号）
+func workerID() string {
+	if id := os.Getenv("WORKER_ID"); id != "" {
+		return id
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// adapterOptions 根据配置构建邮箱适配器工厂选项
+func adapterOptions(cfg *config.DemoConfig) adapter.FactoryOptions {
+	return adapter.FactoryOptions{
+		Demo: adapter.DemoOptions{
+			Interval: cfg.Interval,
+			History:  cfg.History,
+			Seed:     cfg.Seed,
+		},
+	}
+}
+
+// rawMessageArchive 创建原始邮件归档服务，未启用归档时返回 nil
+func rawMessageArchive(cfg *config.StorageConfig, emailRepo repository.EmailRepository, storageProvider storage.Provider) *service.RawMessageService {
+	if !cfg.ArchiveRaw {
+		return nil
+	}
+	return service.NewRawMessageService(emailRepo, storageProvider)
+}
@@ -3,16 +3,24 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
+
+	"fusionmail/pkg/storage"
 )
 
 // Config 应用配置
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Security SecurityConfig
-	Storage  StorageConfig
+	Database  DatabaseConfig
+	Server    ServerConfig
+	Redis     RedisConfig
+	JWT       JWTConfig
+	Security  SecurityConfig
+	Storage   StorageConfig
+	Sync      SyncConfig
+	Proxy     ImageProxyConfig
+	Retention RetentionConfig
+	Demo      DemoConfig
 }
 
 // DatabaseConfig 数据库配置
@@ -27,8 +35,9 @@ type DatabaseConfig struct {
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Host string
-	Port string
+	Host      string
+	Port      string
+	PublicURL string // 服务对外地址（如 https://mail.example.com），用于生成签名下载链接，为空时生成相对地址
 }
 
 // RedisConfig Redis 配置
@@ -49,6 +58,10 @@ type JWTConfig struct {
 type SecurityConfig struct {
 	EncryptionKey  string
 	MasterPassword string // 主密码（用于初始登录）
+	EncryptAtRest  bool   // 静态加密：新写入的邮件正文和附件文件使用信封加密保存
+
+	DownloadLinkSecret        string // 附件签名下载链接的 HMAC 密钥，为空时使用 JWT 密钥
+	DownloadLinkExpiryMinutes int    // 签名下载链接默认有效期（分钟）
 }
 
 // StorageConfig 存储配置
@@ -56,6 +69,65 @@ type StorageConfig struct {
 	Type      string // local, s3, oss
 	LocalPath string // 本地存储路径
 	BaseURL   string // 基础 URL
+
+	// S3 兼容存储（AWS S3、MinIO 等），Type 为 s3 时使用
+	S3Endpoint         string // 自定义服务地址，为空时使用 AWS 区域地址
+	S3Region           string
+	S3Bucket           string
+	S3AccessKey        string
+	S3SecretKey        string
+	S3PathStyle        bool // 使用路径风格地址，MinIO 需要启用
+	S3PartSizeMB       int  // 分片上传每片大小（MB），不小于 5
+	S3URLExpirySeconds int  // 预签名下载 URL 有效期（秒）
+
+	MaxAttachmentMB int  // 单个附件大小上限（MB），超过的附件只保存元数据；账户可单独设置
+	ArchiveRaw      bool // 同步时归档邮件原文（gzip 压缩后保存到存储提供者）
+}
+
+// ImageProxyConfig 外部图片代理配置
+type ImageProxyConfig struct {
+	Enabled        bool // 启用图片代理；关闭时邮件中的外部图片始终被阻止
+	MaxSizeMB      int  // 单张图片大小上限（MB）
+	TimeoutSeconds int  // 获取外部图片的超时时间（秒）
+	CacheHours     int  // 图片在存储提供者中的缓存时间（小时），0 表示不缓存
+}
+
+// RetentionConfig 保留策略配置（天数为 0 表示不清理；邮件相关的策略账户可单独覆盖）
+type RetentionConfig struct {
+	IntervalMinutes int  // 定期清理间隔（分钟），0 表示不定期清理
+	DryRun          bool // 定期清理只记录将要清理的数量，不删除任何数据
+
+	TrashDays      int // 回收站邮件保留天数，超过后永久删除
+	ArchiveDays    int // 归档邮件保留天数，超过后移入回收站
+	AttachmentDays int // 大附件内容保留天数，超过后只保留元数据
+	AttachmentMB   int // 大附件阈值（MB）
+	SyncLogDays    int // 同步日志保留天数
+	WebhookLogDays int // Webhook 日志保留天数
+}
+
+// DemoConfig 演示邮箱配置
+type DemoConfig struct {
+	Interval time.Duration // 两封邮件之间的间隔
+	History  time.Duration // 未指定起始时间时回溯的时间窗口
+	Seed     string        // 随机种子，与邮箱地址一起决定邮件内容
+}
+
+// SyncConfig 同步调度配置
+type SyncConfig struct {
+	QuietHours    string // 静默时段，如 "23:00-07:00"，为空表示不启用
+	Timezone      string // 静默时段所在时区，如 "Asia/Shanghai"
+	JitterSeconds int    // 调度随机抖动上限（秒）
+	WorkerCount   int    // 最大并发同步账户数
+	Mode          string // 同步执行方式：local（API 进程内执行）、queue（投递到 Redis 队列由 worker 执行）
+
+	MaxBackoffMinutes int // 连续失败时退避间隔上限（分钟）
+	FailureThreshold  int // 连续失败多少次后自动隔离账户
+
+	BackfillPageSize    int // 历史邮件回填每页邮件数
+	BackfillPageDelayMs int // 历史邮件回填两页之间的间隔（毫秒），避免触发服务商限流
+
+	ReconcileIntervalMinutes int    // 源邮箱对账间隔（分钟），0 表示不对账
+	SourceDeletePolicy       string // 源邮箱已删除邮件的本地处理策略：keep/archive/delete
 }
 
 // Load 加载配置
@@ -72,6 +144,8 @@ func Load() *Config {
 		Server: ServerConfig{
 			Host: getEnv("SERVER_HOST", "0.0.0.0"),
 			Port: getEnv("SERVER_PORT", "3333"),
+
+			PublicURL: getEnv("SERVER_PUBLIC_URL", ""),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -86,11 +160,65 @@ func Load() *Config {
 		Security: SecurityConfig{
 			EncryptionKey:  getEnv("ENCRYPTION_KEY", "fusionmail-default-key-32-bytes"),
 			MasterPassword: getEnv("MASTER_PASSWORD", "admin123"),
+			EncryptAtRest:  getEnvBool("ENCRYPTION_AT_REST", false),
+
+			DownloadLinkSecret:        getEnv("DOWNLOAD_LINK_SECRET", ""),
+			DownloadLinkExpiryMinutes: getEnvInt("DOWNLOAD_LINK_EXPIRY_MINUTES", 60),
 		},
 		Storage: StorageConfig{
 			Type:      getEnv("STORAGE_TYPE", "local"),
 			LocalPath: getEnv("STORAGE_LOCAL_PATH", "./data/attachments"),
 			BaseURL:   getEnv("STORAGE_BASE_URL", ""),
+
+			S3Endpoint:         getEnv("STORAGE_S3_ENDPOINT", ""),
+			S3Region:           getEnv("STORAGE_S3_REGION", "us-east-1"),
+			S3Bucket:           getEnv("STORAGE_S3_BUCKET", ""),
+			S3AccessKey:        getEnv("STORAGE_S3_ACCESS_KEY", ""),
+			S3SecretKey:        getEnv("STORAGE_S3_SECRET_KEY", ""),
+			S3PathStyle:        getEnvBool("STORAGE_S3_PATH_STYLE", false),
+			S3PartSizeMB:       getEnvInt("STORAGE_S3_PART_SIZE_MB", 8),
+			S3URLExpirySeconds: getEnvInt("STORAGE_S3_URL_EXPIRY_SECONDS", 900),
+
+			MaxAttachmentMB: getEnvInt("STORAGE_MAX_ATTACHMENT_MB", 25),
+			ArchiveRaw:      getEnvBool("STORAGE_ARCHIVE_RAW", true),
+		},
+		Sync: SyncConfig{
+			QuietHours:    getEnv("SYNC_QUIET_HOURS", ""),
+			Timezone:      getEnv("SYNC_TIMEZONE", ""),
+			JitterSeconds: getEnvInt("SYNC_JITTER_SECONDS", 30),
+			WorkerCount:   getEnvInt("SYNC_WORKER_COUNT", 5),
+			Mode:          getEnv("SYNC_MODE", "local"),
+
+			MaxBackoffMinutes: getEnvInt("SYNC_MAX_BACKOFF_MINUTES", 360),
+			FailureThreshold:  getEnvInt("SYNC_FAILURE_THRESHOLD", 5),
+
+			BackfillPageSize:    getEnvInt("SYNC_BACKFILL_PAGE_SIZE", 100),
+			BackfillPageDelayMs: getEnvInt("SYNC_BACKFILL_PAGE_DELAY_MS", 2000),
+
+			ReconcileIntervalMinutes: getEnvInt("SYNC_RECONCILE_INTERVAL_MINUTES", 360),
+			SourceDeletePolicy:       getEnv("SYNC_SOURCE_DELETE_POLICY", "keep"),
+		},
+		Proxy: ImageProxyConfig{
+			Enabled:        getEnvBool("IMAGE_PROXY_ENABLED", true),
+			MaxSizeMB:      getEnvInt("IMAGE_PROXY_MAX_SIZE_MB", 5),
+			TimeoutSeconds: getEnvInt("IMAGE_PROXY_TIMEOUT_SECONDS", 10),
+			CacheHours:     getEnvInt("IMAGE_PROXY_CACHE_HOURS", 168),
+		},
+		Retention: RetentionConfig{
+			IntervalMinutes: getEnvInt("RETENTION_INTERVAL_MINUTES", 0),
+			DryRun:          getEnvBool("RETENTION_DRY_RUN", false),
+
+			TrashDays:      getEnvInt("RETENTION_TRASH_DAYS", 0),
+			ArchiveDays:    getEnvInt("RETENTION_ARCHIVE_DAYS", 0),
+			AttachmentDays: getEnvInt("RETENTION_ATTACHMENT_DAYS", 0),
+			AttachmentMB:   getEnvInt("RETENTION_ATTACHMENT_MB", 0),
+			SyncLogDays:    getEnvInt("RETENTION_SYNC_LOG_DAYS", 90),
+			WebhookLogDays: getEnvInt("RETENTION_WEBHOOK_LOG_DAYS", 30),
+		},
+		Demo: DemoConfig{
+			Interval: getEnvDuration("DEMO_MAIL_INTERVAL", 10*time.Minute),
+			History:  getEnvDuration("DEMO_MAIL_HISTORY", 7*24*time.Hour),
+			Seed:     getEnv("DEMO_MAIL_SEED", ""),
 		},
 	}
 }
@@ -103,6 +231,24 @@ func (c *DatabaseConfig) GetDSN() string {
 	)
 }
 
+// ProviderConfig 构建存储提供者配置
+func (c *StorageConfig) ProviderConfig() *storage.Config {
+	return &storage.Config{
+		Type:      c.Type,
+		LocalPath: c.LocalPath,
+		BaseURL:   c.BaseURL,
+
+		S3Endpoint:  c.S3Endpoint,
+		S3Region:    c.S3Region,
+		S3Bucket:    c.S3Bucket,
+		S3AccessKey: c.S3AccessKey,
+		S3SecretKey: c.S3SecretKey,
+		S3PathStyle: c.S3PathStyle,
+		S3PartSize:  int64(c.S3PartSizeMB) << 20,
+		S3URLExpiry: time.Duration(c.S3URLExpirySeconds) * time.Second,
+	}
+}
+
 // getEnv 获取环境变量，如果不存在则返回默认值
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -111,6 +257,16 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvBool 获取布尔类型的环境变量（true/false、1/0 等）
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 // getEnvInt 获取整数类型的环境变量
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -121,3 +277,13 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvDuration 获取时长类型的环境变量（Go duration 格式，如 "2m"、"168h"），无效或不为正时返回默认值
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultValue
+}
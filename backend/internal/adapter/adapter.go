@@ -33,6 +33,35 @@ type MailProvider interface {
 	TestConnection(ctx context.Context) error
 }
 
+// HistoryFetcher 历史邮件分页拉取接口（可选）
+// 支持该接口的适配器可以从最新邮件开始向更早的邮件逐页回溯，用于导入首次同步之外的历史邮件
+type HistoryFetcher interface {
+	// FetchHistory 拉取一页历史邮件
+	// cursor: 上一页返回的游标，空字符串表示从最新的邮件开始
+	// limit: 每页最大数量
+	FetchHistory(ctx context.Context, cursor string, limit int) (*HistoryPage, error)
+}
+
+// HistoryPage 历史邮件分页结果
+type HistoryPage struct {
+	Emails     []*Email // 本页邮件
+	NextCursor string   // 下一页游标，为空表示已到达最早的邮件
+	Total      int      // 邮箱中的邮件总数估计（0 表示未知）
+}
+
+// SourceLister 源邮箱邮件清单接口（可选）
+// 支持该接口的适配器可以列出服务器上当前存在的全部邮件 ID，用于发现已在源邮箱中删除或移动的邮件
+type SourceLister interface {
+	// ListSourceFolders 按文件夹列出服务器上当前存在的邮件 ID（只包含适配器同步范围内的文件夹）
+	ListSourceFolders(ctx context.Context) ([]SourceFolder, error)
+}
+
+// SourceFolder 源邮箱文件夹中的邮件 ID 清单
+type SourceFolder struct {
+	Name        string   // 文件夹名称（与 Email.SourceFolder 一致）
+	ProviderIDs []string // 文件夹中全部邮件的服务商 ID
+}
+
 // Email 邮件数据结构
 type Email struct {
 	// 基本信息
@@ -70,6 +99,9 @@ type Email struct {
 	ThreadID   string // 会话 ID
 	InReplyTo  string // 回复的邮件 ID
 	References string // 引用的邮件 ID 列表
+
+	// 原始邮件
+	RawMessage []byte // RFC 822 原文（适配器能取得时填写，同步时归档以便查看完整头部和重新解析）
 }
 
 // Attachment 附件数据结构
@@ -117,8 +149,8 @@ type ProxyConfig struct {
 
 // Config 适配器配置
 type Config struct {
-	Provider    string        // 提供商类型：gmail/outlook/imap/pop3
-	Protocol    string        // 协议类型：gmail_api/graph/imap/pop3
+	Provider    string        // 提供商类型：gmail/outlook/imap/pop3/demo
+	Protocol    string        // 协议类型：gmail_api/graph/imap/pop3/demo
 	Credentials *Credentials  // 认证凭证
 	Proxy       *ProxyConfig  // 代理配置（可选）
 	Timeout     time.Duration // 超时时间
@@ -0,0 +1,605 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// demoEpoch 演示邮件流的起点，第 n 封邮件的发送时间为 demoEpoch + n*Interval
+var demoEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	demoDefaultInterval = 10 * time.Minute   // 默认每 10 分钟一封
+	demoDefaultHistory  = 7 * 24 * time.Hour // 未指定起始时间时回溯 7 天
+	demoThreadWindow    = 12                 // 同一会话窗口内的邮件数
+	demoProviderPrefix  = "demo-"            // ProviderID 前缀
+	demoDomain          = "demo.fusionmail.local"
+)
+
+// 演示邮件类型
+const (
+	demoKindNewsletter   = "newsletter"
+	demoKindOTP          = "otp"
+	demoKindInvoice      = "invoice"
+	demoKindConversation = "conversation"
+	demoKindLocalized    = "localized"
+)
+
+// DemoOptions 演示邮箱配置（由 DEMO_MAIL_* 环境变量配置），零值字段使用默认配置
+type DemoOptions struct {
+	Interval time.Duration // 两封邮件之间的间隔（即生成速率）
+	History  time.Duration // 未指定起始时间时回溯的时间窗口
+	Seed     string        // 随机种子，与邮箱地址一起决定邮件内容
+}
+
+// DemoAdapter 演示邮箱适配器
+// 不访问任何网络，按固定速率生成可复现的模拟邮件流（订阅邮件、验证码、
+// 带 PDF 附件的账单、会话往来、多种字符集），用于演示规则、Webhook 和搜索
+type DemoAdapter struct {
+	config    *Config
+	options   DemoOptions
+	seed      uint64
+	connected bool
+	now       func() time.Time
+}
+
+// NewDemoAdapter 创建演示邮箱适配器实例
+func NewDemoAdapter(config *Config, options DemoOptions) (*DemoAdapter, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+
+	if config.Credentials == nil {
+		return nil, fmt.Errorf("credentials is required")
+	}
+
+	return newDemoAdapter(config, options), nil
+}
+
+// newDemoAdapter 使用指定配置创建演示适配器
+func newDemoAdapter(config *Config, options DemoOptions) *DemoAdapter {
+	if options.Interval <= 0 {
+		options.Interval = demoDefaultInterval
+	}
+	if options.History <= 0 {
+		options.History = demoDefaultHistory
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(options.Seed))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.ToLower(config.Credentials.Email)))
+
+	return &DemoAdapter{
+		config:  config,
+		options: options,
+		seed:    h.Sum64(),
+		now:     time.Now,
+	}
+}
+
+// Connect 连接（演示模式无需网络）
+func (a *DemoAdapter) Connect(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	a.connected = true
+	return nil
+}
+
+// Disconnect 断开连接
+func (a *DemoAdapter) Disconnect() error {
+	a.connected = false
+	return nil
+}
+
+// FetchEmails 拉取邮件列表
+// 返回发送时间在 (since, now] 之间的邮件，超过 limit 时保留最新的 limit 封
+func (a *DemoAdapter) FetchEmails(ctx context.Context, since time.Time, limit int) ([]*Email, error) {
+	if !a.connected {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	last := a.indexAt(a.now())
+	if last < 0 {
+		return []*Email{}, nil
+	}
+
+	var first int64
+	if since.IsZero() {
+		first = last - int64(a.options.History/a.options.Interval) + 1
+	} else {
+		first = a.indexAt(since) + 1
+	}
+	if first < 0 {
+		first = 0
+	}
+	if limit > 0 && last-first+1 > int64(limit) {
+		first = last - int64(limit) + 1
+	}
+
+	emails := make([]*Email, 0, max(last-first+1, 0))
+	for n := first; n <= last; n++ {
+		if err := ctx.Err(); err != nil {
+			return emails, err
+		}
+
+		email, err := a.generate(n)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate demo email %d: %w", n, err)
+		}
+		emails = append(emails, email)
+	}
+
+	return emails, nil
+}
+
+// FetchHistory 从最新的邮件开始向前逐页拉取历史邮件，游标为下一封待拉取邮件的序号
+func (a *DemoAdapter) FetchHistory(ctx context.Context, cursor string, limit int) (*HistoryPage, error) {
+	if !a.connected {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	last := a.indexAt(a.now())
+	page := &HistoryPage{Emails: []*Email{}, Total: int(last + 1)}
+	if last < 0 {
+		return page, nil
+	}
+
+	next := last
+	if cursor != "" {
+		n, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || n < 0 || n > last {
+			return nil, fmt.Errorf("invalid history cursor: %s", cursor)
+		}
+		next = n
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	for n := next; n >= 0 && n > next-int64(limit); n-- {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		email, err := a.generate(n)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate demo email %d: %w", n, err)
+		}
+		page.Emails = append(page.Emails, email)
+	}
+
+	if rest := next - int64(limit); rest >= 0 {
+		page.NextCursor = strconv.FormatInt(rest, 10)
+	}
+	return page, nil
+}
+
+// FetchEmailDetail 获取邮件详情
+func (a *DemoAdapter) FetchEmailDetail(ctx context.Context, providerID string) (*Email, error) {
+	if !a.connected {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	n, err := strconv.ParseInt(strings.TrimPrefix(providerID, demoProviderPrefix), 10, 64)
+	if err != nil || !strings.HasPrefix(providerID, demoProviderPrefix) || n < 0 {
+		return nil, fmt.Errorf("invalid provider ID: %s", providerID)
+	}
+	if n > a.indexAt(a.now()) {
+		return nil, fmt.Errorf("email not found")
+	}
+
+	return a.generate(n)
+}
+
+// GetProviderType 获取提供商类型
+func (a *DemoAdapter) GetProviderType() string {
+	return a.config.Provider
+}
+
+// GetProtocol 获取协议类型
+func (a *DemoAdapter) GetProtocol() string {
+	return "demo"
+}
+
+// TestConnection 测试连接
+func (a *DemoAdapter) TestConnection(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// indexAt 返回 t 时刻之前（含）最后一封邮件的序号，t 早于起点时返回 -1
+func (a *DemoAdapter) indexAt(t time.Time) int64 {
+	if t.Before(demoEpoch) {
+		return -1
+	}
+	return int64(t.Sub(demoEpoch) / a.options.Interval)
+}
+
+// rng 返回第 n 封邮件专用的确定性随机数生成器
+func (a *DemoAdapter) rng(n int64, salt uint64) *rand.Rand {
+	return rand.New(rand.NewSource(int64(a.seed ^ uint64(n)*0x9E3779B97F4A7C15 ^ salt)))
+}
+
+// kindOf 返回第 n 封邮件的类型
+func (a *DemoAdapter) kindOf(n int64) string {
+	switch p := a.rng(n, 1).Intn(100); {
+	case p < 30:
+		return demoKindNewsletter
+	case p < 50:
+		return demoKindOTP
+	case p < 65:
+		return demoKindInvoice
+	case p < 90:
+		return demoKindConversation
+	default:
+		return demoKindLocalized
+	}
+}
+
+// messageID 返回第 n 封邮件的 Message-ID（不含尖括号）
+func (a *DemoAdapter) messageID(n int64) string {
+	return fmt.Sprintf("demo-%d.%x@%s", n, a.seed&0xffffffff, demoDomain)
+}
+
+// generate 生成并解析第 n 封邮件
+func (a *DemoAdapter) generate(n int64) (*Email, error) {
+	raw, meta := a.buildMessage(n)
+
+	email, err := ParseRawMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	email.ProviderID = fmt.Sprintf("%s%d", demoProviderPrefix, n)
+	email.ReceivedAt = meta.sentAt.Add(time.Duration(a.rng(n, 2).Intn(90)) * time.Second)
+	email.SizeBytes = int64(len(raw))
+	email.SourceFolder = "INBOX"
+	email.SourceLabels = meta.labels
+	read := meta.read
+	email.SourceIsRead = &read
+
+	return email, nil
+}
+
+// demoMeta 生成邮件时附带的元数据
+type demoMeta struct {
+	sentAt time.Time
+	labels []string
+	read   bool
+}
+
+// buildMessage 构建第 n 封邮件的 RFC 822 原文
+func (a *DemoAdapter) buildMessage(n int64) ([]byte, demoMeta) {
+	r := a.rng(n, 3)
+	meta := demoMeta{
+		sentAt: demoEpoch.Add(time.Duration(n) * a.options.Interval),
+		read:   r.Intn(100) < 30,
+	}
+
+	b := &demoBuilder{
+		base: fmt.Sprintf("demo-boundary-%d-%x", n, a.seed&0xffff),
+		seq:  new(int),
+	}
+	b.header("Message-ID", "<"+a.messageID(n)+">")
+	b.header("Date", meta.sentAt.Format(time.RFC1123Z))
+	b.header("To", a.config.Credentials.Email)
+	b.header("MIME-Version", "1.0")
+
+	switch a.kindOf(n) {
+	case demoKindNewsletter:
+		meta.labels = []string{"Newsletters"}
+		a.buildNewsletter(b, n, r)
+	case demoKindOTP:
+		meta.labels = []string{"Security"}
+		a.buildOTP(b, r)
+	case demoKindInvoice:
+		meta.labels = []string{"Finance"}
+		a.buildInvoice(b, n, r, meta.sentAt)
+	case demoKindConversation:
+		a.buildConversation(b, n)
+	default:
+		a.buildLocalized(b, r)
+	}
+
+	return b.bytes(), meta
+}
+
+// buildNewsletter 订阅邮件：纯文本 + HTML + 内联 Logo + 跟踪像素
+func (a *DemoAdapter) buildNewsletter(b *demoBuilder, n int64, r *rand.Rand) {
+	sender := demoNewsletters[r.Intn(len(demoNewsletters))]
+	issue := n/int64(len(demoNewsletters)) + 1
+	headline := demoHeadlines[r.Intn(len(demoHeadlines))]
+	cid := fmt.Sprintf("logo-%d@%s", n, demoDomain)
+
+	b.header("From", sender.from)
+	b.header("Subject", fmt.Sprintf("%s #%d: %s", sender.name, issue, headline))
+	b.header("List-Unsubscribe", fmt.Sprintf("<https://%s/unsubscribe?list=%s>", sender.domain, sender.list))
+	b.header("List-Id", fmt.Sprintf("<%s.%s>", sender.list, sender.domain))
+
+	text := fmt.Sprintf("%s — Issue #%d\r\n\r\n%s\r\n\r\nRead online: https://%s/issues/%d\r\n",
+		sender.name, issue, headline, sender.domain, issue)
+	html := fmt.Sprintf(`<html><body><img src="cid:%s" alt="%s"><h1>%s</h1>`+
+		`<p>Issue #%d of %s.</p><p><a href="https://%s/issues/%d">Read online</a></p>`+
+		`<img src="https://track.%s/open?u=%d" width="1" height="1" alt=""></body></html>`,
+		cid, sender.name, headline, issue, sender.name, sender.domain, issue, sender.domain, n)
+
+	b.multipart("multipart/alternative", func(alt *demoBuilder) {
+		alt.textPart("text/plain", "utf-8", []byte(text))
+		alt.multipart("multipart/related", func(rel *demoBuilder) {
+			rel.textPart("text/html", "utf-8", []byte(html))
+			rel.binaryPart("image/png", "logo.png", "inline", cid, demoLogoPNG)
+		})
+	})
+}
+
+// buildOTP 验证码邮件
+func (a *DemoAdapter) buildOTP(b *demoBuilder, r *rand.Rand) {
+	service := demoOTPServices[r.Intn(len(demoOTPServices))]
+	code := fmt.Sprintf("%06d", r.Intn(1000000))
+
+	b.header("From", fmt.Sprintf("%s <no-reply@%s>", service, strings.ToLower(service)+".example"))
+	b.header("Subject", fmt.Sprintf("Your %s verification code is %s", service, code))
+	b.singlePart("text/plain", "utf-8", []byte(fmt.Sprintf(
+		"Your %s verification code is %s.\r\n\r\nThis code expires in 10 minutes. If you did not request it, you can ignore this email.\r\n",
+		service, code)))
+}
+
+// buildInvoice 账单邮件：带 PDF 附件
+func (a *DemoAdapter) buildInvoice(b *demoBuilder, n int64, r *rand.Rand, sentAt time.Time) {
+	vendor := demoVendors[r.Intn(len(demoVendors))]
+	number := fmt.Sprintf("INV-%d-%06d", sentAt.Year(), n)
+	amount := fmt.Sprintf("%d.%02d", 5+r.Intn(495), r.Intn(100))
+
+	b.header("From", fmt.Sprintf("%s Billing <billing@%s>", vendor, strings.ToLower(vendor)+".example"))
+	b.header("Subject", fmt.Sprintf("Invoice %s from %s", number, vendor))
+
+	b.multipart("multipart/mixed", func(mixed *demoBuilder) {
+		mixed.textPart("text/plain", "utf-8", []byte(fmt.Sprintf(
+			"Hello,\r\n\r\nPlease find attached invoice %s for USD %s.\r\n\r\nThanks,\r\n%s\r\n",
+			number, amount, vendor)))
+		mixed.binaryPart("application/pdf", strings.ToLower(number)+".pdf", "attachment", "",
+			demoInvoicePDF(vendor, number, amount, sentAt))
+	})
+}
+
+// buildConversation 会话邮件：同一窗口内的邮件组成一个线程
+func (a *DemoAdapter) buildConversation(b *demoBuilder, n int64) {
+	window := n / demoThreadWindow
+	topic := demoTopics[a.rng(window, 4).Intn(len(demoTopics))]
+
+	// 找出同一窗口中更早的会话邮件作为引用链
+	var refs []string
+	for i := window * demoThreadWindow; i < n; i++ {
+		if a.kindOf(i) == demoKindConversation {
+			refs = append(refs, "<"+a.messageID(i)+">")
+		}
+	}
+
+	participant := demoColleagues[a.rng(n, 5).Intn(len(demoColleagues))]
+	b.header("From", participant)
+
+	subject := topic
+	if len(refs) > 0 {
+		subject = "Re: " + topic
+		b.header("In-Reply-To", refs[len(refs)-1])
+		b.header("References", strings.Join(refs, " "))
+	}
+	b.header("Subject", subject)
+
+	line := demoReplies[a.rng(n, 6).Intn(len(demoReplies))]
+	b.singlePart("text/plain", "utf-8", []byte(fmt.Sprintf("%s\r\n\r\n-- \r\n%s\r\n", line, participant)))
+}
+
+// buildLocalized 非 UTF-8 字符集邮件
+func (a *DemoAdapter) buildLocalized(b *demoBuilder, r *rand.Rand) {
+	l := demoLocalized[r.Intn(len(demoLocalized))]
+
+	subject, _ := l.encoding.NewEncoder().String(l.subject)
+	body, _ := l.encoding.NewEncoder().Bytes([]byte(l.body))
+
+	b.header("From", l.from)
+	b.header("Subject", mime.BEncoding.Encode(l.charset, subject))
+	b.singlePart("text/plain", l.charset, body)
+}
+
+// demoBuilder 简单的 MIME 原文构建器
+type demoBuilder struct {
+	buf      bytes.Buffer
+	base     string // 边界前缀
+	seq      *int   // 边界序号（同一封邮件内共享，保证边界互不为前缀）
+	boundary string // 当前容器的边界（顶层为空）
+}
+
+// header 写入一个头部字段
+func (b *demoBuilder) header(key, value string) {
+	fmt.Fprintf(&b.buf, "%s: %s\r\n", key, value)
+}
+
+// singlePart 写入单部分正文（作为整封邮件的正文）
+func (b *demoBuilder) singlePart(contentType, charset string, body []byte) {
+	b.header("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+	b.header("Content-Transfer-Encoding", "base64")
+	b.buf.WriteString("\r\n")
+	writeBase64Lines(&b.buf, body)
+}
+
+// multipart 写入 multipart 容器
+func (b *demoBuilder) multipart(contentType string, fill func(*demoBuilder)) {
+	if b.boundary != "" {
+		// 嵌套的 multipart 本身也是父容器中的一个子部分
+		b.startPart()
+	}
+	*b.seq++
+	boundary := fmt.Sprintf("%s.%d", b.base, *b.seq)
+	b.header("Content-Type", fmt.Sprintf("%s; boundary=\"%s\"", contentType, boundary))
+	b.buf.WriteString("\r\n")
+
+	child := &demoBuilder{base: b.base, seq: b.seq, boundary: boundary}
+	fill(child)
+
+	b.buf.Write(child.buf.Bytes())
+	fmt.Fprintf(&b.buf, "--%s--\r\n", boundary)
+}
+
+// startPart 开始 multipart 中的一个子部分
+func (b *demoBuilder) startPart() {
+	fmt.Fprintf(&b.buf, "--%s\r\n", b.boundary)
+}
+
+// textPart 写入文本子部分
+func (b *demoBuilder) textPart(contentType, charset string, body []byte) {
+	b.startPart()
+	b.singlePart(contentType, charset, body)
+}
+
+// binaryPart 写入二进制子部分（附件或内联资源）
+func (b *demoBuilder) binaryPart(contentType, filename, disposition, contentID string, body []byte) {
+	b.startPart()
+	b.header("Content-Type", fmt.Sprintf("%s; name=\"%s\"", contentType, filename))
+	b.header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"", disposition, filename))
+	if contentID != "" {
+		b.header("Content-ID", "<"+contentID+">")
+	}
+	b.header("Content-Transfer-Encoding", "base64")
+	b.buf.WriteString("\r\n")
+	writeBase64Lines(&b.buf, body)
+}
+
+// bytes 返回构建结果
+func (b *demoBuilder) bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// writeBase64Lines 按 76 字符换行写入 base64 内容
+func writeBase64Lines(w *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.WriteString(encoded[:76])
+		w.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	w.WriteString(encoded)
+	w.WriteString("\r\n")
+}
+
+// demoInvoicePDF 生成一页的最小 PDF 账单
+func demoInvoicePDF(vendor, number, amount string, date time.Time) []byte {
+	stream := fmt.Sprintf("BT /F1 20 Tf 72 770 Td (%s) Tj 0 -30 Td /F1 12 Tf (Invoice %s) Tj "+
+		"0 -18 Td (Date: %s) Tj 0 -18 Td (Amount due: USD %s) Tj ET",
+		vendor, number, date.Format("2006-01-02"), amount)
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+// demoLogoPNG 1x1 像素的 PNG 图片（用作订阅邮件的内联 Logo）
+var demoLogoPNG, _ = base64.StdEncoding.DecodeString(
+	"iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg==")
+
+// 演示数据
+
+type demoNewsletter struct {
+	name   string
+	from   string
+	domain string
+	list   string
+}
+
+var demoNewsletters = []demoNewsletter{
+	{"Go Weekly", "Go Weekly <newsletter@goweekly.example>", "goweekly.example", "go-weekly"},
+	{"Frontend Digest", "Frontend Digest <digest@frontend.example>", "frontend.example", "digest"},
+	{"Cloud Native News", "Cloud Native News <news@cloudnative.example>", "cloudnative.example", "cn-news"},
+	{"Product Hunt Daily", "Product Hunt Daily <hello@producthunt.example>", "producthunt.example", "daily"},
+}
+
+var demoHeadlines = []string{
+	"Generics in practice",
+	"What's new in the latest release",
+	"Scaling Postgres to a billion rows",
+	"A tour of structured logging",
+	"Ten tools we can't live without",
+	"Designing resilient webhooks",
+}
+
+var demoOTPServices = []string{"GitHub", "Stripe", "Slack", "Notion", "Figma"}
+
+var demoVendors = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli"}
+
+var demoTopics = []string{
+	"Q3 roadmap review",
+	"Deploy freeze next week",
+	"Customer escalation: slow sync",
+	"Team offsite planning",
+	"Design review for the new inbox",
+}
+
+var demoColleagues = []string{
+	"Alice Chen <alice@team.example>",
+	"Bob Martin <bob@team.example>",
+	"Carol Diaz <carol@team.example>",
+	"Dan Wu <dan@team.example>",
+}
+
+var demoReplies = []string{
+	"Sounds good to me, let's go ahead.",
+	"Can we push this to Thursday? I have a conflict.",
+	"I've attached my notes to the shared doc.",
+	"+1, agreed with the proposal above.",
+	"Let me double check with the on-call team and get back to you.",
+	"Thanks everyone, closing this out.",
+}
+
+type demoLocalizedMessage struct {
+	charset  string
+	encoding encoding.Encoding
+	from     string
+	subject  string
+	body     string
+}
+
+var demoLocalized = []demoLocalizedMessage{
+	{"gb18030", simplifiedchinese.GB18030, "张伟 <zhangwei@example.cn>",
+		"会议纪要：下周产品发布安排", "大家好，\r\n\r\n附上本周会议纪要，请在周五前确认各自的任务。\r\n\r\n谢谢！\r\n"},
+	{"iso-2022-jp", japanese.ISO2022JP, "佐藤 <sato@example.jp>",
+		"お見積もりのご送付", "いつもお世話になっております。\r\nお見積もりをお送りいたします。ご確認のほどよろしくお願いいたします。\r\n"},
+	{"koi8-r", charmap.KOI8R, "Иван Петров <ivan@example.ru>",
+		"Отчёт за неделю", "Коллеги, добрый день!\r\n\r\nОтправляю еженедельный отчёт.\r\n"},
+	{"iso-8859-1", charmap.ISO8859_1, "Jürgen Müller <juergen@example.de>",
+		"Grüße aus München", "Hallo zusammen,\r\n\r\nanbei die Unterlagen für das Treffen am Dienstag.\r\n\r\nSchöne Grüße\r\n"},
+}
@@ -0,0 +1,191 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDemoAdapter 创建固定时钟的演示适配器
+func newTestDemoAdapter(t *testing.T, email string, now time.Time) *DemoAdapter {
+	t.Helper()
+
+	a := newDemoAdapter(&Config{
+		Provider:    "demo",
+		Protocol:    "demo",
+		Credentials: &Credentials{Email: email},
+	}, DemoOptions{Interval: 5 * time.Minute, History: 24 * time.Hour, Seed: "test"})
+	a.now = func() time.Time { return now }
+
+	if err := a.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return a
+}
+
+// TestDemoAdapterDeterministic 测试相同配置生成相同邮件
+func TestDemoAdapterDeterministic(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := newTestDemoAdapter(t, "demo@example.com", now)
+	b := newTestDemoAdapter(t, "demo@example.com", now)
+
+	first, err := a.FetchEmails(context.Background(), time.Time{}, 50)
+	if err != nil {
+		t.Fatalf("FetchEmails() error = %v", err)
+	}
+	second, err := b.FetchEmails(context.Background(), time.Time{}, 50)
+	if err != nil {
+		t.Fatalf("FetchEmails() error = %v", err)
+	}
+
+	if len(first) != 50 || len(second) != 50 {
+		t.Fatalf("expected 50 emails, got %d and %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i].ProviderID != second[i].ProviderID ||
+			first[i].Subject != second[i].Subject ||
+			first[i].TextBody != second[i].TextBody {
+			t.Fatalf("email %d differs between runs: %q vs %q", i, first[i].Subject, second[i].Subject)
+		}
+	}
+
+	// 不同邮箱地址生成不同的内容
+	other := newTestDemoAdapter(t, "other@example.com", now)
+	third, _ := other.FetchEmails(context.Background(), time.Time{}, 50)
+	same := true
+	for i := range first {
+		if first[i].Subject != third[i].Subject {
+			same = false
+			break
+		}
+	}
+	if same {
+		t.Error("expected different mailboxes to produce different streams")
+	}
+}
+
+// TestDemoAdapterIncremental 测试按时间增量拉取
+func TestDemoAdapterIncremental(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := newTestDemoAdapter(t, "demo@example.com", now)
+
+	emails, err := a.FetchEmails(context.Background(), now.Add(-time.Hour), 0)
+	if err != nil {
+		t.Fatalf("FetchEmails() error = %v", err)
+	}
+	if len(emails) != 12 {
+		t.Fatalf("expected 12 emails in the last hour at 5m interval, got %d", len(emails))
+	}
+	for _, email := range emails {
+		if !email.SentAt.After(now.Add(-time.Hour)) || email.SentAt.After(now) {
+			t.Errorf("email %s sent at %s is outside the requested window", email.ProviderID, email.SentAt)
+		}
+	}
+
+	// 未指定起始时间时使用回溯窗口
+	all, _ := a.FetchEmails(context.Background(), time.Time{}, 0)
+	if len(all) != 24*12 {
+		t.Errorf("expected %d emails in history window, got %d", 24*12, len(all))
+	}
+
+	detail, err := a.FetchEmailDetail(context.Background(), emails[0].ProviderID)
+	if err != nil {
+		t.Fatalf("FetchEmailDetail() error = %v", err)
+	}
+	if detail.Subject != emails[0].Subject {
+		t.Errorf("FetchEmailDetail() subject = %q, want %q", detail.Subject, emails[0].Subject)
+	}
+}
+
+// TestDemoAdapterHistory 测试历史邮件分页回溯直到最早的邮件
+func TestDemoAdapterHistory(t *testing.T) {
+	now := demoEpoch.Add(25 * 5 * time.Minute)
+	a := newTestDemoAdapter(t, "demo@example.com", now)
+
+	var (
+		cursor string
+		seen   = make(map[string]bool)
+		pages  int
+	)
+	for {
+		page, err := a.FetchHistory(context.Background(), cursor, 10)
+		if err != nil {
+			t.Fatalf("FetchHistory(%q) error = %v", cursor, err)
+		}
+		if page.Total != 26 {
+			t.Errorf("FetchHistory() total = %d, want 26", page.Total)
+		}
+		for i, email := range page.Emails {
+			if seen[email.ProviderID] {
+				t.Errorf("email %s returned twice", email.ProviderID)
+			}
+			seen[email.ProviderID] = true
+			if i > 0 && email.SentAt.After(page.Emails[i-1].SentAt) {
+				t.Errorf("page is not ordered newest first")
+			}
+		}
+		pages++
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if pages != 3 || len(seen) != 26 {
+		t.Errorf("expected 26 emails in 3 pages, got %d in %d", len(seen), pages)
+	}
+	if _, err := a.FetchHistory(context.Background(), "abc", 10); err == nil {
+		t.Error("expected error for invalid cursor")
+	}
+}
+
+// TestDemoAdapterContent 测试生成内容覆盖各类邮件
+func TestDemoAdapterContent(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	a := newTestDemoAdapter(t, "demo@example.com", now)
+
+	emails, err := a.FetchEmails(context.Background(), time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("FetchEmails() error = %v", err)
+	}
+
+	var pdf, inline, threaded, otp, localized bool
+	for _, email := range emails {
+		for _, att := range email.Attachments {
+			if att.ContentType == "application/pdf" && bytes.HasPrefix(att.Content, []byte("%PDF-")) {
+				pdf = true
+			}
+			if att.IsInline && att.ContentID != "" && strings.Contains(email.HTMLBody, "cid:"+att.ContentID) {
+				inline = true
+			}
+		}
+		if email.InReplyTo != "" && strings.HasPrefix(email.Subject, "Re: ") {
+			threaded = true
+		}
+		if strings.Contains(email.Subject, "verification code") {
+			otp = true
+		}
+		if strings.Contains(email.Subject, "会议纪要") || strings.Contains(email.Subject, "お見積もり") ||
+			strings.Contains(email.Subject, "Отчёт") || strings.Contains(email.Subject, "Grüße") {
+			if !strings.ContainsAny(email.TextBody, "谢いКü") {
+				t.Errorf("localized body was not decoded: %q", email.TextBody)
+			}
+			localized = true
+		}
+	}
+
+	checks := map[string]bool{
+		"invoice with PDF":      pdf,
+		"inline cid image":      inline,
+		"threaded conversation": threaded,
+		"OTP code":              otp,
+		"non UTF-8 charset":     localized,
+	}
+	for name, ok := range checks {
+		if !ok {
+			t.Errorf("expected at least one %s in the demo stream", name)
+		}
+	}
+}
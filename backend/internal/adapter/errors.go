@@ -0,0 +1,146 @@
+package adapter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+// ErrorKind 适配器错误分类
+// 同步服务根据分类决定退避策略和账户状态
+type ErrorKind string
+
+const (
+	ErrorKindAuth    ErrorKind = "auth"    // 认证失败（密码错误、令牌过期）
+	ErrorKindNetwork ErrorKind = "network" // 网络错误（连接失败、超时）
+	ErrorKindTLS     ErrorKind = "tls"     // TLS 错误（证书无效、握手失败）
+	ErrorKindQuota   ErrorKind = "quota"   // 配额或频率限制
+	ErrorKindParse   ErrorKind = "parse"   // 邮件或响应解析失败
+	ErrorKindUnknown ErrorKind = "unknown" // 未分类错误
+)
+
+// Error 带分类的适配器错误
+type Error struct {
+	Kind ErrorKind // 错误分类
+	Op   string    // 出错的操作，如 "connect"、"login"、"fetch"
+	Err  error     // 原始错误
+}
+
+// NewError 创建带分类的适配器错误
+func NewError(kind ErrorKind, op string, err error) *Error {
+	return &Error{Kind: kind, Op: op, Err: err}
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Kind, e.Op, e.Err)
+}
+
+// Unwrap 返回原始错误
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify 获取错误分类
+// 优先使用适配器返回的 *Error，否则根据错误类型和错误信息推断
+func Classify(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+
+	var adapterErr *Error
+	if errors.As(err, &adapterErr) {
+		return adapterErr.Kind
+	}
+
+	// Gmail API 错误
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		if kind := classifyHTTPStatus(apiErr.Code); kind != "" {
+			return kind
+		}
+	}
+
+	// TLS 与证书错误需要在网络错误之前判断（握手错误也可能实现 net.Error）
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) {
+		return ErrorKindTLS
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindNetwork
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case containsAny(message, "authenticationfailed", "authentication failed", "invalid credentials",
+		"login failed", "auth failed", "invalid_grant", "unauthorized", "-err [auth]"):
+		return ErrorKindAuth
+	case containsAny(message, "tls", "x509", "certificate"):
+		return ErrorKindTLS
+	case containsAny(message, "quota", "overquota", "rate limit", "too many", "throttl"):
+		return ErrorKindQuota
+	case containsAny(message, "connection refused", "connection reset", "no such host", "i/o timeout",
+		"broken pipe", "eof", "network is unreachable"):
+		return ErrorKindNetwork
+	case containsAny(message, "failed to parse", "malformed", "unmarshal", "decode"):
+		return ErrorKindParse
+	}
+
+	return ErrorKindUnknown
+}
+
+// classifyHTTPStatus 根据 HTTP 状态码分类（Gmail API、Graph API）
+func classifyHTTPStatus(code int) ErrorKind {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrorKindAuth
+	case code == http.StatusTooManyRequests:
+		return ErrorKindQuota
+	case code >= http.StatusInternalServerError:
+		return ErrorKindNetwork
+	default:
+		return ""
+	}
+}
+
+// statusError 根据 HTTP 状态码创建带分类的错误
+func statusError(op string, code int, body string) error {
+	kind := classifyHTTPStatus(code)
+	if kind == "" {
+		kind = ErrorKindUnknown
+	}
+	return NewError(kind, op, fmt.Errorf("API returned status %d: %s", code, body))
+}
+
+// connectError 包装连接阶段的错误，无法识别的错误按网络错误处理
+func connectError(op string, err error) error {
+	kind := Classify(err)
+	if kind == ErrorKindUnknown {
+		kind = ErrorKindNetwork
+	}
+	return NewError(kind, op, err)
+}
+
+// containsAny 判断字符串是否包含任一子串
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package adapter
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+// TestClassify 测试错误分类
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"nil", nil, ""},
+		{"typed error", fmt.Errorf("failed to connect: %w", NewError(ErrorKindAuth, "login", errors.New("NO [AUTHENTICATIONFAILED]"))), ErrorKindAuth},
+		{"dial error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrorKindNetwork},
+		{"deadline", fmt.Errorf("fetch: %w", context.DeadlineExceeded), ErrorKindNetwork},
+		{"certificate", fmt.Errorf("connect: %w", x509.UnknownAuthorityError{}), ErrorKindTLS},
+		{"gmail 401", &googleapi.Error{Code: 401}, ErrorKindAuth},
+		{"gmail 429", &googleapi.Error{Code: 429}, ErrorKindQuota},
+		{"quota message", errors.New("NO [OVERQUOTA] mailbox is full"), ErrorKindQuota},
+		{"parse message", errors.New("failed to parse message: malformed header"), ErrorKindParse},
+		{"unknown", errors.New("something odd"), ErrorKindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Classify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,12 +4,19 @@ import (
 	"fmt"
 )
 
+// FactoryOptions 适配器工厂配置
+type FactoryOptions struct {
+	Demo DemoOptions // 演示邮箱配置
+}
+
 // Factory 适配器工厂
-type Factory struct{}
+type Factory struct {
+	options FactoryOptions
+}
 
-// NewFactory 创建适配器工厂实例
-func NewFactory() *Factory {
-	return &Factory{}
+// NewFactory 创建适配器工厂实例，options 零值字段使用默认配置
+func NewFactory(options FactoryOptions) *Factory {
+	return &Factory{options: options}
 }
 
 // CreateProvider 创建邮箱服务提供商适配器
@@ -32,6 +39,8 @@ func (f *Factory) CreateProvider(config *Config) (MailProvider, error) {
 		return NewGmailAdapter(config)
 	case "graph":
 		return NewGraphAdapter(config)
+	case "demo":
+		return NewDemoAdapter(config, f.options.Demo)
 	default:
 		return nil, fmt.Errorf("unsupported protocol: %s", config.Protocol)
 	}
@@ -58,6 +67,7 @@ func (f *Factory) GetSupportedProtocols() []string {
 		"pop3",
 		"gmail_api",
 		"graph",
+		"demo",
 	}
 }
 
@@ -70,6 +80,7 @@ func (f *Factory) GetSupportedProviders() []string {
 		"qq",
 		"163",
 		"generic", // 通用 IMAP/POP3
+		"demo",    // 演示邮箱（无需网络）
 	}
 }
 
@@ -83,6 +94,8 @@ func (f *Factory) GetRecommendedProtocol(provider string) string {
 		return "graph" // Outlook 优先使用 Graph API
 	case "icloud", "qq", "163":
 		return "imap" // 其他提供商使用 IMAP
+	case "demo":
+		return "demo" // 演示邮箱只支持 demo 协议
 	default:
 		return "imap" // 默认使用 IMAP
 	}
@@ -161,6 +174,14 @@ func (f *Factory) GetProviderInfo(provider string) *ProviderInfo {
 			RequiresOAuth:       false,
 			// 通用邮箱不预设服务器地址，由用户配置
 		},
+		"demo": {
+			Name:                "demo",
+			DisplayName:         "演示邮箱（模拟数据）",
+			SupportedProtocols:  []string{"demo"},
+			RecommendedProtocol: "demo",
+			RequiresOAuth:       false,
+			// 演示邮箱不连接任何服务器，邮件由本地生成
+		},
 	}
 
 	if info, ok := infos[provider]; ok {
@@ -175,6 +175,105 @@ func (a *GmailAdapter) FetchEmails(ctx context.Context, since time.Time, limit i
 	return emails, nil
 }
 
+// FetchHistory 从最新的邮件开始向前逐页拉取历史邮件，游标为 Gmail API 的 pageToken
+func (a *GmailAdapter) FetchHistory(ctx context.Context, cursor string, limit int) (*HistoryPage, error) {
+	if a.service == nil {
+		return nil, fmt.Errorf("not connected to Gmail API")
+	}
+
+	// Gmail API 单页最多 500 封
+	maxResults := int64(100)
+	if limit > 0 && limit <= 500 {
+		maxResults = int64(limit)
+	}
+
+	listCall := a.service.Users.Messages.List("me").
+		Q("in:inbox").
+		MaxResults(maxResults)
+	if cursor != "" {
+		listCall = listCall.PageToken(cursor)
+	}
+
+	response, err := listCall.Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	page := &HistoryPage{
+		Emails:     make([]*Email, 0, len(response.Messages)),
+		NextCursor: response.NextPageToken,
+		Total:      int(response.ResultSizeEstimate),
+	}
+	for _, msg := range response.Messages {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		email, err := a.FetchEmailDetail(ctx, msg.Id)
+		if err != nil {
+			// 配额错误需要上层退避重试，其余错误跳过该邮件
+			if Classify(err) == ErrorKindQuota {
+				return nil, err
+			}
+			continue
+		}
+
+		page.Emails = append(page.Emails, email)
+	}
+
+	return page, nil
+}
+
+// ListSourceFolders 列出收件箱和已归档（不在收件箱、不在垃圾箱和垃圾邮件中）的全部邮件 ID
+// Gmail 中从收件箱归档的邮件仍然存在，只有移入垃圾箱或被彻底删除的邮件才会从清单中消失
+func (a *GmailAdapter) ListSourceFolders(ctx context.Context) ([]SourceFolder, error) {
+	if a.service == nil {
+		return nil, fmt.Errorf("not connected to Gmail API")
+	}
+
+	folders := []SourceFolder{
+		{Name: "INBOX"},
+		{Name: "ARCHIVE"},
+	}
+	queries := []string{"in:inbox", "-in:inbox"}
+
+	for i, query := range queries {
+		listCall := a.service.Users.Messages.List("me").
+			Q(query).
+			MaxResults(500).
+			Fields("messages/id", "nextPageToken")
+
+		err := listCall.Pages(ctx, func(response *gmail.ListMessagesResponse) error {
+			for _, msg := range response.Messages {
+				folders[i].ProviderIDs = append(folders[i].ProviderIDs, msg.Id)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list messages: %w", err)
+		}
+	}
+
+	return folders, nil
+}
+
+// gmailSourceFolder 按标签返回与 ListSourceFolders 一致的源文件夹：带 INBOX 标签的在收件箱，
+// 不在垃圾箱和垃圾邮件中的其余邮件为已归档，其他情况返回空字符串（不更新已保存的源文件夹）
+func gmailSourceFolder(labels []string) string {
+	folder := "ARCHIVE"
+	for _, label := range labels {
+		switch label {
+		case "INBOX":
+			return "INBOX"
+		case "TRASH", "SPAM":
+			folder = ""
+		}
+	}
+	return folder
+}
+
 // FetchEmailDetail 获取邮件详情
 func (a *GmailAdapter) FetchEmailDetail(ctx context.Context, providerID string) (*Email, error) {
 	if a.service == nil {
@@ -197,6 +296,7 @@ func (a *GmailAdapter) FetchEmailDetail(ctx context.Context, providerID string)
 		SourceLabels:   msg.LabelIds,
 		HasAttachments: false,
 	}
+	email.SourceFolder = gmailSourceFolder(msg.LabelIds)
 
 	// 解析邮件头
 	for _, header := range msg.Payload.Headers {
@@ -233,7 +333,21 @@ func (a *GmailAdapter) FetchEmailDetail(ctx context.Context, providerID string)
 	}
 
 	// 解析邮件正文和附件
-	a.parseMessagePart(msg.Payload, email)
+	pending := make(map[int]string)
+	a.parseMessagePart(msg.Payload, email, pending)
+
+	// 较大的附件内容不随邮件返回，需要按附件 ID 单独获取
+	for index, attachmentID := range pending {
+		body, err := a.service.Users.Messages.Attachments.Get("me", msg.Id, attachmentID).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get attachment: %w", err)
+		}
+		content, err := base64.URLEncoding.DecodeString(body.Data)
+		if err != nil {
+			return nil, NewError(ErrorKindParse, "fetch", fmt.Errorf("failed to decode attachment: %w", err))
+		}
+		email.Attachments[index].Content = content
+	}
 
 	// 生成摘要
 	if email.Snippet == "" {
@@ -248,7 +362,8 @@ func (a *GmailAdapter) FetchEmailDetail(ctx context.Context, providerID string)
 }
 
 // parseMessagePart 解析邮件部分（递归处理多部分邮件）
-func (a *GmailAdapter) parseMessagePart(part *gmail.MessagePart, email *Email) {
+// 内容需要单独获取的附件记录到 pending（附件下标 -> 附件 ID）
+func (a *GmailAdapter) parseMessagePart(part *gmail.MessagePart, email *Email, pending map[int]string) {
 	// 处理邮件正文
 	if part.MimeType == "text/plain" && part.Body.Data != "" {
 		data, _ := base64.URLEncoding.DecodeString(part.Body.Data)
@@ -273,17 +388,23 @@ func (a *GmailAdapter) parseMessagePart(part *gmail.MessagePart, email *Email) {
 		for _, header := range part.Headers {
 			if header.Name == "Content-ID" {
 				attachment.IsInline = true
-				attachment.ContentID = header.Value
+				attachment.ContentID = strings.Trim(header.Value, "<>")
 				break
 			}
 		}
 
+		if part.Body.AttachmentId != "" {
+			pending[len(email.Attachments)] = part.Body.AttachmentId
+		} else if part.Body.Data != "" {
+			attachment.Content, _ = base64.URLEncoding.DecodeString(part.Body.Data)
+		}
+
 		email.Attachments = append(email.Attachments, attachment)
 	}
 
 	// 递归处理子部分
 	for _, subPart := range part.Parts {
-		a.parseMessagePart(subPart, email)
+		a.parseMessagePart(subPart, email, pending)
 	}
 }
 
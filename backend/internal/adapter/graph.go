@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -38,6 +39,7 @@ type GraphMessage struct {
 	IsRead                  bool             `json:"isRead"`
 	Categories              []string         `json:"categories"`
 	InferenceClassification string           `json:"inferenceClassification"`
+	ParentFolderID          string           `json:"parentFolderId"`
 }
 
 // GraphItemBody 邮件正文
@@ -61,16 +63,18 @@ type GraphEmailAddress struct {
 type GraphMessageList struct {
 	Value    []GraphMessage `json:"value"`
 	NextLink string         `json:"@odata.nextLink"`
+	Count    int            `json:"@odata.count"` // 请求 $count=true 时返回的总数
 }
 
 // GraphAttachment 附件信息
 type GraphAttachment struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	ContentType string `json:"contentType"`
-	Size        int64  `json:"size"`
-	IsInline    bool   `json:"isInline"`
-	ContentID   string `json:"contentId"`
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	Size         int64  `json:"size"`
+	IsInline     bool   `json:"isInline"`
+	ContentID    string `json:"contentId"`
+	ContentBytes []byte `json:"contentBytes"` // 文件附件内容（Base64，JSON 解码时自动还原）
 }
 
 // GraphAttachmentList 附件列表响应
@@ -208,25 +212,161 @@ func (a *GraphAdapter) FetchEmails(ctx context.Context, since time.Time, limit i
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Graph API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, statusError("fetch", resp.StatusCode, string(body))
 	}
 
 	// 解析响应
 	var messageList GraphMessageList
 	if err := json.NewDecoder(resp.Body).Decode(&messageList); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, NewError(ErrorKindParse, "fetch", fmt.Errorf("failed to decode response: %w", err))
 	}
 
 	// 转换为 Email 对象
 	emails := make([]*Email, 0, len(messageList.Value))
 	for _, msg := range messageList.Value {
 		email := a.convertGraphMessageToEmail(&msg)
+		if err := a.attachAttachments(ctx, &msg, email); err != nil {
+			// 配额错误需要上层退避重试，其余错误保留邮件但不含附件
+			if Classify(err) == ErrorKindQuota {
+				return nil, err
+			}
+		}
 		emails = append(emails, email)
 	}
 
 	return emails, nil
 }
 
+// FetchHistory 从最新的邮件开始向前逐页拉取历史邮件，游标为 Graph API 返回的 @odata.nextLink
+func (a *GraphAdapter) FetchHistory(ctx context.Context, cursor string, limit int) (*HistoryPage, error) {
+	if a.httpClient == nil {
+		return nil, fmt.Errorf("not connected to Microsoft Graph API")
+	}
+
+	requestURL := cursor
+	if requestURL == "" {
+		top := 100
+		if limit > 0 && limit < 1000 {
+			top = limit
+		}
+		params := url.Values{}
+		params.Set("$top", fmt.Sprintf("%d", top))
+		params.Set("$orderby", "receivedDateTime DESC")
+		params.Set("$count", "true")
+		requestURL = fmt.Sprintf("%s/me/messages?%s", a.baseURL, params.Encode())
+	} else if !strings.HasPrefix(requestURL, a.baseURL) {
+		return nil, fmt.Errorf("invalid history cursor")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("ConsistencyLevel", "eventual")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch messages: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, statusError("fetch", resp.StatusCode, string(body))
+	}
+
+	var messageList GraphMessageList
+	if err := json.NewDecoder(resp.Body).Decode(&messageList); err != nil {
+		return nil, NewError(ErrorKindParse, "fetch", fmt.Errorf("failed to decode response: %w", err))
+	}
+
+	page := &HistoryPage{
+		Emails:     make([]*Email, 0, len(messageList.Value)),
+		NextCursor: messageList.NextLink,
+		Total:      messageList.Count,
+	}
+	for _, msg := range messageList.Value {
+		email := a.convertGraphMessageToEmail(&msg)
+		if err := a.attachAttachments(ctx, &msg, email); err != nil {
+			if Classify(err) == ErrorKindQuota {
+				return nil, err
+			}
+		}
+		page.Emails = append(page.Emails, email)
+	}
+
+	return page, nil
+}
+
+// ListSourceFolders 列出邮箱中全部邮件 ID，按所在文件夹（parentFolderId）分组
+// 已删除邮件文件夹中的邮件视为已在源邮箱删除，不计入清单
+func (a *GraphAdapter) ListSourceFolders(ctx context.Context) ([]SourceFolder, error) {
+	if a.httpClient == nil {
+		return nil, fmt.Errorf("not connected to Microsoft Graph API")
+	}
+
+	var deletedItems struct {
+		ID string `json:"id"`
+	}
+	if err := a.getJSON(ctx, a.baseURL+"/me/mailFolders/deleteditems?$select=id", &deletedItems); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("$select", "id,parentFolderId")
+	params.Set("$top", "1000")
+	requestURL := fmt.Sprintf("%s/me/messages?%s", a.baseURL, params.Encode())
+
+	index := make(map[string]int)
+	var folders []SourceFolder
+	for requestURL != "" {
+		var messageList GraphMessageList
+		if err := a.getJSON(ctx, requestURL, &messageList); err != nil {
+			return nil, err
+		}
+
+		for _, msg := range messageList.Value {
+			if msg.ParentFolderID == deletedItems.ID {
+				continue
+			}
+			i, ok := index[msg.ParentFolderID]
+			if !ok {
+				i = len(folders)
+				index[msg.ParentFolderID] = i
+				folders = append(folders, SourceFolder{Name: msg.ParentFolderID})
+			}
+			folders[i].ProviderIDs = append(folders[i].ProviderIDs, msg.ID)
+		}
+		requestURL = messageList.NextLink
+	}
+
+	return folders, nil
+}
+
+// getJSON 发送 GET 请求并解析 JSON 响应
+func (a *GraphAdapter) getJSON(ctx context.Context, requestURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch messages: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return statusError("fetch", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return NewError(ErrorKindParse, "fetch", fmt.Errorf("failed to decode response: %w", err))
+	}
+	return nil
+}
+
 // FetchEmailDetail 获取邮件详情
 func (a *GraphAdapter) FetchEmailDetail(ctx context.Context, providerID string) (*Email, error) {
 	if a.httpClient == nil {
@@ -245,47 +385,55 @@ func (a *GraphAdapter) FetchEmailDetail(ctx context.Context, providerID string)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Graph API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, statusError("fetch", resp.StatusCode, string(body))
 	}
 
 	// 解析响应
 	var msg GraphMessage
 	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, NewError(ErrorKindParse, "fetch", fmt.Errorf("failed to decode response: %w", err))
 	}
 
 	// 转换为 Email 对象
 	email := a.convertGraphMessageToEmail(&msg)
 
 	// 获取附件信息
-	if msg.HasAttachments {
-		attachments, err := a.fetchAttachments(ctx, providerID)
-		if err == nil {
-			email.Attachments = attachments
-			email.AttachmentsCount = len(attachments)
-		}
+	if err := a.attachAttachments(ctx, &msg, email); err != nil {
+		return nil, err
 	}
 
 	return email, nil
 }
 
-// fetchAttachments 获取附件列表
-func (a *GraphAdapter) fetchAttachments(ctx context.Context, messageID string) ([]Attachment, error) {
-	requestURL := fmt.Sprintf("%s/me/messages/%s/attachments", a.baseURL, messageID)
+// attachAttachments 为有附件或引用内联图片（cid:）的邮件获取附件内容
+// 只有内联图片的邮件 hasAttachments 为 false，因此同时检查正文中的 cid: 引用
+func (a *GraphAdapter) attachAttachments(ctx context.Context, msg *GraphMessage, email *Email) error {
+	if !msg.HasAttachments && !strings.Contains(msg.Body.Content, "cid:") {
+		return nil
+	}
 
-	resp, err := a.httpClient.Get(requestURL)
+	attachments, err := a.fetchAttachments(ctx, msg.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch attachments: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Graph API returned status %d", resp.StatusCode)
+	email.Attachments = attachments
+	email.AttachmentsCount = 0
+	for _, attachment := range attachments {
+		if !attachment.IsInline {
+			email.AttachmentsCount++
+		}
 	}
+	return nil
+}
+
+// fetchAttachments 获取附件列表
+func (a *GraphAdapter) fetchAttachments(ctx context.Context, messageID string) ([]Attachment, error) {
+	requestURL := fmt.Sprintf("%s/me/messages/%s/attachments", a.baseURL, messageID)
 
 	var attachmentList GraphAttachmentList
-	if err := json.NewDecoder(resp.Body).Decode(&attachmentList); err != nil {
-		return nil, fmt.Errorf("failed to decode attachments: %w", err)
+	if err := a.getJSON(ctx, requestURL, &attachmentList); err != nil {
+		return nil, fmt.Errorf("failed to fetch attachments: %w", err)
 	}
 
 	// 转换为 Attachment 对象
@@ -295,8 +443,9 @@ func (a *GraphAdapter) fetchAttachments(ctx context.Context, messageID string) (
 			Filename:    att.Name,
 			ContentType: att.ContentType,
 			SizeBytes:   att.Size,
+			Content:     att.ContentBytes,
 			IsInline:    att.IsInline,
-			ContentID:   att.ContentID,
+			ContentID:   strings.Trim(att.ContentID, "<>"),
 		})
 	}
 
@@ -314,6 +463,7 @@ func (a *GraphAdapter) convertGraphMessageToEmail(msg *GraphMessage) *Email {
 		HasAttachments: msg.HasAttachments,
 		SourceLabels:   msg.Categories,
 		SourceIsRead:   &msg.IsRead,
+		SourceFolder:   msg.ParentFolderID,
 	}
 
 	// 解析发件人
@@ -389,7 +539,7 @@ func (a *GraphAdapter) TestConnection(ctx context.Context) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("Graph API returned status %d: %s", resp.StatusCode, string(body))
+		return statusError("connect", resp.StatusCode, string(body))
 	}
 
 	return nil
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -66,7 +67,7 @@ func (a *IMAPAdapter) Connect(ctx context.Context) error {
 	// 连接到服务器
 	client, err := imapclient.DialTLS(addr, options)
 	if err != nil {
-		return fmt.Errorf("failed to connect to IMAP server: %w", err)
+		return connectError("connect", fmt.Errorf("failed to connect to IMAP server: %w", err))
 	}
 
 	a.client = client
@@ -86,7 +87,7 @@ func (a *IMAPAdapter) login(ctx context.Context) error {
 	password := a.config.Credentials.Password
 
 	if email == "" || password == "" {
-		return fmt.Errorf("email and password are required")
+		return NewError(ErrorKindAuth, "login", fmt.Errorf("email and password are required"))
 	}
 
 	// 发送 IMAP ID 信息（某些服务器如 163 需要这个来识别客户端）
@@ -109,7 +110,7 @@ func (a *IMAPAdapter) login(ctx context.Context) error {
 	// 登录
 	fmt.Printf("[IMAP] Logging in as %s...\n", email)
 	if err := a.client.Login(email, password).Wait(); err != nil {
-		return fmt.Errorf("failed to login: %w", err)
+		return NewError(ErrorKindAuth, "login", fmt.Errorf("failed to login: %w", err))
 	}
 	fmt.Printf("[IMAP] Login successful\n")
 
@@ -217,6 +218,117 @@ func (a *IMAPAdapter) FetchEmails(ctx context.Context, since time.Time, limit in
 	return emails, nil
 }
 
+// FetchHistory 从最新的邮件开始向前逐页拉取历史邮件，游标为已拉取的最小 UID
+func (a *IMAPAdapter) FetchHistory(ctx context.Context, cursor string, limit int) (*HistoryPage, error) {
+	if a.client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	mailbox, err := a.client.Select("INBOX", nil).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("failed to select INBOX: %w", err)
+	}
+
+	page := &HistoryPage{Emails: []*Email{}, Total: int(mailbox.NumMessages)}
+	if mailbox.NumMessages == 0 {
+		return page, nil
+	}
+
+	// 搜索游标之前的所有 UID
+	criteria := &imap.SearchCriteria{}
+	if cursor != "" {
+		lowest, err := parseUID(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid history cursor: %w", err)
+		}
+		if lowest <= 1 {
+			return page, nil
+		}
+		criteria.UID = []imap.UIDSet{{{Start: 1, Stop: lowest - 1}}}
+	}
+
+	data, err := a.client.UIDSearch(criteria, nil).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search emails: %w", err)
+	}
+
+	uids := data.AllUIDs()
+	if len(uids) == 0 {
+		return page, nil
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	// 取最新的 limit 封
+	if len(uids) > limit {
+		uids = uids[len(uids)-limit:]
+		page.NextCursor = fmt.Sprintf("%d", uids[0])
+	}
+
+	fetchOptions := &imap.FetchOptions{
+		Envelope:     true,
+		BodySection:  []*imap.FetchItemBodySection{{}},
+		UID:          true,
+		InternalDate: true,
+		RFC822Size:   true,
+	}
+
+	fetchCmd := a.client.Fetch(imap.UIDSetNum(uids...), fetchOptions)
+	for {
+		msg := fetchCmd.Next()
+		if msg == nil {
+			break
+		}
+
+		buf, err := msg.Collect()
+		if err != nil {
+			fmt.Printf("[IMAP] Failed to collect message: %v\n", err)
+			continue
+		}
+
+		email, err := a.parseMessageBuffer(buf)
+		if err != nil {
+			fmt.Printf("[IMAP] Failed to parse message: %v\n", err)
+			continue
+		}
+
+		page.Emails = append(page.Emails, email)
+	}
+
+	if err := fetchCmd.Close(); err != nil {
+		return nil, fmt.Errorf("failed to fetch emails: %w", err)
+	}
+
+	return page, nil
+}
+
+// ListSourceFolders 列出 INBOX 中当前存在的全部邮件 UID
+// UID 只在文件夹内唯一，移动到其他文件夹的邮件会获得新 UID，因此这里只列出同步范围内的 INBOX
+func (a *IMAPAdapter) ListSourceFolders(ctx context.Context) ([]SourceFolder, error) {
+	if a.client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+
+	if _, err := a.client.Select("INBOX", nil).Wait(); err != nil {
+		return nil, fmt.Errorf("failed to select INBOX: %w", err)
+	}
+
+	data, err := a.client.UIDSearch(&imap.SearchCriteria{}, nil).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search emails: %w", err)
+	}
+
+	uids := data.AllUIDs()
+	folder := SourceFolder{Name: "INBOX", ProviderIDs: make([]string, 0, len(uids))}
+	for _, uid := range uids {
+		folder.ProviderIDs = append(folder.ProviderIDs, fmt.Sprintf("%d", uid))
+	}
+
+	return []SourceFolder{folder}, nil
+}
+
 // FetchEmailDetail 获取邮件详情
 func (a *IMAPAdapter) FetchEmailDetail(ctx context.Context, providerID string) (*Email, error) {
 	if a.client == nil {
@@ -270,8 +382,10 @@ func (a *IMAPAdapter) FetchEmailDetail(ctx context.Context, providerID string) (
 
 // parseMessageBuffer 解析 IMAP 消息缓冲区
 func (a *IMAPAdapter) parseMessageBuffer(buf *imapclient.FetchMessageBuffer) (*Email, error) {
+	// 只同步 INBOX，源文件夹与 ListSourceFolders 一致
 	email := &Email{
-		ProviderID: fmt.Sprintf("%d", buf.UID),
+		ProviderID:   fmt.Sprintf("%d", buf.UID),
+		SourceFolder: "INBOX",
 	}
 
 	// 解析信封信息
@@ -326,6 +440,7 @@ func (a *IMAPAdapter) parseMessageBuffer(buf *imapclient.FetchMessageBuffer) (*E
 	// 解析邮件正文
 	for _, section := range buf.BodySection {
 		// 使用 mail.CreateReader 正确解析 MIME 结构
+		email.RawMessage = section.Bytes
 		reader := bytes.NewReader(section.Bytes)
 		if err := a.parseBody(email, reader); err != nil {
 			// 如果解析失败，回退到简单处理
@@ -394,6 +509,17 @@ func (a *IMAPAdapter) parseBody(email *Email, r io.Reader) error {
 			case "text/html":
 				// 清理 HTML 内容，移除邮件服务器添加的包装标签
 				email.HTMLBody = cleanHTMLBody(string(body))
+			default:
+				// 内联资源（如 cid: 引用的图片）
+				_, params, _ := h.ContentDisposition()
+				email.Attachments = append(email.Attachments, Attachment{
+					Filename:    params["filename"],
+					ContentType: contentType,
+					SizeBytes:   int64(len(body)),
+					Content:     body,
+					IsInline:    true,
+					ContentID:   strings.Trim(h.Get("Content-ID"), "<>"),
+				})
 			}
 
 		case *mail.AttachmentHeader:
@@ -61,10 +61,10 @@ func (a *POP3Adapter) Connect(ctx context.Context) error {
 	client := pop3.New(opt)
 	conn, err := client.NewConn()
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return connectError("connect", fmt.Errorf("failed to connect: %w", err))
 	}
 	if err := conn.Auth(a.config.Credentials.Email, a.config.Credentials.Password); err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+		return NewError(ErrorKindAuth, "login", fmt.Errorf("authentication failed: %w", err))
 	}
 	a.client = client
 	return nil
@@ -121,11 +121,11 @@ func (a *POP3Adapter) FetchEmails(ctx context.Context, since time.Time, limit in
 	}
 	conn, err := a.client.NewConn()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create connection: %w", err)
+		return nil, connectError("connect", fmt.Errorf("failed to create connection: %w", err))
 	}
 	defer conn.Quit()
 	if err := conn.Auth(a.config.Credentials.Email, a.config.Credentials.Password); err != nil {
-		return nil, fmt.Errorf("authentication failed: %w", err)
+		return nil, NewError(ErrorKindAuth, "login", fmt.Errorf("authentication failed: %w", err))
 	}
 	count, _, err := conn.Stat()
 	if err != nil {
@@ -157,6 +157,57 @@ func (a *POP3Adapter) FetchEmails(ctx context.Context, since time.Time, limit in
 	return emails, nil
 }
 
+// FetchHistory 从最新的邮件开始向前逐页拉取历史邮件，游标为下一封待拉取邮件的序号
+func (a *POP3Adapter) FetchHistory(ctx context.Context, cursor string, limit int) (*HistoryPage, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	conn, err := a.client.NewConn()
+	if err != nil {
+		return nil, connectError("connect", fmt.Errorf("failed to create connection: %w", err))
+	}
+	defer conn.Quit()
+	if err := conn.Auth(a.config.Credentials.Email, a.config.Credentials.Password); err != nil {
+		return nil, NewError(ErrorKindAuth, "login", fmt.Errorf("authentication failed: %w", err))
+	}
+	count, _, err := conn.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("STAT failed: %w", err)
+	}
+	page := &HistoryPage{Emails: []*Email{}, Total: count}
+	next := count
+	if cursor != "" {
+		if next, err = strconv.Atoi(cursor); err != nil || next < 0 {
+			return nil, fmt.Errorf("invalid history cursor: %s", cursor)
+		}
+		// 服务器上的邮件被删除后序号会前移
+		if next > count {
+			next = count
+		}
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	for i := next; i > 0 && i > next-limit; i-- {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		email, err := a.fetchEmailByNumber(conn, i)
+		if err != nil {
+			continue
+		}
+		page.Emails = append(page.Emails, email)
+	}
+	if rest := next - limit; rest > 0 {
+		page.NextCursor = strconv.Itoa(rest)
+	}
+	return page, nil
+}
+
 func (a *POP3Adapter) fetchEmailByNumber(conn *pop3.Conn, msgNum int) (*Email, error) {
 	msgBuffer, err := conn.RetrRaw(msgNum)
 	if err != nil {
@@ -164,10 +215,11 @@ func (a *POP3Adapter) fetchEmailByNumber(conn *pop3.Conn, msgNum int) (*Email, e
 	}
 	msg, err := mail.ReadMessage(strings.NewReader(msgBuffer.String()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse message: %w", err)
+		return nil, NewError(ErrorKindParse, "fetch", fmt.Errorf("failed to parse message: %w", err))
 	}
 	email := &Email{
 		ProviderID: strconv.Itoa(msgNum),
+		RawMessage: msgBuffer.Bytes(),
 		MessageID:  msg.Header.Get("Message-ID"),
 		Subject:    msg.Header.Get("Subject"),
 	}
@@ -219,11 +271,11 @@ func (a *POP3Adapter) FetchEmailDetail(ctx context.Context, providerID string) (
 	}
 	conn, err := a.client.NewConn()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create connection: %w", err)
+		return nil, connectError("connect", fmt.Errorf("failed to create connection: %w", err))
 	}
 	defer conn.Quit()
 	if err := conn.Auth(a.config.Credentials.Email, a.config.Credentials.Password); err != nil {
-		return nil, fmt.Errorf("authentication failed: %w", err)
+		return nil, NewError(ErrorKindAuth, "login", fmt.Errorf("authentication failed: %w", err))
 	}
 	msgNum, err := strconv.Atoi(providerID)
 	if err != nil {
@@ -248,11 +300,11 @@ func (a *POP3Adapter) TestConnection(ctx context.Context) error {
 	}
 	conn, err := a.client.NewConn()
 	if err != nil {
-		return fmt.Errorf("failed to create connection: %w", err)
+		return connectError("connect", fmt.Errorf("failed to create connection: %w", err))
 	}
 	defer conn.Quit()
 	if err := conn.Auth(a.config.Credentials.Email, a.config.Credentials.Password); err != nil {
-		return fmt.Errorf("authentication failed: %w", err)
+		return NewError(ErrorKindAuth, "login", fmt.Errorf("authentication failed: %w", err))
 	}
 	_, _, err = conn.Stat()
 	if err != nil {
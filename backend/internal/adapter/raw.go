@@ -0,0 +1,111 @@
+package adapter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	_ "github.com/emersion/go-message/charset" // 注册字符集解码器（GB18030/ISO-2022-JP/KOI8-R 等）
+	"github.com/emersion/go-message/mail"
+)
+
+// ParseRawMessage 解析 RFC 822 原文
+// 用于能取得原文的适配器以及从归档的原始邮件重新解析；ProviderID、源邮箱状态和接收时间由调用方填写
+func ParseRawMessage(raw []byte) (*Email, error) {
+	mr, err := mail.CreateReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	email := &Email{
+		SizeBytes:  int64(len(raw)),
+		RawMessage: raw,
+	}
+	h := mr.Header
+	email.Subject, _ = h.Subject()
+	email.MessageID, _ = h.MessageID()
+	if ids, _ := h.MsgIDList("In-Reply-To"); len(ids) > 0 {
+		email.InReplyTo = ids[0]
+	}
+	email.References = h.Get("References")
+	email.SentAt, _ = h.Date()
+
+	if from, _ := h.AddressList("From"); len(from) > 0 {
+		email.FromAddress = from[0].Address
+		email.FromName = from[0].Name
+	}
+	if replyTo, _ := h.AddressList("Reply-To"); len(replyTo) > 0 {
+		email.ReplyTo = replyTo[0].Address
+	}
+	email.ToAddresses = headerAddresses(h, "To")
+	email.CcAddresses = headerAddresses(h, "Cc")
+	email.BccAddresses = headerAddresses(h, "Bcc")
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part: %w", err)
+		}
+
+		content, err := io.ReadAll(part.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part body: %w", err)
+		}
+
+		switch ph := part.Header.(type) {
+		case *mail.InlineHeader:
+			contentType, _, _ := ph.ContentType()
+			switch {
+			case contentType == "text/plain":
+				email.TextBody = string(content)
+			case contentType == "text/html":
+				email.HTMLBody = cleanHTMLBody(string(content))
+			default:
+				// 内联资源（如 cid: 引用的图片）
+				_, params, _ := ph.ContentDisposition()
+				email.Attachments = append(email.Attachments, Attachment{
+					Filename:    params["filename"],
+					ContentType: contentType,
+					SizeBytes:   int64(len(content)),
+					Content:     content,
+					IsInline:    true,
+					ContentID:   strings.Trim(ph.Get("Content-ID"), "<>"),
+				})
+			}
+
+		case *mail.AttachmentHeader:
+			filename, _ := ph.Filename()
+			contentType, _, _ := ph.ContentType()
+			email.HasAttachments = true
+			email.AttachmentsCount++
+			email.Attachments = append(email.Attachments, Attachment{
+				Filename:    filename,
+				ContentType: contentType,
+				SizeBytes:   int64(len(content)),
+				Content:     content,
+			})
+		}
+	}
+
+	if email.TextBody != "" {
+		email.Snippet = generateSnippet(email.TextBody, email.Subject)
+	} else if email.HTMLBody != "" {
+		email.Snippet = generateSnippet(stripHTML(email.HTMLBody), email.Subject)
+	}
+
+	return email, nil
+}
+
+// headerAddresses 解析地址列表头部，只保留邮箱地址
+func headerAddresses(h mail.Header, key string) []string {
+	list, _ := h.AddressList(key)
+	var addresses []string
+	for _, addr := range list {
+		addresses = append(addresses, addr.Address)
+	}
+	return addresses
+}
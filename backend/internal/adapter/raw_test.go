@@ -0,0 +1,54 @@
+package adapter
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseRawMessage 测试从原文解析头部、地址、正文和附件
+func TestParseRawMessage(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: =?UTF-8?B?5byg5LiJ?= <zhang@example.com>",
+		"To: a@example.com, \"B\" <b@example.com>",
+		"Cc: c@example.com",
+		"Reply-To: replies@example.com",
+		"Subject: =?UTF-8?Q?Quarterly_report?=",
+		"Message-ID: <report-1@example.com>",
+		"In-Reply-To: <prev@example.com>",
+		"Date: Mon, 01 Jul 2024 10:00:00 +0800",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=b1",
+		"",
+		"--b1",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"See attached.",
+		"--b1",
+		"Content-Type: application/pdf",
+		"Content-Disposition: attachment; filename=report.pdf",
+		"",
+		"%PDF-1.4",
+		"--b1--",
+		"",
+	}, "\r\n")
+
+	email, err := ParseRawMessage([]byte(raw))
+	if err != nil {
+		t.Fatalf("ParseRawMessage failed: %v", err)
+	}
+	if email.Subject != "Quarterly report" || email.FromName != "张三" || email.FromAddress != "zhang@example.com" {
+		t.Errorf("header fields = %q / %q <%s>", email.Subject, email.FromName, email.FromAddress)
+	}
+	if strings.Join(email.ToAddresses, ",") != "a@example.com,b@example.com" || strings.Join(email.CcAddresses, ",") != "c@example.com" {
+		t.Errorf("addresses = %v / %v", email.ToAddresses, email.CcAddresses)
+	}
+	if email.ReplyTo != "replies@example.com" || email.MessageID != "report-1@example.com" || email.InReplyTo != "prev@example.com" {
+		t.Errorf("reply fields = %q %q %q", email.ReplyTo, email.MessageID, email.InReplyTo)
+	}
+	if email.TextBody != "See attached." || !email.HasAttachments || email.AttachmentsCount != 1 {
+		t.Errorf("body = %q, attachments = %d", email.TextBody, email.AttachmentsCount)
+	}
+	if email.SizeBytes != int64(len(raw)) || string(email.RawMessage) != raw {
+		t.Error("raw message not kept")
+	}
+}
@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
+	"time"
 
+	"fusionmail/internal/model"
 	"fusionmail/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -14,12 +20,15 @@ import (
 // AttachmentHandler 附件处理器
 type AttachmentHandler struct {
 	attachmentService *service.AttachmentService
+	links             *service.DownloadLinkService
 }
 
 // NewAttachmentHandler 创建附件处理器
-func NewAttachmentHandler(attachmentService *service.AttachmentService) *AttachmentHandler {
+// links 可以为 nil，此时不提供签名下载链接，附件信息中的 url 为空
+func NewAttachmentHandler(attachmentService *service.AttachmentService, links *service.DownloadLinkService) *AttachmentHandler {
 	return &AttachmentHandler{
 		attachmentService: attachmentService,
+		links:             links,
 	}
 }
 
@@ -44,6 +53,7 @@ func (h *AttachmentHandler) GetAttachment(c *gin.Context) {
 		return
 	}
 
+	h.fillURL(attachment)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    attachment,
@@ -63,6 +73,13 @@ func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
 	}
 
 	reader, attachment, err := h.attachmentService.DownloadAttachment(c.Request.Context(), id)
+	if errors.Is(err, service.ErrAttachmentNotStored) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "附件超过大小限制，未保存内容",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
@@ -72,16 +89,125 @@ func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
 	}
 	defer reader.Close()
 
-	// 设置响应头
-	c.Header("Content-Type", attachment.ContentType)
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", attachment.Filename))
-	c.Header("Content-Length", fmt.Sprintf("%d", attachment.SizeBytes))
+	serveAttachment(c, reader, attachment, false)
+}
 
-	// 流式传输文件内容
-	if _, err := io.Copy(c.Writer, reader); err != nil {
-		// 如果传输失败，记录错误（但此时已经开始发送响应，无法返回 JSON 错误）
-		fmt.Printf("Error streaming attachment: %v\n", err)
+// CreateLinkRequest 创建下载链接请求（字段均可选）
+type CreateLinkRequest struct {
+	ExpiresIn int  `json:"expires_in" binding:"omitempty,min=1,max=604800"` // 有效期（秒），默认使用服务端配置，最长 7 天
+	SingleUse bool `json:"single_use"`                                      // 只能下载一次
+	Inline    bool `json:"inline"`                                          // 在浏览器中直接显示（图片、PDF 等）
+}
+
+// CreateLink 创建附件的签名下载链接（无需登录即可在有效期内下载）
+// POST /api/v1/attachments/:id/link
+func (h *AttachmentHandler) CreateLink(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的附件 ID",
+		})
+		return
+	}
+
+	var req CreateLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if h.links == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "未启用签名下载链接",
+		})
+		return
+	}
+	attachment, err := h.attachmentService.GetAttachment(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "附件不存在",
+		})
+		return
+	}
+	if attachment.StorageType == "none" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "附件超过大小限制，未保存内容",
+		})
+		return
+	}
+
+	link, err := h.links.Sign(attachment.ID, service.DownloadLinkRequest{
+		ExpiresIn: time.Duration(req.ExpiresIn) * time.Second,
+		SingleUse: req.SingleUse,
+		Inline:    req.Inline,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "创建下载链接失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    link,
+	})
+}
+
+// DownloadSigned 通过签名链接下载附件（无需认证）
+// GET /api/v1/files/attachments/:id
+func (h *AttachmentHandler) DownloadSigned(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || h.links == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "下载链接无效",
+		})
+		return
+	}
+
+	reader, attachment, inline, err := h.links.Open(c.Request.Context(), id, c.Request.URL.Query())
+	switch {
+	case errors.Is(err, service.ErrDownloadLinkInvalid):
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "下载链接无效",
+		})
+		return
+	case errors.Is(err, service.ErrDownloadLinkExpired):
+		c.JSON(http.StatusGone, gin.H{
+			"success": false,
+			"error":   "下载链接已过期",
+		})
+		return
+	case errors.Is(err, service.ErrDownloadLinkUsed):
+		c.JSON(http.StatusGone, gin.H{
+			"success": false,
+			"error":   "下载链接已被使用",
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "附件不存在或下载失败",
+		})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Cache-Control", "private, max-age=300")
+	if c.Query("nonce") != "" {
+		c.Header("Cache-Control", "no-store")
 	}
+	serveAttachment(c, reader, attachment, inline)
 }
 
 // DeleteAttachment 删除附件
@@ -131,8 +257,75 @@ func (h *AttachmentHandler) GetEmailAttachments(c *gin.Context) {
 		return
 	}
 
+	for _, attachment := range attachments {
+		h.fillURL(attachment)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    attachments,
 	})
 }
+
+// fillURL 为已保存内容的附件生成默认有效期的签名链接（内联资源在浏览器中直接显示，可用于 <img>）
+func (h *AttachmentHandler) fillURL(attachment *model.EmailAttachment) {
+	if h.links == nil || attachment.StorageType == "none" {
+		return
+	}
+	if link, err := h.links.Sign(attachment.ID, service.DownloadLinkRequest{Inline: attachment.IsInline}); err == nil {
+		attachment.URL = link.URL
+	}
+}
+
+// inlineSafeTypes 可以在浏览器中直接显示的内容类型（HTML、SVG 等可执行脚本的类型始终作为附件下载）
+var inlineSafeTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"image/bmp":       true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// serveAttachment 输出附件内容：规范化 Content-Type，按文件名生成 Content-Disposition（支持非 ASCII 文件名）
+func serveAttachment(c *gin.Context, reader io.Reader, attachment *model.EmailAttachment, inline bool) {
+	contentType := attachmentContentType(attachment)
+	disposition := "attachment"
+	if mediaType, _, _ := mime.ParseMediaType(contentType); inline && inlineSafeTypes[mediaType] {
+		disposition = "inline"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", contentDisposition(disposition, attachment.Filename))
+	c.Header("X-Content-Type-Options", "nosniff")
+	if attachment.SizeBytes > 0 {
+		c.Header("Content-Length", strconv.FormatInt(attachment.SizeBytes, 10))
+	}
+
+	// 流式传输文件内容
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		// 如果传输失败，记录错误（但此时已经开始发送响应，无法返回 JSON 错误）
+		fmt.Printf("Error streaming attachment: %v\n", err)
+	}
+}
+
+// attachmentContentType 附件的 Content-Type：记录的类型无效时按扩展名推断，仍无法确定时使用 application/octet-stream
+func attachmentContentType(attachment *model.EmailAttachment) string {
+	if mediaType, params, err := mime.ParseMediaType(attachment.ContentType); err == nil && strings.Contains(mediaType, "/") {
+		return mime.FormatMediaType(mediaType, params)
+	}
+	if byExt := mime.TypeByExtension(path.Ext(attachment.Filename)); byExt != "" {
+		return byExt
+	}
+	return "application/octet-stream"
+}
+
+// contentDisposition 生成 Content-Disposition，非 ASCII 文件名按 RFC 2231 编码
+func contentDisposition(disposition, filename string) string {
+	if filename != "" {
+		if value := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); value != "" {
+			return value
+		}
+	}
+	return disposition
+}
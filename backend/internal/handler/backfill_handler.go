@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"fusionmail/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackfillHandler 历史邮件回填处理器
+type BackfillHandler struct {
+	backfillService service.BackfillService
+}
+
+// NewBackfillHandler 创建历史邮件回填处理器
+func NewBackfillHandler(backfillService service.BackfillService) *BackfillHandler {
+	return &BackfillHandler{
+		backfillService: backfillService,
+	}
+}
+
+// StartBackfillRequest 启动回填任务请求（字段均可选，未指定时使用服务端配置）
+type StartBackfillRequest struct {
+	PageSize    int `json:"page_size" binding:"omitempty,min=1,max=500"`
+	PageDelayMs int `json:"page_delay_ms" binding:"omitempty,min=0"`
+}
+
+// Start 启动历史邮件回填
+// POST /api/v1/accounts/:uid/backfill
+func (h *BackfillHandler) Start(c *gin.Context) {
+	var req StartBackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	progress, err := h.backfillService.Start(c.Request.Context(), c.Param("uid"), service.BackfillOptions{
+		PageSize:  req.PageSize,
+		PageDelay: time.Duration(req.PageDelayMs) * time.Millisecond,
+	})
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "回填任务已启动",
+		"data":    progress,
+	})
+}
+
+// Get 获取回填进度
+// GET /api/v1/accounts/:uid/backfill
+func (h *BackfillHandler) Get(c *gin.Context) {
+	progress, err := h.backfillService.Get(c.Request.Context(), c.Param("uid"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    progress,
+	})
+}
+
+// Pause 暂停回填
+// POST /api/v1/accounts/:uid/backfill/pause
+func (h *BackfillHandler) Pause(c *gin.Context) {
+	progress, err := h.backfillService.Pause(c.Request.Context(), c.Param("uid"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "回填任务已暂停",
+		"data":    progress,
+	})
+}
+
+// Resume 继续回填
+// POST /api/v1/accounts/:uid/backfill/resume
+func (h *BackfillHandler) Resume(c *gin.Context) {
+	progress, err := h.backfillService.Resume(c.Request.Context(), c.Param("uid"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "回填任务已继续",
+		"data":    progress,
+	})
+}
+
+// Cancel 取消回填
+// DELETE /api/v1/accounts/:uid/backfill
+func (h *BackfillHandler) Cancel(c *gin.Context) {
+	progress, err := h.backfillService.Cancel(c.Request.Context(), c.Param("uid"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "回填任务已取消",
+		"data":    progress,
+	})
+}
+
+// respondError 根据错误类型返回对应的状态码
+func (h *BackfillHandler) respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrBackfillNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrBackfillActive), errors.Is(err, service.ErrBackfillInvalidState):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrBackfillNotSupported):
+		status = http.StatusBadRequest
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fusionmail/internal/repository"
 	"fusionmail/internal/service"
 	"net/http"
@@ -23,7 +24,7 @@ func NewEmailHandler(emailService service.EmailService) *EmailHandler {
 
 // GetEmailList 获取邮件列表
 // @Summary 获取邮件列表
-// @Description 获取邮件列表，支持分页、筛选和排序
+// @Description 获取邮件列表摘要（不含正文），按发送时间倒序，支持游标分页和筛选
 // @Tags emails
 // @Accept json
 // @Produce json
@@ -31,11 +32,13 @@ func NewEmailHandler(emailService service.EmailService) *EmailHandler {
 // @Param is_read query bool false "是否已读"
 // @Param is_starred query bool false "是否星标"
 // @Param is_archived query bool false "是否归档"
+// @Param source_deleted query bool false "是否已在源邮箱删除"
+// @Param collapse_duplicates query bool false "折叠跨账户重复邮件，每组只返回一封（按账户筛选时不生效）"
 // @Param from_address query string false "发件人地址（模糊匹配）"
 // @Param subject query string false "主题（模糊匹配）"
 // @Param start_date query string false "开始日期（YYYY-MM-DD）"
 // @Param end_date query string false "结束日期（YYYY-MM-DD）"
-// @Param page query int false "页码（默认 1）"
+// @Param cursor query string false "分页游标（上一页返回的 next_cursor，为空时返回第一页）"
 // @Param page_size query int false "每页数量（默认 20，最大 100）"
 // @Success 200 {object} service.EmailListResponse
 // @Router /api/v1/emails [get]
@@ -62,22 +65,24 @@ func (h *EmailHandler) GetEmailList(c *gin.Context) {
 		isArchived := isArchivedStr == "true"
 		filter.IsArchived = &isArchived
 	}
+	if sourceDeletedStr := c.Query("source_deleted"); sourceDeletedStr != "" {
+		sourceDeleted := sourceDeletedStr == "true"
+		filter.SourceDeleted = &sourceDeleted
+	}
+	filter.CollapseDuplicates = c.Query("collapse_duplicates") == "true"
 
 	// 默认不显示已删除的邮件
 	isDeleted := false
 	filter.IsDeleted = &isDeleted
 
 	// 解析分页参数
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	cursor := c.Query("cursor")
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
 	// 调用服务层
-	result, err := h.emailService.GetEmailList(c.Request.Context(), filter, page, pageSize)
+	result, err := h.emailService.GetEmailList(c.Request.Context(), filter, cursor, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
+		h.respondListError(c, err)
 		return
 	}
 
@@ -89,7 +94,7 @@ func (h *EmailHandler) GetEmailList(c *gin.Context) {
 
 // GetEmailByID 获取邮件详情
 // @Summary 获取邮件详情
-// @Description 根据 ID 获取邮件的完整信息，包括附件
+// @Description 根据 ID 获取邮件的完整信息，包括正文和附件（列表接口不返回正文）
 // @Tags emails
 // @Accept json
 // @Produce json
@@ -138,7 +143,8 @@ func (h *EmailHandler) GetEmailByID(c *gin.Context) {
 // @Produce json
 // @Param q query string true "搜索关键词"
 // @Param account_uid query string false "账户 UID"
-// @Param page query int false "页码（默认 1）"
+// @Param collapse_duplicates query bool false "折叠跨账户重复邮件，每组只返回一封（按账户筛选时不生效）"
+// @Param cursor query string false "分页游标（上一页返回的 next_cursor，为空时返回第一页）"
 // @Param page_size query int false "每页数量（默认 20，最大 100）"
 // @Success 200 {object} service.EmailListResponse
 // @Router /api/v1/emails/search [get]
@@ -154,16 +160,14 @@ func (h *EmailHandler) SearchEmails(c *gin.Context) {
 	}
 
 	accountUID := c.Query("account_uid")
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	collapseDuplicates := c.Query("collapse_duplicates") == "true"
+	cursor := c.Query("cursor")
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
 	// 调用服务层
-	result, err := h.emailService.SearchEmails(c.Request.Context(), query, accountUID, page, pageSize)
+	result, err := h.emailService.SearchEmails(c.Request.Context(), query, accountUID, collapseDuplicates, cursor, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
+		h.respondListError(c, err)
 		return
 	}
 
@@ -173,6 +177,19 @@ func (h *EmailHandler) SearchEmails(c *gin.Context) {
 	})
 }
 
+// respondListError 返回列表查询错误，游标无效时返回 400
+func (h *EmailHandler) respondListError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, service.ErrInvalidCursor) {
+		status = http.StatusBadRequest
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
 // MarkAsReadRequest 标记已读请求
 type MarkAsReadRequest struct {
 	IDs []int64 `json:"ids" binding:"required"`
@@ -312,7 +329,7 @@ func (h *EmailHandler) ArchiveEmail(c *gin.Context) {
 
 // DeleteEmail 删除邮件
 // @Summary 删除邮件
-// @Description 删除邮件（软删除，仅本地状态）
+// @Description 把邮件移入回收站（仅本地状态），回收站中的邮件超过保留天数后自动永久删除
 // @Tags emails
 // @Accept json
 // @Produce json
@@ -343,6 +360,171 @@ func (h *EmailHandler) DeleteEmail(c *gin.Context) {
 	})
 }
 
+// GetTrash 获取回收站邮件列表
+// @Summary 获取回收站邮件列表
+// @Description 获取回收站中的邮件摘要（含移入时间），按发送时间倒序，支持游标分页
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param account_uid query string false "账户 UID"
+// @Param cursor query string false "分页游标（上一页返回的 next_cursor，为空时返回第一页）"
+// @Param page_size query int false "每页数量（默认 20，最大 100）"
+// @Success 200 {object} service.EmailListResponse
+// @Router /api/v1/emails/trash [get]
+func (h *EmailHandler) GetTrash(c *gin.Context) {
+	isDeleted := true
+	filter := &repository.EmailFilter{
+		AccountUID: c.Query("account_uid"),
+		IsDeleted:  &isDeleted,
+	}
+
+	cursor := c.Query("cursor")
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.emailService.GetEmailList(c.Request.Context(), filter, cursor, pageSize)
+	if err != nil {
+		h.respondListError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// RestoreEmail 恢复邮件
+// @Summary 恢复邮件
+// @Description 把邮件移出回收站
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param id path int true "邮件 ID"
+// @Success 200 {object} map[string]string
+// @Router /api/v1/emails/{id}/restore [post]
+func (h *EmailHandler) RestoreEmail(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid email id",
+		})
+		return
+	}
+
+	if err := h.emailService.RestoreEmails(c.Request.Context(), []int64{id}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "email restored",
+	})
+}
+
+// RestoreEmails 批量恢复邮件
+// @Summary 批量恢复邮件
+// @Description 把多封邮件移出回收站
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param body body MarkAsReadRequest true "邮件 ID 列表"
+// @Success 200 {object} map[string]string
+// @Router /api/v1/emails/restore [post]
+func (h *EmailHandler) RestoreEmails(c *gin.Context) {
+	var req MarkAsReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if err := h.emailService.RestoreEmails(c.Request.Context(), req.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "emails restored",
+	})
+}
+
+// PurgeEmail 永久删除邮件
+// @Summary 永久删除邮件
+// @Description 永久删除回收站中的邮件，同时删除附件和原文文件（不在回收站中的邮件返回 409）
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param id path int true "邮件 ID"
+// @Success 200 {object} map[string]string
+// @Router /api/v1/emails/{id}/permanent [delete]
+func (h *EmailHandler) PurgeEmail(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid email id",
+		})
+		return
+	}
+
+	if err := h.emailService.PurgeEmail(c.Request.Context(), id); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrEmailNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrEmailNotInTrash):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "email permanently deleted",
+	})
+}
+
+// EmptyTrash 清空回收站
+// @Summary 清空回收站
+// @Description 永久删除回收站中的全部邮件及其附件和原文文件，不指定账户时清空所有账户
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param account_uid query string false "账户 UID"
+// @Success 200 {object} map[string]int
+// @Router /api/v1/emails/trash [delete]
+func (h *EmailHandler) EmptyTrash(c *gin.Context) {
+	purged, err := h.emailService.EmptyTrash(c.Request.Context(), c.Query("account_uid"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"purged":  purged,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"purged":  purged,
+	})
+}
+
 // GetUnreadCount 获取未读邮件数
 // @Summary 获取未读邮件数
 // @Description 获取指定账户或全部账户的未读邮件数
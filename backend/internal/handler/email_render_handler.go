@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fusionmail/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmailRenderHandler 邮件正文渲染处理器
+type EmailRenderHandler struct {
+	renderService *service.EmailRenderService
+	imageProxy    *service.ImageProxyService
+}
+
+// NewEmailRenderHandler 创建邮件正文渲染处理器
+// imageProxy 可以为 nil，此时图片代理接口返回 404
+func NewEmailRenderHandler(renderService *service.EmailRenderService, imageProxy *service.ImageProxyService) *EmailRenderHandler {
+	return &EmailRenderHandler{
+		renderService: renderService,
+		imageProxy:    imageProxy,
+	}
+}
+
+// Render 获取清理后可安全显示的邮件正文
+// GET /api/v1/emails/:id/render?remote_images=true
+// 默认阻止外部图片，remote_images=true 时经图片代理加载
+func (h *EmailRenderHandler) Render(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的邮件 ID",
+		})
+		return
+	}
+	remoteImages, _ := strconv.ParseBool(c.Query("remote_images"))
+
+	rendered, err := h.renderService.Render(c.Request.Context(), id, service.RenderOptions{RemoteImages: remoteImages})
+	if errors.Is(err, service.ErrEmailNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "邮件不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "渲染邮件正文失败",
+		})
+		return
+	}
+
+	// 正文中的签名链接有有效期，不缓存
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    rendered,
+	})
+}
+
+// ProxyImage 通过图片代理获取邮件中的外部图片（无需认证，地址由渲染接口签名生成）
+// GET /api/v1/files/images?url=...&signature=...
+func (h *EmailRenderHandler) ProxyImage(c *gin.Context) {
+	if h.imageProxy == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "图片代理未启用",
+		})
+		return
+	}
+
+	image, err := h.imageProxy.Fetch(c.Request.Context(), c.Query("url"), c.Query("signature"))
+	switch {
+	case errors.Is(err, service.ErrImageProxyInvalid):
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "图片代理地址无效",
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"error":   "无法获取外部图片",
+		})
+		return
+	}
+
+	// 内容类型已按嗅探结果校验，禁止浏览器再次猜测；图片不需要发送来源页面
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, image.ContentType, image.Data)
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"fusionmail/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RawMessageHandler 原始邮件处理器
+type RawMessageHandler struct {
+	rawMessageService *service.RawMessageService
+}
+
+// NewRawMessageHandler 创建原始邮件处理器
+func NewRawMessageHandler(rawMessageService *service.RawMessageService) *RawMessageHandler {
+	return &RawMessageHandler{
+		rawMessageService: rawMessageService,
+	}
+}
+
+// DownloadRaw 下载邮件原文（.eml）
+// GET /api/v1/emails/:id/raw
+func (h *RawMessageHandler) DownloadRaw(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的邮件 ID",
+		})
+		return
+	}
+
+	reader, email, err := h.rawMessageService.Open(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", "message/rfc822")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%d.eml\"", email.ID))
+
+	// 流式传输解压后的原文
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		// 如果传输失败，记录错误（但此时已经开始发送响应，无法返回 JSON 错误）
+		fmt.Printf("Error streaming raw message: %v\n", err)
+	}
+}
+
+// GetHeaders 获取邮件原文的全部头部字段
+// GET /api/v1/emails/:id/headers
+func (h *RawMessageHandler) GetHeaders(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的邮件 ID",
+		})
+		return
+	}
+
+	headers, err := h.rawMessageService.Headers(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    headers,
+	})
+}
+
+// respondError 返回原文读取错误
+func (h *RawMessageHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrEmailNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "邮件不存在",
+		})
+	case errors.Is(err, service.ErrRawMessageNotArchived):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "邮件没有归档原文",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "读取邮件原文失败",
+		})
+	}
+}
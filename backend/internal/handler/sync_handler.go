@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"fusionmail/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SyncHandler 同步任务处理器
+type SyncHandler struct {
+	syncManager *service.SyncManager
+}
+
+// NewSyncHandler 创建同步任务处理器
+func NewSyncHandler(syncManager *service.SyncManager) *SyncHandler {
+	return &SyncHandler{
+		syncManager: syncManager,
+	}
+}
+
+// SyncAccount 提交账户同步，立即返回同步任务 ID
+// POST /api/v1/sync/accounts/:uid
+func (h *SyncHandler) SyncAccount(c *gin.Context) {
+	job, err := h.syncManager.SyncAccount(c.Request.Context(), c.Param("uid"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "同步任务已启动",
+		"data":    job,
+	})
+}
+
+// GetLatestJob 获取账户最近的同步任务
+// GET /api/v1/sync/accounts/:uid/job
+func (h *SyncHandler) GetLatestJob(c *gin.Context) {
+	job, err := h.syncManager.LatestSyncJob(c.Request.Context(), c.Param("uid"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// GetJob 获取同步任务进度
+// GET /api/v1/sync/jobs/:id
+func (h *SyncHandler) GetJob(c *gin.Context) {
+	job, err := h.syncManager.SyncJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// StreamJob 以 Server-Sent Events 推送同步任务进度，任务结束后关闭连接
+// GET /api/v1/sync/jobs/:id/events
+func (h *SyncHandler) StreamJob(c *gin.Context) {
+	updates, err := h.syncManager.WatchSyncJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	// 长连接不受服务器写超时限制
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		c.Error(err)
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		job, ok := <-updates
+		if !ok {
+			return false
+		}
+
+		event := "progress"
+		if job.Finished() {
+			event = job.Status
+		}
+		c.SSEvent(event, job)
+		return true
+	})
+}
+
+// CancelJob 取消同步任务
+// DELETE /api/v1/sync/jobs/:id
+func (h *SyncHandler) CancelJob(c *gin.Context) {
+	job, err := h.syncManager.CancelSyncJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "已请求取消同步任务",
+		"data":    job,
+	})
+}
+
+// respondError 根据错误类型返回对应的状态码
+func (h *SyncHandler) respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrSyncJobNotFound), errors.Is(err, service.ErrAccountNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrSyncJobFinished):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ThreadHandler 会话处理器
+type ThreadHandler struct {
+	threadService service.ThreadService
+}
+
+// NewThreadHandler 创建会话处理器
+func NewThreadHandler(threadService service.ThreadService) *ThreadHandler {
+	return &ThreadHandler{
+		threadService: threadService,
+	}
+}
+
+// ListThreads 获取会话列表
+// @Summary 获取会话列表
+// @Description 获取跨账户归并的会话列表，按最新邮件时间倒序
+// @Tags threads
+// @Accept json
+// @Produce json
+// @Param account_uid query string false "只返回包含该账户邮件的会话"
+// @Param is_archived query bool false "是否已归档"
+// @Param unread query bool false "是否有未读邮件"
+// @Param page query int false "页码（默认 1）"
+// @Param page_size query int false "每页数量（默认 20，最大 100）"
+// @Success 200 {object} service.ThreadListResponse
+// @Router /api/v1/threads [get]
+func (h *ThreadHandler) ListThreads(c *gin.Context) {
+	filter := &repository.ThreadFilter{
+		AccountUID: c.Query("account_uid"),
+	}
+	if isArchivedStr := c.Query("is_archived"); isArchivedStr != "" {
+		isArchived := isArchivedStr == "true"
+		filter.IsArchived = &isArchived
+	}
+	if unreadStr := c.Query("unread"); unreadStr != "" {
+		unread := unreadStr == "true"
+		filter.Unread = &unread
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	result, err := h.threadService.ListThreads(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetThread 获取会话详情
+// @Summary 获取会话详情
+// @Description 获取会话信息和会话中按时间排列的邮件
+// @Tags threads
+// @Accept json
+// @Produce json
+// @Param id path int true "会话 ID"
+// @Success 200 {object} service.ThreadDetail
+// @Router /api/v1/threads/{id} [get]
+func (h *ThreadHandler) GetThread(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	detail, err := h.threadService.GetThread(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    detail,
+	})
+}
+
+// MarkAsRead 将会话标记为已读
+// POST /api/v1/threads/:id/read
+func (h *ThreadHandler) MarkAsRead(c *gin.Context) {
+	h.updateThread(c, h.threadService.MarkAsRead, "thread marked as read")
+}
+
+// MarkAsUnread 将会话标记为未读
+// POST /api/v1/threads/:id/unread
+func (h *ThreadHandler) MarkAsUnread(c *gin.Context) {
+	h.updateThread(c, h.threadService.MarkAsUnread, "thread marked as unread")
+}
+
+// Archive 归档会话
+// POST /api/v1/threads/:id/archive
+func (h *ThreadHandler) Archive(c *gin.Context) {
+	h.updateThread(c, h.threadService.Archive, "thread archived")
+}
+
+// updateThread 执行会话级状态变更
+func (h *ThreadHandler) updateThread(c *gin.Context, update func(ctx context.Context, id int64) error, message string) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := update(c.Request.Context(), id); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+	})
+}
+
+// parseID 解析会话 ID，无效时直接返回 400
+func (h *ThreadHandler) parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "invalid thread id",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondError 根据错误类型返回对应的状态码
+func (h *ThreadHandler) respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, service.ErrThreadNotFound) {
+		status = http.StatusNotFound
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
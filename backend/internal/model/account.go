@@ -41,6 +41,23 @@ type Account struct {
 	LastSyncStatus string     `gorm:"size:20" json:"last_sync_status"` // success/failed/running
 	LastSyncError  string     `gorm:"type:text" json:"last_sync_error"`
 
+	// 同步失败跟踪（连续失败时指数退避，超过阈值后 Status 置为 error）
+	ConsecutiveFailures int        `gorm:"default:0" json:"consecutive_failures"`    // 连续失败次数
+	SyncErrorCode       string     `gorm:"size:20" json:"sync_error_code,omitempty"` // 失败原因分类 (auth/network/tls/quota/parse/unknown)
+	QuarantinedAt       *time.Time `json:"quarantined_at,omitempty"`                 // 被自动隔离的时间
+
+	// 附件设置
+	MaxAttachmentMB int `gorm:"default:0" json:"max_attachment_mb"` // 单个附件大小上限（MB），0 表示使用全局默认值
+
+	// 保留策略（0 表示使用全局默认值，-1 表示该账户不清理）
+	TrashRetentionDays      int `gorm:"default:0" json:"trash_retention_days"`      // 回收站邮件保留天数，超过后永久删除
+	ArchiveRetentionDays    int `gorm:"default:0" json:"archive_retention_days"`    // 归档邮件保留天数，超过后移入回收站
+	AttachmentRetentionDays int `gorm:"default:0" json:"attachment_retention_days"` // 大附件内容保留天数，超过后只保留元数据
+	AttachmentRetentionMB   int `gorm:"default:0" json:"attachment_retention_mb"`   // 大附件阈值（MB），不小于该大小的附件按保留天数清理
+
+	// 源邮箱对账（定期比对服务器上的邮件清单，发现已删除或移动的邮件）
+	LastReconciledAt *time.Time `json:"last_reconciled_at,omitempty"` // 上次对账时间
+
 	// 统计信息
 	TotalEmails int `gorm:"default:0" json:"total_emails"`
 	UnreadCount int `gorm:"default:0" json:"unread_count"`
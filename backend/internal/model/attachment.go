@@ -15,8 +15,9 @@ type EmailAttachment struct {
 	SizeBytes   int64  `gorm:"not null" json:"size_bytes"`
 
 	// 存储信息
-	StorageType string `gorm:"size:20;default:'local'" json:"storage_type"` // local/s3/oss
+	StorageType string `gorm:"size:20;default:'local'" json:"storage_type"` // local/s3/oss，none 表示超过大小限制未保存内容
 	StoragePath string `gorm:"type:text;not null" json:"storage_path"`      // 存储路径或 URL
+	BlobHash    string `gorm:"size:64;index" json:"-"`                      // 内容 SHA-256，引用 attachment_blobs；为空表示去重上线前按邮件保存的文件
 	URL         string `gorm:"-" json:"url"`                                // 访问 URL（不存储在数据库）
 
 	// 元数据
@@ -30,3 +31,21 @@ type EmailAttachment struct {
 func (EmailAttachment) TableName() string {
 	return "email_attachments"
 }
+
+// AttachmentBlob 附件内容（按 SHA-256 内容寻址，相同内容只存储一份）
+// RefCount 随附件记录的创建和删除维护；邮件级联删除附件记录时不会扣减，由垃圾回收按实际引用修正
+type AttachmentBlob struct {
+	SHA256      string `gorm:"primaryKey;size:64" json:"sha256"`
+	SizeBytes   int64  `gorm:"not null" json:"size_bytes"`
+	StorageType string `gorm:"size:20;not null" json:"storage_type"`
+	StoragePath string `gorm:"type:text;not null" json:"storage_path"`
+	RefCount    int64  `gorm:"not null;default:0" json:"ref_count"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `gorm:"index" json:"updated_at"` // 最近一次引用或准备上传的时间，垃圾回收跳过近期更新的内容
+}
+
+// TableName 指定表名
+func (AttachmentBlob) TableName() string {
+	return "attachment_blobs"
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+)
+
+// BackfillJob 历史邮件回填任务模型
+// 从最新邮件开始向前逐页导入首次同步之外的历史邮件，游标持久化以便中断后继续
+type BackfillJob struct {
+	ID         int64  `gorm:"primaryKey" json:"id"`
+	AccountUID string `gorm:"size:64;not null;index" json:"account_uid"`
+
+	// 任务状态
+	Status     string `gorm:"size:20;not null;index" json:"status"` // pending/running/paused/completed/cancelled/failed
+	NextCursor string `gorm:"type:text" json:"-"`                   // 下一页游标（适配器定义的格式）
+	LastError  string `gorm:"type:text" json:"last_error"`
+
+	// 节流配置
+	PageSize    int `gorm:"default:100" json:"page_size"`      // 每页邮件数
+	PageDelayMs int `gorm:"default:2000" json:"page_delay_ms"` // 两页之间的间隔（毫秒）
+
+	// 进度统计
+	EmailsProcessed int   `gorm:"default:0" json:"emails_processed"` // 已处理邮件数
+	EmailsNew       int   `gorm:"default:0" json:"emails_new"`       // 新导入邮件数
+	Estimated       int   `gorm:"default:0" json:"estimated"`        // 邮箱邮件总数估计（0 表示未知）
+	ActiveMs        int64 `gorm:"default:0" json:"active_ms"`        // 累计运行时间（不含暂停），用于估算剩余时间
+
+	// 时间信息
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (BackfillJob) TableName() string {
+	return "backfill_jobs"
+}
+
+// IsActive 任务是否仍在进行（未完成、未取消、未失败）
+func (j *BackfillJob) IsActive() bool {
+	return j.Status == "pending" || j.Status == "running" || j.Status == "paused"
+}
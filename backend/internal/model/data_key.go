@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+)
+
+// DataKey 信封加密的数据密钥
+// 每个账户一个数据密钥，加密邮件正文并包装附件和原文的文件密钥；
+// owner 为 _storage 的数据密钥用于不属于账户的文件（图片代理缓存）和早期加密的文件
+// WrappedKey 是被主密钥（ENCRYPTION_KEY）加密后的数据密钥，数据库中不保存明文密钥
+type DataKey struct {
+	Owner      string    `gorm:"primaryKey;size:64" json:"owner"` // 账户 UID 或 _storage
+	WrappedKey string    `gorm:"type:text;not null" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (DataKey) TableName() string {
+	return "data_keys"
+}
+
+// EmailSearchToken 加密正文的搜索索引（盲索引）
+// 正文加密后无法用 ILIKE 搜索，写入时把正文分词并用账户数据密钥做 HMAC，只保存词的哈希
+type EmailSearchToken struct {
+	EmailID   int64  `gorm:"primaryKey" json:"email_id"`
+	TokenHash string `gorm:"primaryKey;size:32;index" json:"token_hash"`
+}
+
+// TableName 指定表名
+func (EmailSearchToken) TableName() string {
+	return "email_search_tokens"
+}
@@ -6,7 +6,7 @@ import (
 
 // Email 邮件主表模型
 type Email struct {
-	ID int64 `gorm:"primaryKey" json:"id"`
+	ID int64 `gorm:"primaryKey;index:idx_sent_at_id,priority:2,sort:desc" json:"id"`
 
 	// 唯一标识（Provider ID + Account UID）
 	ProviderID string `gorm:"size:255;not null;uniqueIndex:idx_provider_account" json:"provider_id"` // 邮箱服务商原生 ID
@@ -27,6 +27,7 @@ type Email struct {
 	TextBody string `gorm:"type:text" json:"text_body"` // 纯文本正文
 	HTMLBody string `gorm:"type:text" json:"html_body"` // HTML 正文
 	Snippet  string `gorm:"type:text" json:"snippet"`   // 摘要（前 200 字符）
+	BodyHash string `gorm:"size:64" json:"-"`           // 正文摘要（启用加密时为账户密钥的 HMAC，同步时据此判断加密正文是否变化；明文保存时为空）
 
 	// 本地状态（只读镜像模式）
 	IsRead      bool   `gorm:"default:false;index" json:"is_read"`     // 本地已读状态
@@ -37,27 +38,44 @@ type Email struct {
 	LocalLabels string `gorm:"type:text" json:"local_labels"`          // 本地标签（JSON 数组，兼容字段）
 	Folder      string `gorm:"size:255" json:"folder"`                 // 本地文件夹
 
+	// 回收站（IsDeleted 为 true 时记录移入时间，保留期从此时开始计算）
+	TrashedAt *time.Time `gorm:"index" json:"trashed_at,omitempty"`
+
 	// 源邮箱状态（只读，不修改）
 	SourceIsRead *bool  `json:"source_is_read"`                 // 源邮箱已读状态
 	SourceLabels string `gorm:"type:text" json:"source_labels"` // 源邮箱标签（JSON 数组）
 	SourceFolder string `gorm:"size:255" json:"source_folder"`  // 源邮箱文件夹
 
+	// 源邮箱删除状态（对账时发现邮件已不在源邮箱中）
+	SourceDeleted   bool       `gorm:"default:false;index" json:"source_deleted"` // 已在源邮箱删除
+	SourceDeletedAt *time.Time `json:"source_deleted_at,omitempty"`               // 发现已删除的时间
+
+	// 跨账户重复邮件（同一封邮件抄送到多个聚合账户）
+	DedupKey    string `gorm:"size:255;index" json:"dedup_key,omitempty"` // 去重键：规范化的 Message-ID，缺失时为内容哈希
+	CanonicalID *int64 `gorm:"index" json:"canonical_id,omitempty"`       // 重复组主副本的邮件 ID，为空表示本身是主副本
+
 	// 附件信息
 	HasAttachment    bool `gorm:"default:false;index" json:"has_attachment"` // 是否有附件（用于规则匹配）
 	HasAttachments   bool `gorm:"default:false" json:"has_attachments"`
 	AttachmentsCount int  `gorm:"default:0" json:"attachments_count"`
 
 	// 时间信息
-	SentAt     time.Time `gorm:"not null;index:idx_sent_at,sort:desc" json:"sent_at"` // 发送时间
-	ReceivedAt time.Time `gorm:"not null" json:"received_at"`                         // 接收时间
-	SyncedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"synced_at"`          // 同步时间
+	SentAt     time.Time `gorm:"not null;index:idx_sent_at_id,priority:1,sort:desc" json:"sent_at"` // 发送时间
+	ReceivedAt time.Time `gorm:"not null" json:"received_at"`                                       // 接收时间
+	SyncedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"synced_at"`                        // 同步时间
 
 	// 元数据
 	SizeBytes  int64  `json:"size_bytes"`                  // 邮件大小
-	ThreadID   string `gorm:"size:255" json:"thread_id"`   // 会话 ID
+	ThreadID   string `gorm:"size:255" json:"thread_id"`   // 服务商会话 ID（Gmail）
 	InReplyTo  string `gorm:"size:255" json:"in_reply_to"` // 回复的邮件 ID
 	References string `gorm:"type:text" json:"references"` // 引用的邮件 ID 列表
 
+	// 原始邮件归档（gzip 压缩的 RFC 822 原文，保存在存储提供者中）
+	RawPath string `gorm:"size:255" json:"-"` // 存储路径，为空表示未归档（适配器无法取得原文或归档上线前入库）
+
+	// 本地会话（跨账户按引用关系归并，见 threads 表）
+	LocalThreadID *int64 `gorm:"index" json:"local_thread_id,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
@@ -69,3 +87,53 @@ type Email struct {
 func (Email) TableName() string {
 	return "emails"
 }
+
+// EmailSummary 邮件列表投影（不含正文和收件人列表，列表页只需要摘要）
+type EmailSummary struct {
+	ID          int64  `json:"id"`
+	ProviderID  string `json:"provider_id"`
+	AccountUID  string `json:"account_uid"`
+	MessageID   string `json:"message_id"`
+	Subject     string `json:"subject"`
+	FromAddress string `json:"from_address"`
+	FromName    string `json:"from_name"`
+	ToAddress   string `json:"to_address"`
+	Snippet     string `json:"snippet"`
+
+	IsRead     bool       `json:"is_read"`
+	IsStarred  bool       `json:"is_starred"`
+	IsArchived bool       `json:"is_archived"`
+	IsDeleted  bool       `json:"is_deleted"`
+	TrashedAt  *time.Time `json:"trashed_at,omitempty"`
+	Labels     string     `json:"labels"`
+
+	SourceDeleted bool   `json:"source_deleted"`
+	CanonicalID   *int64 `json:"canonical_id,omitempty"`
+	LocalThreadID *int64 `json:"local_thread_id,omitempty"`
+	ThreadID      string `json:"thread_id"`
+
+	HasAttachments   bool `json:"has_attachments"`
+	AttachmentsCount int  `json:"attachments_count"`
+
+	SentAt     time.Time `json:"sent_at"`
+	ReceivedAt time.Time `json:"received_at"`
+	SizeBytes  int64     `json:"size_bytes"`
+}
+
+// TableName 指定表名
+func (EmailSummary) TableName() string {
+	return "emails"
+}
+
+// PurgedEmail 永久删除邮件的墓碑记录
+// 同步和历史回填按 (account_uid, provider_id) 跳过这些邮件，避免源邮箱中仍存在的邮件被重新入库
+type PurgedEmail struct {
+	AccountUID string    `gorm:"primaryKey;size:64" json:"account_uid"`
+	ProviderID string    `gorm:"primaryKey;size:255" json:"provider_id"`
+	PurgedAt   time.Time `json:"purged_at"`
+}
+
+// TableName 指定表名
+func (PurgedEmail) TableName() string {
+	return "purged_emails"
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// MailboxCounter 邮件计数器
+// 按账户和源邮箱文件夹维护的邮件数，随邮件写入和本地状态变化在同一事务中增减，不含本地已删除的邮件
+type MailboxCounter struct {
+	AccountUID    string    `gorm:"primaryKey;size:64" json:"account_uid"`
+	Folder        string    `gorm:"primaryKey;size:255" json:"folder"` // 源邮箱文件夹，为空表示服务商没有文件夹信息
+	TotalCount    int64     `gorm:"default:0" json:"total_count"`
+	UnreadCount   int64     `gorm:"default:0" json:"unread_count"`
+	StarredCount  int64     `gorm:"default:0" json:"starred_count"`
+	ArchivedCount int64     `gorm:"default:0" json:"archived_count"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (MailboxCounter) TableName() string {
+	return "mailbox_counters"
+}
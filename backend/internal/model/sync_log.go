@@ -11,13 +11,17 @@ type SyncLog struct {
 
 	// 同步信息
 	SyncType string `gorm:"size:20;not null" json:"sync_type"`    // scheduled/manual
-	Status   string `gorm:"size:20;not null;index" json:"status"` // running/success/failed
+	Status   string `gorm:"size:20;not null;index" json:"status"` // running/success/failed/cancelled
 
 	// 统计信息
 	EmailsFetched int `gorm:"default:0" json:"emails_fetched"`
 	EmailsNew     int `gorm:"default:0" json:"emails_new"`
 	EmailsUpdated int `gorm:"default:0" json:"emails_updated"`
 
+	// 源邮箱对账统计
+	EmailsSourceDeleted int `gorm:"default:0" json:"emails_source_deleted"` // 新发现已在源邮箱删除的邮件数
+	EmailsMoved         int `gorm:"default:0" json:"emails_moved"`          // 源邮箱中文件夹发生变化的邮件数
+
 	// 时间信息
 	StartedAt   time.Time  `gorm:"not null;index:idx_started_at,sort:desc" json:"started_at"`
 	CompletedAt *time.Time `json:"completed_at"`
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+)
+
+// Thread 会话模型
+// 根据 Message-ID、In-Reply-To 和 References 跨账户归并的邮件会话，统计字段随邮件变化维护
+type Thread struct {
+	ID int64 `gorm:"primaryKey" json:"id"`
+
+	// 会话信息
+	Subject      string `gorm:"type:text" json:"subject"`               // 会话主题（最早一封邮件的主题）
+	SubjectKey   string `gorm:"size:255;index" json:"-"`                // 规范化主题（去掉 Re:/Fwd: 等前缀），用于没有引用头的回复归并
+	Participants string `gorm:"type:text" json:"participants"`          // 参与者地址（JSON 数组）
+	AccountUIDs  string `gorm:"type:text" json:"account_uids"`          // 涉及的账户 UID（JSON 数组）
+	IsArchived   bool   `gorm:"default:false;index" json:"is_archived"` // 会话内邮件是否全部已归档
+	MessageCount int    `gorm:"default:0" json:"message_count"`         // 邮件数（不含已删除和跨账户重复副本）
+	UnreadCount  int    `gorm:"default:0;index" json:"unread_count"`    // 未读邮件数
+
+	// 时间信息
+	LastMessageAt time.Time `gorm:"index:idx_threads_last_message_at,sort:desc" json:"last_message_at"` // 最新邮件发送时间
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Thread) TableName() string {
+	return "threads"
+}
+
+// ThreadRef 会话引用的 Message-ID
+// 记录会话中邮件自身及其引用的 Message-ID，晚到的父邮件或回复据此找到所属会话
+type ThreadRef struct {
+	MessageKey string `gorm:"primaryKey;size:255" json:"message_key"` // 规范化的 Message-ID
+	ThreadID   int64  `gorm:"not null;index" json:"thread_id"`
+}
+
+// TableName 指定表名
+func (ThreadRef) TableName() string {
+	return "thread_refs"
+}
@@ -19,6 +19,8 @@ type AccountRepository interface {
 	List(ctx context.Context, offset, limit int) ([]*model.Account, int64, error)
 	ListSyncEnabled(ctx context.Context) ([]*model.Account, error)
 	UpdateSyncStatus(ctx context.Context, uid string, status string, errorMsg string) error
+	UpdateSyncResult(ctx context.Context, account *model.Account) error
+	ResetSyncFailures(ctx context.Context, uid string) error
 	IncrementEmailCount(ctx context.Context, uid string, count int) error
 	UpdateUnreadCount(ctx context.Context, uid string, count int) error
 
@@ -82,9 +84,12 @@ func (r *accountRepository) FindByEmail(ctx context.Context, email string) (*mod
 	return &account, nil
 }
 
-// Update 更新账户
+// accountCounterColumns 由邮件写入事务增量维护的统计字段，整行保存账户时不能用内存中的旧值覆盖
+var accountCounterColumns = []string{"total_emails", "unread_count"}
+
+// Update 更新账户（不覆盖邮件统计字段）
 func (r *accountRepository) Update(ctx context.Context, account *model.Account) error {
-	return r.db.WithContext(ctx).Save(account).Error
+	return r.db.WithContext(ctx).Omit(accountCounterColumns...).Save(account).Error
 }
 
 // Delete 删除账户（软删除）
@@ -139,6 +144,36 @@ func (r *accountRepository) UpdateSyncStatus(ctx context.Context, uid string, st
 		Updates(updates).Error
 }
 
+// UpdateSyncResult 保存一次同步结束后的账户同步状态，只更新同步负责的字段
+func (r *accountRepository) UpdateSyncResult(ctx context.Context, account *model.Account) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Account{}).
+		Where("uid = ?", account.UID).
+		Updates(map[string]interface{}{
+			"status":               account.Status,
+			"last_sync_at":         account.LastSyncAt,
+			"last_sync_status":     account.LastSyncStatus,
+			"last_sync_error":      account.LastSyncError,
+			"consecutive_failures": account.ConsecutiveFailures,
+			"sync_error_code":      account.SyncErrorCode,
+			"quarantined_at":       account.QuarantinedAt,
+			"last_reconciled_at":   account.LastReconciledAt,
+		}).Error
+}
+
+// ResetSyncFailures 清除连续失败记录，被自动隔离（status=error）的账户恢复为 active
+func (r *accountRepository) ResetSyncFailures(ctx context.Context, uid string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Account{}).
+		Where("uid = ?", uid).
+		Updates(map[string]interface{}{
+			"consecutive_failures": 0,
+			"sync_error_code":      "",
+			"quarantined_at":       nil,
+			"status":               gorm.Expr("CASE WHEN status = ? THEN ? ELSE status END", "error", "active"),
+		}).Error
+}
+
 // IncrementEmailCount 增加邮件数量
 func (r *accountRepository) IncrementEmailCount(ctx context.Context, uid string, count int) error {
 	return r.db.WithContext(ctx).
@@ -2,12 +2,19 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"time"
 
 	"fusionmail/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrBlobReclaimed 创建附件引用时内容记录已被垃圾回收，需要重新登记并上传
+var ErrBlobReclaimed = errors.New("attachment blob was reclaimed")
+
 // AttachmentRepository 附件数据仓库
 type AttachmentRepository struct {
 	db *gorm.DB
@@ -48,16 +55,70 @@ func (r *AttachmentRepository) Update(ctx context.Context, attachment *model.Ema
 	return r.db.WithContext(ctx).Save(attachment).Error
 }
 
-// Delete 删除附件
-func (r *AttachmentRepository) Delete(ctx context.Context, id int64) error {
-	return r.db.WithContext(ctx).Delete(&model.EmailAttachment{}, id).Error
+// Delete 删除附件并释放其内容引用，返回引用数降为 0 的内容哈希
+func (r *AttachmentRepository) Delete(ctx context.Context, id int64) ([]string, error) {
+	return r.deleteAndRelease(ctx, "id = ?", id)
 }
 
-// DeleteByEmailID 删除邮件的所有附件
-func (r *AttachmentRepository) DeleteByEmailID(ctx context.Context, emailID int64) error {
-	return r.db.WithContext(ctx).
-		Where("email_id = ?", emailID).
-		Delete(&model.EmailAttachment{}).Error
+// DeleteByEmailID 删除邮件的所有附件并释放其内容引用，返回引用数降为 0 的内容哈希
+func (r *AttachmentRepository) DeleteByEmailID(ctx context.Context, emailID int64) ([]string, error) {
+	return r.deleteAndRelease(ctx, "email_id = ?", emailID)
+}
+
+// deleteAndRelease 在同一事务中删除附件记录并扣减内容引用数
+func (r *AttachmentRepository) deleteAndRelease(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	var released []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var hashes []string
+		err := tx.Model(&model.EmailAttachment{}).
+			Where(query, args...).
+			Where("COALESCE(blob_hash, '') <> ''").
+			Pluck("blob_hash", &hashes).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where(query, args...).Delete(&model.EmailAttachment{}).Error; err != nil {
+			return err
+		}
+
+		released, err = releaseBlobRefs(tx, hashes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+// releaseBlobRefs 按每个哈希出现的次数扣减内容引用数，返回引用数降为 0 的内容哈希
+func releaseBlobRefs(tx *gorm.DB, hashes []string) ([]string, error) {
+	// 按哈希顺序扣减，固定加锁顺序
+	refs := make(map[string]int64)
+	for _, hash := range hashes {
+		refs[hash]++
+	}
+	keys := make([]string, 0, len(refs))
+	for hash := range refs {
+		keys = append(keys, hash)
+	}
+	sort.Strings(keys)
+
+	var released []string
+	for _, hash := range keys {
+		var remaining []int64
+		err := tx.Raw(`UPDATE attachment_blobs
+			SET ref_count = CASE WHEN ref_count > ? THEN ref_count - ? ELSE 0 END
+			WHERE sha256 = ?
+			RETURNING ref_count`, refs[hash], refs[hash], hash).
+			Scan(&remaining).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(remaining) == 1 && remaining[0] == 0 {
+			released = append(released, hash)
+		}
+	}
+	return released, nil
 }
 
 // CountByEmailID 统计邮件的附件数量
@@ -69,3 +130,221 @@ func (r *AttachmentRepository) CountByEmailID(ctx context.Context, emailID int64
 		Count(&count).Error
 	return count, err
 }
+
+// ListStoragePaths 按路径顺序列出已保存内容的附件存储路径（after 之后的一页，去重后的内容只出现一次）
+func (r *AttachmentRepository) ListStoragePaths(ctx context.Context, after string, limit int) ([]string, error) {
+	var paths []string
+	err := r.db.WithContext(ctx).
+		Model(&model.EmailAttachment{}).
+		Distinct("storage_path").
+		Where("storage_type <> ? AND storage_path > ?", "none", after).
+		Order("storage_path ASC").
+		Limit(limit).
+		Pluck("storage_path", &paths).Error
+	return paths, err
+}
+
+// ListAccountUIDsByStoragePath 列出引用该存储路径的附件所属的账户 UID
+func (r *AttachmentRepository) ListAccountUIDsByStoragePath(ctx context.Context, storagePath string) ([]string, error) {
+	var accountUIDs []string
+	err := r.db.WithContext(ctx).
+		Table("email_attachments").
+		Joins("JOIN emails ON emails.id = email_attachments.email_id").
+		Where("email_attachments.storage_path = ?", storagePath).
+		Distinct("emails.account_uid").
+		Order("emails.account_uid ASC").
+		Pluck("emails.account_uid", &accountUIDs).Error
+	return accountUIDs, err
+}
+
+// TouchBlob 登记待上传的附件内容并返回当前记录
+// 内容不存在时插入（引用数为 0），已存在时只刷新更新时间，避免上传期间被垃圾回收
+func (r *AttachmentRepository) TouchBlob(ctx context.Context, blob *model.AttachmentBlob) (*model.AttachmentBlob, error) {
+	now := time.Now()
+	blob.RefCount = 0
+	blob.CreatedAt = now
+	blob.UpdatedAt = now
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "sha256"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"updated_at": now}),
+		}).
+		Create(blob).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var current model.AttachmentBlob
+	err = r.db.WithContext(ctx).Where("sha256 = ?", blob.SHA256).Take(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBlobReclaimed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &current, nil
+}
+
+// CreateWithBlob 创建引用内容的附件记录，并在同一事务中增加内容引用数
+// 内容记录已被回收时返回 ErrBlobReclaimed
+func (r *AttachmentRepository) CreateWithBlob(ctx context.Context, attachment *model.EmailAttachment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.AttachmentBlob{}).
+			Where("sha256 = ?", attachment.BlobHash).
+			Updates(map[string]interface{}{
+				"ref_count":  gorm.Expr("ref_count + 1"),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrBlobReclaimed
+		}
+		return tx.Create(attachment).Error
+	})
+}
+
+// ListUnreferencedBlobs 按哈希顺序列出 cutoff 之前更新、且没有附件记录引用的内容哈希（after 之后的一页）
+func (r *AttachmentRepository) ListUnreferencedBlobs(ctx context.Context, cutoff time.Time, after string, limit int) ([]string, error) {
+	var hashes []string
+	err := r.db.WithContext(ctx).
+		Model(&model.AttachmentBlob{}).
+		Where("updated_at < ? AND sha256 > ?", cutoff, after).
+		Where("NOT EXISTS (SELECT 1 FROM email_attachments a WHERE a.blob_hash = attachment_blobs.sha256)").
+		Order("sha256 ASC").
+		Limit(limit).
+		Pluck("sha256", &hashes).Error
+	return hashes, err
+}
+
+// ReclaimBlob 回收没有引用的内容：锁定内容记录，确认没有附件记录引用后调用 remove 删除存储对象，再删除记录
+// 只回收 cutoff 之前更新的记录；返回是否执行了回收
+// 与 TouchBlob、CreateWithBlob 竞争同一行锁，回收后并发的保存会收到 ErrBlobReclaimed 并重新上传
+func (r *AttachmentRepository) ReclaimBlob(ctx context.Context, hash string, cutoff time.Time, remove func(blob *model.AttachmentBlob) error) (bool, error) {
+	reclaimed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var blob model.AttachmentBlob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("sha256 = ? AND updated_at < ?", hash, cutoff).
+			Take(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// 加锁后重新检查引用（引用数可能因邮件级联删除而偏大，以实际引用为准）
+		var referenced bool
+		err = tx.Raw("SELECT EXISTS (SELECT 1 FROM email_attachments WHERE blob_hash = ?)", hash).Scan(&referenced).Error
+		if err != nil || referenced {
+			return err
+		}
+
+		if err := remove(&blob); err != nil {
+			return err
+		}
+		if err := tx.Delete(&blob).Error; err != nil {
+			return err
+		}
+		reclaimed = true
+		return nil
+	})
+	return reclaimed, err
+}
+
+// RecountBlobRefs 按实际附件记录修正 cutoff 之前更新的内容引用数，返回修正的记录数
+func (r *AttachmentRepository) RecountBlobRefs(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Exec(`UPDATE attachment_blobs
+		SET ref_count = (SELECT COUNT(*) FROM email_attachments a WHERE a.blob_hash = attachment_blobs.sha256)
+		WHERE updated_at < ?
+			AND ref_count <> (SELECT COUNT(*) FROM email_attachments a WHERE a.blob_hash = attachment_blobs.sha256)`, cutoff)
+	return result.RowsAffected, result.Error
+}
+
+// ListBlobs 按哈希顺序列出内容记录（after 之后的一页）；excludeType 不为空时跳过该存储类型的内容
+func (r *AttachmentRepository) ListBlobs(ctx context.Context, excludeType, after string, limit int) ([]*model.AttachmentBlob, error) {
+	var blobs []*model.AttachmentBlob
+	query := r.db.WithContext(ctx).Where("sha256 > ?", after)
+	if excludeType != "" {
+		query = query.Where("storage_type <> ?", excludeType)
+	}
+	err := query.Order("sha256 ASC").Limit(limit).Find(&blobs).Error
+	return blobs, err
+}
+
+// ListLegacyAttachments 按 ID 顺序列出去重上线前按邮件保存、已保存内容的附件（afterID 之后的一页）
+// excludeType 不为空时跳过该存储类型的附件
+func (r *AttachmentRepository) ListLegacyAttachments(ctx context.Context, excludeType string, afterID int64, limit int) ([]*model.EmailAttachment, error) {
+	var attachments []*model.EmailAttachment
+	query := r.db.WithContext(ctx).
+		Where("(blob_hash = '' OR blob_hash IS NULL) AND storage_type <> ? AND id > ?", "none", afterID)
+	if excludeType != "" {
+		query = query.Where("storage_type <> ?", excludeType)
+	}
+	err := query.Order("id ASC").Limit(limit).Find(&attachments).Error
+	return attachments, err
+}
+
+// MoveBlob 更新内容及引用它的附件记录的存储位置（不刷新内容的更新时间，不影响垃圾回收）
+func (r *AttachmentRepository) MoveBlob(ctx context.Context, hash, storageType, storagePath string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		location := map[string]interface{}{"storage_type": storageType, "storage_path": storagePath}
+		if err := tx.Model(&model.AttachmentBlob{}).Where("sha256 = ?", hash).UpdateColumns(location).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.EmailAttachment{}).Where("blob_hash = ?", hash).UpdateColumns(location).Error
+	})
+}
+
+// UpdateStorageLocation 更新附件记录的存储位置
+func (r *AttachmentRepository) UpdateStorageLocation(ctx context.Context, id int64, storageType, storagePath string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.EmailAttachment{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"storage_type": storageType, "storage_path": storagePath}).Error
+}
+
+// ListLargeAttachments 按 ID 顺序列出账户中 before 之前发送的邮件里不小于 minSize 字节、已保存内容的附件（afterID 之后的一页）
+// 跳过星标邮件的附件
+func (r *AttachmentRepository) ListLargeAttachments(ctx context.Context, accountUID string, minSize int64, before time.Time, afterID int64, limit int) ([]*model.EmailAttachment, error) {
+	var attachments []*model.EmailAttachment
+	err := r.db.WithContext(ctx).
+		Where("size_bytes >= ? AND storage_type <> ? AND id > ?", minSize, "none", afterID).
+		Where("email_id IN (SELECT id FROM emails WHERE account_uid = ? AND sent_at < ? AND is_starred = ?)", accountUID, before, false).
+		Order("id ASC").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+// ReleaseContent 释放附件内容、只保留元数据（与超过大小限制未保存内容的附件相同），返回引用数降为 0 的内容哈希
+func (r *AttachmentRepository) ReleaseContent(ctx context.Context, id int64) ([]string, error) {
+	var released []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var attachment model.EmailAttachment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND storage_type <> ?", id, "none").
+			Take(&attachment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&model.EmailAttachment{}).
+			Where("id = ?", id).
+			UpdateColumns(map[string]interface{}{"storage_type": "none", "storage_path": "", "blob_hash": ""}).Error
+		if err != nil || attachment.BlobHash == "" {
+			return err
+		}
+		released, err = releaseBlobRefs(tx, []string{attachment.BlobHash})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return released, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fusionmail/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// BackfillJobRepository 历史邮件回填任务数据仓库接口
+type BackfillJobRepository interface {
+	Create(ctx context.Context, job *model.BackfillJob) error
+	Update(ctx context.Context, job *model.BackfillJob) error
+	UpdateIfStatus(ctx context.Context, job *model.BackfillJob, statuses ...string) (bool, error)
+	FindByID(ctx context.Context, id int64) (*model.BackfillJob, error)
+	FindLatestByAccount(ctx context.Context, accountUID string) (*model.BackfillJob, error)
+	ListByStatus(ctx context.Context, statuses ...string) ([]*model.BackfillJob, error)
+}
+
+// backfillJobRepository 历史邮件回填任务数据仓库实现
+type backfillJobRepository struct {
+	db *gorm.DB
+}
+
+// NewBackfillJobRepository 创建历史邮件回填任务数据仓库实例
+func NewBackfillJobRepository(db *gorm.DB) BackfillJobRepository {
+	return &backfillJobRepository{db: db}
+}
+
+// Create 创建回填任务
+func (r *backfillJobRepository) Create(ctx context.Context, job *model.BackfillJob) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+// Update 更新回填任务（进度、游标和状态）
+func (r *backfillJobRepository) Update(ctx context.Context, job *model.BackfillJob) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
+
+// UpdateIfStatus 仅在数据库中的任务状态仍为 statuses 之一时保存任务，返回是否已保存
+// 其他实例可能已暂停或取消任务，条件更新避免用内存中的旧状态覆盖
+func (r *backfillJobRepository) UpdateIfStatus(ctx context.Context, job *model.BackfillJob, statuses ...string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(job).
+		Where("status IN ?", statuses).
+		Select("*").
+		Updates(job)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindByID 根据 ID 查找回填任务
+func (r *backfillJobRepository) FindByID(ctx context.Context, id int64) (*model.BackfillJob, error) {
+	var job model.BackfillJob
+	err := r.db.WithContext(ctx).First(&job, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// FindLatestByAccount 获取指定账户最近创建的回填任务
+func (r *backfillJobRepository) FindLatestByAccount(ctx context.Context, accountUID string) (*model.BackfillJob, error) {
+	var job model.BackfillJob
+	err := r.db.WithContext(ctx).
+		Where("account_uid = ?", accountUID).
+		Order("id DESC").
+		First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListByStatus 获取指定状态的回填任务
+func (r *backfillJobRepository) ListByStatus(ctx context.Context, statuses ...string) ([]*model.BackfillJob, error) {
+	var jobs []*model.BackfillJob
+	err := r.db.WithContext(ctx).
+		Where("status IN ?", statuses).
+		Order("id ASC").
+		Find(&jobs).Error
+	return jobs, err
+}
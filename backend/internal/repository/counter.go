@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"fusionmail/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CounterRepository 邮件计数器数据仓库接口
+// 计数器由 EmailRepository 在写入邮件的事务中维护，这里只负责读取和修复
+type CounterRepository interface {
+	// ListByAccount 获取账户各文件夹的计数器，accountUID 为空时返回全部账户
+	ListByAccount(ctx context.Context, accountUID string) ([]*model.MailboxCounter, error)
+	// Totals 汇总账户全部文件夹的计数，accountUID 为空时汇总全部账户
+	Totals(ctx context.Context, accountUID string) (*model.MailboxCounter, error)
+	// Recompute 根据邮件表重新计算全部计数器和账户统计字段
+	Recompute(ctx context.Context) error
+	// InitializeIfEmpty 计数器表为空但已有邮件时（计数器上线前的数据）执行一次重算，返回是否执行了重算
+	InitializeIfEmpty(ctx context.Context) (bool, error)
+}
+
+// counterRepository 邮件计数器数据仓库实现
+type counterRepository struct {
+	db *gorm.DB
+}
+
+// NewCounterRepository 创建邮件计数器数据仓库实例
+func NewCounterRepository(db *gorm.DB) CounterRepository {
+	return &counterRepository{db: db}
+}
+
+// ListByAccount 获取账户各文件夹的计数器
+func (r *counterRepository) ListByAccount(ctx context.Context, accountUID string) ([]*model.MailboxCounter, error) {
+	var counters []*model.MailboxCounter
+	query := r.db.WithContext(ctx).Where("total_count > 0")
+	if accountUID != "" {
+		query = query.Where("account_uid = ?", accountUID)
+	}
+	err := query.Order("account_uid ASC, folder ASC").Find(&counters).Error
+	return counters, err
+}
+
+// Totals 汇总账户全部文件夹的计数
+func (r *counterRepository) Totals(ctx context.Context, accountUID string) (*model.MailboxCounter, error) {
+	totals := &model.MailboxCounter{AccountUID: accountUID}
+	query := r.db.WithContext(ctx).
+		Model(&model.MailboxCounter{}).
+		Select("COALESCE(SUM(total_count), 0) AS total_count, " +
+			"COALESCE(SUM(unread_count), 0) AS unread_count, " +
+			"COALESCE(SUM(starred_count), 0) AS starred_count, " +
+			"COALESCE(SUM(archived_count), 0) AS archived_count")
+	if accountUID != "" {
+		query = query.Where("account_uid = ?", accountUID)
+	}
+	if err := query.Scan(totals).Error; err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// Recompute 重新计算全部计数器
+// 重算期间锁住计数器表，并发写入邮件的事务在更新计数器时等待重算提交，增量叠加在重算结果之上
+func (r *counterRepository) Recompute(ctx context.Context) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE mailbox_counters IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM mailbox_counters").Error; err != nil {
+			return err
+		}
+		err := tx.Exec(`INSERT INTO mailbox_counters (account_uid, folder, total_count, unread_count, starred_count, archived_count, updated_at)
+			SELECT account_uid, COALESCE(source_folder, ''), COUNT(*),
+				COUNT(*) FILTER (WHERE NOT is_read),
+				COUNT(*) FILTER (WHERE is_starred),
+				COUNT(*) FILTER (WHERE is_archived),
+				?
+			FROM emails
+			WHERE NOT is_deleted
+			GROUP BY account_uid, COALESCE(source_folder, '')`, time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE accounts SET
+			total_emails = COALESCE((SELECT SUM(total_count) FROM mailbox_counters c WHERE c.account_uid = accounts.uid), 0),
+			unread_count = COALESCE((SELECT SUM(unread_count) FROM mailbox_counters c WHERE c.account_uid = accounts.uid), 0)`).Error
+	})
+}
+
+// InitializeIfEmpty 计数器表为空但已有邮件时执行一次重算
+func (r *counterRepository) InitializeIfEmpty(ctx context.Context) (bool, error) {
+	var initialized bool
+	err := r.db.WithContext(ctx).
+		Raw("SELECT EXISTS (SELECT 1 FROM mailbox_counters) OR NOT EXISTS (SELECT 1 FROM emails)").
+		Scan(&initialized).Error
+	if err != nil || initialized {
+		return false, err
+	}
+	return true, r.Recompute(ctx)
+}
+
+// counterState 邮件中影响计数器的字段
+type counterState struct {
+	ID           int64
+	AccountUID   string
+	SourceFolder string
+	IsRead       bool
+	IsStarred    bool
+	IsArchived   bool
+	IsDeleted    bool
+}
+
+// counterKey 计数器主键
+type counterKey struct {
+	AccountUID string
+	Folder     string
+}
+
+// counterDelta 计数器增量
+type counterDelta struct {
+	Total    int64
+	Unread   int64
+	Starred  int64
+	Archived int64
+}
+
+// add 按邮件状态累加（sign 为 1）或扣减（sign 为 -1）计数，已删除的邮件不计入
+func (d *counterDelta) add(state counterState, sign int64) {
+	if state.IsDeleted {
+		return
+	}
+	d.Total += sign
+	if !state.IsRead {
+		d.Unread += sign
+	}
+	if state.IsStarred {
+		d.Starred += sign
+	}
+	if state.IsArchived {
+		d.Archived += sign
+	}
+}
+
+// isZero 判断增量是否为零
+func (d *counterDelta) isZero() bool {
+	return d.Total == 0 && d.Unread == 0 && d.Starred == 0 && d.Archived == 0
+}
+
+// counterDeltas 比较邮件变更前后的状态，计算各计数器的增量
+// before 中有而 after 中没有的邮件视为被删除，反之视为新增
+func counterDeltas(before, after []counterState) map[counterKey]*counterDelta {
+	deltas := make(map[counterKey]*counterDelta)
+	apply := func(states []counterState, sign int64) {
+		for _, state := range states {
+			key := counterKey{AccountUID: state.AccountUID, Folder: state.SourceFolder}
+			delta, ok := deltas[key]
+			if !ok {
+				delta = &counterDelta{}
+				deltas[key] = delta
+			}
+			delta.add(state, sign)
+		}
+	}
+	apply(before, -1)
+	apply(after, 1)
+
+	for key, delta := range deltas {
+		if delta.isZero() {
+			delete(deltas, key)
+		}
+	}
+	return deltas
+}
+
+// loadCounterStates 锁定并读取邮件中影响计数器的字段
+// 按 ID 顺序加锁，避免并发事务以不同顺序锁同一批邮件造成死锁
+func loadCounterStates(tx *gorm.DB, ids []int64) ([]counterState, error) {
+	var states []counterState
+	for start := 0; start < len(ids); start += sourceStateBatchSize {
+		end := min(start+sourceStateBatchSize, len(ids))
+		var batch []counterState
+		err := tx.Model(&model.Email{}).
+			Select("id", "account_uid", "COALESCE(source_folder, '') AS source_folder", "is_read", "is_starred", "is_archived", "is_deleted").
+			Where("id IN ?", ids[start:end]).
+			Order("id ASC").
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scan(&batch).Error
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, batch...)
+	}
+	return states, nil
+}
+
+// applyCounterDeltas 在事务中累加计数器增量，并同步账户的邮件总数和未读数
+func applyCounterDeltas(tx *gorm.DB, deltas map[counterKey]*counterDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	// 固定加锁顺序
+	keys := make([]counterKey, 0, len(deltas))
+	for key := range deltas {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].AccountUID != keys[j].AccountUID {
+			return keys[i].AccountUID < keys[j].AccountUID
+		}
+		return keys[i].Folder < keys[j].Folder
+	})
+
+	now := time.Now()
+	accounts := make(map[string]*counterDelta)
+	var accountUIDs []string
+	for _, key := range keys {
+		delta := deltas[key]
+		err := tx.Exec(`INSERT INTO mailbox_counters (account_uid, folder, total_count, unread_count, starred_count, archived_count, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (account_uid, folder) DO UPDATE SET
+				total_count = mailbox_counters.total_count + EXCLUDED.total_count,
+				unread_count = mailbox_counters.unread_count + EXCLUDED.unread_count,
+				starred_count = mailbox_counters.starred_count + EXCLUDED.starred_count,
+				archived_count = mailbox_counters.archived_count + EXCLUDED.archived_count,
+				updated_at = EXCLUDED.updated_at`,
+			key.AccountUID, key.Folder, delta.Total, delta.Unread, delta.Starred, delta.Archived, now).Error
+		if err != nil {
+			return err
+		}
+
+		account, ok := accounts[key.AccountUID]
+		if !ok {
+			account = &counterDelta{}
+			accounts[key.AccountUID] = account
+			accountUIDs = append(accountUIDs, key.AccountUID)
+		}
+		account.Total += delta.Total
+		account.Unread += delta.Unread
+	}
+
+	for _, uid := range accountUIDs {
+		account := accounts[uid]
+		if account.Total == 0 && account.Unread == 0 {
+			continue
+		}
+		err := tx.Model(&model.Account{}).
+			Where("uid = ?", uid).
+			Updates(map[string]interface{}{
+				"total_emails": gorm.Expr("total_emails + ?", account.Total),
+				"unread_count": gorm.Expr("unread_count + ?", account.Unread),
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
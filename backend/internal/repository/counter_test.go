@@ -0,0 +1,38 @@
+package repository
+
+import "testing"
+
+// TestCounterDeltas 测试按邮件状态变化计算计数器增量
+func TestCounterDeltas(t *testing.T) {
+	inbox := counterKey{AccountUID: "a", Folder: "INBOX"}
+	archive := counterKey{AccountUID: "a", Folder: "Archive"}
+
+	before := []counterState{
+		{ID: 1, AccountUID: "a", SourceFolder: "INBOX"},                  // 标记已读
+		{ID: 2, AccountUID: "a", SourceFolder: "INBOX", IsRead: true},    // 移到 Archive
+		{ID: 3, AccountUID: "a", SourceFolder: "INBOX", IsStarred: true}, // 本地删除
+		{ID: 4, AccountUID: "a", SourceFolder: "INBOX", IsRead: true},    // 没有变化
+	}
+	after := []counterState{
+		{ID: 1, AccountUID: "a", SourceFolder: "INBOX", IsRead: true},
+		{ID: 2, AccountUID: "a", SourceFolder: "Archive", IsRead: true},
+		{ID: 3, AccountUID: "a", SourceFolder: "INBOX", IsStarred: true, IsDeleted: true},
+		{ID: 4, AccountUID: "a", SourceFolder: "INBOX", IsRead: true},
+		{ID: 5, AccountUID: "a", SourceFolder: "Archive", IsArchived: true}, // 新邮件
+	}
+
+	deltas := counterDeltas(before, after)
+	if len(deltas) != 2 {
+		t.Fatalf("deltas = %v, want 2 keys", deltas)
+	}
+	if got, want := *deltas[inbox], (counterDelta{Total: -2, Unread: -2, Starred: -1}); got != want {
+		t.Errorf("INBOX delta = %+v, want %+v", got, want)
+	}
+	if got, want := *deltas[archive], (counterDelta{Total: 2, Unread: 1, Archived: 1}); got != want {
+		t.Errorf("Archive delta = %+v, want %+v", got, want)
+	}
+
+	if deltas := counterDeltas(after, after); len(deltas) != 0 {
+		t.Errorf("unchanged states produced deltas %v", deltas)
+	}
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/pkg/crypto"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DataKeyRepository 数据密钥数据仓库接口（实现 crypto.DataKeyStore）
+type DataKeyRepository interface {
+	crypto.DataKeyStore
+}
+
+// dataKeyRepository 数据密钥数据仓库实现
+type dataKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewDataKeyRepository 创建数据密钥数据仓库实例
+func NewDataKeyRepository(db *gorm.DB) DataKeyRepository {
+	return &dataKeyRepository{db: db}
+}
+
+// LoadWrappedKey 读取数据密钥，不存在时返回空字符串
+func (r *dataKeyRepository) LoadWrappedKey(ctx context.Context, owner string) (string, error) {
+	var key model.DataKey
+	err := r.db.WithContext(ctx).Where("owner = ?", owner).Take(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return key.WrappedKey, nil
+}
+
+// SaveWrappedKey 保存数据密钥，已存在时保留原密钥并返回它
+func (r *dataKeyRepository) SaveWrappedKey(ctx context.Context, owner, wrapped string) (string, error) {
+	err := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.DataKey{Owner: owner, WrappedKey: wrapped, CreatedAt: time.Now()}).Error
+	if err != nil {
+		return "", err
+	}
+	return r.LoadWrappedKey(ctx, owner)
+}
+
+// ListOwners 列出已有数据密钥的 owner
+func (r *dataKeyRepository) ListOwners(ctx context.Context) ([]string, error) {
+	var owners []string
+	err := r.db.WithContext(ctx).Model(&model.DataKey{}).Order("owner ASC").Pluck("owner", &owners).Error
+	return owners, err
+}
@@ -3,7 +3,13 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"fusionmail/internal/model"
+	"fusionmail/pkg/crypto"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,52 +17,101 @@ import (
 
 // EmailFilter 邮件过滤条件
 type EmailFilter struct {
-	AccountUID  string
-	IsRead      *bool
-	IsStarred   *bool
-	IsArchived  *bool
-	IsDeleted   *bool
-	FromAddress string
-	Subject     string
-	StartDate   string
-	EndDate     string
-	SearchQuery string
+	AccountUID    string
+	IsRead        *bool
+	IsStarred     *bool
+	IsArchived    *bool
+	IsDeleted     *bool
+	SourceDeleted *bool
+	FromAddress   string
+	Subject       string
+	StartDate     string
+	EndDate       string
+	SearchQuery   string
+
+	// CollapseDuplicates 重复组只返回一封（仅在未按账户筛选的统一列表中生效）
+	CollapseDuplicates bool
+}
+
+// EmailCursor 邮件列表游标：上一页最后一封邮件的发送时间和 ID
+type EmailCursor struct {
+	SentAt time.Time
+	ID     int64
+}
+
+// UpsertResult 批量写入邮件时单行的结果（未变化的已有邮件不返回）
+type UpsertResult struct {
+	ID            int64
+	ProviderID    string
+	AccountUID    string
+	LocalThreadID *int64
+	Inserted      bool // true 表示新插入，false 表示已存在且有字段变化
 }
 
 // EmailRepository 邮件数据仓库接口
 type EmailRepository interface {
 	Create(ctx context.Context, email *model.Email) error
 	CreateBatch(ctx context.Context, emails []*model.Email) error
+	UpsertBatch(ctx context.Context, emails []*model.Email) ([]UpsertResult, error)
 	FindByID(ctx context.Context, id int64) (*model.Email, error)
+	FindByIDs(ctx context.Context, ids []int64) ([]*model.Email, error)
 	FindByProviderID(ctx context.Context, providerID, accountUID string) (*model.Email, error)
 	Update(ctx context.Context, email *model.Email) error
 	UpdateLocalStatus(ctx context.Context, id int64, isRead, isStarred, isArchived, isDeleted *bool) error
-	Delete(ctx context.Context, id int64) error
-	List(ctx context.Context, filter *EmailFilter, offset, limit int) ([]*model.Email, int64, error)
-	Search(ctx context.Context, query string, accountUID string, offset, limit int) ([]*model.Email, int64, error)
+	ListSummaries(ctx context.Context, filter *EmailFilter, cursor *EmailCursor, limit int) ([]*model.EmailSummary, error)
+	SearchSummaries(ctx context.Context, query string, accountUID string, collapseDuplicates bool, cursor *EmailCursor, limit int) ([]*model.EmailSummary, error)
+	CountSearch(ctx context.Context, query string, accountUID string, collapseDuplicates bool) (int64, error)
 	CountUnread(ctx context.Context, accountUID string) (int64, error)
 	MarkAsRead(ctx context.Context, ids []int64) error
 	MarkAsUnread(ctx context.Context, ids []int64) error
+	SetStarred(ctx context.Context, ids []int64, starred bool) error
+	SetArchived(ctx context.Context, ids []int64, archived bool) error
+
+	// 跨账户重复检测需要的方法
+	FindDuplicateGroupIDs(ctx context.Context, ids []int64) ([]int64, error)
+
+	// 源邮箱对账需要的方法
+	ListSourceStates(ctx context.Context, accountUID string) ([]*model.Email, error)
+	MarkSourceDeleted(ctx context.Context, ids []int64, deletedAt time.Time, isArchived, isDeleted *bool) error
+	ClearSourceDeleted(ctx context.Context, ids []int64) error
+	UpdateSourceFolder(ctx context.Context, ids []int64, folder string) error
 
 	// 系统管理需要的方法
 	Count(ctx context.Context, filter *EmailFilter) (int64, error)
 	CountByDateRange(ctx context.Context, startTime, endTime time.Time) (int64, error)
 	CountByAccount(ctx context.Context, accountUID string) (int64, error)
+
+	// 静态加密迁移需要的方法
+	EncryptExisting(ctx context.Context, afterID int64, limit int) (int64, int, error)
+
+	// 原始邮件归档需要的方法
+	SetRawPath(ctx context.Context, id int64, rawPath string) error
+	ListArchived(ctx context.Context, accountUID string, afterID int64, limit int) ([]*model.Email, error)
+	UpdateParsed(ctx context.Context, email *model.Email) error
+
+	// 回收站和保留策略需要的方法
+	SetDeleted(ctx context.Context, ids []int64, deleted bool) error
+	ListTrashed(ctx context.Context, accountUID string, before time.Time, afterID int64, limit int) ([]*model.Email, error)
+	ListArchivedIDs(ctx context.Context, accountUID string, before time.Time, afterID int64, limit int) ([]int64, error)
+	Purge(ctx context.Context, ids []int64) ([]int64, error)
+	FindPurgedProviderIDs(ctx context.Context, accountUID string, providerIDs []string) (map[string]bool, error)
 }
 
 // emailRepository 邮件数据仓库实现
 type emailRepository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	cipher *crypto.FieldCipher
 }
 
 // NewEmailRepository 创建邮件数据仓库实例
-func NewEmailRepository(db *gorm.DB) EmailRepository {
-	return &emailRepository{db: db}
+// cipher 可以为 nil，此时正文以明文读写；不为 nil 时写入时按配置加密正文并维护搜索索引，读取时透明解密
+func NewEmailRepository(db *gorm.DB, cipher *crypto.FieldCipher) EmailRepository {
+	return &emailRepository{db: db, cipher: cipher}
 }
 
 // Create 创建邮件
 func (r *emailRepository) Create(ctx context.Context, email *model.Email) error {
-	return r.db.WithContext(ctx).Create(email).Error
+	return r.CreateBatch(ctx, []*model.Email{email})
 }
 
 // CreateBatch 批量创建邮件
@@ -64,7 +119,299 @@ func (r *emailRepository) CreateBatch(ctx context.Context, emails []*model.Email
 	if len(emails) == 0 {
 		return nil
 	}
-	return r.db.WithContext(ctx).CreateInBatches(emails, 100).Error
+	entries, err := r.searchIndexEntries(ctx, emails)
+	if err != nil {
+		return err
+	}
+	restore, err := sealEmailBodies(ctx, r.cipher, emails)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(emails, 100).Error; err != nil {
+			return err
+		}
+		ids := make([]int64, 0, len(emails))
+		index := make(map[int64][]string, len(entries))
+		for _, email := range emails {
+			ids = append(ids, email.ID)
+			if hashes, ok := entries[email]; ok {
+				index[email.ID] = hashes
+			}
+		}
+		if err := writeSearchIndex(tx, index); err != nil {
+			return err
+		}
+		after, err := loadCounterStates(tx, ids)
+		if err != nil {
+			return err
+		}
+		return applyCounterDeltas(tx, counterDeltas(nil, after))
+	})
+}
+
+// upsertBatchSize 每条 INSERT 语句写入的邮件数（避免超出数据库参数数量限制）
+const upsertBatchSize = 200
+
+// upsertSyncedColumns 已有邮件在同步时随源邮箱更新的列，本地状态列不会被覆盖
+// 正文列和源文件夹列单独处理（见 upsertBodyColumns、upsertOptionalColumns）
+var upsertSyncedColumns = []string{
+	"subject", "snippet",
+	"source_is_read", "source_labels",
+	"has_attachments", "attachments_count", "size_bytes",
+}
+
+// upsertOptionalColumns 只有同步拉取到非空值时才更新的列：不设置源文件夹的拉取路径不会清空对账写入的文件夹
+var upsertOptionalColumns = []string{"source_folder"}
+
+// upsertBodyColumns 正文列：加密正文每次写入的密文都不同，按正文摘要判断是否变化，摘要为空（明文保存）时直接比较正文
+var upsertBodyColumns = []string{"text_body", "html_body", "body_hash"}
+
+// UpsertBatch 在一个事务中批量写入同步拉取的邮件
+// 按 (provider_id, account_uid) 冲突时只更新有变化的同步列（未变化的正文不重写），
+// 去重前入库的邮件补全去重键；没有任何变化的已有邮件不更新，也不出现在返回结果中
+// 新邮件和源文件夹变化的邮件在同一事务中更新计数器；设置了去重键的邮件在同一事务中指向重复组主副本
+func (r *emailRepository) UpsertBatch(ctx context.Context, emails []*model.Email) ([]UpsertResult, error) {
+	if len(emails) == 0 {
+		return nil, nil
+	}
+
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(&model.Email{}); err != nil {
+		return nil, fmt.Errorf("failed to parse email schema: %w", err)
+	}
+	var columns []string
+	for _, name := range stmt.Schema.DBNames {
+		if field := stmt.Schema.FieldsByDBName[name]; field != nil && !field.PrimaryKey {
+			columns = append(columns, name)
+		}
+	}
+
+	// 正文加密时同时计算摘要，冲突更新时按摘要判断正文是否变化
+	entries, err := r.searchIndexEntries(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+	restore, err := sealEmailBodies(ctx, r.cipher, emails)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	now := time.Now()
+	var results []UpsertResult
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 在写入的事务中选定重复组主副本；同一批中重复的邮件等第一封写入得到 ID 后再写入并指向它
+		duplicates, err := assignDuplicateGroups(tx, emails)
+		if err != nil {
+			return fmt.Errorf("failed to assign duplicate groups: %w", err)
+		}
+		first := emails
+		if len(duplicates) > 0 {
+			first = make([]*model.Email, 0, len(emails)-len(duplicates))
+			for _, email := range emails {
+				if _, ok := duplicates[email]; !ok {
+					first = append(first, email)
+				}
+			}
+		}
+
+		written, err := r.upsertRows(ctx, tx, stmt, columns, first, entries, now)
+		if err != nil {
+			return err
+		}
+		results = append(results, written...)
+		if len(duplicates) == 0 {
+			return nil
+		}
+
+		ids := make(map[[2]string]int64, len(written))
+		for _, result := range written {
+			ids[[2]string{result.ProviderID, result.AccountUID}] = result.ID
+		}
+		rest := make([]*model.Email, 0, len(duplicates))
+		for _, email := range emails {
+			canonical, ok := duplicates[email]
+			if !ok {
+				continue
+			}
+			key := [2]string{canonical.ProviderID, canonical.AccountUID}
+			id, ok := ids[key]
+			if !ok {
+				// 首封邮件已入库且没有变化时不会出现在写入结果中，按唯一键查询
+				if err := tx.Model(&model.Email{}).
+					Where("provider_id = ? AND account_uid = ?", canonical.ProviderID, canonical.AccountUID).
+					Pluck("id", &id).Error; err != nil {
+					return fmt.Errorf("failed to find duplicate group: %w", err)
+				}
+				ids[key] = id
+			}
+			if id != 0 {
+				email.CanonicalID = &id
+				email.IsRead = canonical.IsRead
+				email.IsStarred = canonical.IsStarred
+			}
+			rest = append(rest, email)
+		}
+		written, err = r.upsertRows(ctx, tx, stmt, columns, rest, entries, now)
+		if err != nil {
+			return err
+		}
+		results = append(results, written...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// upsertRows 在事务中分批执行 upsert 语句，更新搜索索引和计数器，返回实际写入的邮件
+func (r *emailRepository) upsertRows(ctx context.Context, tx *gorm.DB, stmt *gorm.Statement, columns []string,
+	emails []*model.Email, entries map[*model.Email][]string, now time.Time) ([]UpsertResult, error) {
+	var results []UpsertResult
+	for start := 0; start < len(emails); start += upsertBatchSize {
+		batch := emails[start:min(start+upsertBatchSize, len(emails))]
+
+		// 先锁定已有邮件的计数状态，写入后与新状态比较（同步只会改变已有邮件的源文件夹）
+		existing, err := r.loadExistingCounterStates(tx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		args := make([]interface{}, 0, len(batch)*len(columns))
+		for _, email := range batch {
+			if email.CreatedAt.IsZero() {
+				email.CreatedAt = now
+			}
+			email.UpdatedAt = now
+
+			value := reflect.ValueOf(email).Elem()
+			for _, name := range columns {
+				v, _ := stmt.Schema.FieldsByDBName[name].ValueOf(ctx, value)
+				args = append(args, v)
+			}
+		}
+
+		rows, err := tx.Raw(buildUpsertSQL(columns, len(batch)), args...).Rows()
+		if err != nil {
+			return nil, err
+		}
+		byKey := make(map[[2]string]*model.Email, len(batch))
+		for _, email := range batch {
+			byKey[[2]string{email.ProviderID, email.AccountUID}] = email
+		}
+		var written []int64
+		index := make(map[int64][]string)
+		for rows.Next() {
+			var result UpsertResult
+			var accountUID string
+			if err := rows.Scan(&result.ID, &result.ProviderID, &accountUID, &result.LocalThreadID, &result.Inserted); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result.AccountUID = accountUID
+			results = append(results, result)
+			written = append(written, result.ID)
+			if hashes, ok := entries[byKey[[2]string{result.ProviderID, accountUID}]]; ok {
+				index[result.ID] = hashes
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		if err := writeSearchIndex(tx, index); err != nil {
+			return nil, err
+		}
+
+		// 未变化的邮件不在返回结果中，只比较实际写入的邮件
+		var before []counterState
+		for _, id := range written {
+			if state, ok := existing[id]; ok {
+				before = append(before, state)
+			}
+		}
+		after, err := loadCounterStates(tx, written)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyCounterDeltas(tx, counterDeltas(before, after)); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// loadExistingCounterStates 锁定并读取一批待写入邮件中已入库邮件的计数状态（按邮件 ID 索引）
+func (r *emailRepository) loadExistingCounterStates(tx *gorm.DB, emails []*model.Email) (map[int64]counterState, error) {
+	keys := make([][]interface{}, 0, len(emails))
+	for _, email := range emails {
+		keys = append(keys, []interface{}{email.ProviderID, email.AccountUID})
+	}
+
+	var ids []int64
+	err := tx.Model(&model.Email{}).
+		Where("(provider_id, account_uid) IN ?", keys).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := loadCounterStates(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[int64]counterState, len(states))
+	for _, state := range states {
+		existing[state.ID] = state
+	}
+	return existing, nil
+}
+
+// buildUpsertSQL 生成批量写入邮件的 INSERT ... ON CONFLICT 语句
+// xmax = 0 表示本行由 INSERT 新建，否则是冲突后的 UPDATE
+func buildUpsertSQL(columns []string, rowCount int) string {
+	quoted := make([]string, len(columns))
+	for i, name := range columns {
+		quoted[i] = `"` + name + `"`
+	}
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	values := strings.TrimSuffix(strings.Repeat(row+", ", rowCount), ", ")
+
+	missingDedup := `COALESCE(emails."dedup_key", '') = ''`
+	sets := make([]string, 0, len(upsertSyncedColumns)+len(upsertOptionalColumns)+len(upsertBodyColumns)+4)
+	changed := make([]string, 0, len(upsertSyncedColumns)+len(upsertOptionalColumns)+2)
+	for _, name := range upsertSyncedColumns {
+		col := `"` + name + `"`
+		sets = append(sets, fmt.Sprintf("%s = CASE WHEN emails.%s IS DISTINCT FROM EXCLUDED.%s THEN EXCLUDED.%s ELSE emails.%s END", col, col, col, col, col))
+		changed = append(changed, fmt.Sprintf("emails.%s IS DISTINCT FROM EXCLUDED.%s", col, col))
+	}
+	for _, name := range upsertOptionalColumns {
+		col := `"` + name + `"`
+		cond := fmt.Sprintf("(COALESCE(EXCLUDED.%s, '') <> '' AND emails.%s IS DISTINCT FROM EXCLUDED.%s)", col, col, col)
+		sets = append(sets, fmt.Sprintf("%s = CASE WHEN %s THEN EXCLUDED.%s ELSE emails.%s END", col, cond, col, col))
+		changed = append(changed, cond)
+	}
+	sets = append(sets,
+		fmt.Sprintf(`"dedup_key" = CASE WHEN %s THEN EXCLUDED."dedup_key" ELSE emails."dedup_key" END`, missingDedup),
+		fmt.Sprintf(`"canonical_id" = CASE WHEN %s THEN EXCLUDED."canonical_id" ELSE emails."canonical_id" END`, missingDedup),
+		`"synced_at" = EXCLUDED."synced_at"`,
+		`"updated_at" = EXCLUDED."updated_at"`,
+	)
+	bodyChanged := `(emails."body_hash" IS DISTINCT FROM EXCLUDED."body_hash" OR (COALESCE(EXCLUDED."body_hash", '') = '' AND ` +
+		`(emails."text_body" IS DISTINCT FROM EXCLUDED."text_body" OR emails."html_body" IS DISTINCT FROM EXCLUDED."html_body")))`
+	for _, name := range upsertBodyColumns {
+		col := `"` + name + `"`
+		sets = append(sets, fmt.Sprintf("%s = CASE WHEN %s THEN EXCLUDED.%s ELSE emails.%s END", col, bodyChanged, col, col))
+	}
+	changed = append(changed, bodyChanged, missingDedup)
+
+	return fmt.Sprintf("INSERT INTO emails (%s) VALUES %s ON CONFLICT (provider_id, account_uid) DO UPDATE SET %s WHERE %s "+
+		"RETURNING id, provider_id, account_uid, local_thread_id, (xmax = 0) AS inserted",
+		strings.Join(quoted, ", "), values, strings.Join(sets, ", "), strings.Join(changed, " OR "))
 }
 
 // FindByID 根据 ID 查找邮件
@@ -79,9 +426,27 @@ func (r *emailRepository) FindByID(ctx context.Context, id int64) (*model.Email,
 		}
 		return nil, err
 	}
+	if err := openEmailBodies(ctx, r.cipher, &email); err != nil {
+		return nil, err
+	}
 	return &email, nil
 }
 
+// FindByIDs 根据 ID 列表批量查找邮件（不存在的 ID 被忽略）
+func (r *emailRepository) FindByIDs(ctx context.Context, ids []int64) ([]*model.Email, error) {
+	var emails []*model.Email
+	if len(ids) == 0 {
+		return emails, nil
+	}
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&emails).Error; err != nil {
+		return nil, err
+	}
+	if err := openEmailBodies(ctx, r.cipher, emails...); err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
 // FindByProviderID 根据 Provider ID 和 Account UID 查找邮件
 func (r *emailRepository) FindByProviderID(ctx context.Context, providerID, accountUID string) (*model.Email, error) {
 	var email model.Email
@@ -94,12 +459,23 @@ func (r *emailRepository) FindByProviderID(ctx context.Context, providerID, acco
 		}
 		return nil, err
 	}
+	if err := openEmailBodies(ctx, r.cipher, &email); err != nil {
+		return nil, err
+	}
 	return &email, nil
 }
 
 // Update 更新邮件
 func (r *emailRepository) Update(ctx context.Context, email *model.Email) error {
-	return r.db.WithContext(ctx).Save(email).Error
+	restore, err := sealEmailBodies(ctx, r.cipher, []*model.Email{email})
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	return r.withCounters(ctx, []int64{email.ID}, func(tx *gorm.DB) error {
+		return tx.Save(email).Error
+	})
 }
 
 // UpdateLocalStatus 更新本地状态
@@ -123,106 +499,333 @@ func (r *emailRepository) UpdateLocalStatus(ctx context.Context, id int64, isRea
 		return nil
 	}
 
-	return r.db.WithContext(ctx).
-		Model(&model.Email{}).
-		Where("id = ?", id).
-		Updates(updates).Error
+	return r.updateByIDs(ctx, []int64{id}, updates)
 }
 
-// Delete 删除邮件
-func (r *emailRepository) Delete(ctx context.Context, id int64) error {
-	return r.db.WithContext(ctx).Delete(&model.Email{}, id).Error
+// emailSummaryColumns 邮件列表投影查询的列（不含正文）
+var emailSummaryColumns = []string{
+	"id", "provider_id", "account_uid", "message_id",
+	"subject", "from_address", "from_name", "to_address", "snippet",
+	"is_read", "is_starred", "is_archived", "is_deleted", "trashed_at", "labels",
+	"source_deleted", "canonical_id", "local_thread_id", "thread_id",
+	"has_attachments", "attachments_count",
+	"sent_at", "received_at", "size_bytes",
 }
 
-// List 获取邮件列表
-func (r *emailRepository) List(ctx context.Context, filter *EmailFilter, offset, limit int) ([]*model.Email, int64, error) {
-	var emails []*model.Email
-	var total int64
-
-	query := r.db.WithContext(ctx).Model(&model.Email{})
-
-	// 应用过滤条件
-	query = r.applyFilter(query, filter)
+// ListSummaries 获取邮件列表摘要（按 sent_at、id 倒序，cursor 为空时从最新一封开始）
+func (r *emailRepository) ListSummaries(ctx context.Context, filter *EmailFilter, cursor *EmailCursor, limit int) ([]*model.EmailSummary, error) {
+	query := r.applyFilter(r.db.WithContext(ctx).Model(&model.Email{}), filter)
+	return r.findSummaries(query, cursor, limit)
+}
 
-	// 获取总数
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+// SearchSummaries 全文搜索邮件，返回摘要（分页方式同 ListSummaries）
+func (r *emailRepository) SearchSummaries(ctx context.Context, query string, accountUID string, collapseDuplicates bool, cursor *EmailCursor, limit int) ([]*model.EmailSummary, error) {
+	searchQuery, err := r.searchQuery(ctx, query, accountUID, collapseDuplicates)
+	if err != nil {
+		return nil, err
 	}
-
-	// 获取列表
-	err := query.
-		Offset(offset).
-		Limit(limit).
-		Order("sent_at DESC").
-		Find(&emails).Error
-
-	return emails, total, err
+	return r.findSummaries(searchQuery, cursor, limit)
 }
 
-// Search 全文搜索邮件
-func (r *emailRepository) Search(ctx context.Context, query string, accountUID string, offset, limit int) ([]*model.Email, int64, error) {
-	var emails []*model.Email
+// CountSearch 统计全文搜索匹配的邮件数
+func (r *emailRepository) CountSearch(ctx context.Context, query string, accountUID string, collapseDuplicates bool) (int64, error) {
+	searchQuery, err := r.searchQuery(ctx, query, accountUID, collapseDuplicates)
+	if err != nil {
+		return 0, err
+	}
 	var total int64
+	err = searchQuery.Count(&total).Error
+	return total, err
+}
 
+// searchQuery 构建全文搜索条件
+// 明文正文直接匹配；加密的正文通过搜索索引按词匹配
+func (r *emailRepository) searchQuery(ctx context.Context, query string, accountUID string, collapseDuplicates bool) (*gorm.DB, error) {
 	// 使用 PostgreSQL 全文搜索，支持中文
-	searchQuery := r.db.WithContext(ctx).Model(&model.Email{}).
-		Where("(subject ILIKE ? OR from_name ILIKE ? OR from_address ILIKE ? OR text_body ILIKE ?)", 
-			"%"+query+"%", "%"+query+"%", "%"+query+"%", "%"+query+"%")
+	pattern := "%" + query + "%"
+	indexQuery, err := r.searchIndexQuery(ctx, query, accountUID)
+	if err != nil {
+		return nil, err
+	}
+	condition := r.db.Where("subject ILIKE ? OR from_name ILIKE ? OR from_address ILIKE ? OR text_body ILIKE ?",
+		pattern, pattern, pattern, pattern)
+	if indexQuery != nil {
+		condition = condition.Or("id IN (?)", indexQuery)
+	}
+	searchQuery := r.db.WithContext(ctx).Model(&model.Email{}).Where(condition)
 
 	if accountUID != "" {
 		searchQuery = searchQuery.Where("account_uid = ?", accountUID)
+	} else if collapseDuplicates {
+		searchQuery = r.collapseDuplicates(searchQuery, func(query *gorm.DB) *gorm.DB {
+			return query.Where(condition)
+		})
 	}
+	return searchQuery, nil
+}
 
-	// 获取总数
-	if err := searchQuery.Count(&total).Error; err != nil {
-		return nil, 0, err
+// collapseDuplicates 重复组只保留一封：组内同样满足 visible 条件、ID 最小的副本
+// 主副本被删除、归档或不匹配时由组内其他副本代表该组，整组不会从列表中消失
+func (r *emailRepository) collapseDuplicates(query *gorm.DB, visible func(query *gorm.DB) *gorm.DB) *gorm.DB {
+	siblings := visible(r.db.Table("emails AS sibling").Select("1")).
+		Where("sibling.id = COALESCE(emails.canonical_id, emails.id) OR sibling.canonical_id = COALESCE(emails.canonical_id, emails.id)").
+		Where("sibling.id < emails.id")
+	return query.Where("NOT EXISTS (?)", siblings)
+}
+
+// findSummaries 按游标查询一页邮件摘要
+// 用 (sent_at, id) 行比较代替 OFFSET，翻到后面的页也只扫描一页的索引
+func (r *emailRepository) findSummaries(query *gorm.DB, cursor *EmailCursor, limit int) ([]*model.EmailSummary, error) {
+	if cursor != nil {
+		query = query.Where("(sent_at, id) < (?, ?)", cursor.SentAt, cursor.ID)
 	}
 
-	// 获取列表
-	err := searchQuery.
-		Offset(offset).
+	var summaries []*model.EmailSummary
+	err := query.
+		Select(emailSummaryColumns).
+		Order("sent_at DESC, id DESC").
 		Limit(limit).
-		Order("sent_at DESC").
-		Find(&emails).Error
-
-	return emails, total, err
+		Find(&summaries).Error
+	return summaries, err
 }
 
-// CountUnread 统计未读邮件数
+// CountUnread 统计未读邮件数（读取计数器，不扫描邮件表）
 func (r *emailRepository) CountUnread(ctx context.Context, accountUID string) (int64, error) {
 	var count int64
-	query := r.db.WithContext(ctx).Model(&model.Email{}).
-		Where("is_read = ?", false).
-		Where("is_deleted = ?", false)
+	query := r.db.WithContext(ctx).
+		Model(&model.MailboxCounter{}).
+		Select("COALESCE(SUM(unread_count), 0)")
 
 	if accountUID != "" {
 		query = query.Where("account_uid = ?", accountUID)
 	}
 
-	err := query.Count(&count).Error
+	err := query.Scan(&count).Error
 	return count, err
 }
 
 // MarkAsRead 标记为已读
 func (r *emailRepository) MarkAsRead(ctx context.Context, ids []int64) error {
-	if len(ids) == 0 {
+	return r.updateByIDs(ctx, ids, map[string]interface{}{
+		"is_read": true,
+	})
+}
+
+// MarkAsUnread 标记为未读
+func (r *emailRepository) MarkAsUnread(ctx context.Context, ids []int64) error {
+	return r.updateByIDs(ctx, ids, map[string]interface{}{
+		"is_read": false,
+	})
+}
+
+// SetStarred 批量设置星标状态
+func (r *emailRepository) SetStarred(ctx context.Context, ids []int64, starred bool) error {
+	return r.updateByIDs(ctx, ids, map[string]interface{}{
+		"is_starred": starred,
+	})
+}
+
+// SetArchived 批量设置归档状态
+func (r *emailRepository) SetArchived(ctx context.Context, ids []int64, archived bool) error {
+	return r.updateByIDs(ctx, ids, map[string]interface{}{
+		"is_archived": archived,
+	})
+}
+
+// assignDuplicateGroups 在写入事务中为一批待写入的邮件指向重复组主副本（需已设置去重键）
+// 先对涉及的去重键加事务级咨询锁，保证并发同步同一封邮件时只产生一个主副本；
+// 新副本继承主副本的本地已读和星标状态。返回同一批中没有已入库主副本的后续重复邮件及其应指向的本批首封邮件
+func assignDuplicateGroups(tx *gorm.DB, emails []*model.Email) (map[*model.Email]*model.Email, error) {
+	keys := make([]string, 0, len(emails))
+	for _, email := range emails {
+		if email.DedupKey != "" {
+			keys = append(keys, email.DedupKey)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	if err := lockDedupKeys(tx, keys); err != nil {
+		return nil, err
+	}
+	canonicals, err := findCanonicals(tx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := make(map[*model.Email]*model.Email)
+	firsts := make(map[string]*model.Email)
+	for _, email := range emails {
+		if email.DedupKey == "" {
+			continue
+		}
+		canonical := canonicals[email.DedupKey]
+		if canonical == nil {
+			if first, ok := firsts[email.DedupKey]; ok {
+				duplicates[email] = first
+			} else {
+				firsts[email.DedupKey] = email
+			}
+			continue
+		}
+		// 主副本就是这封邮件自身（已入库）时不处理
+		if canonical.ProviderID == email.ProviderID && canonical.AccountUID == email.AccountUID {
+			continue
+		}
+		email.CanonicalID = &canonical.ID
+		email.IsRead = canonical.IsRead
+		email.IsStarred = canonical.IsStarred
+	}
+	return duplicates, nil
+}
+
+// lockDedupKeys 对去重键加事务级咨询锁（仅 PostgreSQL），按锁 ID 排序加锁避免死锁
+func lockDedupKeys(tx *gorm.DB, keys []string) error {
+	if tx.Dialector.Name() != "postgres" {
 		return nil
 	}
-	return r.db.WithContext(ctx).
+
+	seen := make(map[int64]bool, len(keys))
+	locks := make([]int64, 0, len(keys))
+	for _, key := range keys {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		lock := int64(h.Sum64())
+		if !seen[lock] {
+			seen[lock] = true
+			locks = append(locks, lock)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i] < locks[j] })
+
+	for _, lock := range locks {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lock).Error; err != nil {
+			return fmt.Errorf("failed to lock dedup key: %w", err)
+		}
+	}
+	return nil
+}
+
+// findCanonicals 根据去重键批量查找重复组的主副本（按去重键索引，每组取最早入库的一封）
+func findCanonicals(tx *gorm.DB, keys []string) (map[string]*model.Email, error) {
+	var emails []*model.Email
+	err := tx.Select("id", "provider_id", "account_uid", "dedup_key", "is_read", "is_starred").
+		Where("dedup_key IN ? AND canonical_id IS NULL", keys).
+		Order("id ASC").
+		Find(&emails).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate groups: %w", err)
+	}
+
+	canonicals := make(map[string]*model.Email)
+	for _, email := range emails {
+		if _, ok := canonicals[email.DedupKey]; !ok {
+			canonicals[email.DedupKey] = email
+		}
+	}
+	return canonicals, nil
+}
+
+// FindDuplicateGroupIDs 获取指定邮件所在重复组的全部邮件 ID（包括指定的邮件本身）
+func (r *emailRepository) FindDuplicateGroupIDs(ctx context.Context, ids []int64) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var groupIDs []int64
+	groups := r.db.Model(&model.Email{}).
+		Select("COALESCE(canonical_id, id)").
+		Where("id IN ?", ids)
+	err := r.db.WithContext(ctx).
 		Model(&model.Email{}).
-		Where("id IN ?", ids).
-		Update("is_read", true).Error
+		Where("COALESCE(canonical_id, id) IN (?)", groups).
+		Pluck("id", &groupIDs).Error
+	return groupIDs, err
 }
 
-// MarkAsUnread 标记为未读
-func (r *emailRepository) MarkAsUnread(ctx context.Context, ids []int64) error {
+// sourceStateBatchSize 按 ID 批量更新源邮箱状态时每批的数量（避免超出数据库参数数量限制）
+const sourceStateBatchSize = 1000
+
+// ListSourceStates 获取账户下全部邮件的源邮箱状态（只查询对账需要的字段）
+func (r *emailRepository) ListSourceStates(ctx context.Context, accountUID string) ([]*model.Email, error) {
+	var emails []*model.Email
+	err := r.db.WithContext(ctx).
+		Select("id", "provider_id", "source_folder", "source_deleted", "synced_at").
+		Where("account_uid = ?", accountUID).
+		Find(&emails).Error
+	return emails, err
+}
+
+// MarkSourceDeleted 将邮件标记为已在源邮箱删除，并按需同时更新本地归档/删除状态
+func (r *emailRepository) MarkSourceDeleted(ctx context.Context, ids []int64, deletedAt time.Time, isArchived, isDeleted *bool) error {
+	updates := map[string]interface{}{
+		"source_deleted":    true,
+		"source_deleted_at": deletedAt,
+	}
+	if isArchived != nil {
+		updates["is_archived"] = *isArchived
+	}
+	if isDeleted != nil {
+		updates["is_deleted"] = *isDeleted
+	}
+
+	return r.updateByIDs(ctx, ids, updates)
+}
+
+// ClearSourceDeleted 清除源邮箱删除标记（邮件重新出现在源邮箱中）
+func (r *emailRepository) ClearSourceDeleted(ctx context.Context, ids []int64) error {
+	return r.updateByIDs(ctx, ids, map[string]interface{}{
+		"source_deleted":    false,
+		"source_deleted_at": nil,
+	})
+}
+
+// UpdateSourceFolder 更新邮件所在的源邮箱文件夹
+func (r *emailRepository) UpdateSourceFolder(ctx context.Context, ids []int64, folder string) error {
+	return r.updateByIDs(ctx, ids, map[string]interface{}{
+		"source_folder": folder,
+	})
+}
+
+// updateByIDs 按 ID 分批更新邮件字段（同一事务中维护计数器）
+func (r *emailRepository) updateByIDs(ctx context.Context, ids []int64, updates map[string]interface{}) error {
 	if len(ids) == 0 {
 		return nil
 	}
-	return r.db.WithContext(ctx).
-		Model(&model.Email{}).
-		Where("id IN ?", ids).
-		Update("is_read", false).Error
+	if deleted, ok := updates["is_deleted"].(bool); ok {
+		updates["trashed_at"] = trashedAt(deleted)
+	}
+	return r.withCounters(ctx, ids, func(tx *gorm.DB) error {
+		for start := 0; start < len(ids); start += sourceStateBatchSize {
+			end := min(start+sourceStateBatchSize, len(ids))
+			err := tx.Model(&model.Email{}).
+				Where("id IN ?", ids[start:end]).
+				Updates(updates).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// withCounters 在事务中更新邮件，并按更新前后的状态差异维护计数器
+func (r *emailRepository) withCounters(ctx context.Context, ids []int64, update func(tx *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := loadCounterStates(tx, ids)
+		if err != nil {
+			return err
+		}
+		if err := update(tx); err != nil {
+			return err
+		}
+		after, err := loadCounterStates(tx, ids)
+		if err != nil {
+			return err
+		}
+		return applyCounterDeltas(tx, counterDeltas(before, after))
+	})
 }
 
 // applyFilter 应用过滤条件
@@ -230,7 +833,19 @@ func (r *emailRepository) applyFilter(query *gorm.DB, filter *EmailFilter) *gorm
 	if filter == nil {
 		return query
 	}
+	query = applyFilterConditions(query, filter)
 
+	// 按账户筛选时每个账户都应看到自己的副本，不折叠
+	if filter.CollapseDuplicates && filter.AccountUID == "" {
+		query = r.collapseDuplicates(query, func(query *gorm.DB) *gorm.DB {
+			return applyFilterConditions(query, filter)
+		})
+	}
+	return query
+}
+
+// applyFilterConditions 应用除折叠重复邮件外的过滤条件（列名不加表名，也用于折叠时的同组副本子查询）
+func applyFilterConditions(query *gorm.DB, filter *EmailFilter) *gorm.DB {
 	if filter.AccountUID != "" {
 		query = query.Where("account_uid = ?", filter.AccountUID)
 	}
@@ -251,6 +866,10 @@ func (r *emailRepository) applyFilter(query *gorm.DB, filter *EmailFilter) *gorm
 		query = query.Where("is_deleted = ?", *filter.IsDeleted)
 	}
 
+	if filter.SourceDeleted != nil {
+		query = query.Where("source_deleted = ?", *filter.SourceDeleted)
+	}
+
 	if filter.FromAddress != "" {
 		query = query.Where("from_address LIKE ?", "%"+filter.FromAddress+"%")
 	}
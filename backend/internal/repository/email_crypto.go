@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"fusionmail/internal/model"
+	"fusionmail/pkg/crypto"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 搜索索引分词限制
+const (
+	maxSearchTokens     = 4000 // 每封邮件最多索引的词数
+	maxSearchTokenRunes = 64   // 超过长度的词（链接、编码内容等）不索引
+)
+
+// sealEmailBodies 加密邮件正文并计算正文摘要，返回恢复明文的函数（写入后调用方仍使用明文）
+// 未启用加密时正文明文保存，摘要清空
+func sealEmailBodies(ctx context.Context, cipher *crypto.FieldCipher, emails []*model.Email) (func(), error) {
+	type bodies struct{ text, html string }
+	saved := make([]bodies, len(emails))
+	restore := func() {
+		for i, email := range emails {
+			email.TextBody, email.HTMLBody = saved[i].text, saved[i].html
+		}
+	}
+	for i, email := range emails {
+		saved[i] = bodies{email.TextBody, email.HTMLBody}
+	}
+	if cipher == nil || !cipher.Enabled() {
+		for _, email := range emails {
+			email.BodyHash = ""
+		}
+		return func() {}, nil
+	}
+
+	for _, email := range emails {
+		// 已加密的正文（读取时未解密）保留原来的摘要
+		if !crypto.IsEncryptedField(email.TextBody) && !crypto.IsEncryptedField(email.HTMLBody) {
+			digest, err := cipher.Digest(ctx, email.AccountUID, email.TextBody, email.HTMLBody)
+			if err != nil {
+				return nil, fmt.Errorf("failed to digest email body: %w", err)
+			}
+			email.BodyHash = digest
+		}
+		text, err := cipher.Encrypt(ctx, email.AccountUID, email.TextBody)
+		if err != nil {
+			restore()
+			return nil, fmt.Errorf("failed to encrypt email body: %w", err)
+		}
+		html, err := cipher.Encrypt(ctx, email.AccountUID, email.HTMLBody)
+		if err != nil {
+			restore()
+			return nil, fmt.Errorf("failed to encrypt email body: %w", err)
+		}
+		email.TextBody, email.HTMLBody = text, html
+	}
+	return restore, nil
+}
+
+// openEmailBodies 解密邮件正文（明文正文原样保留）
+func openEmailBodies(ctx context.Context, cipher *crypto.FieldCipher, emails ...*model.Email) error {
+	if cipher == nil {
+		return nil
+	}
+	for _, email := range emails {
+		text, err := cipher.Decrypt(ctx, email.AccountUID, email.TextBody)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt email %d: %w", email.ID, err)
+		}
+		html, err := cipher.Decrypt(ctx, email.AccountUID, email.HTMLBody)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt email %d: %w", email.ID, err)
+		}
+		email.TextBody, email.HTMLBody = text, html
+	}
+	return nil
+}
+
+// searchIndexEntries 根据明文正文计算搜索索引（按邮件索引，调用方在写入后补上邮件 ID）
+// 未启用加密时不维护索引，搜索直接匹配明文正文
+func (r *emailRepository) searchIndexEntries(ctx context.Context, emails []*model.Email) (map[*model.Email][]string, error) {
+	if r.cipher == nil || !r.cipher.Enabled() {
+		return nil, nil
+	}
+
+	entries := make(map[*model.Email][]string, len(emails))
+	for _, email := range emails {
+		hashes, err := r.cipher.BlindIndex(ctx, email.AccountUID, searchTokens(email.TextBody))
+		if err != nil {
+			return nil, fmt.Errorf("failed to build search index: %w", err)
+		}
+		entries[email] = hashes
+	}
+	return entries, nil
+}
+
+// writeSearchIndex 在事务中替换邮件的搜索索引
+func writeSearchIndex(tx *gorm.DB, entries map[int64][]string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(entries))
+	var tokens []model.EmailSearchToken
+	for id, hashes := range entries {
+		ids = append(ids, id)
+		for _, hash := range hashes {
+			tokens = append(tokens, model.EmailSearchToken{EmailID: id, TokenHash: hash})
+		}
+	}
+	for start := 0; start < len(ids); start += sourceStateBatchSize {
+		end := min(start+sourceStateBatchSize, len(ids))
+		if err := tx.Where("email_id IN ?", ids[start:end]).Delete(&model.EmailSearchToken{}).Error; err != nil {
+			return err
+		}
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(tokens, 1000).Error
+}
+
+// searchIndexQuery 构建加密正文的搜索子查询：邮件的索引包含搜索词分词后的全部词
+// 每个账户的词哈希不同，统一搜索时带上所有账户的哈希，一封邮件只会匹配其所属账户的哈希
+func (r *emailRepository) searchIndexQuery(ctx context.Context, query string, accountUID string) (*gorm.DB, error) {
+	if r.cipher == nil {
+		return nil, nil
+	}
+	tokens := searchTokens(query)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	owners, err := r.cipher.Owners(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data keys: %w", err)
+	}
+	var hashes []string
+	for _, owner := range owners {
+		if accountUID != "" && owner != accountUID {
+			continue
+		}
+		ownerHashes, err := r.cipher.BlindIndex(ctx, owner, tokens)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, ownerHashes...)
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	return r.db.WithContext(ctx).
+		Model(&model.EmailSearchToken{}).
+		Select("email_id").
+		Where("token_hash IN ?", hashes).
+		Group("email_id").
+		Having("COUNT(DISTINCT token_hash) = ?", len(tokens)), nil
+}
+
+// searchTokens 把文本切分为搜索词：字母数字连续的部分为一个词（转小写），
+// 中日韩文字没有分隔符，按相邻两个字切分（单独的一个字作为一个词）
+func searchTokens(text string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(token string) {
+		if len(tokens) < maxSearchTokens && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	var word, cjk []rune
+	flushWord := func() {
+		if len(word) >= 2 && len(word) <= maxSearchTokenRunes {
+			add(string(word))
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			add(string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			add(string(cjk[i : i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// EncryptExisting 加密一批未加密的邮件正文并建立搜索索引（静态加密迁移）
+// 按 ID 顺序处理 afterID 之后的 limit 封邮件，返回本批最后一封邮件的 ID（没有更多邮件时为 0）和加密的邮件数
+func (r *emailRepository) EncryptExisting(ctx context.Context, afterID int64, limit int) (int64, int, error) {
+	if r.cipher == nil || !r.cipher.Enabled() {
+		return 0, 0, fmt.Errorf("encryption at rest is not enabled")
+	}
+
+	var emails []*model.Email
+	err := r.db.WithContext(ctx).
+		Select("id", "account_uid", "text_body", "html_body").
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&emails).Error
+	if err != nil || len(emails) == 0 {
+		return 0, 0, err
+	}
+	lastID := emails[len(emails)-1].ID
+
+	var pending []*model.Email
+	for _, email := range emails {
+		if (email.TextBody != "" && !crypto.IsEncryptedField(email.TextBody)) ||
+			(email.HTMLBody != "" && !crypto.IsEncryptedField(email.HTMLBody)) {
+			pending = append(pending, email)
+		}
+	}
+	if len(pending) == 0 {
+		return lastID, 0, nil
+	}
+
+	entries, err := r.searchIndexEntries(ctx, pending)
+	if err != nil {
+		return 0, 0, err
+	}
+	plaintexts := make([][2]string, len(pending))
+	for i, email := range pending {
+		plaintexts[i] = [2]string{email.TextBody, email.HTMLBody}
+	}
+	if _, err := sealEmailBodies(ctx, r.cipher, pending); err != nil {
+		return 0, 0, err
+	}
+
+	encrypted := 0
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		index := make(map[int64][]string, len(pending))
+		for i, email := range pending {
+			// 只改正文，不更新 updated_at；读取后正文被同步改写的邮件跳过（新正文已按当前配置写入）
+			result := tx.Model(&model.Email{}).
+				Where("id = ? AND COALESCE(text_body, '') = ? AND COALESCE(html_body, '') = ?", email.ID, plaintexts[i][0], plaintexts[i][1]).
+				UpdateColumns(map[string]interface{}{
+					"text_body": email.TextBody,
+					"html_body": email.HTMLBody,
+					"body_hash": email.BodyHash,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 1 {
+				index[email.ID] = entries[email]
+			}
+		}
+		encrypted = len(index)
+		return writeSearchIndex(tx, index)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return lastID, encrypted, nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"fusionmail/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// reparsedColumns 从原始邮件重新解析时更新的列（本地状态、源邮箱状态和去重、会话信息不变）
+var reparsedColumns = []string{
+	"message_id", "subject", "from_address", "from_name",
+	"to_addresses", "cc_addresses", "bcc_addresses", "reply_to",
+	"text_body", "html_body", "body_hash", "snippet",
+	"has_attachments", "attachments_count",
+	"sent_at", "in_reply_to", "references",
+}
+
+// SetRawPath 记录邮件原文的归档路径（不更新 updated_at）
+func (r *emailRepository) SetRawPath(ctx context.Context, id int64, rawPath string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.Email{}).
+		Where("id = ?", id).
+		UpdateColumn("raw_path", rawPath).Error
+}
+
+// ListArchived 按 ID 顺序列出 afterID 之后已归档原文的邮件（accountUID 为空时不按账户筛选）
+func (r *emailRepository) ListArchived(ctx context.Context, accountUID string, afterID int64, limit int) ([]*model.Email, error) {
+	query := r.db.WithContext(ctx).
+		Where("id > ? AND raw_path <> ''", afterID).
+		Order("id ASC").
+		Limit(limit)
+	if accountUID != "" {
+		query = query.Where("account_uid = ?", accountUID)
+	}
+
+	var emails []*model.Email
+	if err := query.Find(&emails).Error; err != nil {
+		return nil, err
+	}
+	if err := openEmailBodies(ctx, r.cipher, emails...); err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// UpdateParsed 更新重新解析得到的字段，并按新正文重建搜索索引
+func (r *emailRepository) UpdateParsed(ctx context.Context, email *model.Email) error {
+	entries, err := r.searchIndexEntries(ctx, []*model.Email{email})
+	if err != nil {
+		return err
+	}
+	restore, err := sealEmailBodies(ctx, r.cipher, []*model.Email{email})
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(email).Select(reparsedColumns).Updates(email).Error; err != nil {
+			return err
+		}
+		if hashes, ok := entries[email]; ok {
+			return writeSearchIndex(tx, map[int64][]string{email.ID: hashes})
+		}
+		return nil
+	})
+}
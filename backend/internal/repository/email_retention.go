@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"fusionmail/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// trashedAt 返回更新 is_deleted 时 trashed_at 列的值
+// 移入回收站时记录当前时间（已在回收站中的邮件保留原来的时间），移出时清空
+func trashedAt(deleted bool) interface{} {
+	if !deleted {
+		return nil
+	}
+	return gorm.Expr("CASE WHEN is_deleted THEN COALESCE(trashed_at, ?) ELSE ? END", time.Now(), time.Now())
+}
+
+// SetDeleted 批量移入或移出回收站
+func (r *emailRepository) SetDeleted(ctx context.Context, ids []int64, deleted bool) error {
+	return r.updateByIDs(ctx, ids, map[string]interface{}{
+		"is_deleted": deleted,
+	})
+}
+
+// ListTrashed 按 ID 顺序列出 before 之前移入回收站的邮件（只包含 ID、账户和原文路径，accountUID 为空时不按账户筛选）
+// 没有记录移入时间的邮件不列出，避免按最后更新时间误判而被提前永久删除（升级时由迁移回填移入时间）
+func (r *emailRepository) ListTrashed(ctx context.Context, accountUID string, before time.Time, afterID int64, limit int) ([]*model.Email, error) {
+	query := r.db.WithContext(ctx).
+		Select("id", "account_uid", "raw_path").
+		Where("is_deleted = ? AND trashed_at < ? AND id > ?", true, before, afterID).
+		Order("id ASC").
+		Limit(limit)
+	if accountUID != "" {
+		query = query.Where("account_uid = ?", accountUID)
+	}
+
+	var emails []*model.Email
+	err := query.Find(&emails).Error
+	return emails, err
+}
+
+// ListArchivedIDs 按 ID 顺序列出账户中 before 之前发送的已归档邮件 ID（跳过星标邮件和回收站中的邮件）
+func (r *emailRepository) ListArchivedIDs(ctx context.Context, accountUID string, before time.Time, afterID int64, limit int) ([]int64, error) {
+	var ids []int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Email{}).
+		Where("account_uid = ? AND is_archived = ? AND is_deleted = ? AND is_starred = ?", accountUID, true, false, false).
+		Where("sent_at < ? AND id > ?", before, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Purge 永久删除邮件及其搜索索引（附件和原文由调用方先行删除），返回提升为重复组主副本的邮件 ID
+// 被删除的主副本还有其他副本时，最早入库的副本成为新的主副本，其余副本改为指向它；
+// 同时写入墓碑记录，之后的同步不会重新拉取这些邮件
+func (r *emailRepository) Purge(ctx context.Context, ids []int64) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var promoted []int64
+	err := r.withCounters(ctx, ids, func(tx *gorm.DB) error {
+		var duplicates []*model.Email
+		err := tx.Select("id", "canonical_id").
+			Where("canonical_id IN ? AND id NOT IN ?", ids, ids).
+			Order("id ASC").
+			Find(&duplicates).Error
+		if err != nil {
+			return err
+		}
+
+		groups := make(map[int64][]int64)
+		for _, duplicate := range duplicates {
+			groups[*duplicate.CanonicalID] = append(groups[*duplicate.CanonicalID], duplicate.ID)
+		}
+		for _, members := range groups {
+			canonical := members[0]
+			if err := tx.Model(&model.Email{}).Where("id = ?", canonical).UpdateColumn("canonical_id", nil).Error; err != nil {
+				return err
+			}
+			if len(members) > 1 {
+				err := tx.Model(&model.Email{}).Where("id IN ?", members[1:]).UpdateColumn("canonical_id", canonical).Error
+				if err != nil {
+					return err
+				}
+			}
+			promoted = append(promoted, canonical)
+		}
+
+		var purged []*model.Email
+		if err := tx.Select("account_uid", "provider_id").Where("id IN ?", ids).Find(&purged).Error; err != nil {
+			return err
+		}
+		if len(purged) > 0 {
+			now := time.Now()
+			tombstones := make([]*model.PurgedEmail, 0, len(purged))
+			for _, email := range purged {
+				tombstones = append(tombstones, &model.PurgedEmail{AccountUID: email.AccountUID, ProviderID: email.ProviderID, PurgedAt: now})
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tombstones).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("email_id IN ?", ids).Delete(&model.EmailSearchToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&model.Email{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(promoted, func(i, j int) bool { return promoted[i] < promoted[j] })
+	return promoted, nil
+}
+
+// FindPurgedProviderIDs 返回账户中已永久删除（有墓碑记录）的邮件 ID
+func (r *emailRepository) FindPurgedProviderIDs(ctx context.Context, accountUID string, providerIDs []string) (map[string]bool, error) {
+	purged := make(map[string]bool)
+	if len(providerIDs) == 0 {
+		return purged, nil
+	}
+
+	var ids []string
+	err := r.db.WithContext(ctx).
+		Model(&model.PurgedEmail{}).
+		Where("account_uid = ? AND provider_id IN ?", accountUID, providerIDs).
+		Pluck("provider_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		purged[id] = true
+	}
+	return purged, nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"fusionmail/internal/model"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestBuildUpsertSQL 测试批量写入语句的占位符、冲突更新和返回列
+func TestBuildUpsertSQL(t *testing.T) {
+	sql := buildUpsertSQL([]string{"provider_id", "account_uid", "subject", "references"}, 2)
+
+	if got := strings.Count(sql, "?"); got != 8 {
+		t.Errorf("placeholders = %d, want 8", got)
+	}
+	for _, want := range []string{
+		`INSERT INTO emails ("provider_id", "account_uid", "subject", "references") VALUES (?, ?, ?, ?), (?, ?, ?, ?)`,
+		`ON CONFLICT (provider_id, account_uid) DO UPDATE SET`,
+		`"snippet" = CASE WHEN emails."snippet" IS DISTINCT FROM EXCLUDED."snippet" THEN EXCLUDED."snippet" ELSE emails."snippet" END`,
+		// 加密正文的密文每次不同，按正文摘要判断是否变化
+		`"html_body" = CASE WHEN (emails."body_hash" IS DISTINCT FROM EXCLUDED."body_hash" OR (COALESCE(EXCLUDED."body_hash", '') = '' AND`,
+		`WHERE emails."subject" IS DISTINCT FROM EXCLUDED."subject" OR`,
+		// 拉取路径没有设置源文件夹时保留对账写入的值
+		`"source_folder" = CASE WHEN (COALESCE(EXCLUDED."source_folder", '') <> '' AND emails."source_folder" IS DISTINCT FROM EXCLUDED."source_folder") THEN`,
+		`RETURNING id, provider_id, account_uid, local_thread_id, (xmax = 0) AS inserted`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("upsert SQL missing %q\n%s", want, sql)
+		}
+	}
+
+	// 本地状态列不能被同步覆盖
+	for _, column := range []string{"is_read", "is_starred", "is_archived", "is_deleted"} {
+		if strings.Contains(sql, `"`+column+`"`) {
+			t.Errorf("upsert SQL touches local state column %s", column)
+		}
+	}
+}
+
+// TestSearchTokens 测试搜索分词：英文按词转小写，中文按相邻两字切分
+func TestSearchTokens(t *testing.T) {
+	got := searchTokens("Invoice #2024-07 for ACME, invoice a 发票已开具")
+	want := []string{"invoice", "2024", "07", "for", "acme", "发票", "票已", "已开", "开具"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("searchTokens = %v, want %v", got, want)
+	}
+
+	if got := searchTokens("票"); len(got) != 1 || got[0] != "票" {
+		t.Errorf("single character tokens = %v", got)
+	}
+	if got := searchTokens("x " + strings.Repeat("a", maxSearchTokenRunes+1)); len(got) != 0 {
+		t.Errorf("short and overlong words should be skipped, got %v", got)
+	}
+}
+
+// TestAssignDuplicateGroups 测试写入前选定重复组主副本：已入库的主副本优先，同一批中的重复邮件指向本批首封
+func TestAssignDuplicateGroups(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&model.Email{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	stored := &model.Email{ProviderID: "p1", AccountUID: "a", DedupKey: "mid:old", IsRead: true, IsStarred: true}
+	if err := db.Create(stored).Error; err != nil {
+		t.Fatalf("failed to create email: %v", err)
+	}
+
+	self := &model.Email{ProviderID: "p1", AccountUID: "a", DedupKey: "mid:old"}
+	copied := &model.Email{ProviderID: "p2", AccountUID: "b", DedupKey: "mid:old"}
+	first := &model.Email{ProviderID: "p3", AccountUID: "a", DedupKey: "mid:new"}
+	second := &model.Email{ProviderID: "p4", AccountUID: "b", DedupKey: "mid:new"}
+	third := &model.Email{ProviderID: "p5", AccountUID: "c", DedupKey: "mid:new"}
+
+	duplicates, err := assignDuplicateGroups(db, []*model.Email{self, copied, first, second, third})
+	if err != nil {
+		t.Fatalf("assignDuplicateGroups() error = %v", err)
+	}
+
+	if self.CanonicalID != nil {
+		t.Errorf("stored canonical pointed at %d", *self.CanonicalID)
+	}
+	if copied.CanonicalID == nil || *copied.CanonicalID != stored.ID || !copied.IsRead || !copied.IsStarred {
+		t.Errorf("copy = %+v, want canonical %d with its read and star state", copied, stored.ID)
+	}
+	if first.CanonicalID != nil {
+		t.Errorf("first new email pointed at %d", *first.CanonicalID)
+	}
+	if len(duplicates) != 2 || duplicates[second] != first || duplicates[third] != first {
+		t.Errorf("duplicates = %v, want second and third pointing at first", duplicates)
+	}
+}
+
+// TestCollapseDuplicates 测试统一列表中重复组只显示一封，主副本被删除或归档时由其他副本代表该组
+func TestCollapseDuplicates(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&model.Email{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	canonical := &model.Email{ProviderID: "p1", AccountUID: "a", DedupKey: "mid:1"}
+	if err := db.Create(canonical).Error; err != nil {
+		t.Fatalf("failed to create email: %v", err)
+	}
+	copies := []*model.Email{
+		{ProviderID: "p2", AccountUID: "b", DedupKey: "mid:1", CanonicalID: &canonical.ID},
+		{ProviderID: "p3", AccountUID: "c", DedupKey: "mid:1", CanonicalID: &canonical.ID},
+		{ProviderID: "p4", AccountUID: "a", DedupKey: "mid:2"},
+	}
+	if err := db.Create(copies).Error; err != nil {
+		t.Fatalf("failed to create emails: %v", err)
+	}
+
+	repo := NewEmailRepository(db, nil)
+	ctx := context.Background()
+	visible := func(step string, filter *EmailFilter, want ...string) {
+		t.Helper()
+		summaries, err := repo.ListSummaries(ctx, filter, nil, 10)
+		if err != nil {
+			t.Fatalf("%s: ListSummaries() error = %v", step, err)
+		}
+		var got []string
+		for _, summary := range summaries {
+			got = append(got, summary.ProviderID)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: visible = %v, want %v", step, got, want)
+		}
+		if count, err := repo.Count(ctx, filter); err != nil || count != int64(len(want)) {
+			t.Errorf("%s: Count() = %d, %v; want %d", step, count, err, len(want))
+		}
+	}
+
+	notDeleted := false
+	inbox := &EmailFilter{IsDeleted: &notDeleted, CollapseDuplicates: true}
+	visible("all present", inbox, "p1", "p4")
+
+	// 主副本移入回收站：组内下一封副本代表该组，回收站中仍显示主副本
+	db.Model(canonical).Update("is_deleted", true)
+	visible("canonical deleted", inbox, "p2", "p4")
+	deleted := true
+	visible("trash", &EmailFilter{IsDeleted: &deleted, CollapseDuplicates: true}, "p1")
+
+	// 按账户筛选时不折叠
+	visible("account filter", &EmailFilter{AccountUID: "c", IsDeleted: &notDeleted, CollapseDuplicates: true}, "p3")
+}
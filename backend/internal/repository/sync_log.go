@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"time"
+
 	"fusionmail/internal/model"
 
 	"gorm.io/gorm"
@@ -10,10 +12,12 @@ import (
 // SyncLogRepository 同步日志数据仓库接口
 type SyncLogRepository interface {
 	Create(ctx context.Context, log *model.SyncLog) error
+	Update(ctx context.Context, log *model.SyncLog) error
 	FindByID(ctx context.Context, id int64) (*model.SyncLog, error)
 	List(ctx context.Context, accountUID string, offset, limit int) ([]*model.SyncLog, int64, error)
 	ListByStatus(ctx context.Context, status string, offset, limit int) ([]*model.SyncLog, int64, error)
-	DeleteOldLogs(ctx context.Context, days int) error
+	DeleteOldLogs(ctx context.Context, before time.Time) (int64, error)
+	CountOldLogs(ctx context.Context, before time.Time) (int64, error)
 
 	// 系统管理需要的方法
 	Count(ctx context.Context, status string) (int64, error)
@@ -37,6 +41,11 @@ func (r *syncLogRepository) Create(ctx context.Context, log *model.SyncLog) erro
 	return r.db.WithContext(ctx).Create(log).Error
 }
 
+// Update 更新同步日志
+func (r *syncLogRepository) Update(ctx context.Context, log *model.SyncLog) error {
+	return r.db.WithContext(ctx).Save(log).Error
+}
+
 // FindByID 根据 ID 查找同步日志
 func (r *syncLogRepository) FindByID(ctx context.Context, id int64) (*model.SyncLog, error) {
 	var log model.SyncLog
@@ -96,11 +105,22 @@ func (r *syncLogRepository) ListByStatus(ctx context.Context, status string, off
 	return logs, total, err
 }
 
-// DeleteOldLogs 删除旧日志
-func (r *syncLogRepository) DeleteOldLogs(ctx context.Context, days int) error {
-	return r.db.WithContext(ctx).
-		Where("started_at < NOW() - INTERVAL '? days'", days).
-		Delete(&model.SyncLog{}).Error
+// DeleteOldLogs 删除 before 之前开始的日志，返回删除的条数
+func (r *syncLogRepository) DeleteOldLogs(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("started_at < ?", before).
+		Delete(&model.SyncLog{})
+	return result.RowsAffected, result.Error
+}
+
+// CountOldLogs 统计 before 之前开始的日志数
+func (r *syncLogRepository) CountOldLogs(ctx context.Context, before time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.SyncLog{}).
+		Where("started_at < ?", before).
+		Count(&count).Error
+	return count, err
 }
 
 // Count 统计同步日志数量
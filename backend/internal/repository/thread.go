@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/pkg/crypto"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxThreadParticipants 会话参与者列表保存的最大地址数
+const maxThreadParticipants = 50
+
+// ThreadFilter 会话过滤条件
+type ThreadFilter struct {
+	AccountUID string // 只返回包含该账户邮件的会话
+	IsArchived *bool
+	Unread     *bool // true 只返回有未读邮件的会话
+}
+
+// ThreadRepository 会话数据仓库接口
+type ThreadRepository interface {
+	Create(ctx context.Context, thread *model.Thread) error
+	FindByID(ctx context.Context, id int64) (*model.Thread, error)
+	List(ctx context.Context, filter *ThreadFilter, offset, limit int) ([]*model.Thread, int64, error)
+	ListEmails(ctx context.Context, threadID int64) ([]*model.Email, error)
+	ListEmailIDs(ctx context.Context, threadID int64) ([]int64, error)
+
+	// 会话归并需要的方法
+	ListThreadingFields(ctx context.Context) ([]*model.Email, error)
+	FindIDsByMessageKeys(ctx context.Context, keys []string) ([]int64, error)
+	FindRecentBySubjectKey(ctx context.Context, subjectKey string, since time.Time) (*model.Thread, error)
+	FindIDsByEmailIDs(ctx context.Context, emailIDs []int64) ([]int64, error)
+	AddMessageKeys(ctx context.Context, threadID int64, keys []string) error
+	AssignEmails(ctx context.Context, threadID int64, emailIDs []int64) error
+	Merge(ctx context.Context, targetID int64, sourceIDs []int64) error
+	RefreshStats(ctx context.Context, threadID int64) error
+	DeleteAll(ctx context.Context) error
+}
+
+// threadRepository 会话数据仓库实现
+type threadRepository struct {
+	db     *gorm.DB
+	cipher *crypto.FieldCipher
+}
+
+// NewThreadRepository 创建会话数据仓库实例
+// cipher 可以为 nil，此时会话中的邮件正文按明文读取；应与邮件仓库使用同一个加密器
+func NewThreadRepository(db *gorm.DB, cipher *crypto.FieldCipher) ThreadRepository {
+	return &threadRepository{db: db, cipher: cipher}
+}
+
+// Create 创建会话
+func (r *threadRepository) Create(ctx context.Context, thread *model.Thread) error {
+	return r.db.WithContext(ctx).Create(thread).Error
+}
+
+// FindByID 根据 ID 查找会话
+func (r *threadRepository) FindByID(ctx context.Context, id int64) (*model.Thread, error) {
+	var thread model.Thread
+	err := r.db.WithContext(ctx).First(&thread, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// List 获取会话列表（按最新邮件时间倒序，不含邮件已全部删除的会话）
+func (r *threadRepository) List(ctx context.Context, filter *ThreadFilter, offset, limit int) ([]*model.Thread, int64, error) {
+	var threads []*model.Thread
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&model.Thread{}).Where("message_count > 0")
+	if filter != nil {
+		if filter.AccountUID != "" {
+			query = query.Where("EXISTS (SELECT 1 FROM emails WHERE emails.local_thread_id = threads.id AND emails.account_uid = ?)", filter.AccountUID)
+		}
+		if filter.IsArchived != nil {
+			query = query.Where("is_archived = ?", *filter.IsArchived)
+		}
+		if filter.Unread != nil {
+			if *filter.Unread {
+				query = query.Where("unread_count > 0")
+			} else {
+				query = query.Where("unread_count = 0")
+			}
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Offset(offset).
+		Limit(limit).
+		Order("last_message_at DESC").
+		Find(&threads).Error
+
+	return threads, total, err
+}
+
+// ListEmails 获取会话中的邮件（按发送时间正序，不含已删除的邮件）
+func (r *threadRepository) ListEmails(ctx context.Context, threadID int64) ([]*model.Email, error) {
+	var emails []*model.Email
+	err := r.db.WithContext(ctx).
+		Where("local_thread_id = ? AND is_deleted = ?", threadID, false).
+		Order("sent_at ASC").
+		Find(&emails).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := openEmailBodies(ctx, r.cipher, emails...); err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// ListEmailIDs 获取会话中全部未删除邮件的 ID（包括跨账户重复副本）
+func (r *threadRepository) ListEmailIDs(ctx context.Context, threadID int64) ([]int64, error) {
+	var ids []int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Email{}).
+		Where("local_thread_id = ? AND is_deleted = ?", threadID, false).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ListThreadingFields 获取全部邮件的会话归并字段（重建会话时使用）
+func (r *threadRepository) ListThreadingFields(ctx context.Context) ([]*model.Email, error) {
+	var emails []*model.Email
+	err := r.db.WithContext(ctx).
+		Select("id", "message_id", "in_reply_to", "references", "subject", "sent_at").
+		Order("id ASC").
+		Find(&emails).Error
+	return emails, err
+}
+
+// FindIDsByMessageKeys 查找包含或引用了指定 Message-ID 的会话
+func (r *threadRepository) FindIDsByMessageKeys(ctx context.Context, keys []string) ([]int64, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	var ids []int64
+	err := r.db.WithContext(ctx).
+		Model(&model.ThreadRef{}).
+		Distinct("thread_id").
+		Where("message_key IN ?", keys).
+		Pluck("thread_id", &ids).Error
+	return ids, err
+}
+
+// FindRecentBySubjectKey 查找指定时间之后仍有邮件的同主题会话（取最近的一个）
+func (r *threadRepository) FindRecentBySubjectKey(ctx context.Context, subjectKey string, since time.Time) (*model.Thread, error) {
+	var thread model.Thread
+	err := r.db.WithContext(ctx).
+		Where("subject_key = ? AND last_message_at >= ?", subjectKey, since).
+		Order("last_message_at DESC").
+		First(&thread).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// FindIDsByEmailIDs 获取邮件所属的会话 ID
+func (r *threadRepository) FindIDsByEmailIDs(ctx context.Context, emailIDs []int64) ([]int64, error) {
+	if len(emailIDs) == 0 {
+		return nil, nil
+	}
+
+	var ids []int64
+	err := r.db.WithContext(ctx).
+		Model(&model.Email{}).
+		Distinct("local_thread_id").
+		Where("id IN ? AND local_thread_id IS NOT NULL", emailIDs).
+		Pluck("local_thread_id", &ids).Error
+	return ids, err
+}
+
+// AddMessageKeys 记录会话包含或引用的 Message-ID（已记录的忽略）
+func (r *threadRepository) AddMessageKeys(ctx context.Context, threadID int64, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	refs := make([]*model.ThreadRef, 0, len(keys))
+	for _, key := range keys {
+		refs = append(refs, &model.ThreadRef{MessageKey: key, ThreadID: threadID})
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(refs, 500).Error
+}
+
+// AssignEmails 将邮件归入会话
+func (r *threadRepository) AssignEmails(ctx context.Context, threadID int64, emailIDs []int64) error {
+	if len(emailIDs) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Model(&model.Email{}).
+		Where("id IN ?", emailIDs).
+		Update("local_thread_id", threadID).Error
+}
+
+// Merge 将多个会话合并到目标会话（迁移邮件和 Message-ID 引用后删除被合并的会话）
+func (r *threadRepository) Merge(ctx context.Context, targetID int64, sourceIDs []int64) error {
+	if len(sourceIDs) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Email{}).
+			Where("local_thread_id IN ?", sourceIDs).
+			Update("local_thread_id", targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ThreadRef{}).
+			Where("thread_id IN ?", sourceIDs).
+			Update("thread_id", targetID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Thread{}, sourceIDs).Error
+	})
+}
+
+// threadStats 会话统计查询结果
+type threadStats struct {
+	MessageCount  int
+	UnreadCount   int
+	LastMessageAt *time.Time
+	IsArchived    bool
+}
+
+// RefreshStats 根据会话中的邮件重新计算主题、参与者、最新时间和未读数
+// 统计不含已删除的邮件，邮件数和未读数只计重复组的主副本
+func (r *threadRepository) RefreshStats(ctx context.Context, threadID int64) error {
+	db := r.db.WithContext(ctx)
+
+	var stats threadStats
+	err := db.Model(&model.Email{}).
+		Select("COUNT(*) AS message_count, "+
+			"COUNT(*) FILTER (WHERE NOT is_read) AS unread_count, "+
+			"MAX(sent_at) AS last_message_at, "+
+			"COALESCE(BOOL_AND(is_archived), FALSE) AS is_archived").
+		Where("local_thread_id = ? AND is_deleted = ? AND canonical_id IS NULL", threadID, false).
+		Scan(&stats).Error
+	if err != nil {
+		return err
+	}
+
+	var participants, accountUIDs []string
+	active := db.Model(&model.Email{}).Where("local_thread_id = ? AND is_deleted = ?", threadID, false)
+	if err := active.Session(&gorm.Session{}).Distinct("from_address").Limit(maxThreadParticipants).Pluck("from_address", &participants).Error; err != nil {
+		return err
+	}
+	if err := active.Session(&gorm.Session{}).Distinct("account_uid").Pluck("account_uid", &accountUIDs).Error; err != nil {
+		return err
+	}
+	participantsJSON, _ := json.Marshal(participants)
+	accountUIDsJSON, _ := json.Marshal(accountUIDs)
+
+	updates := map[string]interface{}{
+		"message_count": stats.MessageCount,
+		"unread_count":  stats.UnreadCount,
+		"is_archived":   stats.IsArchived,
+		"participants":  string(participantsJSON),
+		"account_uids":  string(accountUIDsJSON),
+	}
+	if stats.LastMessageAt != nil {
+		updates["last_message_at"] = *stats.LastMessageAt
+	}
+
+	// 回复可能先于原始邮件同步，主题始终取最早的一封
+	var first model.Email
+	err = db.Select("subject").
+		Where("local_thread_id = ?", threadID).
+		Order("sent_at ASC").
+		Limit(1).
+		Find(&first).Error
+	if err != nil {
+		return err
+	}
+	if first.Subject != "" {
+		updates["subject"] = first.Subject
+	}
+
+	return db.Model(&model.Thread{}).Where("id = ?", threadID).Updates(updates).Error
+}
+
+// DeleteAll 删除全部会话并清除邮件的会话归属（重建会话前调用）
+func (r *threadRepository) DeleteAll(ctx context.Context) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Email{}).
+			Where("local_thread_id IS NOT NULL").
+			Update("local_thread_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("1 = 1").Delete(&model.ThreadRef{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("1 = 1").Delete(&model.Thread{}).Error
+	})
+}
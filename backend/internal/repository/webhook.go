@@ -150,7 +150,8 @@ func (r *webhookRepository) CountActive(ctx context.Context) (int64, error) {
 type WebhookLogRepository interface {
 	Create(ctx context.Context, log *model.WebhookLog) error
 	FindByWebhookID(ctx context.Context, webhookID int64, offset, limit int) ([]*model.WebhookLog, int64, error)
-	DeleteOldLogs(ctx context.Context, beforeDate time.Time) error
+	DeleteOldLogs(ctx context.Context, before time.Time) (int64, error)
+	CountOldLogs(ctx context.Context, before time.Time) (int64, error)
 }
 
 // webhookLogRepository Webhook 日志数据仓库实现
@@ -189,9 +190,20 @@ func (r *webhookLogRepository) FindByWebhookID(ctx context.Context, webhookID in
 	return logs, total, err
 }
 
-// DeleteOldLogs 删除旧日志
-func (r *webhookLogRepository) DeleteOldLogs(ctx context.Context, beforeDate time.Time) error {
-	return r.db.WithContext(ctx).
-		Where("created_at < ?", beforeDate).
-		Delete(&model.WebhookLog{}).Error
+// DeleteOldLogs 删除 before 之前创建的日志，返回删除的条数
+func (r *webhookLogRepository) DeleteOldLogs(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("created_at < ?", before).
+		Delete(&model.WebhookLog{})
+	return result.RowsAffected, result.Error
+}
+
+// CountOldLogs 统计 before 之前创建的日志数
+func (r *webhookLogRepository) CountOldLogs(ctx context.Context, before time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.WebhookLog{}).
+		Where("created_at < ?", before).
+		Count(&count).Error
+	return count, err
 }
@@ -17,6 +17,12 @@ func SetupRouter(
 	ruleHandler *handler.RuleHandler,
 	webhookHandler *handler.WebhookHandler,
 	systemHandler *handler.SystemHandler,
+	backfillHandler *handler.BackfillHandler,
+	syncHandler *handler.SyncHandler,
+	attachmentHandler *handler.AttachmentHandler,
+	threadHandler *handler.ThreadHandler,
+	rawMessageHandler *handler.RawMessageHandler,
+	renderHandler *handler.EmailRenderHandler,
 	syncManager *service.SyncManager,
 	redisClient *redis.Client,
 	jwtSecret string,
@@ -51,6 +57,10 @@ func SetupRouter(
 		// 获取邮箱提供商列表（无需认证）
 		api.GET("/system/providers", systemHandler.GetProviders)
 
+		// 签名下载链接（无需认证，由链接签名校验权限）
+		api.GET("/files/attachments/:id", rateLimitMiddleware.Limit(), attachmentHandler.DownloadSigned)
+		api.GET("/files/images", rateLimitMiddleware.Limit(), renderHandler.ProxyImage)
+
 
 
 		// 认证接口（无需认证，但有速率限制）
@@ -81,6 +91,13 @@ func SetupRouter(
 				accounts.POST("/:uid/disable", accountHandler.DisableAccount)
 				accounts.POST("/:uid/enable", accountHandler.EnableAccount)
 				accounts.POST("/:uid/clear-error", accountHandler.ClearSyncError)
+
+				// 历史邮件回填
+				accounts.POST("/:uid/backfill", backfillHandler.Start)
+				accounts.GET("/:uid/backfill", backfillHandler.Get)
+				accounts.POST("/:uid/backfill/pause", backfillHandler.Pause)
+				accounts.POST("/:uid/backfill/resume", backfillHandler.Resume)
+				accounts.DELETE("/:uid/backfill", backfillHandler.Cancel)
 			}
 
 			// 邮件管理接口
@@ -89,13 +106,32 @@ func SetupRouter(
 				emails.GET("", emailHandler.GetEmailList)
 				emails.GET("/search", emailHandler.SearchEmails)
 				emails.GET("/unread-count", emailHandler.GetUnreadCount)
+				emails.GET("/trash", emailHandler.GetTrash)
+				emails.DELETE("/trash", emailHandler.EmptyTrash)
 				emails.GET("/stats/:account_uid", emailHandler.GetAccountStats)
 				emails.GET("/:id", emailHandler.GetEmailByID)
 				emails.POST("/mark-read", emailHandler.MarkAsRead)
 				emails.POST("/mark-unread", emailHandler.MarkAsUnread)
+				emails.POST("/restore", emailHandler.RestoreEmails)
 				emails.POST("/:id/toggle-star", emailHandler.ToggleStar)
 				emails.POST("/:id/archive", emailHandler.ArchiveEmail)
 				emails.DELETE("/:id", emailHandler.DeleteEmail)
+				emails.POST("/:id/restore", emailHandler.RestoreEmail)
+				emails.DELETE("/:id/permanent", emailHandler.PurgeEmail)
+				emails.GET("/:id/attachments", attachmentHandler.GetEmailAttachments)
+				emails.GET("/:id/raw", rawMessageHandler.DownloadRaw)
+				emails.GET("/:id/headers", rawMessageHandler.GetHeaders)
+				emails.GET("/:id/render", renderHandler.Render)
+			}
+
+			// 会话接口
+			threads := protected.Group("/threads")
+			{
+				threads.GET("", threadHandler.ListThreads)
+				threads.GET("/:id", threadHandler.GetThread)
+				threads.POST("/:id/read", threadHandler.MarkAsRead)
+				threads.POST("/:id/unread", threadHandler.MarkAsUnread)
+				threads.POST("/:id/archive", threadHandler.Archive)
 			}
 
 			// 规则管理接口
@@ -123,34 +159,20 @@ func SetupRouter(
 				webhooks.GET("/:id/logs", webhookHandler.GetWebhookLogs)
 			}
 
-			// 附件管理接口（待实现）
-			// attachments := protected.Group("/attachments")
-			// {
-			// 	attachments.GET("/:id", attachmentHandler.GetAttachment)
-			// 	attachments.GET("/:id/download", attachmentHandler.DownloadAttachment)
-			// 	attachments.DELETE("/:id", attachmentHandler.DeleteAttachment)
-			// }
-
-			// 邮件附件接口（待实现）
-			// emails.GET("/:id/attachments", attachmentHandler.GetEmailAttachments)
+			// 附件管理接口
+			attachments := protected.Group("/attachments")
+			{
+				attachments.GET("/:id", attachmentHandler.GetAttachment)
+				attachments.GET("/:id/download", attachmentHandler.DownloadAttachment)
+				attachments.POST("/:id/link", attachmentHandler.CreateLink)
+				attachments.DELETE("/:id", attachmentHandler.DeleteAttachment)
+			}
 
 			// 同步管理接口
 			sync := protected.Group("/sync")
 			{
-				sync.POST("/accounts/:uid", func(c *gin.Context) {
-					accountUID := c.Param("uid")
-					if err := syncManager.SyncAccount(c.Request.Context(), accountUID); err != nil {
-						c.JSON(500, gin.H{
-							"success": false,
-							"error":   err.Error(),
-						})
-						return
-					}
-					c.JSON(200, gin.H{
-						"success": true,
-						"message": "同步任务已启动",
-					})
-				})
+				sync.POST("/accounts/:uid", syncHandler.SyncAccount)
+				sync.GET("/accounts/:uid/job", syncHandler.GetLatestJob)
 
 				sync.POST("/all", func(c *gin.Context) {
 					if err := syncManager.SyncAllAccounts(c.Request.Context()); err != nil {
@@ -168,6 +190,11 @@ func SetupRouter(
 
 				sync.GET("/status", systemHandler.GetSyncStatus)
 
+				// 同步任务进度和取消
+				sync.GET("/jobs/:id", syncHandler.GetJob)
+				sync.GET("/jobs/:id/events", syncHandler.StreamJob)
+				sync.DELETE("/jobs/:id", syncHandler.CancelJob)
+
 				// 同步日志接口
 				sync.GET("/logs", systemHandler.GetSyncLogs)
 			}
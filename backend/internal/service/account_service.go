@@ -10,6 +10,7 @@ import (
 	"fusionmail/internal/model"
 	"fusionmail/internal/repository"
 	"fusionmail/pkg/crypto"
+	"fusionmail/pkg/event"
 
 	"github.com/google/uuid"
 )
@@ -56,6 +57,13 @@ type CreateAccountRequest struct {
 	Password     string `json:"password" binding:"required"`
 	SyncEnabled  bool   `json:"sync_enabled"`
 	SyncInterval int    `json:"sync_interval"`
+	// 单个附件大小上限（MB），0 表示使用全局默认值
+	MaxAttachmentMB int `json:"max_attachment_mb,omitempty" binding:"omitempty,min=0"`
+	// 保留策略，0 表示使用全局默认值，-1 表示该账户不清理
+	TrashRetentionDays      int `json:"trash_retention_days,omitempty" binding:"omitempty,min=-1"`
+	ArchiveRetentionDays    int `json:"archive_retention_days,omitempty" binding:"omitempty,min=-1"`
+	AttachmentRetentionDays int `json:"attachment_retention_days,omitempty" binding:"omitempty,min=-1"`
+	AttachmentRetentionMB   int `json:"attachment_retention_mb,omitempty" binding:"omitempty,min=-1"`
 	// 通用邮箱配置字段
 	IMAPHost   string `json:"imap_host,omitempty"`
 	IMAPPort   int    `json:"imap_port,omitempty"`
@@ -70,6 +78,13 @@ type UpdateAccountRequest struct {
 	Password     *string `json:"password,omitempty"`
 	SyncEnabled  *bool   `json:"sync_enabled,omitempty"`
 	SyncInterval *int    `json:"sync_interval,omitempty"`
+	// 单个附件大小上限（MB），0 表示使用全局默认值
+	MaxAttachmentMB *int `json:"max_attachment_mb,omitempty" binding:"omitempty,min=0"`
+	// 保留策略，0 表示使用全局默认值，-1 表示该账户不清理
+	TrashRetentionDays      *int `json:"trash_retention_days,omitempty" binding:"omitempty,min=-1"`
+	ArchiveRetentionDays    *int `json:"archive_retention_days,omitempty" binding:"omitempty,min=-1"`
+	AttachmentRetentionDays *int `json:"attachment_retention_days,omitempty" binding:"omitempty,min=-1"`
+	AttachmentRetentionMB   *int `json:"attachment_retention_mb,omitempty" binding:"omitempty,min=-1"`
 	// 通用邮箱配置字段
 	IMAPHost   *string `json:"imap_host,omitempty"`
 	IMAPPort   *int    `json:"imap_port,omitempty"`
@@ -78,17 +93,28 @@ type UpdateAccountRequest struct {
 	Encryption *string `json:"encryption,omitempty"`
 }
 
+// AccountChangeNotifier 账户变更通知接口
+// 由同步调度器实现，账户新增、修改或删除后立即重新计算调度
+type AccountChangeNotifier interface {
+	NotifyAccountChanged(accountUID string)
+}
+
 // accountService 账户管理服务实现
 type accountService struct {
 	accountRepo    repository.AccountRepository
 	adapterFactory *adapter.Factory
 	encryptor      crypto.Encryptor
+	notifier       AccountChangeNotifier
+	events         EventPublisher
 }
 
 // NewAccountService 创建账户管理服务实例
+// notifier 可以为 nil，此时账户变更不会通知调度器；events 可以为 nil，此时不发布账户事件
 func NewAccountService(
 	accountRepo repository.AccountRepository,
 	adapterFactory *adapter.Factory,
+	notifier AccountChangeNotifier,
+	events EventPublisher,
 ) (AccountService, error) {
 	encryptor, err := crypto.NewEncryptor()
 	if err != nil {
@@ -99,9 +125,18 @@ func NewAccountService(
 		accountRepo:    accountRepo,
 		adapterFactory: adapterFactory,
 		encryptor:      encryptor,
+		notifier:       notifier,
+		events:         events,
 	}, nil
 }
 
+// notifyChanged 通知调度器账户已变更
+func (s *accountService) notifyChanged(uid string) {
+	if s.notifier != nil {
+		s.notifier.NotifyAccountChanged(uid)
+	}
+}
+
 // Create 创建账户
 func (s *accountService) Create(ctx context.Context, req *CreateAccountRequest) (*model.Account, error) {
 	// 生成唯一 UID
@@ -123,6 +158,12 @@ func (s *accountService) Create(ctx context.Context, req *CreateAccountRequest)
 		EncryptedCredentials: encryptedPassword,
 		SyncEnabled:          req.SyncEnabled,
 		SyncInterval:         req.SyncInterval,
+		MaxAttachmentMB:      req.MaxAttachmentMB,
+		// 保留策略
+		TrashRetentionDays:      req.TrashRetentionDays,
+		ArchiveRetentionDays:    req.ArchiveRetentionDays,
+		AttachmentRetentionDays: req.AttachmentRetentionDays,
+		AttachmentRetentionMB:   req.AttachmentRetentionMB,
 		// 通用邮箱配置
 		IMAPHost:   req.IMAPHost,
 		IMAPPort:   req.IMAPPort,
@@ -143,6 +184,8 @@ func (s *accountService) Create(ctx context.Context, req *CreateAccountRequest)
 		return nil, fmt.Errorf("failed to create account: %w", err)
 	}
 
+	s.notifyChanged(account.UID)
+	publishEvent(ctx, s.events, event.AccountEvent(event.EventAccountAdded, account.UID, account.Email))
 	return account, nil
 }
 
@@ -193,6 +236,22 @@ func (s *accountService) Update(ctx context.Context, uid string, req *UpdateAcco
 	if req.SyncInterval != nil {
 		account.SyncInterval = *req.SyncInterval
 	}
+	if req.MaxAttachmentMB != nil {
+		account.MaxAttachmentMB = *req.MaxAttachmentMB
+	}
+	// 更新保留策略
+	if req.TrashRetentionDays != nil {
+		account.TrashRetentionDays = *req.TrashRetentionDays
+	}
+	if req.ArchiveRetentionDays != nil {
+		account.ArchiveRetentionDays = *req.ArchiveRetentionDays
+	}
+	if req.AttachmentRetentionDays != nil {
+		account.AttachmentRetentionDays = *req.AttachmentRetentionDays
+	}
+	if req.AttachmentRetentionMB != nil {
+		account.AttachmentRetentionMB = *req.AttachmentRetentionMB
+	}
 	// 更新通用邮箱配置
 	if req.IMAPHost != nil {
 		account.IMAPHost = *req.IMAPHost
@@ -210,6 +269,17 @@ func (s *accountService) Update(ctx context.Context, uid string, req *UpdateAcco
 		account.Encryption = *req.Encryption
 	}
 
+	// 凭证或服务器配置变更后，清除失败记录并恢复被自动隔离的账户
+	if req.Password != nil || req.IMAPHost != nil || req.IMAPPort != nil ||
+		req.POP3Host != nil || req.POP3Port != nil || req.Encryption != nil {
+		if account.Status == "error" {
+			account.Status = "active"
+		}
+		account.ConsecutiveFailures = 0
+		account.SyncErrorCode = ""
+		account.QuarantinedAt = nil
+	}
+
 	account.UpdatedAt = time.Now()
 
 	// 保存更新
@@ -217,6 +287,8 @@ func (s *accountService) Update(ctx context.Context, uid string, req *UpdateAcco
 		return nil, fmt.Errorf("failed to update account: %w", err)
 	}
 
+	s.notifyChanged(account.UID)
+	publishEvent(ctx, s.events, event.AccountEvent(event.EventAccountUpdated, account.UID, account.Email))
 	return account, nil
 }
 
@@ -231,6 +303,9 @@ func (s *accountService) Delete(ctx context.Context, uid string) error {
 	if err := s.accountRepo.Delete(ctx, account.ID); err != nil {
 		return fmt.Errorf("failed to delete account: %w", err)
 	}
+
+	s.notifyChanged(uid)
+	publishEvent(ctx, s.events, event.AccountEvent(event.EventAccountDeleted, uid, account.Email))
 	return nil
 }
 
@@ -277,6 +352,8 @@ func (s *accountService) TestConnection(ctx context.Context, uid string) error {
 		credentials.Host = "outlook.office365.com"
 		credentials.Port = 993
 		credentials.TLS = true
+	case "demo":
+		// 演示邮箱不连接服务器，无需主机配置
 	case "generic":
 		// 使用用户配置的服务器信息
 		if account.Protocol == "imap" {
@@ -346,6 +423,8 @@ func (s *accountService) SetStatus(ctx context.Context, uid string, status strin
 		return fmt.Errorf("failed to update account status: %w", err)
 	}
 
+	s.notifyChanged(uid)
+	publishEvent(ctx, s.events, event.AccountEvent(event.EventAccountUpdated, uid, account.Email))
 	return nil
 }
 
@@ -356,11 +435,24 @@ func (s *accountService) DisableAccount(ctx context.Context, uid string) error {
 
 // EnableAccount 启用账户
 func (s *accountService) EnableAccount(ctx context.Context, uid string) error {
-	return s.SetStatus(ctx, uid, "active")
+	if err := s.SetStatus(ctx, uid, "active"); err != nil {
+		return err
+	}
+	// 重新启用后从正常间隔开始同步，不再沿用失败退避
+	return s.ClearSyncError(ctx, uid)
 }
 
 // ClearSyncError 清除同步错误状态
+// 同时清除连续失败记录，被自动隔离的账户恢复为 active
 func (s *accountService) ClearSyncError(ctx context.Context, uid string) error {
 	// 使用 repository 的 UpdateSyncStatus 方法清除错误
-	return s.accountRepo.UpdateSyncStatus(ctx, uid, "", "")
+	if err := s.accountRepo.UpdateSyncStatus(ctx, uid, "", ""); err != nil {
+		return err
+	}
+	if err := s.accountRepo.ResetSyncFailures(ctx, uid); err != nil {
+		return fmt.Errorf("failed to reset sync failures: %w", err)
+	}
+
+	s.notifyChanged(uid)
+	return nil
 }
@@ -1,71 +1,216 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"path/filepath"
-	"strings"
+	"log"
+	"mime"
+	"path"
+	"strconv"
+	"time"
 
+	"fusionmail/internal/adapter"
 	"fusionmail/internal/model"
 	"fusionmail/internal/repository"
 	"fusionmail/pkg/storage"
 )
 
+// defaultMaxAttachmentSize 未配置时单个附件的默认大小上限
+const defaultMaxAttachmentSize = 25 << 20
+
+// storageTypeNone 超过大小限制、只保存了元数据的附件
+const storageTypeNone = "none"
+
+// maxBlobSaveAttempts 内容被并发回收时保存附件的最大尝试次数
+const maxBlobSaveAttempts = 3
+
+// attachmentGCBatchSize 垃圾回收每批检查的内容数
+const attachmentGCBatchSize = 500
+
+// errAttachmentGCDryRun 试运行时回滚回收事务
+var errAttachmentGCDryRun = errors.New("dry run")
+
+// ErrAttachmentNotStored 附件超过大小限制，同步时没有保存内容
+var ErrAttachmentNotStored = errors.New("attachment content was not stored (exceeds size limit)")
+
+// AttachmentOptions 附件服务配置
+type AttachmentOptions struct {
+	StorageType    string // 存储类型（local/s3/oss），记录到附件的 storage_type
+	DefaultMaxSize int64  // 单个附件默认大小上限（字节），账户未单独设置时使用
+}
+
 // AttachmentService 附件服务
 type AttachmentService struct {
 	attachmentRepo  *repository.AttachmentRepository
 	storageProvider storage.Provider
+	options         AttachmentOptions
 }
 
 // NewAttachmentService 创建附件服务
 func NewAttachmentService(
 	attachmentRepo *repository.AttachmentRepository,
 	storageProvider storage.Provider,
+	options AttachmentOptions,
 ) *AttachmentService {
+	if options.StorageType == "" {
+		options.StorageType = "local"
+	}
+	if options.DefaultMaxSize <= 0 {
+		options.DefaultMaxSize = defaultMaxAttachmentSize
+	}
+
 	return &AttachmentService{
 		attachmentRepo:  attachmentRepo,
 		storageProvider: storageProvider,
+		options:         options,
 	}
 }
 
+// MaxSize 获取账户的单个附件大小上限（字节）
+func (s *AttachmentService) MaxSize(account *model.Account) int64 {
+	if account.MaxAttachmentMB > 0 {
+		return int64(account.MaxAttachmentMB) << 20
+	}
+	return s.options.DefaultMaxSize
+}
+
 // SaveAttachment 保存附件
-func (s *AttachmentService) SaveAttachment(
-	ctx context.Context,
-	emailID int64,
-	accountUID string,
-	filename string,
-	contentType string,
-	size int64,
-	reader io.Reader,
-) (*model.EmailAttachment, error) {
-	// 生成存储路径：{account_uid}/{email_id}/{filename}
-	storagePath := filepath.Join(accountUID, fmt.Sprintf("%d", emailID), sanitizeFilename(filename))
-
-	// 上传文件
-	url, err := s.storageProvider.Upload(ctx, storagePath, reader, contentType)
+// attachment 需填写 EmailID、Filename、ContentType、SizeBytes 以及内联信息，存储字段由本方法填写
+// 附件内容按 SHA-256 寻址，相同内容只存储一份，附件记录通过 BlobHash 引用
+func (s *AttachmentService) SaveAttachment(ctx context.Context, attachment *model.EmailAttachment, reader io.Reader) error {
+	// 计算哈希需要完整内容（同步保存的附件已受大小上限约束）
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read attachment: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	// 内容在保存过程中被并发回收时重新登记并上传
+	for attempt := 1; ; attempt++ {
+		err := s.saveBlobReference(ctx, attachment, hash, content)
+		if errors.Is(err, repository.ErrBlobReclaimed) && attempt < maxBlobSaveAttempts {
+			continue
+		}
+		return err
+	}
+}
+
+// saveBlobReference 登记内容（必要时上传）并创建引用它的附件记录
+func (s *AttachmentService) saveBlobReference(ctx context.Context, attachment *model.EmailAttachment, hash string, content []byte) error {
+	blob, err := s.attachmentRepo.TouchBlob(ctx, &model.AttachmentBlob{
+		SHA256:      hash,
+		SizeBytes:   int64(len(content)),
+		StorageType: s.options.StorageType,
+		StoragePath: blobStoragePath(hash),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload attachment: %w", err)
+		if errors.Is(err, repository.ErrBlobReclaimed) {
+			return err
+		}
+		return fmt.Errorf("failed to register attachment blob: %w", err)
 	}
 
-	// 创建附件记录
-	attachment := &model.EmailAttachment{
-		EmailID:     emailID,
-		Filename:    filename,
-		ContentType: contentType,
-		SizeBytes:   size,
-		StorageType: "local",
-		StoragePath: storagePath,
-		URL:         url,
+	// 已有引用的内容一定已上传；没有引用的内容可能上次上传失败或正在被回收，重新上传（内容相同，覆盖无害）
+	if blob.RefCount == 0 {
+		if _, err := s.storageProvider.Upload(ctx, blob.StoragePath, bytes.NewReader(content), attachment.ContentType); err != nil {
+			return fmt.Errorf("failed to upload attachment: %w", err)
+		}
+	} else if sharer, ok := s.storageProvider.(storage.KeySharer); ok {
+		// 其他账户上传的加密内容为当前账户补充包装文件密钥，失败时仍可用原账户的包装解密
+		if err := sharer.ShareKey(ctx, blob.StoragePath); err != nil {
+			log.Printf("Failed to share file key of %s: %v", blob.StoragePath, err)
+		}
 	}
 
-	if err := s.attachmentRepo.Create(ctx, attachment); err != nil {
-		// 如果数据库保存失败，尝试删除已上传的文件
-		_ = s.storageProvider.Delete(ctx, storagePath)
-		return nil, fmt.Errorf("failed to save attachment record: %w", err)
+	attachment.BlobHash = hash
+	attachment.StorageType = blob.StorageType
+	attachment.StoragePath = blob.StoragePath
+	if err := s.attachmentRepo.CreateWithBlob(ctx, attachment); err != nil {
+		// 上传的内容没有引用，由垃圾回收清理
+		if errors.Is(err, repository.ErrBlobReclaimed) {
+			return err
+		}
+		return fmt.Errorf("failed to save attachment record: %w", err)
 	}
 
-	return attachment, nil
+	url, err := s.storageProvider.GetURL(ctx, blob.StoragePath)
+	if err != nil {
+		return fmt.Errorf("failed to get attachment url: %w", err)
+	}
+	attachment.URL = url
+	return nil
+}
+
+// blobStoragePath 内容的存储路径：blobs/{哈希前 2 位}/{3-4 位}/{哈希}，避免单个目录文件过多
+func blobStoragePath(hash string) string {
+	return path.Join("blobs", hash[:2], hash[2:4], hash)
+}
+
+// SaveEmailAttachments 保存同步拉取到的邮件附件（包括带 Content-ID 的内联资源）
+// 超过账户大小上限的附件只保存元数据；单个附件保存失败不影响其他附件，返回合并后的错误
+func (s *AttachmentService) SaveEmailAttachments(
+	ctx context.Context,
+	account *model.Account,
+	emailID int64,
+	attachments []adapter.Attachment,
+) error {
+	maxSize := s.MaxSize(account)
+	// 加密存储时文件密钥用账户的数据密钥包装
+	ctx = storage.WithKeyOwners(ctx, account.UID)
+
+	var errs []error
+	for i, att := range attachments {
+		size := att.SizeBytes
+		if len(att.Content) > 0 {
+			size = int64(len(att.Content))
+		}
+
+		attachment := &model.EmailAttachment{
+			EmailID:     emailID,
+			Filename:    attachmentFilename(att, i),
+			ContentType: att.ContentType,
+			SizeBytes:   size,
+			IsInline:    att.IsInline,
+			ContentID:   att.ContentID,
+		}
+
+		// 超过大小限制或适配器没有提供内容时只记录元数据
+		if size > maxSize || att.Content == nil {
+			attachment.StorageType = storageTypeNone
+			if err := s.attachmentRepo.Create(ctx, attachment); err != nil {
+				errs = append(errs, fmt.Errorf("failed to save attachment record: %w", err))
+			}
+			continue
+		}
+
+		if err := s.SaveAttachment(ctx, attachment, bytes.NewReader(att.Content)); err != nil {
+			errs = append(errs, fmt.Errorf("attachment %q: %w", attachment.Filename, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// attachmentFilename 返回附件文件名，没有文件名的内联资源按内容类型生成
+func attachmentFilename(att adapter.Attachment, index int) string {
+	if att.Filename != "" {
+		return att.Filename
+	}
+
+	name := fmt.Sprintf("attachment-%d", index+1)
+	if att.IsInline {
+		name = fmt.Sprintf("inline-%d", index+1)
+	}
+	if exts, _ := mime.ExtensionsByType(att.ContentType); len(exts) > 0 {
+		name += exts[0]
+	}
+	return name
 }
 
 // GetAttachment 获取附件信息
@@ -85,6 +230,9 @@ func (s *AttachmentService) DownloadAttachment(ctx context.Context, id int64) (i
 	if err != nil {
 		return nil, nil, fmt.Errorf("attachment not found: %w", err)
 	}
+	if attachment.StorageType == storageTypeNone {
+		return nil, attachment, ErrAttachmentNotStored
+	}
 
 	// 下载文件
 	reader, err := s.storageProvider.Download(ctx, attachment.StoragePath)
@@ -95,7 +243,7 @@ func (s *AttachmentService) DownloadAttachment(ctx context.Context, id int64) (i
 	return reader, attachment, nil
 }
 
-// DeleteAttachment 删除附件
+// DeleteAttachment 删除附件，附件内容只在最后一个引用删除后回收
 func (s *AttachmentService) DeleteAttachment(ctx context.Context, id int64) error {
 	// 获取附件信息
 	attachment, err := s.attachmentRepo.FindByID(ctx, id)
@@ -103,17 +251,20 @@ func (s *AttachmentService) DeleteAttachment(ctx context.Context, id int64) erro
 		return fmt.Errorf("attachment not found: %w", err)
 	}
 
-	// 删除文件
-	if err := s.storageProvider.Delete(ctx, attachment.StoragePath); err != nil {
-		return fmt.Errorf("failed to delete attachment file: %w", err)
+	// 去重上线前保存的文件只属于这一条附件记录，直接删除
+	if attachment.BlobHash == "" && attachment.StorageType != storageTypeNone {
+		if err := s.storageProvider.Delete(ctx, attachment.StoragePath); err != nil {
+			return fmt.Errorf("failed to delete attachment file: %w", err)
+		}
 	}
 
-	// 删除数据库记录
-	if err := s.attachmentRepo.Delete(ctx, id); err != nil {
+	// 删除数据库记录并释放内容引用
+	released, err := s.attachmentRepo.Delete(ctx, id)
+	if err != nil {
 		return fmt.Errorf("failed to delete attachment record: %w", err)
 	}
 
-	return nil
+	return s.reclaimBlobs(ctx, released)
 }
 
 // DeleteAttachmentsByEmailID 删除邮件的所有附件
@@ -124,24 +275,263 @@ func (s *AttachmentService) DeleteAttachmentsByEmailID(ctx context.Context, emai
 		return err
 	}
 
-	// 删除每个附件
+	// 删除去重上线前保存的文件（忽略错误，继续删除其他文件）
 	for _, attachment := range attachments {
-		// 删除文件（忽略错误，继续删除其他文件）
-		_ = s.storageProvider.Delete(ctx, attachment.StoragePath)
+		if attachment.BlobHash == "" && attachment.StorageType != storageTypeNone {
+			_ = s.storageProvider.Delete(ctx, attachment.StoragePath)
+		}
+	}
+
+	// 批量删除数据库记录并释放内容引用
+	released, err := s.attachmentRepo.DeleteByEmailID(ctx, emailID)
+	if err != nil {
+		return err
 	}
 
-	// 批量删除数据库记录
-	return s.attachmentRepo.DeleteByEmailID(ctx, emailID)
+	return s.reclaimBlobs(ctx, released)
 }
 
-// sanitizeFilename 清理文件名，移除不安全的字符
-func sanitizeFilename(filename string) string {
-	// 替换路径分隔符
-	filename = strings.ReplaceAll(filename, "/", "_")
-	filename = strings.ReplaceAll(filename, "\\", "_")
+// ListLargeAttachments 按 ID 顺序列出账户中 before 之前发送的邮件里不小于 minSize 字节、已保存内容的附件（跳过星标邮件）
+func (s *AttachmentService) ListLargeAttachments(ctx context.Context, accountUID string, minSize int64, before time.Time, afterID int64, limit int) ([]*model.EmailAttachment, error) {
+	return s.attachmentRepo.ListLargeAttachments(ctx, accountUID, minSize, before, afterID, limit)
+}
+
+// ReleaseContent 删除附件内容、只保留元数据（保留策略清理大附件），内容只在最后一个引用释放后回收
+func (s *AttachmentService) ReleaseContent(ctx context.Context, attachment *model.EmailAttachment) error {
+	released, err := s.attachmentRepo.ReleaseContent(ctx, attachment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to release attachment content: %w", err)
+	}
 
-	// 替换其他不安全字符
-	filename = strings.ReplaceAll(filename, "..", "_")
+	// 去重上线前保存的文件只属于这一条附件记录，记录更新后直接删除
+	if attachment.BlobHash == "" && attachment.StorageType != storageTypeNone {
+		if err := s.storageProvider.Delete(ctx, attachment.StoragePath); err != nil {
+			return fmt.Errorf("failed to delete attachment file: %w", err)
+		}
+	}
 
-	return filename
+	return s.reclaimBlobs(ctx, released)
+}
+
+// reclaimBlobs 立即回收引用数降为 0 的内容（回收失败的内容留给垃圾回收处理）
+func (s *AttachmentService) reclaimBlobs(ctx context.Context, hashes []string) error {
+	var errs []error
+	for _, hash := range hashes {
+		if _, err := s.attachmentRepo.ReclaimBlob(ctx, hash, time.Now(), s.deleteBlobObject(ctx)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reclaim attachment blob %s: %w", hash, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deleteBlobObject 返回删除内容存储对象的回调
+func (s *AttachmentService) deleteBlobObject(ctx context.Context) func(blob *model.AttachmentBlob) error {
+	return func(blob *model.AttachmentBlob) error {
+		return s.storageProvider.Delete(ctx, blob.StoragePath)
+	}
+}
+
+// EncryptStoredFiles 将已保存的明文附件文件按引用账户加密后写回原路径（静态加密迁移），返回改写的文件数
+// 按存储数据密钥加密的旧文件改为按账户包装，已为全部引用账户包装的文件跳过
+func (s *AttachmentService) EncryptStoredFiles(ctx context.Context, provider *storage.EncryptedProvider) (int, error) {
+	encrypted := 0
+	after := ""
+	for {
+		paths, err := s.attachmentRepo.ListStoragePaths(ctx, after, attachmentGCBatchSize)
+		if err != nil {
+			return encrypted, fmt.Errorf("failed to list attachment files: %w", err)
+		}
+		for _, storagePath := range paths {
+			// 去重后的内容为每个引用它的账户各包装一份文件密钥
+			owners, err := s.attachmentRepo.ListAccountUIDsByStoragePath(ctx, storagePath)
+			if err != nil {
+				return encrypted, fmt.Errorf("failed to list accounts of %s: %w", storagePath, err)
+			}
+			done, err := provider.EncryptInPlace(storage.WithKeyOwners(ctx, owners...), storagePath)
+			if err != nil {
+				return encrypted, fmt.Errorf("failed to encrypt %s: %w", storagePath, err)
+			}
+			if done {
+				encrypted++
+			}
+		}
+		if len(paths) < attachmentGCBatchSize {
+			return encrypted, nil
+		}
+		after = paths[len(paths)-1]
+	}
+}
+
+// MigrateStoredFiles 把附件文件原样复制到目标存储，并把记录的存储位置更新为目标存储类型（路径不变）
+// 已是目标存储类型的记录跳过，中断后重新执行即可继续；单个文件失败不影响其他文件，通过 onError 报告
+func (s *AttachmentService) MigrateStoredFiles(ctx context.Context, target storage.Provider, targetType string, onError func(storagePath string, err error)) (*StorageMigrationResult, error) {
+	result := &StorageMigrationResult{}
+	fail := func(storagePath string, err error) {
+		result.Failed++
+		if onError != nil {
+			onError(storagePath, err)
+		}
+	}
+
+	// 按内容保存的附件：复制内容后同时更新内容记录和引用它的附件记录
+	after := ""
+	for {
+		blobs, err := s.attachmentRepo.ListBlobs(ctx, targetType, after, storageMigrationBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list attachment blobs: %w", err)
+		}
+		for _, blob := range blobs {
+			copied, size, err := copyStoredFile(ctx, s.storageProvider, target, blob.StoragePath, "application/octet-stream")
+			if err == nil {
+				err = s.attachmentRepo.MoveBlob(ctx, blob.SHA256, targetType, blob.StoragePath)
+			}
+			if err != nil {
+				fail(blob.StoragePath, err)
+				continue
+			}
+			result.add(copied, size)
+		}
+		if len(blobs) < storageMigrationBatchSize {
+			break
+		}
+		after = blobs[len(blobs)-1].SHA256
+	}
+
+	// 去重上线前按邮件保存的附件
+	var afterID int64
+	for {
+		attachments, err := s.attachmentRepo.ListLegacyAttachments(ctx, targetType, afterID, storageMigrationBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list attachments: %w", err)
+		}
+		for _, attachment := range attachments {
+			copied, size, err := copyStoredFile(ctx, s.storageProvider, target, attachment.StoragePath, attachment.ContentType)
+			if err == nil {
+				err = s.attachmentRepo.UpdateStorageLocation(ctx, attachment.ID, targetType, attachment.StoragePath)
+			}
+			if err != nil {
+				fail(attachment.StoragePath, err)
+				continue
+			}
+			result.add(copied, size)
+		}
+		if len(attachments) < storageMigrationBatchSize {
+			return result, nil
+		}
+		afterID = attachments[len(attachments)-1].ID
+	}
+}
+
+// VerifyStoredFiles 按数据库记录检查附件文件：内容文件校验 SHA-256 和大小，按邮件保存的文件检查能否完整读取
+// 存储提供者需能解密静态加密的文件；发现的问题通过 report 逐条报告
+func (s *AttachmentService) VerifyStoredFiles(ctx context.Context, report func(issue StorageIssue)) (*StorageVerifyResult, error) {
+	result := &StorageVerifyResult{}
+	issue := func(issue StorageIssue) {
+		result.Issues++
+		if report != nil {
+			report(issue)
+		}
+	}
+
+	after := ""
+	for {
+		blobs, err := s.attachmentRepo.ListBlobs(ctx, "", after, storageMigrationBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list attachment blobs: %w", err)
+		}
+		for _, blob := range blobs {
+			result.Checked++
+			checksum, size, problem, err := checkStoredFile(ctx, s.storageProvider, blob.StoragePath)
+			if problem == "" && (checksum != blob.SHA256 || size != blob.SizeBytes) {
+				problem = StorageIssueCorrupted
+				err = fmt.Errorf("content hash %s (%d bytes) does not match record (%d bytes)", checksum, size, blob.SizeBytes)
+			}
+			if problem != "" {
+				issue(StorageIssue{Kind: "attachment_blob", ID: blob.SHA256, Path: blob.StoragePath, Problem: problem, Err: err})
+			}
+		}
+		if len(blobs) < storageMigrationBatchSize {
+			break
+		}
+		after = blobs[len(blobs)-1].SHA256
+	}
+
+	var afterID int64
+	for {
+		attachments, err := s.attachmentRepo.ListLegacyAttachments(ctx, "", afterID, storageMigrationBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list attachments: %w", err)
+		}
+		for _, attachment := range attachments {
+			result.Checked++
+			if _, _, problem, err := checkStoredFile(ctx, s.storageProvider, attachment.StoragePath); problem != "" {
+				issue(StorageIssue{Kind: "attachment", ID: strconv.FormatInt(attachment.ID, 10), Path: attachment.StoragePath, Problem: problem, Err: err})
+			}
+		}
+		if len(attachments) < storageMigrationBatchSize {
+			return result, nil
+		}
+		afterID = attachments[len(attachments)-1].ID
+	}
+}
+
+// AttachmentGCResult 附件垃圾回收结果
+type AttachmentGCResult struct {
+	Recounted      int64 // 修正引用数的内容记录数
+	Reclaimed      int   // 回收（或试运行时可回收）的内容数
+	ReclaimedBytes int64 // 回收的内容总大小
+	Failed         int   // 回收失败的内容数
+}
+
+// CollectGarbage 回收没有附件记录引用的内容（邮件级联删除、保存失败遗留的内容）
+// 跳过 gracePeriod 内登记或引用过的内容，避免与正在进行的保存竞争；dryRun 时只统计不删除
+func (s *AttachmentService) CollectGarbage(ctx context.Context, gracePeriod time.Duration, dryRun bool) (*AttachmentGCResult, error) {
+	cutoff := time.Now().Add(-gracePeriod)
+	result := &AttachmentGCResult{}
+
+	if !dryRun {
+		recounted, err := s.attachmentRepo.RecountBlobRefs(ctx, cutoff)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recount blob references: %w", err)
+		}
+		result.Recounted = recounted
+	}
+
+	after := ""
+	for {
+		hashes, err := s.attachmentRepo.ListUnreferencedBlobs(ctx, cutoff, after, attachmentGCBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list unreferenced blobs: %w", err)
+		}
+
+		for _, hash := range hashes {
+			var size int64
+			remove := func(blob *model.AttachmentBlob) error {
+				size = blob.SizeBytes
+				if dryRun {
+					// 试运行：返回错误回滚事务，不删除任何内容
+					return errAttachmentGCDryRun
+				}
+				return s.storageProvider.Delete(ctx, blob.StoragePath)
+			}
+
+			reclaimed, err := s.attachmentRepo.ReclaimBlob(ctx, hash, cutoff, remove)
+			switch {
+			case errors.Is(err, errAttachmentGCDryRun):
+				result.Reclaimed++
+				result.ReclaimedBytes += size
+			case err != nil:
+				log.Printf("Failed to reclaim attachment blob %s: %v", hash, err)
+				result.Failed++
+			case reclaimed:
+				result.Reclaimed++
+				result.ReclaimedBytes += size
+			}
+		}
+
+		if len(hashes) < attachmentGCBatchSize {
+			return result, nil
+		}
+		after = hashes[len(hashes)-1]
+	}
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/model"
+)
+
+// TestAttachmentMaxSize 测试账户附件大小上限优先于全局配置
+func TestAttachmentMaxSize(t *testing.T) {
+	s := NewAttachmentService(nil, nil, AttachmentOptions{DefaultMaxSize: 10 << 20})
+
+	if got := s.MaxSize(&model.Account{}); got != 10<<20 {
+		t.Errorf("MaxSize() without account limit = %d, want %d", got, 10<<20)
+	}
+	if got := s.MaxSize(&model.Account{MaxAttachmentMB: 2}); got != 2<<20 {
+		t.Errorf("MaxSize() with account limit = %d, want %d", got, 2<<20)
+	}
+	if got := NewAttachmentService(nil, nil, AttachmentOptions{}).MaxSize(&model.Account{}); got != defaultMaxAttachmentSize {
+		t.Errorf("MaxSize() with zero options = %d, want %d", got, defaultMaxAttachmentSize)
+	}
+}
+
+// TestAttachmentFilename 测试没有文件名的附件和内联资源的文件名生成
+func TestAttachmentFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		att  adapter.Attachment
+		want string
+	}{
+		{"keeps filename", adapter.Attachment{Filename: "report.pdf", ContentType: "application/pdf"}, "report.pdf"},
+		{"inline image", adapter.Attachment{ContentType: "image/png", IsInline: true}, "inline-3.png"},
+		{"unknown type", adapter.Attachment{ContentType: "application/x-unknown"}, "attachment-3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attachmentFilename(tt.att, 2); got != tt.want {
+				t.Errorf("attachmentFilename() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
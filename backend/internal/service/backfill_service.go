@@ -0,0 +1,577 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+)
+
+const (
+	defaultBackfillPageSize  = 100
+	defaultBackfillPageDelay = 2 * time.Second
+	maxBackfillPageSize      = 500
+	maxBackfillPageDelay     = 10 * time.Minute // 配额受限时页间隔翻倍的上限
+	maxBackfillRetries       = 5                // 单页连续失败多少次后任务失败
+)
+
+var (
+	// ErrBackfillNotSupported 邮箱协议不支持历史邮件分页拉取
+	ErrBackfillNotSupported = errors.New("provider does not support history backfill")
+
+	// ErrBackfillActive 账户已有进行中的回填任务
+	ErrBackfillActive = errors.New("backfill job is already active for this account")
+
+	// ErrBackfillNotFound 账户没有回填任务
+	ErrBackfillNotFound = errors.New("backfill job not found")
+
+	// ErrBackfillInvalidState 当前状态不允许该操作
+	ErrBackfillInvalidState = errors.New("backfill job cannot be changed in its current state")
+
+	// 停止运行中任务的原因
+	errBackfillPaused    = errors.New("backfill paused")
+	errBackfillCancelled = errors.New("backfill cancelled")
+	errBackfillStopped   = errors.New("backfill stopped elsewhere")
+)
+
+// BackfillOptions 回填任务节流配置
+type BackfillOptions struct {
+	PageSize  int           // 每页邮件数
+	PageDelay time.Duration // 两页之间的间隔，避免触发服务商限流
+	Locker    SyncLocker    // 分布式锁，保证多实例下同一任务只在一个实例上运行，为空时只在本实例内互斥
+}
+
+// BackfillProgress 回填任务进度
+type BackfillProgress struct {
+	model.BackfillJob
+	Percent    float64 `json:"percent"`     // 完成百分比（总数未知时为 0）
+	ETASeconds *int64  `json:"eta_seconds"` // 预计剩余秒数（无法估算时为空）
+}
+
+// BackfillService 历史邮件回填服务接口
+type BackfillService interface {
+	// Start 为账户创建并启动回填任务，options 零值字段使用默认配置
+	Start(ctx context.Context, accountUID string, options BackfillOptions) (*BackfillProgress, error)
+
+	// Get 获取账户最近一次回填任务的进度
+	Get(ctx context.Context, accountUID string) (*BackfillProgress, error)
+
+	// Pause 暂停账户的回填任务，游标保留，可通过 Resume 继续
+	Pause(ctx context.Context, accountUID string) (*BackfillProgress, error)
+
+	// Resume 继续已暂停或失败的回填任务
+	Resume(ctx context.Context, accountUID string) (*BackfillProgress, error)
+
+	// Cancel 取消账户的回填任务，已导入的邮件保留
+	Cancel(ctx context.Context, accountUID string) (*BackfillProgress, error)
+
+	// ResumeInterrupted 继续服务重启前未完成的回填任务
+	ResumeInterrupted(ctx context.Context) error
+
+	// Stop 停止所有运行中的任务（状态保持 running，下次启动时继续）
+	Stop()
+}
+
+// backfillImporter 回填任务复用的同步能力（由 syncService 实现）
+type backfillImporter interface {
+	newProvider(account *model.Account) (adapter.MailProvider, error)
+	processEmails(ctx context.Context, account *model.Account, adapterEmails []*adapter.Email, syncLog *model.SyncLog) error
+	exclusive(ctx context.Context, accountUID string, fn func(ctx context.Context) error) error
+}
+
+// backfillRunner 运行中的回填任务
+type backfillRunner struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// backfillService 历史邮件回填服务实现
+type backfillService struct {
+	accountRepo repository.AccountRepository
+	jobRepo     repository.BackfillJobRepository
+	importer    backfillImporter
+	options     BackfillOptions
+
+	mu      sync.Mutex
+	baseCtx context.Context
+	runners map[string]*backfillRunner // 按账户 UID 索引，同一账户同时只运行一个任务
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// NewBackfillService 创建历史邮件回填服务实例
+func NewBackfillService(
+	accountRepo repository.AccountRepository,
+	jobRepo repository.BackfillJobRepository,
+	syncService SyncService,
+	options BackfillOptions,
+) (BackfillService, error) {
+	importer, ok := syncService.(backfillImporter)
+	if !ok {
+		return nil, fmt.Errorf("sync service does not support backfill")
+	}
+
+	if options.PageSize <= 0 {
+		options.PageSize = defaultBackfillPageSize
+	}
+	if options.PageDelay <= 0 {
+		options.PageDelay = defaultBackfillPageDelay
+	}
+
+	return &backfillService{
+		accountRepo: accountRepo,
+		jobRepo:     jobRepo,
+		importer:    importer,
+		options:     options,
+		baseCtx:     context.Background(),
+		runners:     make(map[string]*backfillRunner),
+		sleep:       sleepContext,
+	}, nil
+}
+
+// Start 为账户创建并启动回填任务
+func (s *backfillService) Start(ctx context.Context, accountUID string, options BackfillOptions) (*BackfillProgress, error) {
+	account, err := s.accountRepo.FindByUID(ctx, accountUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find account: %w", err)
+	}
+	if account == nil {
+		return nil, fmt.Errorf("account not found: %s", accountUID)
+	}
+	if account.Status != "active" {
+		return nil, fmt.Errorf("account is not active (status: %s): %s", account.Status, accountUID)
+	}
+
+	// 提前检查协议是否支持分页拉取，避免创建注定失败的任务
+	provider, err := s.importer.newProvider(account)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := provider.(adapter.HistoryFetcher); !ok {
+		return nil, ErrBackfillNotSupported
+	}
+
+	latest, err := s.jobRepo.FindLatestByAccount(ctx, accountUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find backfill job: %w", err)
+	}
+	if latest != nil && latest.IsActive() {
+		return nil, ErrBackfillActive
+	}
+
+	pageSize := options.PageSize
+	if pageSize <= 0 {
+		pageSize = s.options.PageSize
+	}
+	if pageSize > maxBackfillPageSize {
+		pageSize = maxBackfillPageSize
+	}
+	pageDelay := options.PageDelay
+	if pageDelay <= 0 {
+		pageDelay = s.options.PageDelay
+	}
+
+	job := &model.BackfillJob{
+		AccountUID:  accountUID,
+		Status:      "pending",
+		PageSize:    pageSize,
+		PageDelayMs: int(pageDelay.Milliseconds()),
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create backfill job: %w", err)
+	}
+
+	// 启动后任务由后台协程修改，先生成进度快照
+	progress := newBackfillProgress(job)
+	s.launch(job)
+	return progress, nil
+}
+
+// Get 获取账户最近一次回填任务的进度
+func (s *backfillService) Get(ctx context.Context, accountUID string) (*BackfillProgress, error) {
+	job, err := s.latestJob(ctx, accountUID)
+	if err != nil {
+		return nil, err
+	}
+	return newBackfillProgress(job), nil
+}
+
+// Pause 暂停账户的回填任务
+func (s *backfillService) Pause(ctx context.Context, accountUID string) (*BackfillProgress, error) {
+	return s.stopJob(ctx, accountUID, errBackfillPaused)
+}
+
+// Cancel 取消账户的回填任务
+func (s *backfillService) Cancel(ctx context.Context, accountUID string) (*BackfillProgress, error) {
+	return s.stopJob(ctx, accountUID, errBackfillCancelled)
+}
+
+// stopJob 停止本实例运行中的任务（由运行协程写入最终状态），或直接修改任务状态
+// （任务在其他实例上运行时，该实例保存下一页进度时发现状态已变化后停止）
+func (s *backfillService) stopJob(ctx context.Context, accountUID string, cause error) (*BackfillProgress, error) {
+	job, err := s.latestJob(ctx, accountUID)
+	if err != nil {
+		return nil, err
+	}
+	if !job.IsActive() || (job.Status == "paused" && cause == errBackfillPaused) {
+		return nil, ErrBackfillInvalidState
+	}
+
+	s.mu.Lock()
+	runner := s.runners[accountUID]
+	s.mu.Unlock()
+
+	if runner != nil {
+		runner.cancel(cause)
+		select {
+		case <-runner.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return s.Get(ctx, accountUID)
+	}
+
+	// 任务可能正在其他实例上运行：只写入状态，运行实例保存下一页进度时发现状态已变化后停止
+	status := job.Status
+	applyBackfillStop(job, cause, time.Now())
+	saved, err := s.jobRepo.UpdateIfStatus(ctx, job, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update backfill job: %w", err)
+	}
+	if !saved {
+		// 读取后任务状态已变化（如刚好完成）
+		return nil, ErrBackfillInvalidState
+	}
+	return newBackfillProgress(job), nil
+}
+
+// Resume 继续已暂停或失败的回填任务
+func (s *backfillService) Resume(ctx context.Context, accountUID string) (*BackfillProgress, error) {
+	job, err := s.latestJob(ctx, accountUID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != "paused" && job.Status != "failed" {
+		return nil, ErrBackfillInvalidState
+	}
+
+	job.Status = "pending"
+	job.LastError = ""
+	if err := s.jobRepo.Update(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to update backfill job: %w", err)
+	}
+
+	// 启动后任务由后台协程修改，先生成进度快照
+	progress := newBackfillProgress(job)
+	s.launch(job)
+	return progress, nil
+}
+
+// ResumeInterrupted 继续服务重启前未完成的回填任务
+func (s *backfillService) ResumeInterrupted(ctx context.Context) error {
+	s.mu.Lock()
+	s.baseCtx = ctx
+	s.mu.Unlock()
+
+	jobs, err := s.jobRepo.ListByStatus(ctx, "pending", "running")
+	if err != nil {
+		return fmt.Errorf("failed to list backfill jobs: %w", err)
+	}
+
+	for _, job := range jobs {
+		log.Printf("Resuming backfill job %d for account %s", job.ID, job.AccountUID)
+		s.launch(job)
+	}
+	return nil
+}
+
+// Stop 停止所有运行中的任务
+func (s *backfillService) Stop() {
+	s.mu.Lock()
+	runners := make([]*backfillRunner, 0, len(s.runners))
+	for _, runner := range s.runners {
+		runners = append(runners, runner)
+	}
+	s.mu.Unlock()
+
+	for _, runner := range runners {
+		runner.cancel(context.Canceled)
+		<-runner.done
+	}
+}
+
+// latestJob 获取账户最近一次回填任务
+func (s *backfillService) latestJob(ctx context.Context, accountUID string) (*model.BackfillJob, error) {
+	job, err := s.jobRepo.FindLatestByAccount(ctx, accountUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find backfill job: %w", err)
+	}
+	if job == nil {
+		return nil, ErrBackfillNotFound
+	}
+	return job, nil
+}
+
+// launch 在后台协程中运行任务
+func (s *backfillService) launch(job *model.BackfillJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.runners[job.AccountUID]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancelCause(s.baseCtx)
+	runner := &backfillRunner{cancel: cancel, done: make(chan struct{})}
+	s.runners[job.AccountUID] = runner
+
+	go func() {
+		defer close(runner.done)
+		defer func() {
+			s.mu.Lock()
+			delete(s.runners, job.AccountUID)
+			s.mu.Unlock()
+			cancel(nil)
+		}()
+
+		// 多实例部署时每个实例启动都会继续未完成的任务，只有获得任务锁的实例运行
+		if s.options.Locker != nil {
+			release, acquired, err := holdLock(ctx, s.options.Locker, backfillLockKey(job.AccountUID))
+			if err != nil {
+				log.Printf("Failed to acquire backfill lock for account %s, continuing without it: %v", job.AccountUID, err)
+			} else if !acquired {
+				log.Printf("Backfill job %d for account %s is running on another instance", job.ID, job.AccountUID)
+				return
+			} else {
+				defer release()
+			}
+		}
+		s.run(ctx, job)
+	}()
+}
+
+// backfillLockKey 获取账户回填任务的锁键
+func backfillLockKey(accountUID string) string {
+	return "backfill:" + accountUID
+}
+
+// run 逐页向前拉取历史邮件直到最早的邮件、任务被停止或连续失败
+func (s *backfillService) run(ctx context.Context, job *model.BackfillJob) {
+	err := s.runPages(ctx, job)
+
+	// 任务已停止，使用独立的上下文保存最终状态
+	saveCtx := context.Background()
+	switch {
+	case errors.Is(err, errBackfillStopped):
+		// 其他实例已暂停或取消任务并写入了状态
+		log.Printf("Backfill job %d for account %s was stopped on another instance", job.ID, job.AccountUID)
+		return
+	case err == nil:
+		now := time.Now()
+		job.Status = "completed"
+		job.CompletedAt = &now
+		log.Printf("Backfill job %d for account %s completed: %d processed, %d new",
+			job.ID, job.AccountUID, job.EmailsProcessed, job.EmailsNew)
+	case ctx.Err() != nil:
+		cause := context.Cause(ctx)
+		if cause != errBackfillPaused && cause != errBackfillCancelled {
+			// 服务关闭：保持 running 状态，重启后从游标继续
+			return
+		}
+		applyBackfillStop(job, cause, time.Now())
+		log.Printf("Backfill job %d for account %s %s", job.ID, job.AccountUID, job.Status)
+	default:
+		job.Status = "failed"
+		job.LastError = err.Error()
+		log.Printf("Backfill job %d for account %s failed: %v", job.ID, job.AccountUID, err)
+	}
+
+	if err := s.saveRunning(saveCtx, job); err != nil {
+		log.Printf("Failed to update backfill job %d: %v", job.ID, err)
+	}
+}
+
+// saveRunning 保存运行中任务的进度和状态，任务已被其他实例暂停或取消时返回 errBackfillStopped
+func (s *backfillService) saveRunning(ctx context.Context, job *model.BackfillJob) error {
+	saved, err := s.jobRepo.UpdateIfStatus(ctx, job, "pending", "running")
+	if err != nil {
+		return fmt.Errorf("failed to update backfill job: %w", err)
+	}
+	if !saved {
+		return errBackfillStopped
+	}
+	return nil
+}
+
+// runPages 执行回填循环，每页处理完后保存进度和游标
+func (s *backfillService) runPages(ctx context.Context, job *model.BackfillJob) error {
+	account, err := s.accountRepo.FindByUID(ctx, job.AccountUID)
+	if err != nil {
+		return fmt.Errorf("failed to find account: %w", err)
+	}
+	if account == nil {
+		return fmt.Errorf("account not found: %s", job.AccountUID)
+	}
+
+	provider, err := s.importer.newProvider(account)
+	if err != nil {
+		return err
+	}
+	fetcher, ok := provider.(adapter.HistoryFetcher)
+	if !ok {
+		return ErrBackfillNotSupported
+	}
+
+	if err := provider.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer provider.Disconnect()
+
+	now := time.Now()
+	job.Status = "running"
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	if err := s.saveRunning(ctx, job); err != nil {
+		return err
+	}
+
+	delay := time.Duration(job.PageDelayMs) * time.Millisecond
+	failures := 0
+	for {
+		pageStart := time.Now()
+		page, err := fetcher.FetchHistory(ctx, job.NextCursor, job.PageSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			failures++
+			if failures >= maxBackfillRetries {
+				return fmt.Errorf("failed to fetch history page: %w", err)
+			}
+
+			// 配额受限时放慢节奏，其他错误按原间隔重试
+			if adapter.Classify(err) == adapter.ErrorKindQuota {
+				delay = min(delay*2, maxBackfillPageDelay)
+			}
+			log.Printf("Backfill job %d for account %s: page failed (%d/%d), retrying in %s: %v",
+				job.ID, job.AccountUID, failures, maxBackfillRetries, delay, err)
+			if err := s.sleep(ctx, delay); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// 复用增量同步的入库逻辑，已存在的邮件只更新；整页在一个事务中写入，失败时重试本页
+		// 写入时持有账户同步锁，不与该账户的增量同步并发
+		counters := &model.SyncLog{}
+		err = s.importer.exclusive(ctx, job.AccountUID, func(ctx context.Context) error {
+			return s.importer.processEmails(ctx, account, page.Emails, counters)
+		})
+		if errors.Is(err, ErrSyncLocked) {
+			// 其他实例正在同步该账户，等待后重试本页，不计入失败次数
+			log.Printf("Backfill job %d for account %s: account is being synced, retrying in %s", job.ID, job.AccountUID, delay)
+			if err := s.sleep(ctx, delay); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			failures++
+			if failures >= maxBackfillRetries {
+				return fmt.Errorf("failed to store history page: %w", err)
+			}
+			log.Printf("Backfill job %d for account %s: storing page failed (%d/%d), retrying in %s: %v",
+				job.ID, job.AccountUID, failures, maxBackfillRetries, delay, err)
+			if err := s.sleep(ctx, delay); err != nil {
+				return err
+			}
+			continue
+		}
+		failures = 0
+
+		job.EmailsProcessed += len(page.Emails)
+		job.EmailsNew += counters.EmailsNew
+		if page.Total > 0 {
+			job.Estimated = page.Total
+		}
+		job.NextCursor = page.NextCursor
+		job.ActiveMs += time.Since(pageStart).Milliseconds()
+
+		if page.NextCursor == "" {
+			return nil
+		}
+
+		if err := s.saveRunning(ctx, job); err != nil {
+			return err
+		}
+
+		if err := s.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// applyBackfillStop 按停止原因设置任务状态
+func applyBackfillStop(job *model.BackfillJob, cause error, now time.Time) {
+	if cause == errBackfillCancelled {
+		job.Status = "cancelled"
+		job.CompletedAt = &now
+		return
+	}
+	job.Status = "paused"
+}
+
+// newBackfillProgress 根据任务统计计算完成百分比和预计剩余时间
+// 剩余时间 = 剩余邮件数 × 平均每封耗时 + 剩余页数 × 页间隔
+func newBackfillProgress(job *model.BackfillJob) *BackfillProgress {
+	progress := &BackfillProgress{BackfillJob: *job}
+
+	if job.Status == "completed" {
+		progress.Percent = 100
+		return progress
+	}
+	if job.Estimated <= 0 {
+		return progress
+	}
+
+	done := min(job.EmailsProcessed, job.Estimated)
+	progress.Percent = float64(done) * 100 / float64(job.Estimated)
+
+	if job.EmailsProcessed == 0 || job.ActiveMs == 0 || !job.IsActive() {
+		return progress
+	}
+
+	remaining := int64(job.Estimated - done)
+	etaMs := remaining * job.ActiveMs / int64(job.EmailsProcessed)
+	if job.PageSize > 0 {
+		pages := (remaining + int64(job.PageSize) - 1) / int64(job.PageSize)
+		etaMs += pages * int64(job.PageDelayMs)
+	}
+	eta := etaMs / 1000
+	progress.ETASeconds = &eta
+	return progress
+}
+
+// sleepContext 等待指定时间，上下文取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,446 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+)
+
+// TestNewBackfillProgress 测试完成百分比和剩余时间估算
+func TestNewBackfillProgress(t *testing.T) {
+	tests := []struct {
+		name        string
+		job         model.BackfillJob
+		wantPercent float64
+		wantETA     int64 // -1 表示无法估算
+	}{
+		{
+			name:        "总数未知",
+			job:         model.BackfillJob{Status: "running", EmailsProcessed: 100, ActiveMs: 1000},
+			wantPercent: 0,
+			wantETA:     -1,
+		},
+		{
+			name:        "尚未处理",
+			job:         model.BackfillJob{Status: "pending", Estimated: 1000},
+			wantPercent: 0,
+			wantETA:     -1,
+		},
+		{
+			// 剩余 750 封，每封 20ms，剩余 8 页每页间隔 2s
+			name:        "按平均耗时和页间隔估算",
+			job:         model.BackfillJob{Status: "running", Estimated: 1000, EmailsProcessed: 250, ActiveMs: 5000, PageSize: 100, PageDelayMs: 2000},
+			wantPercent: 25,
+			wantETA:     31,
+		},
+		{
+			name:        "已取消不估算剩余时间",
+			job:         model.BackfillJob{Status: "cancelled", Estimated: 1000, EmailsProcessed: 500, ActiveMs: 5000},
+			wantPercent: 50,
+			wantETA:     -1,
+		},
+		{
+			name:        "已完成",
+			job:         model.BackfillJob{Status: "completed", Estimated: 900, EmailsProcessed: 1000},
+			wantPercent: 100,
+			wantETA:     -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newBackfillProgress(&tt.job)
+			if got.Percent != tt.wantPercent {
+				t.Errorf("Percent = %v, want %v", got.Percent, tt.wantPercent)
+			}
+			switch {
+			case tt.wantETA < 0 && got.ETASeconds != nil:
+				t.Errorf("ETASeconds = %d, want nil", *got.ETASeconds)
+			case tt.wantETA >= 0 && (got.ETASeconds == nil || *got.ETASeconds != tt.wantETA):
+				t.Errorf("ETASeconds = %v, want %d", got.ETASeconds, tt.wantETA)
+			}
+		})
+	}
+}
+
+// TestBackfillRunsToCompletion 测试回填逐页导入直到最早的邮件
+func TestBackfillRunsToCompletion(t *testing.T) {
+	s, importer, jobs := newTestBackfillService(25)
+
+	if _, err := s.Start(context.Background(), "acc-1", BackfillOptions{PageSize: 10}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	job := waitBackfillStatus(t, jobs, "completed")
+
+	if job.EmailsProcessed != 25 || job.EmailsNew != 25 || job.Estimated != 25 {
+		t.Errorf("processed/new/estimated = %d/%d/%d, want 25/25/25", job.EmailsProcessed, job.EmailsNew, job.Estimated)
+	}
+	if importer.count() != 25 {
+		t.Errorf("imported %d emails, want 25", importer.count())
+	}
+
+	// 已完成的任务不能再暂停
+	if _, err := s.Pause(context.Background(), "acc-1"); err != ErrBackfillInvalidState {
+		t.Errorf("Pause() on completed job error = %v, want %v", err, ErrBackfillInvalidState)
+	}
+}
+
+// TestBackfillPauseResume 测试暂停保留游标，继续后从游标处接着导入
+func TestBackfillPauseResume(t *testing.T) {
+	s, importer, jobs := newTestBackfillService(25)
+
+	// 第一页完成后停在页间隔，等待暂停
+	firstPage := make(chan struct{})
+	var once sync.Once
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		once.Do(func() { close(firstPage) })
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	if _, err := s.Start(context.Background(), "acc-1", BackfillOptions{PageSize: 10}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, err := s.Start(context.Background(), "acc-1", BackfillOptions{}); err != ErrBackfillActive {
+		t.Errorf("second Start() error = %v, want %v", err, ErrBackfillActive)
+	}
+	<-firstPage
+
+	progress, err := s.Pause(context.Background(), "acc-1")
+	if err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if progress.Status != "paused" || progress.EmailsProcessed != 10 || progress.NextCursor != "14" {
+		t.Fatalf("after pause status/processed/cursor = %s/%d/%q, want paused/10/\"14\"",
+			progress.Status, progress.EmailsProcessed, progress.NextCursor)
+	}
+
+	s.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	if _, err := s.Resume(context.Background(), "acc-1"); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	job := waitBackfillStatus(t, jobs, "completed")
+
+	if job.EmailsProcessed != 25 || importer.count() != 25 {
+		t.Errorf("processed = %d, imported = %d, want 25", job.EmailsProcessed, importer.count())
+	}
+}
+
+// TestBackfillCancel 测试取消后任务结束且不再继续
+func TestBackfillCancel(t *testing.T) {
+	s, _, jobs := newTestBackfillService(25)
+
+	firstPage := make(chan struct{})
+	var once sync.Once
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		once.Do(func() { close(firstPage) })
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	if _, err := s.Start(context.Background(), "acc-1", BackfillOptions{PageSize: 10}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	<-firstPage
+
+	progress, err := s.Cancel(context.Background(), "acc-1")
+	if err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if progress.Status != "cancelled" || progress.CompletedAt == nil {
+		t.Errorf("after cancel status = %s, completed_at = %v", progress.Status, progress.CompletedAt)
+	}
+	if _, err := s.Resume(context.Background(), "acc-1"); err != ErrBackfillInvalidState {
+		t.Errorf("Resume() on cancelled job error = %v, want %v", err, ErrBackfillInvalidState)
+	}
+	if job := jobs.latest(); job.Status != "cancelled" {
+		t.Errorf("stored status = %s, want cancelled", job.Status)
+	}
+}
+
+// TestBackfillPausedElsewhere 测试任务在其他实例上运行时暂停：运行实例保存下一页时停止，不覆盖暂停状态
+func TestBackfillPausedElsewhere(t *testing.T) {
+	runner, importer, jobs := newTestBackfillService(25)
+
+	firstPage := make(chan struct{})
+	nextPage := make(chan struct{})
+	var once sync.Once
+	runner.sleep = func(ctx context.Context, d time.Duration) error {
+		once.Do(func() { close(firstPage) })
+		<-nextPage
+		return nil
+	}
+
+	if _, err := runner.Start(context.Background(), "acc-1", BackfillOptions{PageSize: 10}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	<-firstPage
+
+	// 另一个实例没有本地运行协程，只能写入状态
+	other, _, _ := newTestBackfillService(25)
+	other.jobRepo = jobs
+	progress, err := other.Pause(context.Background(), "acc-1")
+	if err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if progress.Status != "paused" {
+		t.Fatalf("Pause() status = %s, want paused", progress.Status)
+	}
+
+	close(nextPage)
+	runner.mu.Lock()
+	r := runner.runners["acc-1"]
+	runner.mu.Unlock()
+	if r != nil {
+		select {
+		case <-r.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("runner did not stop after the job was paused elsewhere")
+		}
+	}
+
+	if job := jobs.latest(); job.Status != "paused" || job.EmailsProcessed != 10 || job.NextCursor != "14" {
+		t.Errorf("stored status/processed/cursor = %s/%d/%q, want paused/10/\"14\"", job.Status, job.EmailsProcessed, job.NextCursor)
+	}
+	if importer.count() != 20 {
+		t.Errorf("imported %d emails, want 20 (the page in flight when paused)", importer.count())
+	}
+}
+
+// TestBackfillWaitsForSyncLock 测试账户正在同步时等待后重试本页，不计入失败次数
+func TestBackfillWaitsForSyncLock(t *testing.T) {
+	s, importer, jobs := newTestBackfillService(25)
+	importer.locked = maxBackfillRetries + 1
+
+	if _, err := s.Start(context.Background(), "acc-1", BackfillOptions{PageSize: 10}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	job := waitBackfillStatus(t, jobs, "completed")
+
+	if job.EmailsProcessed != 25 || importer.count() != 25 {
+		t.Errorf("processed = %d, imported = %d, want 25", job.EmailsProcessed, importer.count())
+	}
+}
+
+// TestBackfillLockedElsewhere 测试任务锁被其他实例持有时不运行任务
+func TestBackfillLockedElsewhere(t *testing.T) {
+	s, importer, jobs := newTestBackfillService(25)
+	s.options.Locker = &fakeLocker{held: true}
+
+	if _, err := s.Start(context.Background(), "acc-1", BackfillOptions{PageSize: 10}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		running := len(s.runners)
+		s.mu.Unlock()
+		if running == 0 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if job := jobs.latest(); job.Status != "pending" || importer.count() != 0 {
+		t.Errorf("status = %s, imported = %d, want pending job left to the other instance", job.Status, importer.count())
+	}
+}
+
+// newTestBackfillService 创建使用内存仓库和模拟邮箱的回填服务
+func newTestBackfillService(total int) (*backfillService, *fakeImporter, *fakeBackfillJobRepo) {
+	importer := &fakeImporter{total: total, seen: make(map[string]bool)}
+	jobs := &fakeBackfillJobRepo{}
+	s := &backfillService{
+		accountRepo: &fakeAccountRepo{account: &model.Account{UID: "acc-1", Status: "active"}},
+		jobRepo:     jobs,
+		importer:    importer,
+		options:     BackfillOptions{PageSize: defaultBackfillPageSize, PageDelay: time.Millisecond},
+		baseCtx:     context.Background(),
+		runners:     make(map[string]*backfillRunner),
+		sleep:       func(ctx context.Context, d time.Duration) error { return nil },
+	}
+	return s, importer, jobs
+}
+
+// waitBackfillStatus 等待最近的任务进入指定状态
+func waitBackfillStatus(t *testing.T, jobs *fakeBackfillJobRepo, status string) model.BackfillJob {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job := jobs.latest(); job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job := jobs.latest()
+	t.Fatalf("backfill status = %s (%s), want %s", job.Status, job.LastError, status)
+	return job
+}
+
+// fakeAccountRepo 只实现回填用到的查询
+type fakeAccountRepo struct {
+	repository.AccountRepository
+	account *model.Account
+}
+
+func (r *fakeAccountRepo) FindByUID(ctx context.Context, uid string) (*model.Account, error) {
+	if r.account.UID != uid {
+		return nil, nil
+	}
+	account := *r.account
+	return &account, nil
+}
+
+// fakeBackfillJobRepo 内存回填任务仓库
+type fakeBackfillJobRepo struct {
+	mu   sync.Mutex
+	jobs []model.BackfillJob
+}
+
+func (r *fakeBackfillJobRepo) Create(ctx context.Context, job *model.BackfillJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job.ID = int64(len(r.jobs) + 1)
+	r.jobs = append(r.jobs, *job)
+	return nil
+}
+
+func (r *fakeBackfillJobRepo) Update(ctx context.Context, job *model.BackfillJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[job.ID-1] = *job
+	return nil
+}
+
+func (r *fakeBackfillJobRepo) UpdateIfStatus(ctx context.Context, job *model.BackfillJob, statuses ...string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(statuses, r.jobs[job.ID-1].Status) {
+		return false, nil
+	}
+	r.jobs[job.ID-1] = *job
+	return true, nil
+}
+
+func (r *fakeBackfillJobRepo) FindByID(ctx context.Context, id int64) (*model.BackfillJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id < 1 || int(id) > len(r.jobs) {
+		return nil, nil
+	}
+	job := r.jobs[id-1]
+	return &job, nil
+}
+
+func (r *fakeBackfillJobRepo) FindLatestByAccount(ctx context.Context, accountUID string) (*model.BackfillJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		if r.jobs[i].AccountUID == accountUID {
+			job := r.jobs[i]
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeBackfillJobRepo) ListByStatus(ctx context.Context, statuses ...string) ([]*model.BackfillJob, error) {
+	return nil, nil
+}
+
+func (r *fakeBackfillJobRepo) latest() model.BackfillJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.jobs[len(r.jobs)-1]
+}
+
+// fakeImporter 模拟邮箱和入库逻辑
+type fakeImporter struct {
+	total int
+
+	mu     sync.Mutex
+	seen   map[string]bool
+	locked int // 前几次写入时账户正在由其他实例同步
+}
+
+func (f *fakeImporter) exclusive(ctx context.Context, accountUID string, fn func(ctx context.Context) error) error {
+	f.mu.Lock()
+	if f.locked > 0 {
+		f.locked--
+		f.mu.Unlock()
+		return ErrSyncLocked
+	}
+	f.mu.Unlock()
+	return fn(ctx)
+}
+
+func (f *fakeImporter) newProvider(account *model.Account) (adapter.MailProvider, error) {
+	return &fakeHistoryProvider{total: f.total}, nil
+}
+
+func (f *fakeImporter) processEmails(ctx context.Context, account *model.Account, emails []*adapter.Email, syncLog *model.SyncLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, email := range emails {
+		if f.seen[email.ProviderID] {
+			syncLog.EmailsUpdated++
+			continue
+		}
+		f.seen[email.ProviderID] = true
+		syncLog.EmailsNew++
+	}
+	return nil
+}
+
+func (f *fakeImporter) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.seen)
+}
+
+// fakeHistoryProvider 序号从 total-1 递减到 0 的模拟邮箱
+type fakeHistoryProvider struct {
+	total int
+}
+
+func (p *fakeHistoryProvider) Connect(ctx context.Context) error { return nil }
+func (p *fakeHistoryProvider) Disconnect() error                 { return nil }
+func (p *fakeHistoryProvider) GetProviderType() string           { return "fake" }
+func (p *fakeHistoryProvider) GetProtocol() string               { return "fake" }
+func (p *fakeHistoryProvider) TestConnection(ctx context.Context) error {
+	return nil
+}
+func (p *fakeHistoryProvider) FetchEmails(ctx context.Context, since time.Time, limit int) ([]*adapter.Email, error) {
+	return nil, nil
+}
+func (p *fakeHistoryProvider) FetchEmailDetail(ctx context.Context, providerID string) (*adapter.Email, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (p *fakeHistoryProvider) FetchHistory(ctx context.Context, cursor string, limit int) (*adapter.HistoryPage, error) {
+	next := p.total - 1
+	if cursor != "" {
+		next, _ = strconv.Atoi(cursor)
+	}
+
+	page := &adapter.HistoryPage{Total: p.total}
+	for n := next; n >= 0 && n > next-limit; n-- {
+		page.Emails = append(page.Emails, &adapter.Email{ProviderID: strconv.Itoa(n)})
+	}
+	if rest := next - limit; rest >= 0 {
+		page.NextCursor = strconv.Itoa(rest)
+	}
+	return page, nil
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fusionmail/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 下载链接有效期
+const (
+	defaultDownloadLinkExpiry = time.Hour
+	MaxDownloadLinkExpiry     = 7 * 24 * time.Hour
+)
+
+// downloadLinkUsedKeyPrefix 已使用的一次性链接在 Redis 中的键前缀
+const downloadLinkUsedKeyPrefix = "download_link:used:"
+
+// 下载链接校验错误
+var (
+	ErrDownloadLinkInvalid = errors.New("download link is invalid")
+	ErrDownloadLinkExpired = errors.New("download link has expired")
+	ErrDownloadLinkUsed    = errors.New("download link has already been used")
+)
+
+// DownloadLinkOptions 下载链接配置
+type DownloadLinkOptions struct {
+	Secret        string        // HMAC 签名密钥
+	BaseURL       string        // 服务对外地址（如 https://mail.example.com），为空时返回以 /api/v1 开头的相对地址
+	DefaultExpiry time.Duration // 未指定有效期时使用
+}
+
+// DownloadLinkRequest 创建下载链接的参数
+type DownloadLinkRequest struct {
+	ExpiresIn time.Duration // 有效期，0 表示默认有效期，超过 MaxDownloadLinkExpiry 时按最长有效期
+	SingleUse bool          // 只能下载一次
+	Inline    bool          // 在浏览器中直接显示（仅对图片、PDF 等安全类型生效，其他类型仍作为附件下载）
+}
+
+// DownloadLink 签名的附件下载链接
+type DownloadLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SingleUse bool      `json:"single_use"`
+}
+
+// DownloadLinkService 附件下载链接服务
+// 链接参数（附件 ID、过期时间、一次性标识、显示方式）用 HMAC 签名，无需登录即可在有效期内下载，
+// 用于 <img> 等无法携带认证头的场景和分享给他人
+type DownloadLinkService struct {
+	attachments *AttachmentService
+	redis       *redis.Client
+	secret      []byte
+	options     DownloadLinkOptions
+
+	mu   sync.Mutex
+	used map[string]time.Time // Redis 不可用时在进程内记录已使用的一次性链接（nonce -> 过期时间）
+	now  func() time.Time
+}
+
+// NewDownloadLinkService 创建附件下载链接服务
+// redisClient 可以为 nil，此时一次性链接的使用记录只保存在进程内（多实例部署时需要 Redis）
+func NewDownloadLinkService(attachments *AttachmentService, redisClient *redis.Client, options DownloadLinkOptions) (*DownloadLinkService, error) {
+	if options.Secret == "" {
+		return nil, fmt.Errorf("download link secret is required")
+	}
+	if options.DefaultExpiry <= 0 {
+		options.DefaultExpiry = defaultDownloadLinkExpiry
+	}
+	options.BaseURL = strings.TrimSuffix(options.BaseURL, "/")
+
+	return &DownloadLinkService{
+		attachments: attachments,
+		redis:       redisClient,
+		secret:      []byte(options.Secret),
+		options:     options,
+		used:        make(map[string]time.Time),
+		now:         time.Now,
+	}, nil
+}
+
+// Sign 创建附件的签名下载链接
+func (s *DownloadLinkService) Sign(attachmentID int64, req DownloadLinkRequest) (*DownloadLink, error) {
+	expiresIn := req.ExpiresIn
+	if expiresIn <= 0 {
+		expiresIn = s.options.DefaultExpiry
+	}
+	expiresIn = min(expiresIn, MaxDownloadLinkExpiry)
+	expiresAt := s.now().Add(expiresIn).Truncate(time.Second)
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	if req.SingleUse {
+		nonce := make([]byte, 16)
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, fmt.Errorf("failed to generate link nonce: %w", err)
+		}
+		query.Set("nonce", hex.EncodeToString(nonce))
+	}
+	if req.Inline {
+		query.Set("disposition", "inline")
+	}
+	query.Set("signature", s.signature(attachmentID, query))
+
+	return &DownloadLink{
+		URL:       fmt.Sprintf("%s/api/v1/files/attachments/%d?%s", s.options.BaseURL, attachmentID, query.Encode()),
+		ExpiresAt: expiresAt,
+		SingleUse: req.SingleUse,
+	}, nil
+}
+
+// Open 校验下载链接并打开附件内容，返回链接是否要求在浏览器中直接显示
+// 一次性链接在校验通过时即标记为已使用
+func (s *DownloadLinkService) Open(ctx context.Context, attachmentID int64, query url.Values) (io.ReadCloser, *model.EmailAttachment, bool, error) {
+	if err := s.verify(ctx, attachmentID, query); err != nil {
+		return nil, nil, false, err
+	}
+	reader, attachment, err := s.attachments.DownloadAttachment(ctx, attachmentID)
+	if err != nil {
+		return nil, attachment, false, err
+	}
+	return reader, attachment, query.Get("disposition") == "inline", nil
+}
+
+// verify 校验签名、有效期，并消耗一次性链接
+func (s *DownloadLinkService) verify(ctx context.Context, attachmentID int64, query url.Values) error {
+	signature := query.Get("signature")
+	if signature == "" || !hmac.Equal([]byte(signature), []byte(s.signature(attachmentID, query))) {
+		return ErrDownloadLinkInvalid
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrDownloadLinkInvalid
+	}
+	expiresAt := time.Unix(expires, 0)
+	if !s.now().Before(expiresAt) {
+		return ErrDownloadLinkExpired
+	}
+
+	if nonce := query.Get("nonce"); nonce != "" {
+		first, err := s.consume(ctx, nonce, expiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to record download link use: %w", err)
+		}
+		if !first {
+			return ErrDownloadLinkUsed
+		}
+	}
+	return nil
+}
+
+// signature 计算链接签名（签名覆盖附件 ID 和全部链接参数）
+func (s *DownloadLinkService) signature(attachmentID int64, query url.Values) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "attachment-download\n%d\n%s\n%s\n%s",
+		attachmentID, query.Get("expires"), query.Get("nonce"), query.Get("disposition"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// consume 记录一次性链接已使用，返回是否为首次使用；记录保留到链接过期
+func (s *DownloadLinkService) consume(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	ttl := expiresAt.Sub(s.now())
+	if s.redis != nil {
+		return s.redis.SetNX(ctx, downloadLinkUsedKeyPrefix+nonce, 1, ttl).Result()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for key, expiry := range s.used {
+		if !now.Before(expiry) {
+			delete(s.used, key)
+		}
+	}
+	if _, ok := s.used[nonce]; ok {
+		return false, nil
+	}
+	s.used[nonce] = expiresAt
+	return true, nil
+}
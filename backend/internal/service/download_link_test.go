@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseLink 解析下载链接中的附件路径和参数
+func parseLink(t *testing.T, link *DownloadLink) (string, url.Values) {
+	t.Helper()
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		t.Fatalf("invalid link %q: %v", link.URL, err)
+	}
+	return u.Scheme + "://" + u.Host + u.Path, u.Query()
+}
+
+// TestDownloadLinkSignature 测试签名校验、篡改和过期
+func TestDownloadLinkSignature(t *testing.T) {
+	s, err := NewDownloadLinkService(nil, nil, DownloadLinkOptions{Secret: "secret", BaseURL: "https://mail.example.com/"})
+	if err != nil {
+		t.Fatalf("NewDownloadLinkService failed: %v", err)
+	}
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	link, err := s.Sign(42, DownloadLinkRequest{Inline: true})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	base, query := parseLink(t, link)
+	if base != "https://mail.example.com/api/v1/files/attachments/42" {
+		t.Errorf("link base = %s", base)
+	}
+	if !link.ExpiresAt.Equal(now.Add(defaultDownloadLinkExpiry)) || query.Get("disposition") != "inline" {
+		t.Errorf("link = %+v", link)
+	}
+	if err := s.verify(ctx, 42, query); err != nil {
+		t.Errorf("verify valid link: %v", err)
+	}
+
+	// 换附件 ID 或篡改参数都会使签名失效
+	if err := s.verify(ctx, 43, query); !errors.Is(err, ErrDownloadLinkInvalid) {
+		t.Errorf("verify other attachment = %v", err)
+	}
+	tampered := url.Values{}
+	for key, values := range query {
+		tampered[key] = values
+	}
+	tampered.Set("expires", "9999999999")
+	if err := s.verify(ctx, 42, tampered); !errors.Is(err, ErrDownloadLinkInvalid) {
+		t.Errorf("verify tampered expiry = %v", err)
+	}
+	tampered = url.Values{"expires": query["expires"], "signature": query["signature"]}
+	if err := s.verify(ctx, 42, tampered); !errors.Is(err, ErrDownloadLinkInvalid) {
+		t.Errorf("verify removed disposition = %v", err)
+	}
+
+	other, _ := NewDownloadLinkService(nil, nil, DownloadLinkOptions{Secret: "other"})
+	if err := other.verify(ctx, 42, query); !errors.Is(err, ErrDownloadLinkInvalid) {
+		t.Errorf("verify with other secret = %v", err)
+	}
+
+	now = now.Add(defaultDownloadLinkExpiry)
+	if err := s.verify(ctx, 42, query); !errors.Is(err, ErrDownloadLinkExpired) {
+		t.Errorf("verify expired link = %v", err)
+	}
+
+	long, _ := s.Sign(42, DownloadLinkRequest{ExpiresIn: 30 * 24 * time.Hour})
+	if !long.ExpiresAt.Equal(now.Add(MaxDownloadLinkExpiry)) {
+		t.Errorf("expiry not capped: %v", long.ExpiresAt)
+	}
+	if _, err := NewDownloadLinkService(nil, nil, DownloadLinkOptions{}); err == nil {
+		t.Error("empty secret should be rejected")
+	}
+}
+
+// TestDownloadLinkSingleUse 测试一次性链接只能使用一次
+func TestDownloadLinkSingleUse(t *testing.T) {
+	s, _ := NewDownloadLinkService(nil, nil, DownloadLinkOptions{Secret: "secret"})
+	ctx := context.Background()
+
+	link, err := s.Sign(7, DownloadLinkRequest{SingleUse: true, ExpiresIn: time.Minute})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !strings.HasPrefix(link.URL, "/api/v1/files/attachments/7?") || !link.SingleUse {
+		t.Errorf("link = %+v", link)
+	}
+	_, query := parseLink(t, link)
+	if err := s.verify(ctx, 7, query); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := s.verify(ctx, 7, query); !errors.Is(err, ErrDownloadLinkUsed) {
+		t.Errorf("second use = %v, want ErrDownloadLinkUsed", err)
+	}
+
+	// 普通链接可以重复使用
+	reusable, _ := s.Sign(7, DownloadLinkRequest{})
+	_, query = parseLink(t, reusable)
+	for i := 0; i < 2; i++ {
+		if err := s.verify(ctx, 7, query); err != nil {
+			t.Errorf("reusable link use %d: %v", i+1, err)
+		}
+	}
+}
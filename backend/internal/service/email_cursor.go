@@ -0,0 +1,48 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+)
+
+// ErrInvalidCursor 分页游标无效
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// encodeEmailCursor 将列表最后一封邮件的位置编码为不透明的游标字符串
+func encodeEmailCursor(email *model.EmailSummary) string {
+	raw := fmt.Sprintf("%d:%d", email.SentAt.UnixNano(), email.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeEmailCursor 解析游标，空字符串表示第一页（返回 nil）
+func decodeEmailCursor(cursor string) (*repository.EmailCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sentAt, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(sentAt, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	emailID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &repository.EmailCursor{SentAt: time.Unix(0, nanos), ID: emailID}, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"fusionmail/internal/model"
+)
+
+// TestEmailCursor 测试游标编码和解析
+func TestEmailCursor(t *testing.T) {
+	sentAt := time.Date(2024, 5, 1, 8, 30, 0, 123456000, time.UTC)
+	cursor := encodeEmailCursor(&model.EmailSummary{ID: 42, SentAt: sentAt})
+
+	decoded, err := decodeEmailCursor(cursor)
+	if err != nil {
+		t.Fatalf("decodeEmailCursor returned error: %v", err)
+	}
+	if decoded.ID != 42 || !decoded.SentAt.Equal(sentAt) {
+		t.Errorf("decoded cursor = %+v, want id 42 at %v", decoded, sentAt)
+	}
+
+	if decoded, err := decodeEmailCursor(""); err != nil || decoded != nil {
+		t.Errorf("empty cursor = %+v, %v; want nil, nil", decoded, err)
+	}
+
+	for _, invalid := range []string{"!!!", "MTIz", "YTpi"} {
+		if _, err := decodeEmailCursor(invalid); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodeEmailCursor(%q) error = %v, want ErrInvalidCursor", invalid, err)
+		}
+	}
+}
+
+// TestNewEmailListResponse 测试多取一封判断下一页
+func TestNewEmailListResponse(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	summaries := []*model.EmailSummary{
+		{ID: 3, SentAt: base.Add(2 * time.Hour)},
+		{ID: 2, SentAt: base.Add(time.Hour)},
+		{ID: 1, SentAt: base},
+	}
+
+	resp := newEmailListResponse(summaries, 3, 2)
+	if !resp.HasMore || len(resp.Emails) != 2 {
+		t.Fatalf("response = %d emails, has_more %v; want 2 emails and more", len(resp.Emails), resp.HasMore)
+	}
+	next, err := decodeEmailCursor(resp.NextCursor)
+	if err != nil || next.ID != 2 || !next.SentAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("next cursor = %+v, %v; want position of email 2", next, err)
+	}
+
+	last := newEmailListResponse(summaries[2:], 0, 2)
+	if last.HasMore || last.NextCursor != "" || len(last.Emails) != 1 {
+		t.Errorf("last page = %+v, want one email without next cursor", last)
+	}
+
+	empty := newEmailListResponse(nil, 0, 2)
+	if empty.Emails == nil {
+		t.Error("empty page emails should be an empty slice, not nil")
+	}
+}
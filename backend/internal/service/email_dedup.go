@@ -0,0 +1,58 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"fusionmail/internal/adapter"
+)
+
+// 去重键前缀，区分 Message-ID 和内容哈希
+const (
+	dedupKeyMessageID = "mid:"
+	dedupKeyHash      = "hash:"
+)
+
+// maxMessageKeyLen 超过该长度的 Message-ID 改用哈希，保证去重键和会话引用不超过字段长度
+const maxMessageKeyLen = 200
+
+// normalizeMessageID 规范化 Message-ID：去掉尖括号和空白，统一小写
+func normalizeMessageID(messageID string) string {
+	id := strings.TrimSpace(messageID)
+	id = strings.TrimPrefix(id, "<")
+	id = strings.TrimSuffix(id, ">")
+	return strings.ToLower(strings.Join(strings.Fields(id), ""))
+}
+
+// messageKey 返回用于比较的 Message-ID：规范化后过长的改用哈希，Message-ID 为空时返回空字符串
+func messageKey(messageID string) string {
+	id := normalizeMessageID(messageID)
+	if len(id) > maxMessageKeyLen {
+		return hashString(id)
+	}
+	return id
+}
+
+// dedupKey 计算邮件的跨账户去重键
+// 优先使用规范化的 Message-ID；缺失时用发件人、主题、发送时间和正文计算内容哈希
+func dedupKey(email *adapter.Email) string {
+	if key := messageKey(email.MessageID); key != "" {
+		return dedupKeyMessageID + key
+	}
+
+	content := strings.Join([]string{
+		strings.ToLower(strings.TrimSpace(email.FromAddress)),
+		strings.TrimSpace(email.Subject),
+		fmt.Sprintf("%d", email.SentAt.UTC().Unix()),
+		strings.TrimSpace(email.TextBody),
+	}, "\n")
+	return dedupKeyHash + hashString(content)
+}
+
+// hashString 计算字符串的 SHA-256 十六进制摘要
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"fusionmail/internal/adapter"
+)
+
+// TestDedupKey 测试 Message-ID 规范化和缺失时的内容哈希
+func TestDedupKey(t *testing.T) {
+	sentAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	a := dedupKey(&adapter.Email{MessageID: " <ABC.123@Example.COM> "})
+	b := dedupKey(&adapter.Email{MessageID: "abc.123@example.com"})
+	if a != "mid:abc.123@example.com" || a != b {
+		t.Errorf("dedupKey() = %q / %q, want both mid:abc.123@example.com", a, b)
+	}
+
+	long := dedupKey(&adapter.Email{MessageID: strings.Repeat("x", 300) + "@example.com"})
+	if !strings.HasPrefix(long, dedupKeyMessageID) || len(long) > 255 {
+		t.Errorf("dedupKey() for long Message-ID = %q", long)
+	}
+
+	// 没有 Message-ID 时，同一内容（不同时区表示的同一时间）得到相同哈希
+	c := dedupKey(&adapter.Email{FromAddress: "Alice@Example.com", Subject: "Hi", SentAt: sentAt, TextBody: "body"})
+	d := dedupKey(&adapter.Email{FromAddress: "alice@example.com", Subject: "Hi", SentAt: sentAt.In(time.FixedZone("CST", 8*3600)), TextBody: "body\n"})
+	if !strings.HasPrefix(c, dedupKeyHash) || c != d {
+		t.Errorf("dedupKey() content hash = %q / %q, want equal hash keys", c, d)
+	}
+	if e := dedupKey(&adapter.Email{FromAddress: "alice@example.com", Subject: "Hi", SentAt: sentAt, TextBody: "other"}); e == c {
+		t.Errorf("dedupKey() for different body = %q, want different key", e)
+	}
+}
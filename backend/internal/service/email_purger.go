@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+)
+
+// EmailPurger 永久删除邮件：先删除附件和原文文件，再删除邮件记录
+// 文件删除失败的邮件保留在数据库中，下次清理时重试，避免留下没有记录的孤立文件
+type EmailPurger struct {
+	emailRepo   repository.EmailRepository
+	attachments *AttachmentService
+	rawMessages *RawMessageService
+	threads     ThreadService
+}
+
+// NewEmailPurger 创建邮件永久删除服务
+// attachments、rawMessages 和 threads 可以为 nil，此时分别不删除附件、不删除原文文件、不刷新会话统计
+func NewEmailPurger(emailRepo repository.EmailRepository, attachments *AttachmentService, rawMessages *RawMessageService, threads ThreadService) *EmailPurger {
+	return &EmailPurger{
+		emailRepo:   emailRepo,
+		attachments: attachments,
+		rawMessages: rawMessages,
+		threads:     threads,
+	}
+}
+
+// Purge 永久删除邮件（需要 ID、账户和原文路径），返回删除的邮件数
+// 单封邮件的文件删除失败不影响其他邮件，错误合并后返回
+func (p *EmailPurger) Purge(ctx context.Context, emails []*model.Email) (int, error) {
+	var errs []error
+	ids := make([]int64, 0, len(emails))
+	for _, email := range emails {
+		if err := p.deleteFiles(ctx, email); err != nil {
+			errs = append(errs, fmt.Errorf("email %d: %w", email.ID, err))
+			continue
+		}
+		ids = append(ids, email.ID)
+	}
+	if len(ids) == 0 {
+		return 0, errors.Join(errs...)
+	}
+
+	promoted, err := p.emailRepo.Purge(ctx, ids)
+	if err != nil {
+		return 0, errors.Join(append(errs, fmt.Errorf("failed to delete emails: %w", err))...)
+	}
+
+	// 提升为主副本的邮件影响会话的邮件数和参与者
+	if p.threads != nil && len(promoted) > 0 {
+		if err := p.threads.RefreshForEmails(ctx, promoted); err != nil {
+			log.Printf("Failed to refresh threads after purging emails: %v", err)
+		}
+	}
+	return len(ids), errors.Join(errs...)
+}
+
+// deleteFiles 删除邮件的附件和原文文件
+func (p *EmailPurger) deleteFiles(ctx context.Context, email *model.Email) error {
+	if p.attachments != nil {
+		if err := p.attachments.DeleteAttachmentsByEmailID(ctx, email.ID); err != nil {
+			return fmt.Errorf("failed to delete attachments: %w", err)
+		}
+	}
+	if p.rawMessages != nil {
+		if err := p.rawMessages.Delete(ctx, email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+)
+
+// RenderOptions 邮件渲染选项
+type RenderOptions struct {
+	RemoteImages bool // 通过图片代理显示外部图片（未配置图片代理时仍然阻止）
+}
+
+// RenderedEmail 渲染后的邮件正文
+type RenderedEmail struct {
+	HTML                string `json:"html"`                  // 清理后的 HTML 片段，可直接嵌入页面（建议放在 sandbox iframe 中）
+	PlainText           bool   `json:"plain_text"`            // 邮件没有 HTML 正文，由纯文本正文转换
+	RemoteImagesBlocked int    `json:"remote_images_blocked"` // 被阻止的外部图片数，大于 0 时前端可以提示"显示图片"
+	TrackersRemoved     int    `json:"trackers_removed"`      // 移除的追踪像素数
+}
+
+// ImageProxy 外部图片代理，把外部图片地址转换为经服务器中转的地址
+type ImageProxy interface {
+	ProxyURL(rawURL string) (string, error)
+}
+
+// EmailRenderService 邮件正文渲染服务
+// 把邮件 HTML 正文清理为可安全显示的片段：cid: 内联图片替换为签名附件链接，
+// 外部图片默认阻止、用户选择显示时改为经图片代理加载（不泄露用户 IP 和阅读时间），追踪像素直接移除
+type EmailRenderService struct {
+	emailRepo   repository.EmailRepository
+	attachments *AttachmentService
+	links       *DownloadLinkService
+	imageProxy  ImageProxy
+}
+
+// NewEmailRenderService 创建邮件正文渲染服务
+// links 可以为 nil，此时 cid: 内联图片被移除；imageProxy 可以为 nil，此时外部图片始终被阻止
+func NewEmailRenderService(emailRepo repository.EmailRepository, attachments *AttachmentService, links *DownloadLinkService, imageProxy ImageProxy) *EmailRenderService {
+	return &EmailRenderService{
+		emailRepo:   emailRepo,
+		attachments: attachments,
+		links:       links,
+		imageProxy:  imageProxy,
+	}
+}
+
+// Render 渲染邮件正文
+func (s *EmailRenderService) Render(ctx context.Context, id int64, options RenderOptions) (*RenderedEmail, error) {
+	email, err := s.emailRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email: %w", err)
+	}
+	if email == nil {
+		return nil, ErrEmailNotFound
+	}
+
+	if strings.TrimSpace(email.HTMLBody) == "" {
+		return &RenderedEmail{HTML: renderPlainText(email.TextBody), PlainText: true}, nil
+	}
+
+	sanitizer := &htmlSanitizer{}
+	if s.links != nil && s.attachments != nil && strings.Contains(strings.ToLower(email.HTMLBody), "cid:") {
+		inline, err := s.inlineAttachments(ctx, email.ID)
+		if err != nil {
+			return nil, err
+		}
+		sanitizer.resolveCID = func(contentID string) string {
+			attachment, ok := inline[strings.ToLower(contentID)]
+			if !ok {
+				return ""
+			}
+			link, err := s.links.Sign(attachment.ID, DownloadLinkRequest{Inline: true})
+			if err != nil {
+				return ""
+			}
+			return link.URL
+		}
+	}
+	if options.RemoteImages && s.imageProxy != nil {
+		sanitizer.proxyImage = func(rawURL string) string {
+			proxied, err := s.imageProxy.ProxyURL(rawURL)
+			if err != nil {
+				return ""
+			}
+			return proxied
+		}
+	}
+
+	body, err := sanitizer.sanitize(email.HTMLBody)
+	if err != nil {
+		return nil, err
+	}
+	return &RenderedEmail{
+		HTML:                body,
+		RemoteImagesBlocked: sanitizer.blockedImages,
+		TrackersRemoved:     sanitizer.removedTrackers,
+	}, nil
+}
+
+// inlineAttachments 获取邮件中已保存内容、带 Content-ID 的附件（键为小写的 Content-ID）
+func (s *EmailRenderService) inlineAttachments(ctx context.Context, emailID int64) (map[string]*model.EmailAttachment, error) {
+	attachments, err := s.attachments.GetAttachmentsByEmailID(ctx, emailID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	inline := make(map[string]*model.EmailAttachment, len(attachments))
+	for _, attachment := range attachments {
+		if attachment.ContentID == "" || attachment.StorageType == storageTypeNone {
+			continue
+		}
+		inline[strings.ToLower(strings.Trim(attachment.ContentID, "<>"))] = attachment
+	}
+	return inline, nil
+}
+
+// renderPlainText 把纯文本正文转换为保留换行的 HTML
+func renderPlainText(text string) string {
+	if text == "" {
+		return ""
+	}
+	return `<div style="white-space: pre-wrap">` + html.EscapeString(text) + `</div>`
+}
@@ -2,62 +2,86 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"time"
+
 	"fusionmail/internal/model"
 	"fusionmail/internal/repository"
+	"fusionmail/pkg/event"
 )
 
 // EmailService 邮件服务接口
 type EmailService interface {
 	// 邮件查询
 	GetEmailByID(ctx context.Context, id int64) (*model.Email, error)
-	GetEmailList(ctx context.Context, filter *repository.EmailFilter, page, pageSize int) (*EmailListResponse, error)
-	SearchEmails(ctx context.Context, query string, accountUID string, page, pageSize int) (*EmailListResponse, error)
+	GetEmailList(ctx context.Context, filter *repository.EmailFilter, cursor string, pageSize int) (*EmailListResponse, error)
+	SearchEmails(ctx context.Context, query string, accountUID string, collapseDuplicates bool, cursor string, pageSize int) (*EmailListResponse, error)
 
-	// 邮件状态管理（本地）
+	// 邮件状态管理（本地，已读和星标同步到跨账户的重复副本）
 	MarkAsRead(ctx context.Context, ids []int64) error
 	MarkAsUnread(ctx context.Context, ids []int64) error
 	ToggleStar(ctx context.Context, id int64) error
 	ArchiveEmail(ctx context.Context, id int64) error
 	DeleteEmail(ctx context.Context, id int64) error
 
+	// 回收站（DeleteEmail 移入回收站，超过保留期的邮件由保留策略清理自动永久删除）
+	RestoreEmails(ctx context.Context, ids []int64) error
+	PurgeEmail(ctx context.Context, id int64) error
+	EmptyTrash(ctx context.Context, accountUID string) (int, error)
+
 	// 统计信息
 	GetUnreadCount(ctx context.Context, accountUID string) (int64, error)
 	GetAccountStats(ctx context.Context, accountUID string) (*AccountEmailStats, error)
 }
 
-// EmailListResponse 邮件列表响应
+// ErrEmailNotInTrash 只能永久删除回收站中的邮件
+var ErrEmailNotInTrash = errors.New("email is not in trash")
+
+// EmailListResponse 邮件列表响应（游标分页，列表项不含正文）
 type EmailListResponse struct {
-	Emails     []*model.Email `json:"emails"`
-	Total      int64          `json:"total"`
-	Page       int            `json:"page"`
-	PageSize   int            `json:"page_size"`
-	TotalPages int            `json:"total_pages"`
+	Emails     []*model.EmailSummary `json:"emails"`
+	Total      int64                 `json:"total"` // 只在第一页（不带游标）统计，后续页为 0
+	PageSize   int                   `json:"page_size"`
+	NextCursor string                `json:"next_cursor,omitempty"` // 下一页游标，没有更多邮件时为空
+	HasMore    bool                  `json:"has_more"`
 }
 
-// AccountEmailStats 账户邮件统计
+// AccountEmailStats 账户邮件统计（不含已删除的邮件）
 type AccountEmailStats struct {
-	TotalCount    int64 `json:"total_count"`
-	UnreadCount   int64 `json:"unread_count"`
-	StarredCount  int64 `json:"starred_count"`
-	ArchivedCount int64 `json:"archived_count"`
+	TotalCount    int64                   `json:"total_count"`
+	UnreadCount   int64                   `json:"unread_count"`
+	StarredCount  int64                   `json:"starred_count"`
+	ArchivedCount int64                   `json:"archived_count"`
+	Folders       []*model.MailboxCounter `json:"folders"` // 按源邮箱文件夹的统计
 }
 
 // emailService 邮件服务实现
 type emailService struct {
 	emailRepo   repository.EmailRepository
 	accountRepo repository.AccountRepository
+	counterRepo repository.CounterRepository
+	events      EventPublisher
+	threads     ThreadService
+	purger      *EmailPurger
 }
 
 // NewEmailService 创建邮件服务实例
-func NewEmailService(emailRepo repository.EmailRepository, accountRepo repository.AccountRepository) EmailService {
+// events 可以为 nil，此时邮件状态变更不发布事件；threads 可以为 nil，此时不维护会话统计
+// purger 负责永久删除邮件及其附件和原文
+func NewEmailService(emailRepo repository.EmailRepository, accountRepo repository.AccountRepository, counterRepo repository.CounterRepository, events EventPublisher, threads ThreadService, purger *EmailPurger) EmailService {
 	return &emailService{
 		emailRepo:   emailRepo,
 		accountRepo: accountRepo,
+		counterRepo: counterRepo,
+		events:      events,
+		threads:     threads,
+		purger:      purger,
 	}
 }
 
-// GetEmailByID 根据 ID 获取邮件详情
+// GetEmailByID 根据 ID 获取邮件详情（含正文和附件，列表接口只返回摘要）
 func (s *emailService) GetEmailByID(ctx context.Context, id int64) (*model.Email, error) {
 	email, err := s.emailRepo.FindByID(ctx, id)
 	if err != nil {
@@ -69,75 +93,83 @@ func (s *emailService) GetEmailByID(ctx context.Context, id int64) (*model.Email
 	return email, nil
 }
 
-// GetEmailList 获取邮件列表（支持分页和筛选）
-func (s *emailService) GetEmailList(ctx context.Context, filter *repository.EmailFilter, page, pageSize int) (*EmailListResponse, error) {
-	// 参数验证
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+// GetEmailList 获取邮件列表（支持游标分页和筛选）
+func (s *emailService) GetEmailList(ctx context.Context, filter *repository.EmailFilter, cursor string, pageSize int) (*EmailListResponse, error) {
+	after, err := decodeEmailCursor(cursor)
+	if err != nil {
+		return nil, err
 	}
+	pageSize = normalizePageSize(pageSize)
 
-	// 计算偏移量
-	offset := (page - 1) * pageSize
-
-	// 查询邮件列表
-	emails, total, err := s.emailRepo.List(ctx, filter, offset, pageSize)
+	// 多取一封判断是否还有下一页
+	summaries, err := s.emailRepo.ListSummaries(ctx, filter, after, pageSize+1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get email list: %w", err)
 	}
 
-	// 计算总页数
-	totalPages := int(total) / pageSize
-	if int(total)%pageSize > 0 {
-		totalPages++
+	var total int64
+	if after == nil {
+		total, err = s.emailRepo.Count(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count emails: %w", err)
+		}
 	}
 
-	return &EmailListResponse{
-		Emails:     emails,
-		Total:      total,
-		Page:       page,
-		PageSize:   pageSize,
-		TotalPages: totalPages,
-	}, nil
+	return newEmailListResponse(summaries, total, pageSize), nil
 }
 
 // SearchEmails 全文搜索邮件
-func (s *emailService) SearchEmails(ctx context.Context, query string, accountUID string, page, pageSize int) (*EmailListResponse, error) {
+func (s *emailService) SearchEmails(ctx context.Context, query string, accountUID string, collapseDuplicates bool, cursor string, pageSize int) (*EmailListResponse, error) {
 	// 参数验证
 	if query == "" {
 		return nil, fmt.Errorf("search query is required")
 	}
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+	after, err := decodeEmailCursor(cursor)
+	if err != nil {
+		return nil, err
 	}
+	pageSize = normalizePageSize(pageSize)
 
-	// 计算偏移量
-	offset := (page - 1) * pageSize
-
-	// 搜索邮件
-	emails, total, err := s.emailRepo.Search(ctx, query, accountUID, offset, pageSize)
+	summaries, err := s.emailRepo.SearchSummaries(ctx, query, accountUID, collapseDuplicates, after, pageSize+1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search emails: %w", err)
 	}
 
-	// 计算总页数
-	totalPages := int(total) / pageSize
-	if int(total)%pageSize > 0 {
-		totalPages++
+	var total int64
+	if after == nil {
+		total, err = s.emailRepo.CountSearch(ctx, query, accountUID, collapseDuplicates)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count search results: %w", err)
+		}
 	}
 
-	return &EmailListResponse{
-		Emails:     emails,
-		Total:      total,
-		Page:       page,
-		PageSize:   pageSize,
-		TotalPages: totalPages,
-	}, nil
+	return newEmailListResponse(summaries, total, pageSize), nil
+}
+
+// normalizePageSize 校验每页数量（默认 20，最大 100）
+func normalizePageSize(pageSize int) int {
+	if pageSize < 1 || pageSize > 100 {
+		return 20
+	}
+	return pageSize
+}
+
+// newEmailListResponse 根据多取一封的查询结果构建列表响应
+func newEmailListResponse(summaries []*model.EmailSummary, total int64, pageSize int) *EmailListResponse {
+	resp := &EmailListResponse{
+		Emails:   summaries,
+		Total:    total,
+		PageSize: pageSize,
+	}
+	if len(summaries) > pageSize {
+		resp.Emails = summaries[:pageSize]
+		resp.HasMore = true
+		resp.NextCursor = encodeEmailCursor(resp.Emails[pageSize-1])
+	}
+	if resp.Emails == nil {
+		resp.Emails = []*model.EmailSummary{}
+	}
+	return resp
 }
 
 // MarkAsRead 标记邮件为已读
@@ -145,7 +177,19 @@ func (s *emailService) MarkAsRead(ctx context.Context, ids []int64) error {
 	if len(ids) == 0 {
 		return nil
 	}
-	return s.emailRepo.MarkAsRead(ctx, ids)
+	ids, err := s.withDuplicates(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if err := s.emailRepo.MarkAsRead(ctx, ids); err != nil {
+		return err
+	}
+	s.refreshThreads(ctx, ids)
+
+	s.publishForEmails(ctx, ids, func(email *model.Email) *event.Event {
+		return event.EmailReadEvent(email.ID, email.AccountUID)
+	})
+	return nil
 }
 
 // MarkAsUnread 标记邮件为未读
@@ -153,7 +197,57 @@ func (s *emailService) MarkAsUnread(ctx context.Context, ids []int64) error {
 	if len(ids) == 0 {
 		return nil
 	}
-	return s.emailRepo.MarkAsUnread(ctx, ids)
+	ids, err := s.withDuplicates(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if err := s.emailRepo.MarkAsUnread(ctx, ids); err != nil {
+		return err
+	}
+	s.refreshThreads(ctx, ids)
+
+	s.publishForEmails(ctx, ids, func(email *model.Email) *event.Event {
+		return event.EmailUnreadEvent(email.ID, email.AccountUID)
+	})
+	return nil
+}
+
+// withDuplicates 将邮件 ID 扩展为所在重复组的全部邮件 ID
+func (s *emailService) withDuplicates(ctx context.Context, ids []int64) ([]int64, error) {
+	groupIDs, err := s.emailRepo.FindDuplicateGroupIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate emails: %w", err)
+	}
+	if len(groupIDs) == 0 {
+		return ids, nil
+	}
+	return groupIDs, nil
+}
+
+// refreshThreads 重新计算邮件所属会话的统计，失败只记录日志
+func (s *emailService) refreshThreads(ctx context.Context, ids []int64) {
+	if s.threads == nil {
+		return
+	}
+	if err := s.threads.RefreshForEmails(ctx, ids); err != nil {
+		log.Printf("Failed to refresh threads: %v", err)
+	}
+}
+
+// publishForEmails 为批量操作涉及的每封邮件发布事件（事件需要账户 UID，因此先查询邮件）
+func (s *emailService) publishForEmails(ctx context.Context, ids []int64, newEvent func(email *model.Email) *event.Event) {
+	if s.events == nil {
+		return
+	}
+
+	emails, err := s.emailRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("Failed to load emails for events: %v", err)
+		return
+	}
+	for _, email := range emails {
+		publishEvent(ctx, s.events, newEvent(email))
+	}
 }
 
 // ToggleStar 切换星标状态
@@ -166,76 +260,133 @@ func (s *emailService) ToggleStar(ctx context.Context, id int64) error {
 		return fmt.Errorf("email not found")
 	}
 
-	// 切换星标状态
+	// 切换星标状态（重复组内的副本一起切换）
 	newStarred := !email.IsStarred
-	return s.emailRepo.UpdateLocalStatus(ctx, id, nil, &newStarred, nil, nil)
+	ids, err := s.withDuplicates(ctx, []int64{id})
+	if err != nil {
+		return err
+	}
+	if err := s.emailRepo.SetStarred(ctx, ids, newStarred); err != nil {
+		return err
+	}
+
+	s.publishForEmails(ctx, ids, func(email *model.Email) *event.Event {
+		return event.EmailStarredEvent(email.ID, email.AccountUID, newStarred)
+	})
+	return nil
 }
 
 // ArchiveEmail 归档邮件
 func (s *emailService) ArchiveEmail(ctx context.Context, id int64) error {
 	archived := true
-	return s.emailRepo.UpdateLocalStatus(ctx, id, nil, nil, &archived, nil)
+	if err := s.emailRepo.UpdateLocalStatus(ctx, id, nil, nil, &archived, nil); err != nil {
+		return err
+	}
+	s.refreshThreads(ctx, []int64{id})
+
+	s.publishForEmails(ctx, []int64{id}, func(email *model.Email) *event.Event {
+		return event.EmailArchivedEvent(email.ID, email.AccountUID)
+	})
+	return nil
 }
 
 // DeleteEmail 删除邮件（软删除）
 func (s *emailService) DeleteEmail(ctx context.Context, id int64) error {
 	deleted := true
-	return s.emailRepo.UpdateLocalStatus(ctx, id, nil, nil, nil, &deleted)
-}
+	if err := s.emailRepo.UpdateLocalStatus(ctx, id, nil, nil, nil, &deleted); err != nil {
+		return err
+	}
+	s.refreshThreads(ctx, []int64{id})
 
-// GetUnreadCount 获取未读邮件数
-func (s *emailService) GetUnreadCount(ctx context.Context, accountUID string) (int64, error) {
-	return s.emailRepo.CountUnread(ctx, accountUID)
+	s.publishForEmails(ctx, []int64{id}, func(email *model.Email) *event.Event {
+		return event.EmailDeletedEvent(email.ID, email.AccountUID)
+	})
+	return nil
 }
 
-// GetAccountStats 获取账户邮件统计信息
-func (s *emailService) GetAccountStats(ctx context.Context, accountUID string) (*AccountEmailStats, error) {
-	stats := &AccountEmailStats{}
-
-	// 统计总数
-	filter := &repository.EmailFilter{
-		AccountUID: accountUID,
+// RestoreEmails 把邮件移出回收站
+func (s *emailService) RestoreEmails(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
 	}
-	falseVal := false
-	filter.IsDeleted = &falseVal
+	if err := s.emailRepo.SetDeleted(ctx, ids, false); err != nil {
+		return fmt.Errorf("failed to restore emails: %w", err)
+	}
+	s.refreshThreads(ctx, ids)
+
+	s.publishForEmails(ctx, ids, func(email *model.Email) *event.Event {
+		return event.EmailRestoredEvent(email.ID, email.AccountUID)
+	})
+	return nil
+}
 
-	_, total, err := s.emailRepo.List(ctx, filter, 0, 1)
+// PurgeEmail 永久删除回收站中的邮件（包括附件和原文文件）
+func (s *emailService) PurgeEmail(ctx context.Context, id int64) error {
+	email, err := s.emailRepo.FindByID(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count total emails: %w", err)
+		return fmt.Errorf("failed to get email: %w", err)
+	}
+	if email == nil {
+		return ErrEmailNotFound
+	}
+	if !email.IsDeleted {
+		return ErrEmailNotInTrash
 	}
-	stats.TotalCount = total
 
-	// 统计未读数
-	unreadCount, err := s.emailRepo.CountUnread(ctx, accountUID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to count unread emails: %w", err)
+	if _, err := s.purger.Purge(ctx, []*model.Email{email}); err != nil {
+		return fmt.Errorf("failed to purge email: %w", err)
 	}
-	stats.UnreadCount = unreadCount
+	return nil
+}
 
-	// 统计星标数
-	trueVal := true
-	starredFilter := &repository.EmailFilter{
-		AccountUID: accountUID,
-		IsStarred:  &trueVal,
-		IsDeleted:  &falseVal,
+// EmptyTrash 永久删除回收站中的全部邮件（accountUID 为空时清空所有账户），返回删除的邮件数
+// 文件删除失败的邮件保留在回收站中，错误合并后返回
+func (s *emailService) EmptyTrash(ctx context.Context, accountUID string) (int, error) {
+	// 只删除开始清空前移入回收站的邮件
+	before := time.Now()
+	var errs []error
+	purged := 0
+	var afterID int64
+	for {
+		emails, err := s.emailRepo.ListTrashed(ctx, accountUID, before, afterID, retentionBatchSize)
+		if err != nil {
+			return purged, fmt.Errorf("failed to list trashed emails: %w", err)
+		}
+		if len(emails) == 0 {
+			return purged, errors.Join(errs...)
+		}
+		afterID = emails[len(emails)-1].ID
+
+		count, err := s.purger.Purge(ctx, emails)
+		purged += count
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
-	_, starredCount, err := s.emailRepo.List(ctx, starredFilter, 0, 1)
+}
+
+// GetUnreadCount 获取未读邮件数
+func (s *emailService) GetUnreadCount(ctx context.Context, accountUID string) (int64, error) {
+	return s.emailRepo.CountUnread(ctx, accountUID)
+}
+
+// GetAccountStats 获取账户邮件统计信息（读取计数器，不扫描邮件表）
+func (s *emailService) GetAccountStats(ctx context.Context, accountUID string) (*AccountEmailStats, error) {
+	totals, err := s.counterRepo.Totals(ctx, accountUID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count starred emails: %w", err)
+		return nil, fmt.Errorf("failed to get email counters: %w", err)
 	}
-	stats.StarredCount = starredCount
 
-	// 统计归档数
-	archivedFilter := &repository.EmailFilter{
-		AccountUID: accountUID,
-		IsArchived: &trueVal,
-		IsDeleted:  &falseVal,
-	}
-	_, archivedCount, err := s.emailRepo.List(ctx, archivedFilter, 0, 1)
+	folders, err := s.counterRepo.ListByAccount(ctx, accountUID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count archived emails: %w", err)
+		return nil, fmt.Errorf("failed to get folder counters: %w", err)
 	}
-	stats.ArchivedCount = archivedCount
 
-	return stats, nil
+	return &AccountEmailStats{
+		TotalCount:    totals.TotalCount,
+		UnreadCount:   totals.UnreadCount,
+		StarredCount:  totals.StarredCount,
+		ArchivedCount: totals.ArchivedCount,
+		Folders:       folders,
+	}, nil
 }
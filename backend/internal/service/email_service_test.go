@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/pkg/event"
+)
+
+// TestEmailServicePublishesEvents 测试邮件状态变更后发布带账户 UID 的事件
+func TestEmailServicePublishesEvents(t *testing.T) {
+	repo := &fakeEmailRepo{emails: map[int64]*model.Email{
+		1: {ID: 1, AccountUID: "acc-1"},
+		2: {ID: 2, AccountUID: "acc-2", IsStarred: true},
+	}}
+	events := &fakeEventPublisher{}
+	s := NewEmailService(repo, nil, nil, events, nil, nil)
+	ctx := context.Background()
+
+	if err := s.MarkAsRead(ctx, []int64{1, 2, 3}); err != nil {
+		t.Fatalf("MarkAsRead() error = %v", err)
+	}
+	if err := s.ToggleStar(ctx, 2); err != nil {
+		t.Fatalf("ToggleStar() error = %v", err)
+	}
+	if err := s.DeleteEmail(ctx, 1); err != nil {
+		t.Fatalf("DeleteEmail() error = %v", err)
+	}
+	if err := s.RestoreEmails(ctx, []int64{1}); err != nil {
+		t.Fatalf("RestoreEmails() error = %v", err)
+	}
+
+	got := events.all()
+	want := []struct {
+		typ        event.EventType
+		accountUID string
+	}{
+		{event.EventEmailRead, "acc-1"},
+		{event.EventEmailRead, "acc-2"},
+		{event.EventEmailStarred, "acc-2"},
+		{event.EventEmailDeleted, "acc-1"},
+		{event.EventEmailRestored, "acc-1"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("published %d events, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Data["account_uid"] != w.accountUID {
+			t.Errorf("event[%d] = %s/%v, want %s/%s", i, got[i].Type, got[i].Data["account_uid"], w.typ, w.accountUID)
+		}
+	}
+	if starred := got[2].Data["is_starred"]; starred != false {
+		t.Errorf("starred event is_starred = %v, want false", starred)
+	}
+
+	// 未配置事件发布时正常执行
+	if err := NewEmailService(repo, nil, nil, nil, nil, nil).ArchiveEmail(ctx, 1); err != nil {
+		t.Errorf("ArchiveEmail() without publisher error = %v", err)
+	}
+}
+
+// TestEmailServicePropagatesDuplicateState 测试已读和星标状态同步到跨账户的重复副本
+func TestEmailServicePropagatesDuplicateState(t *testing.T) {
+	canonicalID := int64(1)
+	repo := &fakeEmailRepo{emails: map[int64]*model.Email{
+		1: {ID: 1, AccountUID: "acc-1"},
+		2: {ID: 2, AccountUID: "acc-2", CanonicalID: &canonicalID},
+		3: {ID: 3, AccountUID: "acc-3", CanonicalID: &canonicalID},
+		4: {ID: 4, AccountUID: "acc-1"},
+	}}
+	s := NewEmailService(repo, nil, nil, nil, nil, nil)
+	ctx := context.Background()
+
+	if err := s.MarkAsRead(ctx, []int64{2}); err != nil {
+		t.Fatalf("MarkAsRead() error = %v", err)
+	}
+	if err := s.ToggleStar(ctx, 3); err != nil {
+		t.Fatalf("ToggleStar() error = %v", err)
+	}
+
+	for id, email := range repo.emails {
+		inGroup := id != 4
+		if email.IsRead != inGroup || email.IsStarred != inGroup {
+			t.Errorf("email %d read/starred = %v/%v, want %v", id, email.IsRead, email.IsStarred, inGroup)
+		}
+	}
+}
+
+// TestEmailServiceTrash 测试恢复邮件、只允许永久删除回收站中的邮件以及按账户清空回收站
+func TestEmailServiceTrash(t *testing.T) {
+	repo := &fakeEmailRepo{emails: map[int64]*model.Email{
+		1: {ID: 1, AccountUID: "acc-1", IsDeleted: true},
+		2: {ID: 2, AccountUID: "acc-1", IsDeleted: true},
+		3: {ID: 3, AccountUID: "acc-1"},
+		4: {ID: 4, AccountUID: "acc-2", IsDeleted: true},
+	}}
+	s := NewEmailService(repo, nil, nil, nil, nil, NewEmailPurger(repo, nil, nil, nil))
+	ctx := context.Background()
+
+	if err := s.RestoreEmails(ctx, []int64{1}); err != nil {
+		t.Fatalf("RestoreEmails() error = %v", err)
+	}
+	if repo.emails[1].IsDeleted {
+		t.Error("email 1 still in trash")
+	}
+
+	if err := s.PurgeEmail(ctx, 3); !errors.Is(err, ErrEmailNotInTrash) {
+		t.Errorf("PurgeEmail() outside trash error = %v, want ErrEmailNotInTrash", err)
+	}
+	if err := s.PurgeEmail(ctx, 99); !errors.Is(err, ErrEmailNotFound) {
+		t.Errorf("PurgeEmail() missing email error = %v, want ErrEmailNotFound", err)
+	}
+
+	purged, err := s.EmptyTrash(ctx, "acc-1")
+	if err != nil || purged != 1 {
+		t.Fatalf("EmptyTrash() = %d, %v, want 1", purged, err)
+	}
+	if _, ok := repo.emails[2]; ok {
+		t.Error("email 2 not purged")
+	}
+	for _, id := range []int64{1, 3, 4} {
+		if _, ok := repo.emails[id]; !ok {
+			t.Errorf("email %d purged", id)
+		}
+	}
+
+	if err := s.PurgeEmail(ctx, 4); err != nil {
+		t.Fatalf("PurgeEmail() error = %v", err)
+	}
+	if _, ok := repo.emails[4]; ok {
+		t.Error("email 4 not purged")
+	}
+}
+
+// fakeEmailRepo 只实现邮件状态变更用到的方法
+type fakeEmailRepo struct {
+	repository.EmailRepository
+	emails map[int64]*model.Email
+}
+
+func (r *fakeEmailRepo) FindByID(ctx context.Context, id int64) (*model.Email, error) {
+	return r.emails[id], nil
+}
+
+func (r *fakeEmailRepo) FindByIDs(ctx context.Context, ids []int64) ([]*model.Email, error) {
+	var emails []*model.Email
+	for _, id := range ids {
+		if email, ok := r.emails[id]; ok {
+			emails = append(emails, email)
+		}
+	}
+	return emails, nil
+}
+
+func (r *fakeEmailRepo) MarkAsRead(ctx context.Context, ids []int64) error {
+	for _, id := range ids {
+		if email, ok := r.emails[id]; ok {
+			email.IsRead = true
+		}
+	}
+	return nil
+}
+
+func (r *fakeEmailRepo) SetStarred(ctx context.Context, ids []int64, starred bool) error {
+	for _, id := range ids {
+		if email, ok := r.emails[id]; ok {
+			email.IsStarred = starred
+		}
+	}
+	return nil
+}
+
+func (r *fakeEmailRepo) FindDuplicateGroupIDs(ctx context.Context, ids []int64) ([]int64, error) {
+	groupOf := func(email *model.Email) int64 {
+		if email.CanonicalID != nil {
+			return *email.CanonicalID
+		}
+		return email.ID
+	}
+
+	groups := make(map[int64]bool)
+	for _, id := range ids {
+		if email, ok := r.emails[id]; ok {
+			groups[groupOf(email)] = true
+		}
+	}
+	var groupIDs []int64
+	for id, email := range r.emails {
+		if groups[groupOf(email)] {
+			groupIDs = append(groupIDs, id)
+		}
+	}
+	sort.Slice(groupIDs, func(i, j int) bool { return groupIDs[i] < groupIDs[j] })
+	return groupIDs, nil
+}
+
+func (r *fakeEmailRepo) UpdateLocalStatus(ctx context.Context, id int64, isRead, isStarred, isArchived, isDeleted *bool) error {
+	if email, ok := r.emails[id]; ok && isStarred != nil {
+		email.IsStarred = *isStarred
+	}
+	return nil
+}
+
+func (r *fakeEmailRepo) SetDeleted(ctx context.Context, ids []int64, deleted bool) error {
+	for _, id := range ids {
+		if email, ok := r.emails[id]; ok {
+			email.IsDeleted = deleted
+		}
+	}
+	return nil
+}
+
+func (r *fakeEmailRepo) ListTrashed(ctx context.Context, accountUID string, before time.Time, afterID int64, limit int) ([]*model.Email, error) {
+	var emails []*model.Email
+	for id, email := range r.emails {
+		if email.IsDeleted && id > afterID && (accountUID == "" || email.AccountUID == accountUID) {
+			emails = append(emails, email)
+		}
+	}
+	sort.Slice(emails, func(i, j int) bool { return emails[i].ID < emails[j].ID })
+	if len(emails) > limit {
+		emails = emails[:limit]
+	}
+	return emails, nil
+}
+
+func (r *fakeEmailRepo) Purge(ctx context.Context, ids []int64) ([]int64, error) {
+	for _, id := range ids {
+		delete(r.emails, id)
+	}
+	return nil, nil
+}
+
+// fakeEventPublisher 记录发布的事件
+type fakeEventPublisher struct {
+	mu     sync.Mutex
+	events []*event.Event
+}
+
+func (p *fakeEventPublisher) PublishEvent(ctx context.Context, evt *event.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, evt)
+	return nil
+}
+
+func (p *fakeEventPublisher) all() []*event.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*event.Event(nil), p.events...)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"log"
 
 	"fusionmail/internal/model"
 	"fusionmail/pkg/event"
@@ -21,6 +22,23 @@ type EventService interface {
 	PublishEvent(ctx context.Context, evt *event.Event) error
 }
 
+// EventPublisher 事件发布接口
+// 同步、账户和邮件服务通过它发布领域事件，由 EventService 订阅后触发规则和 Webhook
+type EventPublisher interface {
+	PublishEvent(ctx context.Context, evt *event.Event) error
+}
+
+// publishEvent 发布事件，publisher 为 nil 时忽略
+// 发布失败只记录日志，不影响已完成的业务操作
+func publishEvent(ctx context.Context, publisher EventPublisher, evt *event.Event) {
+	if publisher == nil {
+		return
+	}
+	if err := publisher.PublishEvent(context.WithoutCancel(ctx), evt); err != nil {
+		log.Printf("Failed to publish %s event: %v", evt.Type, err)
+	}
+}
+
 // eventService 事件服务实现
 type eventService struct {
 	eventBus       event.Bus
@@ -100,6 +118,7 @@ func (s *eventService) subscribeToWebhookEvents(ctx context.Context) error {
 		event.EventEmailStarred,
 		event.EventEmailArchived,
 		event.EventEmailDeleted,
+		event.EventEmailRestored,
 	}
 
 	for _, eventType := range emailEvents {
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedElements 保留的元素（按允许列表，未列出的元素去掉标签、保留内容）
+var allowedElements = map[string]bool{
+	"a": true, "abbr": true, "address": true, "article": true, "b": true, "bdi": true, "bdo": true,
+	"big": true, "blockquote": true, "br": true, "caption": true, "center": true, "cite": true,
+	"code": true, "col": true, "colgroup": true, "dd": true, "del": true, "dfn": true, "div": true,
+	"dl": true, "dt": true, "em": true, "figcaption": true, "figure": true, "font": true,
+	"footer": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "i": true, "img": true, "ins": true, "kbd": true, "li": true,
+	"main": true, "mark": true, "ol": true, "p": true, "pre": true, "q": true, "s": true,
+	"samp": true, "section": true, "small": true, "span": true, "strike": true, "strong": true,
+	"sub": true, "summary": true, "details": true, "sup": true, "table": true, "tbody": true,
+	"td": true, "tfoot": true, "th": true, "thead": true, "time": true, "tr": true, "tt": true,
+	"u": true, "ul": true, "var": true, "wbr": true,
+}
+
+// droppedElements 连同内容一起移除的元素（脚本、样式表、表单控件、嵌入内容等）
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "iframe": true,
+	"frame": true, "frameset": true, "object": true, "embed": true, "applet": true,
+	"audio": true, "video": true, "source": true, "track": true, "picture": true, "canvas": true,
+	"map": true, "area": true, "input": true, "button": true, "select": true, "option": true,
+	"optgroup": true, "textarea": true, "datalist": true, "output": true, "meter": true,
+	"progress": true, "dialog": true, "title": true, "head": true, "meta": true, "link": true,
+	"base": true, "svg": true, "math": true, "param": true,
+}
+
+// allowedAttributes 保留的展示类属性；href、src、style 单独校验，on* 事件、id、class 等一律移除
+var allowedAttributes = map[string]bool{
+	"abbr": true, "align": true, "alt": true, "bgcolor": true, "border": true, "cellpadding": true,
+	"cellspacing": true, "clear": true, "color": true, "colspan": true, "datetime": true,
+	"dir": true, "face": true, "frame": true, "headers": true, "height": true, "hspace": true,
+	"lang": true, "noshade": true, "nowrap": true, "reversed": true, "rowspan": true,
+	"rules": true, "scope": true, "size": true, "span": true, "start": true, "summary": true,
+	"title": true, "type": true, "valign": true, "vspace": true, "width": true,
+}
+
+// blockedStyleProperties 移除的 CSS 属性（可覆盖页面、执行代码或加载外部资源）
+var blockedStyleProperties = map[string]bool{
+	"behavior": true, "-moz-binding": true, "position": true, "content": true, "cursor": true,
+}
+
+// blockedStyleValues 含有这些内容的 CSS 声明整条移除（外部资源、脚本以及可用于绕过检查的转义）
+var blockedStyleValues = []string{"url(", "image-set(", "expression(", "javascript:", "@import", "\\", "<"}
+
+// stylePropertyPattern 合法的 CSS 属性名
+var stylePropertyPattern = regexp.MustCompile(`^-?[a-z][a-z0-9-]*$`)
+
+// dataImagePattern 允许内嵌的 data: 图片（不含 SVG，SVG 可以携带脚本）
+var dataImagePattern = regexp.MustCompile(`(?i)^data:image/(png|gif|jpe?g|webp|bmp);base64,[a-z0-9+/=\s]+$`)
+
+// trackerHosts 已知的邮件打开追踪服务域名（匹配域名本身及其子域名）
+var trackerHosts = []string{
+	"mailtrack.io", "yesware.com", "bananatag.com", "getnotify.com", "mailtag.io",
+	"sidekickopen01.com", "sidekickopen70.com", "track.getsidekick.com", "mixmax.com",
+	"streak.com", "mailfoogae.appspot.com", "pixel.watch", "emltrk.com", "pstmrk.it",
+}
+
+// trackerPathPatterns 常见邮件营销平台的打开追踪地址特征（如 SendGrid 的 /wf/open、Mailchimp 的 /track/open.php）
+var trackerPathPatterns = []string{
+	"/wf/open", "/track/open", "/tracking/open", "/trk/open", "/open.php", "/open.aspx",
+	"/e/o/", "/o.gif", "/pixel.gif", "/beacon.gif", "/email/open",
+}
+
+// htmlSanitizer 邮件 HTML 清理器
+// 按允许列表保留元素和属性，移除脚本、样式表、表单和事件处理器；
+// 图片地址按来源处理：cid: 引用交给 resolveCID，外部图片交给 proxyImage，追踪像素直接移除
+type htmlSanitizer struct {
+	resolveCID func(contentID string) string // 返回内联附件的访问地址，空字符串表示找不到
+	proxyImage func(rawURL string) string    // 返回外部图片的代理地址；为 nil 或返回空字符串时阻止该图片
+
+	blockedImages   int // 被阻止的外部图片数
+	removedTrackers int // 移除的追踪像素数
+}
+
+// sanitize 清理 HTML 片段，返回可以安全嵌入页面的 HTML
+// 解析按 HTML5 规则进行，未闭合或错误嵌套的标签在输出中会被补全
+func (s *htmlSanitizer) sanitize(body string) (string, error) {
+	container := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(body), container)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html: %w", err)
+	}
+
+	root := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	for _, node := range nodes {
+		root.AppendChild(node)
+	}
+	s.sanitizeChildren(root)
+
+	var buf bytes.Buffer
+	for child := root.FirstChild; child != nil; child = child.NextSibling {
+		if err := html.Render(&buf, child); err != nil {
+			return "", fmt.Errorf("failed to render html: %w", err)
+		}
+	}
+	return buf.String(), nil
+}
+
+// sanitizeChildren 清理节点的全部子节点
+func (s *htmlSanitizer) sanitizeChildren(parent *html.Node) {
+	for node := parent.FirstChild; node != nil; {
+		next := node.NextSibling
+		switch {
+		case node.Type == html.TextNode:
+		case node.Type != html.ElementNode || node.Namespace != "" || droppedElements[node.Data]:
+			// 注释（包括 IE 条件注释）、SVG/MathML 以及危险元素连同内容移除
+			parent.RemoveChild(node)
+		case allowedElements[node.Data]:
+			if s.sanitizeElement(node) {
+				s.sanitizeChildren(node)
+			} else {
+				parent.RemoveChild(node)
+			}
+		default:
+			// 未知元素（html、body、form、Office 的 o:p 等）去掉标签，子节点提升到当前位置继续处理
+			first := node.FirstChild
+			for child := node.FirstChild; child != nil; child = node.FirstChild {
+				node.RemoveChild(child)
+				parent.InsertBefore(child, node)
+			}
+			parent.RemoveChild(node)
+			if first != nil {
+				next = first
+			}
+		}
+		node = next
+	}
+}
+
+// sanitizeElement 过滤元素属性，返回 false 表示整个元素应移除
+func (s *htmlSanitizer) sanitizeElement(node *html.Node) bool {
+	attrs := make([]html.Attribute, 0, len(node.Attr))
+	var src string
+	for _, attr := range node.Attr {
+		if attr.Namespace != "" {
+			continue
+		}
+		key := strings.ToLower(attr.Key)
+		switch {
+		case key == "href" && node.Data == "a":
+			if href, ok := safeLinkURL(attr.Val); ok {
+				attrs = append(attrs, html.Attribute{Key: "href", Val: href})
+			}
+		case key == "src" && node.Data == "img":
+			src = strings.TrimSpace(attr.Val)
+		case key == "style":
+			if style := sanitizeStyle(attr.Val); style != "" {
+				attrs = append(attrs, html.Attribute{Key: "style", Val: style})
+			}
+		case allowedAttributes[key]:
+			attrs = append(attrs, html.Attribute{Key: key, Val: attr.Val})
+		}
+	}
+	node.Attr = attrs
+
+	switch node.Data {
+	case "a":
+		// 链接在新窗口打开，不向目标网站发送来源页面
+		if getAttribute(node, "href") != "" {
+			node.Attr = append(node.Attr,
+				html.Attribute{Key: "target", Val: "_blank"},
+				html.Attribute{Key: "rel", Val: "noopener noreferrer nofollow"})
+		}
+	case "img":
+		return s.sanitizeImage(node, src)
+	}
+	return true
+}
+
+// sanitizeImage 处理图片地址，返回 false 表示图片应移除
+func (s *htmlSanitizer) sanitizeImage(node *html.Node, src string) bool {
+	lower := strings.ToLower(src)
+	switch {
+	case src == "":
+		return false
+	case strings.HasPrefix(lower, "cid:"):
+		if s.resolveCID == nil {
+			return false
+		}
+		contentID, err := url.PathUnescape(src[len("cid:"):])
+		if err != nil {
+			contentID = src[len("cid:"):]
+		}
+		resolved := s.resolveCID(strings.Trim(contentID, "<>"))
+		if resolved == "" {
+			return false
+		}
+		setAttribute(node, "src", resolved)
+		return true
+	case strings.HasPrefix(lower, "data:"):
+		if !dataImagePattern.MatchString(src) {
+			return false
+		}
+		setAttribute(node, "src", src)
+		return true
+	}
+
+	u, err := url.Parse(src)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if isTrackingPixel(node, u) {
+		s.removedTrackers++
+		return false
+	}
+	if s.proxyImage != nil {
+		if proxied := s.proxyImage(u.String()); proxied != "" {
+			setAttribute(node, "src", proxied)
+			return true
+		}
+	}
+	// 保留没有 src 的图片元素（及其 alt、尺寸），避免打乱邮件排版
+	s.blockedImages++
+	return true
+}
+
+// isTrackingPixel 判断外部图片是否为追踪像素：已知追踪服务、1x1 或隐藏的图片
+func isTrackingPixel(node *html.Node, u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, tracker := range trackerHosts {
+		if host == tracker || strings.HasSuffix(host, "."+tracker) {
+			return true
+		}
+	}
+	path := strings.ToLower(u.EscapedPath())
+	for _, pattern := range trackerPathPatterns {
+		if strings.Contains(path, pattern) {
+			return true
+		}
+	}
+
+	width, height := getAttribute(node, "width"), getAttribute(node, "height")
+	for _, decl := range styleDeclarations(getAttribute(node, "style")) {
+		switch decl[0] {
+		case "width":
+			width = decl[1]
+		case "height":
+			height = decl[1]
+		case "display":
+			if decl[1] == "none" {
+				return true
+			}
+		case "visibility":
+			if decl[1] == "hidden" {
+				return true
+			}
+		case "opacity":
+			if v, err := strconv.ParseFloat(decl[1], 64); err == nil && v == 0 {
+				return true
+			}
+		}
+	}
+	return isTinyDimension(width) && isTinyDimension(height)
+}
+
+// isTinyDimension 尺寸是否不超过 1 像素（未设置时返回 false）
+func isTinyDimension(value string) bool {
+	value = strings.TrimSuffix(strings.TrimSpace(strings.ToLower(value)), "px")
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return err == nil && n <= 1
+}
+
+// safeLinkURL 校验链接地址，只允许 http(s)、mailto、tel 和页内锚点
+func safeLinkURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "#") {
+		return raw, true
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+		return u.String(), true
+	case "mailto", "tel":
+		return u.String(), true
+	}
+	return "", false
+}
+
+// sanitizeStyle 过滤内联样式，移除可加载外部资源、执行代码或覆盖页面的声明
+func sanitizeStyle(style string) string {
+	var kept []string
+	for _, decl := range styleDeclarations(style) {
+		if blockedStyleProperties[decl[0]] || !stylePropertyPattern.MatchString(decl[0]) {
+			continue
+		}
+		blocked := false
+		for _, pattern := range blockedStyleValues {
+			if strings.Contains(decl[1], pattern) {
+				blocked = true
+				break
+			}
+		}
+		if !blocked {
+			kept = append(kept, decl[0]+": "+decl[1])
+		}
+	}
+	return strings.Join(kept, "; ")
+}
+
+// styleDeclarations 把内联样式拆分为 [属性, 值] 列表（均转为小写并去除空白）
+func styleDeclarations(style string) [][2]string {
+	var decls [][2]string
+	for _, part := range strings.Split(style, ";") {
+		property, value, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
+		}
+		property = strings.ToLower(strings.TrimSpace(property))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if property == "" || value == "" {
+			continue
+		}
+		decls = append(decls, [2]string{property, value})
+	}
+	return decls
+}
+
+// getAttribute 获取元素属性值
+func getAttribute(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// setAttribute 设置元素属性值
+func setAttribute(node *html.Node, key, value string) {
+	for i, attr := range node.Attr {
+		if attr.Key == key {
+			node.Attr[i].Val = value
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: key, Val: value})
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"fusionmail/internal/model"
+)
+
+// TestSanitizeHTML 测试允许列表过滤
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"script removed", `<p>hi<script>alert(1)</script></p>`, `<p>hi</p>`},
+		{"style sheet removed", `<style>body{display:none}</style><div>x</div>`, `<div>x</div>`},
+		{"event handlers removed", `<div onclick="steal()" id="x" class="y" align="center">x</div>`, `<div align="center">x</div>`},
+		{"form unwrapped, controls removed", `<form action="https://evil"><p>name</p><input name="q"><button>go</button></form>`, `<p>name</p>`},
+		{"unknown tags unwrapped", `<html><body><o:p>a</o:p><custom><b>b</b></custom></body></html>`, `a<b>b</b>`},
+		{"comments removed", `<!--[if mso]><p>mso</p><![endif]--><p>x</p>`, `<p>x</p>`},
+		{"javascript link removed", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"relative link removed", `<a href="/api/v1/emails">x</a>`, `<a>x</a>`},
+		{"safe link", `<a href="https://example.com/a?b=1">x</a>`, `<a href="https://example.com/a?b=1" target="_blank" rel="noopener noreferrer nofollow">x</a>`},
+		{"mailto link", `<a href="mailto:a@example.com">x</a>`, `<a href="mailto:a@example.com" target="_blank" rel="noopener noreferrer nofollow">x</a>`},
+		{"unclosed tags balanced", `<div><b>x`, `<div><b>x</b></div>`},
+		{"svg removed", `<svg><script>alert(1)</script></svg>ok`, `ok`},
+		{"style filtered", `<p style="color: Red; background: url(https://t.example/p.gif); position: fixed; font-size: 12px">x</p>`, `<p style="color: red; font-size: 12px">x</p>`},
+		{"escaped css removed", `<p style="background-image: \75rl(x)">x</p>`, `<p>x</p>`},
+		{"data image kept", `<img src="data:image/png;base64,iVBORw0KGgo=">`, `<img src="data:image/png;base64,iVBORw0KGgo="/>`},
+		{"svg data image removed", `<img src="data:image/svg+xml;base64,PHN2Zz4=">`, ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &htmlSanitizer{}
+			got, err := s.sanitize(tt.input)
+			if err != nil {
+				t.Fatalf("sanitize failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("sanitize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSanitizeImages 测试 cid: 替换、外部图片阻止和代理以及追踪像素移除
+func TestSanitizeImages(t *testing.T) {
+	input := `<img src="cid:logo@example.com" alt="logo">` +
+		`<img src="cid:%3Cmissing%3E">` +
+		`<img src="https://cdn.example.com/banner.png" width="600" alt="banner">` +
+		`<img src="https://cdn.example.com/p.gif" width="1" height="1">` +
+		`<img src="https://cdn.example.com/h.png" style="display: none">` +
+		`<img src="https://mailtrack.io/trace/mail/abc.png">` +
+		`<img src="https://u123.ct.sendgrid.net/wf/open?upn=xyz">` +
+		`<img src="ftp://cdn.example.com/x.png">`
+
+	resolveCID := func(contentID string) string {
+		if contentID == "logo@example.com" {
+			return "https://mail.example.com/api/v1/files/attachments/7?signature=s"
+		}
+		return ""
+	}
+
+	// 默认阻止外部图片，保留图片元素
+	s := &htmlSanitizer{resolveCID: resolveCID}
+	got, err := s.sanitize(input)
+	if err != nil {
+		t.Fatalf("sanitize failed: %v", err)
+	}
+	want := `<img alt="logo" src="https://mail.example.com/api/v1/files/attachments/7?signature=s"/>` +
+		`<img width="600" alt="banner"/>`
+	if got != want {
+		t.Errorf("sanitize = %q, want %q", got, want)
+	}
+	if s.blockedImages != 1 || s.removedTrackers != 4 {
+		t.Errorf("blocked = %d, trackers = %d", s.blockedImages, s.removedTrackers)
+	}
+
+	// 选择显示图片时改为代理地址，追踪像素仍然移除
+	s = &htmlSanitizer{proxyImage: func(rawURL string) string { return "/proxy?url=" + rawURL }}
+	got, err = s.sanitize(input)
+	if err != nil {
+		t.Fatalf("sanitize failed: %v", err)
+	}
+	want = `<img width="600" alt="banner" src="/proxy?url=https://cdn.example.com/banner.png"/>`
+	if got != want {
+		t.Errorf("sanitize with proxy = %q, want %q", got, want)
+	}
+	if s.blockedImages != 0 || s.removedTrackers != 4 {
+		t.Errorf("blocked = %d, trackers = %d", s.blockedImages, s.removedTrackers)
+	}
+}
+
+// fakeImageProxy 返回固定前缀的代理地址
+type fakeImageProxy struct{}
+
+func (fakeImageProxy) ProxyURL(rawURL string) (string, error) {
+	return "/api/v1/proxy?url=" + rawURL, nil
+}
+
+// TestEmailRender 测试渲染服务的纯文本转换和外部图片选项
+func TestEmailRender(t *testing.T) {
+	repo := &fakeEmailRepo{emails: map[int64]*model.Email{
+		1: {ID: 1, TextBody: "a < b\nsecond line"},
+		2: {ID: 2, HTMLBody: `<p>hi</p><img src="https://cdn.example.com/a.png">`},
+	}}
+	ctx := context.Background()
+
+	s := NewEmailRenderService(repo, nil, nil, fakeImageProxy{})
+	rendered, err := s.Render(ctx, 1, RenderOptions{})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !rendered.PlainText || rendered.HTML != `<div style="white-space: pre-wrap">a &lt; b`+"\n"+`second line</div>` {
+		t.Errorf("plain text render = %+v", rendered)
+	}
+
+	rendered, err = s.Render(ctx, 2, RenderOptions{})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.RemoteImagesBlocked != 1 || strings.Contains(rendered.HTML, "cdn.example.com") {
+		t.Errorf("default render = %+v", rendered)
+	}
+
+	rendered, err = s.Render(ctx, 2, RenderOptions{RemoteImages: true})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.RemoteImagesBlocked != 0 || !strings.Contains(rendered.HTML, `src="/api/v1/proxy?url=https://cdn.example.com/a.png"`) {
+		t.Errorf("render with remote images = %+v", rendered)
+	}
+
+	// 未配置代理时即使选择显示也阻止
+	rendered, err = NewEmailRenderService(repo, nil, nil, nil).Render(ctx, 2, RenderOptions{RemoteImages: true})
+	if err != nil || rendered.RemoteImagesBlocked != 1 {
+		t.Errorf("render without proxy = %+v, %v", rendered, err)
+	}
+
+	if _, err := s.Render(ctx, 3, RenderOptions{}); !errors.Is(err, ErrEmailNotFound) {
+		t.Errorf("render missing email = %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"fusionmail/pkg/storage"
+)
+
+// 图片代理默认限制
+const (
+	defaultImageProxyMaxSize = 5 << 20
+	defaultImageProxyTimeout = 10 * time.Second
+	maxImageProxyRedirects   = 5
+)
+
+// imageCacheMagic 缓存文件头部标识，后跟获取时间和内容类型各一行
+const imageCacheMagic = "FMIMG1"
+
+// imageProxyUserAgent 获取外部图片时使用的 User-Agent（不透露用户浏览器信息）
+const imageProxyUserAgent = "FusionMail-ImageProxy/1.0"
+
+// 图片代理错误
+var (
+	ErrImageProxyInvalid      = errors.New("image proxy link is invalid")
+	ErrRemoteImageUnavailable = errors.New("remote image is unavailable")
+)
+
+// errPrivateAddress 外部图片地址解析到内网地址
+var errPrivateAddress = errors.New("address is not publicly routable")
+
+// proxiedImageTypes 允许代理的图片类型（按内容嗅探结果判断，不含可携带脚本的 SVG）
+var proxiedImageTypes = map[string]bool{
+	"image/png":    true,
+	"image/jpeg":   true,
+	"image/gif":    true,
+	"image/webp":   true,
+	"image/bmp":    true,
+	"image/x-icon": true,
+}
+
+// reservedNetworks 不允许代理访问的保留网段（除 net.IP 自带判断的私有、回环、链路本地地址外）
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+// ImageProxyOptions 图片代理配置
+type ImageProxyOptions struct {
+	Secret   string        // HMAC 签名密钥，只有签名过的地址才会被代理，防止被当作开放代理
+	BaseURL  string        // 服务对外地址，为空时返回以 /api/v1 开头的相对地址
+	MaxSize  int64         // 单张图片大小上限（字节）
+	Timeout  time.Duration // 获取外部图片的超时时间
+	CacheTTL time.Duration // 缓存时间，0 表示不缓存
+}
+
+// ProxiedImage 代理获取的图片
+type ProxiedImage struct {
+	Data        []byte
+	ContentType string
+	FetchedAt   time.Time
+}
+
+// ImageProxyService 外部图片代理服务
+// 由服务器获取邮件中的外部图片，不向发件人暴露用户 IP 和阅读时间；
+// 请求不带 Cookie 和 Referer，只接受大小限制内的位图，结果缓存在存储提供者中
+type ImageProxyService struct {
+	storageProvider storage.Provider
+	client          *http.Client
+	secret          []byte
+	options         ImageProxyOptions
+	now             func() time.Time
+}
+
+// NewImageProxyService 创建外部图片代理服务
+// storageProvider 可以为 nil，此时不缓存图片
+func NewImageProxyService(storageProvider storage.Provider, options ImageProxyOptions) (*ImageProxyService, error) {
+	if options.Secret == "" {
+		return nil, fmt.Errorf("image proxy secret is required")
+	}
+	if options.MaxSize <= 0 {
+		options.MaxSize = defaultImageProxyMaxSize
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultImageProxyTimeout
+	}
+	options.BaseURL = strings.TrimSuffix(options.BaseURL, "/")
+
+	return &ImageProxyService{
+		storageProvider: storageProvider,
+		client:          newImageProxyClient(options.Timeout),
+		secret:          []byte(options.Secret),
+		options:         options,
+		now:             time.Now,
+	}, nil
+}
+
+// newImageProxyClient 创建获取外部图片的 HTTP 客户端
+// 连接前检查解析后的 IP（防止通过域名或重定向访问内网服务），不使用环境变量中的代理，不保存 Cookie
+func newImageProxyClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          20,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxImageProxyRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxImageProxyRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("unsupported redirect scheme %q", req.URL.Scheme)
+			}
+			// 重定向时 HTTP 客户端会自动带上来源地址
+			req.Header.Del("Referer")
+			return nil
+		},
+	}
+}
+
+// isPublicIP 判断 IP 是否为公网地址
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// mustParseCIDR 解析网段常量
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// ProxyURL 生成外部图片的代理地址（实现 ImageProxy）
+// 地址只绑定图片 URL、不含有效期，浏览器可以长期缓存
+func (s *ImageProxyService) ProxyURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("unsupported image url: %s", rawURL)
+	}
+	query := url.Values{}
+	query.Set("url", rawURL)
+	query.Set("signature", s.signature(rawURL))
+	return fmt.Sprintf("%s/api/v1/files/images?%s", s.options.BaseURL, query.Encode()), nil
+}
+
+// Fetch 校验代理地址签名并获取图片（优先使用缓存）
+func (s *ImageProxyService) Fetch(ctx context.Context, rawURL, signature string) (*ProxiedImage, error) {
+	if signature == "" || !hmac.Equal([]byte(signature), []byte(s.signature(rawURL))) {
+		return nil, ErrImageProxyInvalid
+	}
+
+	cachePath := imageCachePath(rawURL)
+	if image := s.loadCache(ctx, cachePath); image != nil {
+		return image, nil
+	}
+
+	image, err := s.fetchRemote(ctx, rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRemoteImageUnavailable, err)
+	}
+	s.saveCache(ctx, cachePath, image)
+	return image, nil
+}
+
+// signature 计算代理地址签名
+func (s *ImageProxyService) signature(rawURL string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "image-proxy\n%s", rawURL)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// fetchRemote 从外部获取图片并校验大小和类型
+func (s *ImageProxyService) fetchRemote(ctx context.Context, rawURL string) (*ProxiedImage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", imageProxyUserAgent)
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > s.options.MaxSize {
+		return nil, fmt.Errorf("image exceeds %d bytes", s.options.MaxSize)
+	}
+	if declared := resp.Header.Get("Content-Type"); declared != "" {
+		mediaType, _, _ := mime.ParseMediaType(declared)
+		if mediaType != "application/octet-stream" && (!strings.HasPrefix(mediaType, "image/") || mediaType == "image/svg+xml") {
+			return nil, fmt.Errorf("unsupported content type %q", declared)
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, s.options.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.options.MaxSize {
+		return nil, fmt.Errorf("image exceeds %d bytes", s.options.MaxSize)
+	}
+
+	// 以内容嗅探结果为准，声明为图片但实际是 HTML 等内容的响应被拒绝
+	contentType := http.DetectContentType(data)
+	if !proxiedImageTypes[contentType] {
+		return nil, fmt.Errorf("unsupported image content %q", contentType)
+	}
+	return &ProxiedImage{Data: data, ContentType: contentType, FetchedAt: s.now()}, nil
+}
+
+// imageCachePath 图片缓存路径：image-cache/{URL 哈希前 2 位}/{URL 哈希}
+func imageCachePath(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	hash := hex.EncodeToString(sum[:])
+	return path.Join("image-cache", hash[:2], hash)
+}
+
+// loadCache 读取未过期的缓存，缓存不存在、已过期或读取失败时返回 nil
+func (s *ImageProxyService) loadCache(ctx context.Context, cachePath string) *ProxiedImage {
+	if s.storageProvider == nil || s.options.CacheTTL <= 0 {
+		return nil
+	}
+	if exists, err := s.storageProvider.Exists(ctx, cachePath); err != nil || !exists {
+		return nil
+	}
+	file, err := s.storageProvider.Download(ctx, cachePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var lines [3]string
+	for i := range lines {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil
+		}
+		lines[i] = strings.TrimSuffix(line, "\n")
+	}
+	fetched, err := strconv.ParseInt(lines[1], 10, 64)
+	if lines[0] != imageCacheMagic || err != nil || !proxiedImageTypes[lines[2]] {
+		return nil
+	}
+	fetchedAt := time.Unix(fetched, 0)
+	if s.now().Sub(fetchedAt) >= s.options.CacheTTL {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, s.options.MaxSize+1))
+	if err != nil || int64(len(data)) > s.options.MaxSize {
+		return nil
+	}
+	return &ProxiedImage{Data: data, ContentType: lines[2], FetchedAt: fetchedAt}
+}
+
+// saveCache 保存图片缓存（过期的缓存直接覆盖），失败只记录日志
+func (s *ImageProxyService) saveCache(ctx context.Context, cachePath string, image *ProxiedImage) {
+	if s.storageProvider == nil || s.options.CacheTTL <= 0 {
+		return
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n%d\n%s\n", imageCacheMagic, image.FetchedAt.Unix(), image.ContentType)
+	buf.Write(image.Data)
+	if _, err := s.storageProvider.Upload(ctx, cachePath, &buf, "application/octet-stream"); err != nil {
+		log.Printf("Failed to cache proxied image %s: %v", cachePath, err)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fusionmail/pkg/storage"
+)
+
+// pngHeader 最小的 PNG 文件头（内容嗅探识别为 image/png）
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// newTestImageProxy 创建允许访问本机测试服务器的图片代理
+func newTestImageProxy(t *testing.T, cacheTTL time.Duration) *ImageProxyService {
+	t.Helper()
+	provider, err := storage.NewLocalProvider(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("NewLocalProvider failed: %v", err)
+	}
+	s, err := NewImageProxyService(provider, ImageProxyOptions{Secret: "secret", MaxSize: 1024, CacheTTL: cacheTTL})
+	if err != nil {
+		t.Fatalf("NewImageProxyService failed: %v", err)
+	}
+	// 保留重定向处理，去掉内网地址检查
+	s.client.Transport = &http.Transport{}
+	return s
+}
+
+// proxyQuery 解析代理地址中的参数
+func proxyQuery(t *testing.T, s *ImageProxyService, rawURL string) url.Values {
+	t.Helper()
+	proxied, err := s.ProxyURL(rawURL)
+	if err != nil {
+		t.Fatalf("ProxyURL failed: %v", err)
+	}
+	u, err := url.Parse(proxied)
+	if err != nil || u.Path != "/api/v1/files/images" {
+		t.Fatalf("proxy url = %s", proxied)
+	}
+	return u.Query()
+}
+
+// TestImageProxyFetch 测试签名校验、请求头清理、内容校验和缓存
+func TestImageProxyFetch(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	var referers []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		referers = append(referers, r.Header.Get("Referer")+r.Header.Get("Cookie"))
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/logo.png", http.StatusFound)
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pngHeader)
+		case "/fake.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("<html><script>alert(1)</script></html>"))
+		case "/large.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(append(pngHeader, make([]byte, 2048)...))
+		case "/image.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte("<svg></svg>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s := newTestImageProxy(t, time.Hour)
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	query := proxyQuery(t, s, server.URL+"/redirect")
+	if _, err := s.Fetch(ctx, server.URL+"/logo.png", query.Get("signature")); !errors.Is(err, ErrImageProxyInvalid) {
+		t.Errorf("fetch with other url's signature = %v", err)
+	}
+
+	image, err := s.Fetch(ctx, query.Get("url"), query.Get("signature"))
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if image.ContentType != "image/png" || string(image.Data) != string(pngHeader) {
+		t.Errorf("image = %q %q", image.ContentType, image.Data)
+	}
+	for _, leaked := range referers {
+		if leaked != "" {
+			t.Errorf("request leaked referer or cookie: %q", leaked)
+		}
+	}
+
+	// 缓存有效期内不再请求外部服务器，过期后重新获取
+	if _, err := s.Fetch(ctx, query.Get("url"), query.Get("signature")); err != nil || requests != 2 {
+		t.Errorf("cached fetch: requests = %d, err = %v", requests, err)
+	}
+	now = now.Add(2 * time.Hour)
+	if _, err := s.Fetch(ctx, query.Get("url"), query.Get("signature")); err != nil || requests != 4 {
+		t.Errorf("expired fetch: requests = %d, err = %v", requests, err)
+	}
+
+	for _, name := range []string{"/fake.png", "/large.png", "/image.svg", "/missing.png"} {
+		query := proxyQuery(t, s, server.URL+name)
+		if _, err := s.Fetch(ctx, query.Get("url"), query.Get("signature")); !errors.Is(err, ErrRemoteImageUnavailable) {
+			t.Errorf("fetch %s = %v", name, err)
+		}
+	}
+}
+
+// TestImageProxyBlocksPrivateAddresses 测试默认客户端拒绝访问内网地址
+func TestImageProxyBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngHeader)
+	}))
+	defer server.Close()
+
+	s, err := NewImageProxyService(nil, ImageProxyOptions{Secret: "secret"})
+	if err != nil {
+		t.Fatalf("NewImageProxyService failed: %v", err)
+	}
+	query := proxyQuery(t, s, server.URL+"/logo.png")
+	_, err = s.Fetch(context.Background(), query.Get("url"), query.Get("signature"))
+	if !errors.Is(err, ErrRemoteImageUnavailable) || !strings.Contains(err.Error(), errPrivateAddress.Error()) {
+		t.Errorf("fetch loopback = %v", err)
+	}
+
+	if _, err := s.ProxyURL("javascript:alert(1)"); err == nil {
+		t.Error("ProxyURL accepted non-http url")
+	}
+
+	tests := map[string]bool{
+		"93.184.216.34":   true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"2606:4700::1":    true,
+	}
+	for ip, want := range tests {
+		if got := isPublicIP(net.ParseIP(ip)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
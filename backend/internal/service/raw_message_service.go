@@ -0,0 +1,344 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/pkg/storage"
+
+	"github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/textproto"
+)
+
+// rawMessageBatchSize 重新解析和加密迁移每批处理的邮件数
+const rawMessageBatchSize = 200
+
+// ErrEmailNotFound 邮件不存在
+var ErrEmailNotFound = errors.New("email not found")
+
+// ErrRawMessageNotArchived 邮件没有归档原文（适配器无法取得原文或归档上线前入库）
+var ErrRawMessageNotArchived = errors.New("raw message is not archived")
+
+// RawHeader 原始邮件的一个头部字段
+type RawHeader struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`             // 原始值（已展开折行）
+	Decoded string `json:"decoded,omitempty"` // 解码 RFC 2047 编码字后的值，与原始值相同时省略
+}
+
+// ReparseResult 批量重新解析结果
+type ReparseResult struct {
+	Reparsed int `json:"reparsed"`
+	Failed   int `json:"failed"`
+}
+
+// RawMessageService 原始邮件归档服务
+// 同步时把 RFC 822 原文 gzip 压缩后保存到存储提供者，用于查看完整头部、下载原文和解析器修复后重新解析
+type RawMessageService struct {
+	emailRepo       repository.EmailRepository
+	storageProvider storage.Provider
+}
+
+// NewRawMessageService 创建原始邮件归档服务
+func NewRawMessageService(emailRepo repository.EmailRepository, storageProvider storage.Provider) *RawMessageService {
+	return &RawMessageService{
+		emailRepo:       emailRepo,
+		storageProvider: storageProvider,
+	}
+}
+
+// rawStoragePath 原文的存储路径：raw/{账户 UID}/{ID 哈希前 2 位}/{ID 哈希}.eml.gz
+// 按 (账户, 服务商 ID) 确定路径，同一封邮件重复归档时覆盖同一个文件；服务商 ID 可能含 / 等字符，取哈希
+func rawStoragePath(accountUID, providerID string) string {
+	sum := sha256.Sum256([]byte(providerID))
+	hash := hex.EncodeToString(sum[:])
+	return path.Join("raw", accountUID, hash[:2], hash+".eml.gz")
+}
+
+// Archive 压缩并保存邮件原文，记录归档路径（email 需已入库）
+func (s *RawMessageService) Archive(ctx context.Context, email *model.Email, raw []byte) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(raw); err != nil {
+		return fmt.Errorf("failed to compress raw message: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress raw message: %w", err)
+	}
+
+	rawPath := rawStoragePath(email.AccountUID, email.ProviderID)
+	if _, err := s.storageProvider.Upload(storage.WithKeyOwners(ctx, email.AccountUID), rawPath, &buf, "application/gzip"); err != nil {
+		return fmt.Errorf("failed to upload raw message: %w", err)
+	}
+	if err := s.emailRepo.SetRawPath(ctx, email.ID, rawPath); err != nil {
+		return fmt.Errorf("failed to save raw message path: %w", err)
+	}
+	email.RawPath = rawPath
+	return nil
+}
+
+// Delete 删除邮件的原文文件（邮件永久删除时调用，没有归档原文时什么都不做）
+func (s *RawMessageService) Delete(ctx context.Context, email *model.Email) error {
+	if email.RawPath == "" {
+		return nil
+	}
+	if err := s.storageProvider.Delete(ctx, email.RawPath); err != nil {
+		return fmt.Errorf("failed to delete raw message: %w", err)
+	}
+	return nil
+}
+
+// Open 打开邮件的原文（解压后的 RFC 822 内容），调用方负责关闭
+func (s *RawMessageService) Open(ctx context.Context, id int64) (io.ReadCloser, *model.Email, error) {
+	email, err := s.emailRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get email: %w", err)
+	}
+	if email == nil {
+		return nil, nil, ErrEmailNotFound
+	}
+	reader, err := s.open(ctx, email)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, email, nil
+}
+
+// open 下载并解压邮件原文
+func (s *RawMessageService) open(ctx context.Context, email *model.Email) (io.ReadCloser, error) {
+	if email.RawPath == "" {
+		return nil, ErrRawMessageNotArchived
+	}
+	file, err := s.storageProvider.Download(ctx, email.RawPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download raw message: %w", err)
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to decompress raw message: %w", err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, file}, nil
+}
+
+// Headers 获取邮件原文的全部头部字段（按原文顺序，包括 Received 等重复字段）
+func (s *RawMessageService) Headers(ctx context.Context, id int64) ([]RawHeader, error) {
+	reader, _, err := s.Open(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	header, err := textproto.ReadHeader(bufio.NewReader(reader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse raw message headers: %w", err)
+	}
+
+	decoder := mime.WordDecoder{CharsetReader: charset.Reader}
+	headers := make([]RawHeader, 0, header.Len())
+	fields := header.Fields()
+	for fields.Next() {
+		// 保留原文中字段名的大小写（Key 返回规范化后的名称）
+		h := RawHeader{Name: fields.Key(), Value: fields.Value()}
+		if line, err := fields.Raw(); err == nil {
+			if i := bytes.IndexByte(line, ':'); i > 0 {
+				h.Name = strings.TrimSpace(string(line[:i]))
+			}
+		}
+		if decoded, err := decoder.DecodeHeader(h.Value); err == nil && decoded != h.Value {
+			h.Decoded = decoded
+		}
+		headers = append(headers, h)
+	}
+	return headers, nil
+}
+
+// Reparse 用当前的解析器从原文重新生成邮件的解析字段（主题、地址、正文、摘要等）
+// 本地状态、源邮箱状态和已保存的附件不变
+func (s *RawMessageService) Reparse(ctx context.Context, email *model.Email) error {
+	reader, err := s.open(ctx, email)
+	if err != nil {
+		return err
+	}
+	raw, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read raw message: %w", err)
+	}
+
+	parsed, err := adapter.ParseRawMessage(raw)
+	if err != nil {
+		return err
+	}
+
+	email.MessageID = parsed.MessageID
+	if parsed.Subject != "" {
+		email.Subject = parsed.Subject
+	}
+	if parsed.FromAddress != "" {
+		email.FromAddress = parsed.FromAddress
+		email.FromName = parsed.FromName
+	}
+	email.ToAddresses = joinAddresses(parsed.ToAddresses)
+	email.CcAddresses = joinAddresses(parsed.CcAddresses)
+	email.BccAddresses = joinAddresses(parsed.BccAddresses)
+	email.ReplyTo = parsed.ReplyTo
+	email.TextBody = parsed.TextBody
+	email.HTMLBody = parsed.HTMLBody
+	email.Snippet = parsed.Snippet
+	email.HasAttachments = parsed.HasAttachments
+	email.AttachmentsCount = parsed.AttachmentsCount
+	if !parsed.SentAt.IsZero() {
+		email.SentAt = parsed.SentAt
+	}
+	email.InReplyTo = parsed.InReplyTo
+	email.References = parsed.References
+
+	if err := s.emailRepo.UpdateParsed(ctx, email); err != nil {
+		return fmt.Errorf("failed to update email %d: %w", email.ID, err)
+	}
+	return nil
+}
+
+// ReparseAll 重新解析已归档原文的邮件（accountUID 为空时处理全部账户）
+// 单封邮件失败不影响其他邮件，通过 onError 报告
+func (s *RawMessageService) ReparseAll(ctx context.Context, accountUID string, onError func(email *model.Email, err error)) (*ReparseResult, error) {
+	result := &ReparseResult{}
+	var afterID int64
+	for {
+		emails, err := s.emailRepo.ListArchived(ctx, accountUID, afterID, rawMessageBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list archived emails: %w", err)
+		}
+		for _, email := range emails {
+			if err := s.Reparse(ctx, email); err != nil {
+				result.Failed++
+				if onError != nil {
+					onError(email, err)
+				}
+				continue
+			}
+			result.Reparsed++
+		}
+		if len(emails) < rawMessageBatchSize {
+			return result, nil
+		}
+		afterID = emails[len(emails)-1].ID
+	}
+}
+
+// EncryptStoredFiles 将已归档的明文原文按账户加密后写回原路径（静态加密迁移），返回改写的文件数
+// 按存储数据密钥加密的旧文件改为按账户包装，已按账户包装的文件跳过
+func (s *RawMessageService) EncryptStoredFiles(ctx context.Context, provider *storage.EncryptedProvider) (int, error) {
+	encrypted := 0
+	var afterID int64
+	for {
+		emails, err := s.emailRepo.ListArchived(ctx, "", afterID, rawMessageBatchSize)
+		if err != nil {
+			return encrypted, fmt.Errorf("failed to list archived emails: %w", err)
+		}
+		for _, email := range emails {
+			done, err := provider.EncryptInPlace(storage.WithKeyOwners(ctx, email.AccountUID), email.RawPath)
+			if err != nil {
+				return encrypted, fmt.Errorf("failed to encrypt %s: %w", email.RawPath, err)
+			}
+			if done {
+				encrypted++
+			}
+		}
+		if len(emails) < rawMessageBatchSize {
+			return encrypted, nil
+		}
+		afterID = emails[len(emails)-1].ID
+	}
+}
+
+// MigrateStoredFiles 把已归档的原文原样复制到目标存储（路径不变）
+// 目标存储中已有相同内容的文件跳过，中断后重新执行即可继续；单个文件失败通过 onError 报告
+func (s *RawMessageService) MigrateStoredFiles(ctx context.Context, target storage.Provider, onError func(rawPath string, err error)) (*StorageMigrationResult, error) {
+	result := &StorageMigrationResult{}
+	var afterID int64
+	for {
+		emails, err := s.emailRepo.ListArchived(ctx, "", afterID, rawMessageBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list archived emails: %w", err)
+		}
+		for _, email := range emails {
+			copied, size, err := copyStoredFile(ctx, s.storageProvider, target, email.RawPath, "application/gzip")
+			if err != nil {
+				result.Failed++
+				if onError != nil {
+					onError(email.RawPath, err)
+				}
+				continue
+			}
+			result.add(copied, size)
+		}
+		if len(emails) < rawMessageBatchSize {
+			return result, nil
+		}
+		afterID = emails[len(emails)-1].ID
+	}
+}
+
+// VerifyStoredFiles 检查已归档的原文是否存在且能完整解压（gzip 校验和可以发现损坏），问题通过 report 逐条报告
+func (s *RawMessageService) VerifyStoredFiles(ctx context.Context, report func(issue StorageIssue)) (*StorageVerifyResult, error) {
+	result := &StorageVerifyResult{}
+	var afterID int64
+	for {
+		emails, err := s.emailRepo.ListArchived(ctx, "", afterID, rawMessageBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list archived emails: %w", err)
+		}
+		for _, email := range emails {
+			result.Checked++
+			if problem, err := s.verifyStoredFile(ctx, email); problem != "" {
+				result.Issues++
+				if report != nil {
+					report(StorageIssue{Kind: "raw_message", ID: strconv.FormatInt(email.ID, 10), Path: email.RawPath, Problem: problem, Err: err})
+				}
+			}
+		}
+		if len(emails) < rawMessageBatchSize {
+			return result, nil
+		}
+		afterID = emails[len(emails)-1].ID
+	}
+}
+
+// verifyStoredFile 检查一封邮件的原文，返回问题类型（没有问题时为空）
+func (s *RawMessageService) verifyStoredFile(ctx context.Context, email *model.Email) (string, error) {
+	exists, err := s.storageProvider.Exists(ctx, email.RawPath)
+	if err != nil {
+		return StorageIssueUnreadable, err
+	}
+	if !exists {
+		return StorageIssueMissing, nil
+	}
+	reader, err := s.open(ctx, email)
+	if err != nil {
+		return StorageIssueCorrupted, err
+	}
+	defer reader.Close()
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return StorageIssueCorrupted, err
+	}
+	return "", nil
+}
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+)
+
+const (
+	// retentionBatchSize 保留策略清理每批处理的邮件或附件数
+	retentionBatchSize = 200
+
+	// retentionStartDelay 服务启动后第一次定期清理前的等待时间（避开启动时的首次同步）
+	retentionStartDelay = 5 * time.Minute
+
+	// retentionLockKey 定期清理的分布式锁，多实例部署时同一时刻只有一个实例执行
+	retentionLockKey = "retention"
+)
+
+// RetentionPolicy 邮件保留策略（天数为 0 表示不清理）
+type RetentionPolicy struct {
+	TrashDays      int `json:"trash_days"`      // 回收站邮件保留天数，超过后永久删除（包括附件和原文）
+	ArchiveDays    int `json:"archive_days"`    // 归档邮件保留天数（按发送时间），超过后移入回收站；星标邮件不受影响
+	AttachmentDays int `json:"attachment_days"` // 大附件内容保留天数（按邮件发送时间），超过后只保留元数据；星标邮件不受影响
+	AttachmentMB   int `json:"attachment_mb"`   // 大附件阈值（MB），为 0 时不清理附件
+}
+
+// forAccount 合并账户的单独设置：账户值大于 0 时覆盖全局值，小于 0 时该账户不清理
+func (p RetentionPolicy) forAccount(account *model.Account) RetentionPolicy {
+	return RetentionPolicy{
+		TrashDays:      retentionValue(account.TrashRetentionDays, p.TrashDays),
+		ArchiveDays:    retentionValue(account.ArchiveRetentionDays, p.ArchiveDays),
+		AttachmentDays: retentionValue(account.AttachmentRetentionDays, p.AttachmentDays),
+		AttachmentMB:   retentionValue(account.AttachmentRetentionMB, p.AttachmentMB),
+	}
+}
+
+// retentionValue 按账户设置覆盖全局值
+func retentionValue(override, global int) int {
+	switch {
+	case override > 0:
+		return override
+	case override < 0:
+		return 0
+	default:
+		return global
+	}
+}
+
+// RetentionOptions 保留策略清理配置
+type RetentionOptions struct {
+	Policy         RetentionPolicy // 全局保留策略，账户可单独覆盖
+	SyncLogDays    int             // 同步日志保留天数，0 表示不清理
+	WebhookLogDays int             // Webhook 日志保留天数，0 表示不清理
+
+	Interval time.Duration // 定期清理间隔，<= 0 时不定期清理（只能手动执行）
+	DryRun   bool          // 定期清理只生成报告，不删除任何数据
+	Locker   SyncLocker    // 分布式锁，为空时只在本实例内执行（单实例部署）
+}
+
+// AccountRetentionReport 单个账户的清理结果（试运行时为将要清理的数量）
+type AccountRetentionReport struct {
+	AccountUID string          `json:"account_uid"`
+	Email      string          `json:"email"`
+	Policy     RetentionPolicy `json:"policy"` // 合并账户设置后实际使用的策略
+
+	TrashPurged         int   `json:"trash_purged"`         // 永久删除的回收站邮件数
+	ArchiveTrashed      int   `json:"archive_trashed"`      // 移入回收站的过期归档邮件数
+	AttachmentsReleased int   `json:"attachments_released"` // 释放内容的大附件数
+	AttachmentBytes     int64 `json:"attachment_bytes"`     // 释放内容的大附件大小合计（相同内容被其他附件引用时不会实际回收）
+	Failed              int   `json:"failed"`               // 处理失败的邮件或附件数，下次清理时重试
+}
+
+// RetentionReport 一次保留策略清理的结果
+type RetentionReport struct {
+	DryRun     bool      `json:"dry_run"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+
+	Accounts           []*AccountRetentionReport `json:"accounts"`
+	SyncLogsDeleted    int64                     `json:"sync_logs_deleted"`
+	WebhookLogsDeleted int64                     `json:"webhook_logs_deleted"`
+}
+
+// Failed 处理失败的邮件和附件总数
+func (r *RetentionReport) Failed() int {
+	failed := 0
+	for _, account := range r.Accounts {
+		failed += account.Failed
+	}
+	return failed
+}
+
+// RetentionService 保留策略清理服务（定期清理任务）
+// 依次把过期的归档邮件移入回收站、永久删除过期的回收站邮件、释放旧邮件的大附件内容，并删除过期的同步日志和 Webhook 日志
+type RetentionService struct {
+	accountRepo    repository.AccountRepository
+	emailRepo      repository.EmailRepository
+	attachments    *AttachmentService
+	purger         *EmailPurger
+	threads        ThreadService
+	syncLogRepo    repository.SyncLogRepository
+	webhookLogRepo repository.WebhookLogRepository
+	options        RetentionOptions
+
+	now  func() time.Time
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRetentionService 创建保留策略清理服务
+// threads 可以为 nil，此时归档邮件移入回收站后不刷新会话统计
+func NewRetentionService(
+	accountRepo repository.AccountRepository,
+	emailRepo repository.EmailRepository,
+	attachments *AttachmentService,
+	purger *EmailPurger,
+	threads ThreadService,
+	syncLogRepo repository.SyncLogRepository,
+	webhookLogRepo repository.WebhookLogRepository,
+	options RetentionOptions,
+) *RetentionService {
+	return &RetentionService{
+		accountRepo:    accountRepo,
+		emailRepo:      emailRepo,
+		attachments:    attachments,
+		purger:         purger,
+		threads:        threads,
+		syncLogRepo:    syncLogRepo,
+		webhookLogRepo: webhookLogRepo,
+		options:        options,
+		now:            time.Now,
+	}
+}
+
+// Run 按保留策略执行一次清理；dryRun 为 true 时只统计将要清理的数量，不修改任何数据
+// 单封邮件或单个附件处理失败时记入报告并继续，查询失败时中止并返回已完成部分的报告
+func (s *RetentionService) Run(ctx context.Context, dryRun bool) (*RetentionReport, error) {
+	report := &RetentionReport{DryRun: dryRun, StartedAt: s.now()}
+
+	accounts, err := s.accountRepo.FindAll(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	for _, account := range accounts {
+		accountReport := &AccountRetentionReport{
+			AccountUID: account.UID,
+			Email:      account.Email,
+			Policy:     s.options.Policy.forAccount(account),
+		}
+		report.Accounts = append(report.Accounts, accountReport)
+		if err := s.applyPolicy(ctx, accountReport, dryRun); err != nil {
+			return report, fmt.Errorf("account %s: %w", account.UID, err)
+		}
+	}
+
+	if s.options.SyncLogDays > 0 {
+		before := s.now().AddDate(0, 0, -s.options.SyncLogDays)
+		if dryRun {
+			report.SyncLogsDeleted, err = s.syncLogRepo.CountOldLogs(ctx, before)
+		} else {
+			report.SyncLogsDeleted, err = s.syncLogRepo.DeleteOldLogs(ctx, before)
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to delete old sync logs: %w", err)
+		}
+	}
+	if s.options.WebhookLogDays > 0 {
+		before := s.now().AddDate(0, 0, -s.options.WebhookLogDays)
+		if dryRun {
+			report.WebhookLogsDeleted, err = s.webhookLogRepo.CountOldLogs(ctx, before)
+		} else {
+			report.WebhookLogsDeleted, err = s.webhookLogRepo.DeleteOldLogs(ctx, before)
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to delete old webhook logs: %w", err)
+		}
+	}
+
+	report.FinishedAt = s.now()
+	return report, nil
+}
+
+// applyPolicy 对一个账户执行保留策略
+// 先把过期归档邮件移入回收站（从现在开始计算回收站保留期，本次不会被永久删除），再清理回收站和大附件
+func (s *RetentionService) applyPolicy(ctx context.Context, report *AccountRetentionReport, dryRun bool) error {
+	policy := report.Policy
+	if policy.ArchiveDays > 0 {
+		if err := s.trashArchived(ctx, report, s.now().AddDate(0, 0, -policy.ArchiveDays), dryRun); err != nil {
+			return err
+		}
+	}
+	if policy.TrashDays > 0 {
+		if err := s.purgeTrash(ctx, report, s.now().AddDate(0, 0, -policy.TrashDays), dryRun); err != nil {
+			return err
+		}
+	}
+	if policy.AttachmentDays > 0 && policy.AttachmentMB > 0 {
+		if err := s.releaseAttachments(ctx, report, s.now().AddDate(0, 0, -policy.AttachmentDays), dryRun); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashArchived 把 before 之前发送的已归档邮件移入回收站
+func (s *RetentionService) trashArchived(ctx context.Context, report *AccountRetentionReport, before time.Time, dryRun bool) error {
+	var afterID int64
+	for {
+		ids, err := s.emailRepo.ListArchivedIDs(ctx, report.AccountUID, before, afterID, retentionBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list archived emails: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		afterID = ids[len(ids)-1]
+
+		if dryRun {
+			report.ArchiveTrashed += len(ids)
+			continue
+		}
+		if err := s.emailRepo.SetDeleted(ctx, ids, true); err != nil {
+			log.Printf("Failed to move archived emails of account %s to trash: %v", report.AccountUID, err)
+			report.Failed += len(ids)
+			continue
+		}
+		report.ArchiveTrashed += len(ids)
+		if s.threads != nil {
+			if err := s.threads.RefreshForEmails(ctx, ids); err != nil {
+				log.Printf("Failed to refresh threads after moving emails to trash: %v", err)
+			}
+		}
+	}
+}
+
+// purgeTrash 永久删除 before 之前移入回收站的邮件
+func (s *RetentionService) purgeTrash(ctx context.Context, report *AccountRetentionReport, before time.Time, dryRun bool) error {
+	var afterID int64
+	for {
+		emails, err := s.emailRepo.ListTrashed(ctx, report.AccountUID, before, afterID, retentionBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list trashed emails: %w", err)
+		}
+		if len(emails) == 0 {
+			return nil
+		}
+		afterID = emails[len(emails)-1].ID
+
+		if dryRun {
+			report.TrashPurged += len(emails)
+			continue
+		}
+		purged, err := s.purger.Purge(ctx, emails)
+		if err != nil {
+			log.Printf("Failed to purge trashed emails of account %s: %v", report.AccountUID, err)
+		}
+		report.TrashPurged += purged
+		report.Failed += len(emails) - purged
+	}
+}
+
+// releaseAttachments 释放 before 之前发送的邮件中超过阈值的附件内容
+func (s *RetentionService) releaseAttachments(ctx context.Context, report *AccountRetentionReport, before time.Time, dryRun bool) error {
+	minSize := int64(report.Policy.AttachmentMB) << 20
+	var afterID int64
+	for {
+		attachments, err := s.attachments.ListLargeAttachments(ctx, report.AccountUID, minSize, before, afterID, retentionBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list large attachments: %w", err)
+		}
+		if len(attachments) == 0 {
+			return nil
+		}
+		afterID = attachments[len(attachments)-1].ID
+
+		for _, attachment := range attachments {
+			if !dryRun {
+				if err := s.attachments.ReleaseContent(ctx, attachment); err != nil {
+					log.Printf("Failed to release content of attachment %d: %v", attachment.ID, err)
+					report.Failed++
+					continue
+				}
+			}
+			report.AttachmentsReleased++
+			report.AttachmentBytes += attachment.SizeBytes
+		}
+	}
+}
+
+// Start 启动定期清理（Interval <= 0 时不启动）
+func (s *RetentionService) Start(ctx context.Context) {
+	if s.options.Interval <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.loop(ctx, s.stop, s.done)
+}
+
+// Stop 停止定期清理，等待正在进行的清理结束
+func (s *RetentionService) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// loop 定期清理循环
+func (s *RetentionService) loop(ctx context.Context, stop, done chan struct{}) {
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	timer := time.NewTimer(retentionStartDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			s.runScheduled(ctx)
+			timer.Reset(s.options.Interval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runScheduled 在分布式锁保护下执行一次定期清理并记录结果
+func (s *RetentionService) runScheduled(ctx context.Context) {
+	if s.options.Locker != nil {
+		acquired, err := s.options.Locker.AcquireLock(ctx, retentionLockKey)
+		if err != nil {
+			log.Printf("Failed to acquire retention lock, continuing without it: %v", err)
+		} else if !acquired {
+			return
+		} else {
+			defer func() {
+				if err := s.options.Locker.ReleaseLock(context.Background(), retentionLockKey); err != nil {
+					log.Printf("Failed to release retention lock: %v", err)
+				}
+			}()
+
+			// 清理时间较长时定期续期，防止锁过期后被其他实例获取
+			stopExtend := make(chan struct{})
+			defer close(stopExtend)
+			go func() {
+				ticker := time.NewTicker(syncLockExtendInterval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						if err := s.options.Locker.ExtendLock(ctx, retentionLockKey); err != nil {
+							log.Printf("Failed to extend retention lock: %v", err)
+						}
+					case <-stopExtend:
+						return
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+	}
+
+	report, err := s.Run(ctx, s.options.DryRun)
+	if err != nil {
+		log.Printf("Retention run failed: %v", err)
+	}
+	logRetentionReport(report)
+}
+
+// logRetentionReport 记录清理结果（只记录有变化的账户）
+func logRetentionReport(report *RetentionReport) {
+	prefix := "Retention"
+	if report.DryRun {
+		prefix = "Retention (dry run)"
+	}
+	for _, account := range report.Accounts {
+		if account.TrashPurged+account.ArchiveTrashed+account.AttachmentsReleased+account.Failed == 0 {
+			continue
+		}
+		log.Printf("%s: account %s purged %d trashed emails, moved %d archived emails to trash, released %d attachments (%d bytes), %d failed",
+			prefix, account.AccountUID, account.TrashPurged, account.ArchiveTrashed, account.AttachmentsReleased, account.AttachmentBytes, account.Failed)
+	}
+	log.Printf("%s: deleted %d sync logs and %d webhook logs", prefix, report.SyncLogsDeleted, report.WebhookLogsDeleted)
+}
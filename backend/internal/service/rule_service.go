@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"fusionmail/internal/model"
 	"fusionmail/internal/repository"
@@ -331,7 +332,11 @@ func (s *ruleService) executeAction(ctx context.Context, action *model.RuleActio
 		email.IsArchived = true
 		return s.emailRepo.Update(ctx, email)
 	case "delete":
-		email.IsDeleted = true
+		if !email.IsDeleted {
+			now := time.Now()
+			email.IsDeleted = true
+			email.TrashedAt = &now
+		}
 		return s.emailRepo.Update(ctx, email)
 	case "move_folder":
 		email.Folder = action.Value
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/model"
+)
+
+// 源邮箱删除邮件的本地处理策略
+const (
+	SourceDeletePolicyKeep    = "keep"    // 只标记为源邮箱已删除，本地状态不变
+	SourceDeletePolicyArchive = "archive" // 标记并在本地归档
+	SourceDeletePolicyDelete  = "delete"  // 标记并在本地删除（软删除）
+)
+
+// ReconcileOptions 源邮箱对账配置
+type ReconcileOptions struct {
+	Interval time.Duration // 同一账户两次对账的最小间隔，0 表示不对账
+	Policy   string        // 源邮箱已删除邮件的本地处理策略（keep/archive/delete），默认 keep
+}
+
+// reconcilePlan 一次对账需要执行的变更
+type reconcilePlan struct {
+	deleted  []int64            // 新发现已在源邮箱删除的邮件
+	restored []int64            // 重新出现在源邮箱中的邮件
+	folders  map[string][]int64 // 源邮箱文件夹发生变化的邮件（按新文件夹分组）
+	moved    int                // 从一个已知文件夹移动到另一个文件夹的邮件数
+}
+
+// validSourceDeletePolicy 检查源邮箱删除策略是否有效
+func validSourceDeletePolicy(policy string) bool {
+	switch policy {
+	case SourceDeletePolicyKeep, SourceDeletePolicyArchive, SourceDeletePolicyDelete:
+		return true
+	}
+	return false
+}
+
+// planReconcile 比对服务器上的邮件清单和本地邮件，计算需要执行的变更
+// cutoff 之后才同步入库的邮件可能不在清单中（清单拉取早于入库），不会被标记为已删除
+func planReconcile(stored []*model.Email, folders []adapter.SourceFolder, cutoff time.Time) *reconcilePlan {
+	present := make(map[string]string)
+	for _, folder := range folders {
+		for _, id := range folder.ProviderIDs {
+			present[id] = folder.Name
+		}
+	}
+
+	plan := &reconcilePlan{folders: make(map[string][]int64)}
+	for _, email := range stored {
+		folder, ok := present[email.ProviderID]
+		if !ok {
+			if !email.SourceDeleted && email.SyncedAt.Before(cutoff) {
+				plan.deleted = append(plan.deleted, email.ID)
+			}
+			continue
+		}
+
+		if email.SourceDeleted {
+			plan.restored = append(plan.restored, email.ID)
+		}
+		if folder != email.SourceFolder {
+			plan.folders[folder] = append(plan.folders[folder], email.ID)
+			// 之前未记录文件夹的邮件只是补全，不算移动
+			if email.SourceFolder != "" {
+				plan.moved++
+			}
+		}
+	}
+
+	return plan
+}
+
+// reconcileDue 判断账户是否到了对账时间
+func (s *syncService) reconcileDue(account *model.Account, now time.Time) bool {
+	if s.reconcileOptions.Interval <= 0 {
+		return false
+	}
+	return account.LastReconciledAt == nil || now.Sub(*account.LastReconciledAt) >= s.reconcileOptions.Interval
+}
+
+// reconcileSource 比对源邮箱中现存的邮件，标记已删除的邮件并更新移动过的邮件所在文件夹
+func (s *syncService) reconcileSource(ctx context.Context, account *model.Account, lister adapter.SourceLister, syncLog *model.SyncLog) error {
+	startedAt := time.Now()
+
+	folders, err := lister.ListSourceFolders(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list source folders: %w", err)
+	}
+
+	stored, err := s.emailRepo.ListSourceStates(ctx, account.UID)
+	if err != nil {
+		return fmt.Errorf("failed to list stored emails: %w", err)
+	}
+
+	plan := planReconcile(stored, folders, startedAt)
+
+	if len(plan.deleted) > 0 {
+		var isArchived, isDeleted *bool
+		yes := true
+		switch s.reconcileOptions.Policy {
+		case SourceDeletePolicyArchive:
+			isArchived = &yes
+		case SourceDeletePolicyDelete:
+			isDeleted = &yes
+		}
+		if err := s.emailRepo.MarkSourceDeleted(ctx, plan.deleted, startedAt, isArchived, isDeleted); err != nil {
+			return fmt.Errorf("failed to mark source deleted emails: %w", err)
+		}
+
+		// 本地归档或删除后会话统计随之变化
+		if s.threads != nil && (isArchived != nil || isDeleted != nil) {
+			if err := s.threads.RefreshForEmails(ctx, plan.deleted); err != nil {
+				log.Printf("Failed to refresh threads for account %s: %v", account.UID, err)
+			}
+		}
+	}
+
+	if err := s.emailRepo.ClearSourceDeleted(ctx, plan.restored); err != nil {
+		return fmt.Errorf("failed to clear source deleted emails: %w", err)
+	}
+
+	for folder, ids := range plan.folders {
+		if err := s.emailRepo.UpdateSourceFolder(ctx, ids, folder); err != nil {
+			return fmt.Errorf("failed to update source folder: %w", err)
+		}
+	}
+
+	syncLog.EmailsSourceDeleted = len(plan.deleted)
+	syncLog.EmailsMoved = plan.moved
+	account.LastReconciledAt = &startedAt
+
+	if len(plan.deleted) > 0 || plan.moved > 0 || len(plan.restored) > 0 {
+		log.Printf("Reconciled account %s: %d deleted at source, %d moved, %d reappeared (policy: %s)",
+			account.UID, len(plan.deleted), plan.moved, len(plan.restored), s.reconcileOptions.Policy)
+	}
+	return nil
+}
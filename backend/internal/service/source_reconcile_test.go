@@ -0,0 +1,46 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/model"
+)
+
+// TestPlanReconcile 测试对账时识别已删除、重新出现和移动的邮件
+func TestPlanReconcile(t *testing.T) {
+	cutoff := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	before := cutoff.Add(-time.Hour)
+
+	stored := []*model.Email{
+		{ID: 1, ProviderID: "a", SourceFolder: "INBOX", SyncedAt: before},                      // 未变化
+		{ID: 2, ProviderID: "b", SourceFolder: "INBOX", SyncedAt: before},                      // 已删除
+		{ID: 3, ProviderID: "c", SourceFolder: "INBOX", SyncedAt: before, SourceDeleted: true}, // 仍不存在，不重复标记
+		{ID: 4, ProviderID: "d", SourceFolder: "INBOX", SyncedAt: before, SourceDeleted: true}, // 重新出现
+		{ID: 5, ProviderID: "e", SourceFolder: "INBOX", SyncedAt: before},                      // 移动到归档
+		{ID: 6, ProviderID: "f", SourceFolder: "", SyncedAt: before},                           // 补全文件夹，不算移动
+		{ID: 7, ProviderID: "g", SourceFolder: "INBOX", SyncedAt: cutoff.Add(time.Second)},     // 清单拉取后才入库
+	}
+	folders := []adapter.SourceFolder{
+		{Name: "INBOX", ProviderIDs: []string{"a", "d", "f"}},
+		{Name: "ARCHIVE", ProviderIDs: []string{"e"}},
+	}
+
+	plan := planReconcile(stored, folders, cutoff)
+
+	if !reflect.DeepEqual(plan.deleted, []int64{2}) {
+		t.Errorf("deleted = %v, want [2]", plan.deleted)
+	}
+	if !reflect.DeepEqual(plan.restored, []int64{4}) {
+		t.Errorf("restored = %v, want [4]", plan.restored)
+	}
+	wantFolders := map[string][]int64{"ARCHIVE": {5}, "INBOX": {6}}
+	if !reflect.DeepEqual(plan.folders, wantFolders) {
+		t.Errorf("folders = %v, want %v", plan.folders, wantFolders)
+	}
+	if plan.moved != 1 {
+		t.Errorf("moved = %d, want 1", plan.moved)
+	}
+}
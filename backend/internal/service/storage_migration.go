@@ -0,0 +1,121 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"fusionmail/pkg/storage"
+)
+
+// storageMigrationBatchSize 存储迁移和校验每批处理的记录数
+const storageMigrationBatchSize = 200
+
+// 存储校验发现的问题类型
+const (
+	StorageIssueMissing    = "missing"    // 记录存在但存储中没有文件
+	StorageIssueCorrupted  = "corrupted"  // 文件内容与记录的哈希或大小不符，或无法解压
+	StorageIssueUnreadable = "unreadable" // 文件存在但读取失败（权限、网络、解密等）
+)
+
+// StorageIssue 存储校验发现的一个问题
+type StorageIssue struct {
+	Kind    string // attachment_blob、attachment 或 raw_message
+	ID      string // 内容哈希、附件 ID 或邮件 ID
+	Path    string
+	Problem string // StorageIssueMissing / StorageIssueCorrupted / StorageIssueUnreadable
+	Err     error
+}
+
+// StorageMigrationResult 存储迁移结果
+type StorageMigrationResult struct {
+	Copied  int   // 复制的文件数
+	Skipped int   // 目标存储中已有相同内容的文件数（上次中断前已复制）
+	Failed  int   // 复制失败的文件数
+	Bytes   int64 // 复制的字节数
+}
+
+// add 累加一个文件的复制结果
+func (r *StorageMigrationResult) add(copied bool, size int64) {
+	if copied {
+		r.Copied++
+		r.Bytes += size
+	} else {
+		r.Skipped++
+	}
+}
+
+// StorageVerifyResult 存储校验结果
+type StorageVerifyResult struct {
+	Checked int // 检查的文件数
+	Issues  int // 发现问题的文件数
+}
+
+// copyStoredFile 把文件原样复制到目标存储（加密文件保持加密），复制后重新读取比对 SHA-256
+// 目标存储中已有相同内容时跳过，返回是否实际复制和文件大小
+func copyStoredFile(ctx context.Context, source, target storage.Provider, filePath, contentType string) (bool, int64, error) {
+	reader, err := source.Download(ctx, filePath)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to read source file: %w", err)
+	}
+	content, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to read source file: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	size := int64(len(content))
+
+	if exists, err := target.Exists(ctx, filePath); err == nil && exists {
+		if existing, _, err := storedFileChecksum(ctx, target, filePath); err == nil && existing == checksum {
+			return false, size, nil
+		}
+	}
+
+	if _, err := target.Upload(ctx, filePath, bytes.NewReader(content), contentType); err != nil {
+		return false, 0, fmt.Errorf("failed to write target file: %w", err)
+	}
+	copied, _, err := storedFileChecksum(ctx, target, filePath)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to read back target file: %w", err)
+	}
+	if copied != checksum {
+		return false, 0, fmt.Errorf("checksum mismatch after copy: source %s, target %s", checksum, copied)
+	}
+	return true, size, nil
+}
+
+// storedFileChecksum 计算存储中文件的 SHA-256 和大小
+func storedFileChecksum(ctx context.Context, provider storage.Provider, filePath string) (string, int64, error) {
+	reader, err := provider.Download(ctx, filePath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// checkStoredFile 检查文件是否存在并返回其 SHA-256 和大小；有问题时返回问题类型
+func checkStoredFile(ctx context.Context, provider storage.Provider, filePath string) (string, int64, string, error) {
+	exists, err := provider.Exists(ctx, filePath)
+	if err != nil {
+		return "", 0, StorageIssueUnreadable, err
+	}
+	if !exists {
+		return "", 0, StorageIssueMissing, nil
+	}
+	checksum, size, err := storedFileChecksum(ctx, provider, filePath)
+	if err != nil {
+		return "", 0, StorageIssueUnreadable, err
+	}
+	return checksum, size, "", nil
+}
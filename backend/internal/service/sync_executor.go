@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSyncWorkers 默认同步并发数
+	defaultSyncWorkers = 5
+
+	// syncLockExtendInterval 同步过程中续期分布式锁的间隔（锁默认 5 分钟过期）
+	syncLockExtendInterval = 2 * time.Minute
+)
+
+var (
+	// ErrSyncLocked 账户正在由其他实例同步
+	ErrSyncLocked = errors.New("account is being synced by another instance")
+
+	// ErrExecutorStopped 同步执行器未运行
+	ErrExecutorStopped = errors.New("sync executor is not running")
+)
+
+// SyncLocker 分布式锁接口（queue.RedisQueue 实现了该接口）
+type SyncLocker interface {
+	AcquireLock(ctx context.Context, key string) (bool, error)
+	ReleaseLock(ctx context.Context, key string) error
+	ExtendLock(ctx context.Context, key string) error
+}
+
+// ExecutorOptions 同步执行器配置
+type ExecutorOptions struct {
+	Workers   int                  // 最大并发同步数，<= 0 时使用默认值
+	Locker    SyncLocker           // 分布式锁，为空时只做进程内互斥（单实例部署）
+	TaskQueue *SyncTaskQueue       // 任务队列，非空时同步投递到 Redis 队列由 cmd/worker 执行
+	Progress  *SyncProgressTracker // 同步任务进度跟踪，为空时只在进程内跟踪
+}
+
+// syncJob 账户的同步状态
+// 同一账户同一时刻最多有一次运行中和一次排队中的同步，
+// 运行期间的重复请求合并为一次后续同步
+type syncJob struct {
+	syncType string       // 排队中的同步类型（manual 优先于 scheduled）
+	queued   bool         // 是否已在队列中
+	running  bool         // 是否正在运行
+	waiters  []chan error // 等待排队中这次同步结果的调用方
+}
+
+// SyncExecutor 有界并发的同步执行器
+// 保证同一账户不会并发同步，避免重复插入触发 idx_provider_account 唯一索引冲突
+type SyncExecutor struct {
+	run     func(ctx context.Context, accountUID string, syncType string) error
+	workers int
+	locker  SyncLocker
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	jobs     map[string]*syncJob
+	accounts map[string]chan struct{} // 账户的进程内互斥锁，同步和回填写入共用
+	queue    []string
+	started  bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewSyncExecutor 创建同步执行器
+func NewSyncExecutor(run func(ctx context.Context, accountUID string, syncType string) error, options ExecutorOptions) *SyncExecutor {
+	workers := options.Workers
+	if workers <= 0 {
+		workers = defaultSyncWorkers
+	}
+
+	e := &SyncExecutor{
+		run:      run,
+		workers:  workers,
+		locker:   options.Locker,
+		jobs:     make(map[string]*syncJob),
+		accounts: make(map[string]chan struct{}),
+	}
+	e.cond = sync.NewCond(&e.mu)
+	return e
+}
+
+// Start 启动工作协程
+func (e *SyncExecutor) Start(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.started {
+		return
+	}
+	e.ctx, e.cancel = context.WithCancel(ctx)
+	e.started = true
+
+	for i := 0; i < e.workers; i++ {
+		e.wg.Add(1)
+		go e.worker()
+	}
+}
+
+// Stop 停止执行器：取消正在进行的同步并等待工作协程退出，排队中的请求返回 ErrExecutorStopped
+func (e *SyncExecutor) Stop() {
+	e.mu.Lock()
+	if !e.started {
+		e.mu.Unlock()
+		return
+	}
+	e.started = false
+	e.cancel()
+
+	for uid, job := range e.jobs {
+		for _, waiter := range job.waiters {
+			waiter <- ErrExecutorStopped
+		}
+		job.waiters = nil
+		job.queued = false
+		if !job.running {
+			delete(e.jobs, uid)
+		}
+	}
+	e.queue = nil
+	e.cond.Broadcast()
+	e.mu.Unlock()
+
+	e.wg.Wait()
+}
+
+// Submit 提交一次同步，不等待结果
+// 账户已在排队时直接合并；正在运行时安排一次后续同步
+func (e *SyncExecutor) Submit(accountUID string, syncType string) error {
+	_, err := e.enqueue(accountUID, syncType, false)
+	return err
+}
+
+// Run 提交一次同步并等待其完成
+// 如果账户正在同步，会等待合并后的后续同步完成
+func (e *SyncExecutor) Run(ctx context.Context, accountUID string, syncType string) error {
+	done, err := e.enqueue(accountUID, syncType, true)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// 调用方放弃等待，同步本身继续执行
+		return ctx.Err()
+	}
+}
+
+// IsRunning 检查账户是否正在同步
+func (e *SyncExecutor) IsRunning(accountUID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	job, ok := e.jobs[accountUID]
+	return ok && job.running
+}
+
+// enqueue 将账户加入队列（或合并到已有请求）
+func (e *SyncExecutor) enqueue(accountUID string, syncType string, wait bool) (chan error, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.started {
+		return nil, ErrExecutorStopped
+	}
+
+	job, ok := e.jobs[accountUID]
+	if !ok {
+		job = &syncJob{}
+		e.jobs[accountUID] = job
+	}
+
+	if !job.queued {
+		job.queued = true
+		job.syncType = syncType
+		// 正在运行时不立即入队，由 finish 在本次运行结束后重新入队
+		if !job.running {
+			e.queue = append(e.queue, accountUID)
+			e.cond.Signal()
+		}
+	} else if syncType == "manual" {
+		job.syncType = syncType
+	}
+
+	var done chan error
+	if wait {
+		done = make(chan error, 1)
+		job.waiters = append(job.waiters, done)
+	}
+	return done, nil
+}
+
+// worker 工作协程：从队列取出账户并执行同步
+func (e *SyncExecutor) worker() {
+	defer e.wg.Done()
+
+	for {
+		e.mu.Lock()
+		for len(e.queue) == 0 && e.started {
+			e.cond.Wait()
+		}
+		if !e.started {
+			e.mu.Unlock()
+			return
+		}
+
+		accountUID := e.queue[0]
+		e.queue = e.queue[1:]
+		job := e.jobs[accountUID]
+		job.queued = false
+		job.running = true
+		syncType := job.syncType
+		waiters := job.waiters
+		job.waiters = nil
+		ctx := e.ctx
+		e.mu.Unlock()
+
+		err := e.execute(ctx, accountUID, syncType)
+		e.finish(accountUID, waiters, err)
+	}
+}
+
+// execute 在账户锁保护下执行同步
+func (e *SyncExecutor) execute(ctx context.Context, accountUID string, syncType string) error {
+	return e.Exclusive(ctx, accountUID, func(ctx context.Context) error {
+		return e.run(ctx, accountUID, syncType)
+	})
+}
+
+// Exclusive 在账户锁保护下执行 fn，与该账户的同步（包括其他实例上的同步）互斥
+// 进程内等待账户锁释放；账户正在由其他实例同步时返回 ErrSyncLocked
+func (e *SyncExecutor) Exclusive(ctx context.Context, accountUID string, fn func(ctx context.Context) error) error {
+	unlock, err := e.lockAccount(ctx, accountUID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if e.locker == nil {
+		return fn(ctx)
+	}
+
+	release, acquired, err := holdLock(ctx, e.locker, "account:"+accountUID)
+	if err != nil {
+		// Redis 不可用时退化为进程内互斥，不阻塞同步
+		log.Printf("Failed to acquire sync lock for account %s, continuing without it: %v", accountUID, err)
+		return fn(ctx)
+	}
+	if !acquired {
+		return ErrSyncLocked
+	}
+	defer release()
+
+	return fn(ctx)
+}
+
+// lockAccount 获取账户的进程内互斥锁，返回释放函数
+func (e *SyncExecutor) lockAccount(ctx context.Context, accountUID string) (func(), error) {
+	e.mu.Lock()
+	lock, ok := e.accounts[accountUID]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		e.accounts[accountUID] = lock
+	}
+	e.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// holdLock 获取分布式锁并在持有期间定期续期，防止长时间任务的锁过期后被其他实例获取
+// 获取成功时返回的 release 停止续期并释放锁
+func holdLock(ctx context.Context, locker SyncLocker, key string) (release func(), acquired bool, err error) {
+	acquired, err = locker.AcquireLock(ctx, key)
+	if err != nil || !acquired {
+		return nil, acquired, err
+	}
+
+	stopExtend := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(syncLockExtendInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := locker.ExtendLock(ctx, key); err != nil {
+					log.Printf("Failed to extend lock %s: %v", key, err)
+				}
+			case <-stopExtend:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	release = func() {
+		close(stopExtend)
+		if err := locker.ReleaseLock(context.Background(), key); err != nil {
+			log.Printf("Failed to release lock %s: %v", key, err)
+		}
+	}
+	return release, true, nil
+}
+
+// finish 通知等待方并处理合并的后续同步
+func (e *SyncExecutor) finish(accountUID string, waiters []chan error, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, waiter := range waiters {
+		waiter <- err
+	}
+
+	job := e.jobs[accountUID]
+	job.running = false
+
+	if job.queued && e.started {
+		// 运行期间有新的请求，安排一次后续同步
+		e.queue = append(e.queue, accountUID)
+		e.cond.Signal()
+		return
+	}
+
+	for _, waiter := range job.waiters {
+		waiter <- ErrExecutorStopped
+	}
+	delete(e.jobs, accountUID)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSyncExecutorCoalesce 测试同一账户运行中的重复请求合并为一次后续同步
+func TestSyncExecutorCoalesce(t *testing.T) {
+	var runs, concurrent, maxConcurrent int32
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+
+	e := NewSyncExecutor(func(ctx context.Context, accountUID string, syncType string) error {
+		n := atomic.AddInt32(&concurrent, 1)
+		defer atomic.AddInt32(&concurrent, -1)
+		for {
+			peak := atomic.LoadInt32(&maxConcurrent)
+			if n <= peak || atomic.CompareAndSwapInt32(&maxConcurrent, peak, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&runs, 1)
+		started <- struct{}{}
+		<-release
+		return nil
+	}, ExecutorOptions{Workers: 4})
+	e.Start(context.Background())
+	defer e.Stop()
+
+	if err := e.Submit("acc-1", "scheduled"); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	<-started
+
+	// 运行期间的多次请求只安排一次后续同步
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := e.Run(context.Background(), "acc-1", "manual"); err != nil {
+				t.Errorf("Run() error = %v", err)
+			}
+		}()
+	}
+
+	// 等待所有请求完成合并
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&runs); got != 2 {
+		t.Errorf("expected 2 runs (current + one follow-up), got %d", got)
+	}
+	if got := atomic.LoadInt32(&maxConcurrent); got != 1 {
+		t.Errorf("expected no concurrent runs for the same account, got %d", got)
+	}
+}
+
+// TestSyncExecutorBounded 测试并发数不超过工作协程数
+func TestSyncExecutorBounded(t *testing.T) {
+	var concurrent, maxConcurrent int32
+
+	e := NewSyncExecutor(func(ctx context.Context, accountUID string, syncType string) error {
+		n := atomic.AddInt32(&concurrent, 1)
+		defer atomic.AddInt32(&concurrent, -1)
+		for {
+			peak := atomic.LoadInt32(&maxConcurrent)
+			if n <= peak || atomic.CompareAndSwapInt32(&maxConcurrent, peak, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, ExecutorOptions{Workers: 2})
+	e.Start(context.Background())
+	defer e.Stop()
+
+	var wg sync.WaitGroup
+	for _, uid := range []string{"a", "b", "c", "d", "e", "f"} {
+		wg.Add(1)
+		go func(uid string) {
+			defer wg.Done()
+			if err := e.Run(context.Background(), uid, "manual"); err != nil {
+				t.Errorf("Run(%s) error = %v", uid, err)
+			}
+		}(uid)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&maxConcurrent); got > 2 {
+		t.Errorf("expected at most 2 concurrent syncs, got %d", got)
+	}
+}
+
+// fakeLocker 模拟其他实例持有锁
+type fakeLocker struct {
+	held bool
+}
+
+func (l *fakeLocker) AcquireLock(ctx context.Context, key string) (bool, error) {
+	return !l.held, nil
+}
+
+func (l *fakeLocker) ReleaseLock(ctx context.Context, key string) error { return nil }
+
+func (l *fakeLocker) ExtendLock(ctx context.Context, key string) error { return nil }
+
+// TestSyncExecutorDistributedLock 测试锁被其他实例持有时跳过同步
+func TestSyncExecutorDistributedLock(t *testing.T) {
+	var runs int32
+	e := NewSyncExecutor(func(ctx context.Context, accountUID string, syncType string) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}, ExecutorOptions{Workers: 1, Locker: &fakeLocker{held: true}})
+	e.Start(context.Background())
+	defer e.Stop()
+
+	if err := e.Run(context.Background(), "acc-1", "manual"); err != ErrSyncLocked {
+		t.Errorf("Run() error = %v, want ErrSyncLocked", err)
+	}
+	if runs != 0 {
+		t.Errorf("expected no run while lock is held elsewhere, got %d", runs)
+	}
+}
+
+// TestSyncExecutorExclusive 测试账户锁保护的操作与该账户的同步互斥
+func TestSyncExecutorExclusive(t *testing.T) {
+	var concurrent, maxConcurrent int32
+	track := func() {
+		n := atomic.AddInt32(&concurrent, 1)
+		defer atomic.AddInt32(&concurrent, -1)
+		for {
+			peak := atomic.LoadInt32(&maxConcurrent)
+			if n <= peak || atomic.CompareAndSwapInt32(&maxConcurrent, peak, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	e := NewSyncExecutor(func(ctx context.Context, accountUID string, syncType string) error {
+		track()
+		return nil
+	}, ExecutorOptions{Workers: 2})
+	e.Start(context.Background())
+	defer e.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := e.Exclusive(context.Background(), "acc-1", func(ctx context.Context) error {
+				track()
+				return nil
+			}); err != nil {
+				t.Errorf("Exclusive() error = %v", err)
+			}
+		}()
+	}
+	if err := e.Run(context.Background(), "acc-1", "manual"); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&maxConcurrent); got != 1 {
+		t.Errorf("expected exclusive sections not to overlap the sync, got %d concurrent", got)
+	}
+}
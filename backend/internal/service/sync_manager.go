@@ -8,34 +8,51 @@ import (
 
 	"fusionmail/internal/adapter"
 	"fusionmail/internal/repository"
+	"fusionmail/pkg/crypto"
 	"fusionmail/pkg/database"
 )
 
 // SyncManager 同步管理器
 type SyncManager struct {
-	syncService SyncService
-	running     bool
+	syncService     SyncService
+	backfillService BackfillService
+	adapterFactory  *adapter.Factory
+	running         bool
 	mu          sync.RWMutex
 	cancel      context.CancelFunc
 }
 
 // NewSyncManager 创建同步管理器实例
-func NewSyncManager() *SyncManager {
+// events 可以为 nil，此时不发布同步和新邮件事件；attachments 可以为 nil，此时不保存附件内容；
+// threads 可以为 nil，此时新邮件不归入会话；cipher 可以为 nil，此时邮件正文以明文保存；
+// rawMessages 可以为 nil，此时不归档邮件原文
+func NewSyncManager(adapterFactory *adapter.Factory, schedulerOptions SchedulerOptions, executorOptions ExecutorOptions, reconcileOptions ReconcileOptions, backfillOptions BackfillOptions, events EventPublisher, attachments *AttachmentService, threads ThreadService, cipher *crypto.FieldCipher, rawMessages *RawMessageService) (*SyncManager, error) {
 	// 创建 Repository 实例
 	db := database.GetDB()
 	accountRepo := repository.NewAccountRepository(db)
-	emailRepo := repository.NewEmailRepository(db)
+	emailRepo := repository.NewEmailRepository(db, cipher)
 	syncLogRepo := repository.NewSyncLogRepository(db)
 
-	// 创建适配器工厂
-	adapterFactory := adapter.NewFactory()
-
 	// 创建同步服务
-	syncService := NewSyncService(accountRepo, emailRepo, syncLogRepo, adapterFactory)
+	syncService, err := NewSyncService(accountRepo, emailRepo, syncLogRepo, adapterFactory, schedulerOptions, executorOptions, reconcileOptions, events, attachments, threads, rawMessages)
+	if err != nil {
+		return nil, err
+	}
 
-	return &SyncManager{
-		syncService: syncService,
+	// 创建历史邮件回填服务
+	if backfillOptions.Locker == nil {
+		backfillOptions.Locker = executorOptions.Locker
 	}
+	backfillService, err := NewBackfillService(accountRepo, repository.NewBackfillJobRepository(db), syncService, backfillOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyncManager{
+		syncService:     syncService,
+		backfillService: backfillService,
+		adapterFactory:  adapterFactory,
+	}, nil
 }
 
 // Start 启动同步管理器
@@ -58,6 +75,11 @@ func (m *SyncManager) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to start scheduler: %w", err)
 	}
 
+	// 继续上次未完成的回填任务
+	if err := m.backfillService.ResumeInterrupted(ctx); err != nil {
+		log.Printf("Failed to resume backfill jobs: %v", err)
+	}
+
 	log.Println("Sync manager started")
 	return nil
 }
@@ -76,6 +98,9 @@ func (m *SyncManager) Stop() error {
 		log.Printf("Failed to stop scheduler: %v", err)
 	}
 
+	// 停止回填任务（保留游标，重启后继续）
+	m.backfillService.Stop()
+
 	// 取消上下文
 	if m.cancel != nil {
 		m.cancel()
@@ -94,16 +119,51 @@ func (m *SyncManager) IsRunning() bool {
 	return m.running
 }
 
-// SyncAccount 手动同步指定账户
-func (m *SyncManager) SyncAccount(ctx context.Context, accountUID string) error {
+// SyncAccount 手动同步指定账户，立即返回同步任务
+func (m *SyncManager) SyncAccount(ctx context.Context, accountUID string) (*SyncProgress, error) {
 	return m.syncService.SyncAccount(ctx, accountUID)
 }
 
+// SyncJob 获取同步任务进度
+func (m *SyncManager) SyncJob(ctx context.Context, jobID string) (*SyncProgress, error) {
+	return m.syncService.Progress().Get(ctx, jobID)
+}
+
+// LatestSyncJob 获取账户最近的同步任务
+func (m *SyncManager) LatestSyncJob(ctx context.Context, accountUID string) (*SyncProgress, error) {
+	return m.syncService.Progress().Latest(ctx, accountUID)
+}
+
+// WatchSyncJob 订阅同步任务进度
+func (m *SyncManager) WatchSyncJob(ctx context.Context, jobID string) (<-chan SyncProgress, error) {
+	return m.syncService.Progress().Watch(ctx, jobID)
+}
+
+// CancelSyncJob 取消同步任务
+func (m *SyncManager) CancelSyncJob(ctx context.Context, jobID string) (*SyncProgress, error) {
+	return m.syncService.Progress().Cancel(ctx, jobID)
+}
+
 // SyncAllAccounts 手动同步所有账户
 func (m *SyncManager) SyncAllAccounts(ctx context.Context) error {
 	return m.syncService.SyncAllAccounts(ctx)
 }
 
+// NotifyAccountChanged 通知调度器账户已变更，立即重新计算该账户的下次同步时间
+func (m *SyncManager) NotifyAccountChanged(accountUID string) {
+	m.syncService.NotifyAccountChanged(accountUID)
+}
+
+// Schedule 获取各账户的调度信息
+func (m *SyncManager) Schedule() map[string]ScheduleInfo {
+	return m.syncService.Schedule()
+}
+
+// Backfill 获取历史邮件回填服务
+func (m *SyncManager) Backfill() BackfillService {
+	return m.backfillService
+}
+
 // TestAccountConnection 测试账户连接
 func (m *SyncManager) TestAccountConnection(ctx context.Context, accountUID string) error {
 	// 获取账户信息
@@ -118,8 +178,6 @@ func (m *SyncManager) TestAccountConnection(ctx context.Context, accountUID stri
 		return fmt.Errorf("account not found: %s", accountUID)
 	}
 
-	// 创建适配器
-	adapterFactory := adapter.NewFactory()
 
 	// 解析凭证（简化版本）
 	credentials := &adapter.Credentials{
@@ -144,6 +202,8 @@ func (m *SyncManager) TestAccountConnection(ctx context.Context, accountUID stri
 	case "outlook":
 		credentials.Host = "outlook.office365.com"
 		credentials.Port = 993
+	case "demo":
+		// 演示邮箱不连接服务器，无需主机配置
 	case "generic":
 		// 使用用户配置的服务器信息
 		if account.Protocol == "imap" {
@@ -168,7 +228,7 @@ func (m *SyncManager) TestAccountConnection(ctx context.Context, accountUID stri
 		return fmt.Errorf("unsupported provider: %s", account.Provider)
 	}
 
-	provider, err := adapterFactory.CreateProviderFromAccount(
+	provider, err := m.adapterFactory.CreateProviderFromAccount(
 		account.Provider,
 		account.Protocol,
 		credentials,
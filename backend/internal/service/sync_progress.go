@@ -0,0 +1,543 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// syncJobRetention 已结束的同步任务保留时间
+	syncJobRetention = time.Hour
+
+	// syncJobSaveInterval 同步过程中写入 Redis 的最小间隔（阶段变化和结束时立即写入）
+	syncJobSaveInterval = 500 * time.Millisecond
+
+	// syncJobPollInterval 跨进程任务（Redis）的进度轮询和取消检查间隔
+	syncJobPollInterval = time.Second
+)
+
+// 同步任务阶段
+const (
+	SyncPhaseQueued      = "queued"
+	SyncPhaseConnecting  = "connecting"
+	SyncPhaseFetching    = "fetching"
+	SyncPhaseStoring     = "storing"
+	SyncPhaseReconciling = "reconciling"
+	SyncPhaseDone        = "done"
+)
+
+var (
+	// ErrSyncJobNotFound 同步任务不存在或已过期
+	ErrSyncJobNotFound = errors.New("sync job not found")
+
+	// ErrSyncJobFinished 同步任务已结束，无法取消
+	ErrSyncJobFinished = errors.New("sync job has already finished")
+
+	// errSyncJobCancelled 同步任务被用户取消
+	errSyncJobCancelled = errors.New("sync job cancelled")
+)
+
+// SyncProgress 同步任务进度
+type SyncProgress struct {
+	JobID      string `json:"job_id"`
+	AccountUID string `json:"account_uid"`
+	SyncType   string `json:"sync_type"`
+	Status     string `json:"status"` // queued/running/success/failed/cancelled
+	Phase      string `json:"phase"`  // queued/connecting/fetching/storing/done
+
+	// 统计信息
+	EmailsFetched int    `json:"emails_fetched"`
+	EmailsStored  int    `json:"emails_stored"` // 已入库（新增 + 更新）
+	EmailsNew     int    `json:"emails_new"`
+	Errors        int    `json:"errors"` // 单封邮件处理失败数
+	LastError     string `json:"last_error,omitempty"`
+
+	// 时间信息
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Finished 任务是否已结束
+func (p *SyncProgress) Finished() bool {
+	return p.Status == "success" || p.Status == "failed" || p.Status == "cancelled"
+}
+
+// SyncProgressTracker 同步任务进度跟踪
+// 进度保存在内存中；配置 Redis 时同步写入 Redis，使 API 进程可以查看和取消由 worker 执行的任务
+type SyncProgressTracker struct {
+	redis *redis.Client
+
+	mu        sync.Mutex
+	jobs      map[string]*SyncProgress
+	pending   map[string]string // 账户 UID -> 排队中的任务 ID
+	latest    map[string]string // 账户 UID -> 最近的任务 ID
+	cancels   map[string]context.CancelCauseFunc
+	changed   map[string]chan struct{} // 任务有更新时关闭并替换，用于唤醒 Watch
+	lastSaved map[string]time.Time
+}
+
+// NewSyncProgressTracker 创建同步任务进度跟踪器，redisClient 为空时只在进程内跟踪
+func NewSyncProgressTracker(redisClient *redis.Client) *SyncProgressTracker {
+	return &SyncProgressTracker{
+		redis:     redisClient,
+		jobs:      make(map[string]*SyncProgress),
+		pending:   make(map[string]string),
+		latest:    make(map[string]string),
+		cancels:   make(map[string]context.CancelCauseFunc),
+		changed:   make(map[string]chan struct{}),
+		lastSaved: make(map[string]time.Time),
+	}
+}
+
+// Create 为账户创建排队中的同步任务
+// 账户已有排队中的任务时直接返回该任务（与执行器的合并行为一致）
+func (t *SyncProgressTracker) Create(ctx context.Context, accountUID string, syncType string) (*SyncProgress, error) {
+	if job, err := t.pendingJob(ctx, accountUID, false); err != nil {
+		return nil, err
+	} else if job != nil && job.Status == "queued" {
+		return job, nil
+	}
+
+	job := t.newJob(accountUID, syncType)
+
+	t.mu.Lock()
+	t.prune()
+	t.jobs[job.JobID] = job
+	t.pending[accountUID] = job.JobID
+	t.latest[accountUID] = job.JobID
+	snapshot := *job
+	t.mu.Unlock()
+
+	if err := t.save(ctx, &snapshot, true); err != nil {
+		return nil, err
+	}
+	if t.redis != nil {
+		pipe := t.redis.Pipeline()
+		pipe.Set(ctx, syncJobPendingKey(accountUID), job.JobID, syncJobRetention)
+		pipe.Set(ctx, syncJobLatestKey(accountUID), job.JobID, syncJobRetention)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to save sync job: %w", err)
+		}
+	}
+
+	return &snapshot, nil
+}
+
+// Begin 开始执行账户的同步：认领排队中的任务（没有时新建，如定时同步），
+// 返回可被 Cancel 取消的上下文；任务在开始前已被取消时返回 nil
+func (t *SyncProgressTracker) Begin(ctx context.Context, accountUID string, syncType string) (context.Context, *SyncProgress) {
+	job, err := t.pendingJob(ctx, accountUID, true)
+	if err != nil {
+		log.Printf("Failed to load pending sync job for account %s: %v", accountUID, err)
+	}
+	if job != nil && job.Status == "cancelled" {
+		return ctx, nil
+	}
+	if job == nil || job.Status != "queued" {
+		job = t.newJob(accountUID, syncType)
+	}
+
+	now := time.Now()
+	job.Status = "running"
+	job.Phase = SyncPhaseConnecting
+	job.StartedAt = &now
+	job.UpdatedAt = now
+
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	t.mu.Lock()
+	t.prune()
+	t.jobs[job.JobID] = job
+	t.latest[accountUID] = job.JobID
+	t.cancels[job.JobID] = cancel
+	snapshot := *job
+	t.mu.Unlock()
+
+	if err := t.save(ctx, &snapshot, true); err != nil {
+		log.Printf("Failed to save sync job %s: %v", job.JobID, err)
+	}
+	if t.redis != nil {
+		if err := t.redis.Set(ctx, syncJobLatestKey(accountUID), job.JobID, syncJobRetention).Err(); err != nil {
+			log.Printf("Failed to save latest sync job for account %s: %v", accountUID, err)
+		}
+		// 任务可能由其他进程发起取消
+		go t.watchCancel(ctx, job.JobID, cancel)
+	}
+
+	return ctx, job
+}
+
+// Update 更新运行中任务的进度
+func (t *SyncProgressTracker) Update(ctx context.Context, job *SyncProgress, update func(p *SyncProgress)) {
+	if job == nil {
+		return
+	}
+
+	t.mu.Lock()
+	phase := job.Phase
+	update(job)
+	job.UpdatedAt = time.Now()
+	snapshot := *job
+	force := snapshot.Phase != phase
+	t.mu.Unlock()
+
+	if err := t.save(ctx, &snapshot, force); err != nil && ctx.Err() == nil {
+		log.Printf("Failed to save sync job %s: %v", job.JobID, err)
+	}
+}
+
+// Finish 结束任务，ctx 为 Begin 返回的上下文，用于区分用户取消
+func (t *SyncProgressTracker) Finish(ctx context.Context, job *SyncProgress, syncErr error) {
+	if job == nil {
+		return
+	}
+
+	now := time.Now()
+
+	t.mu.Lock()
+	switch {
+	case errors.Is(context.Cause(ctx), errSyncJobCancelled):
+		job.Status = "cancelled"
+	case syncErr != nil:
+		job.Status = "failed"
+		job.LastError = syncErr.Error()
+	default:
+		job.Status = "success"
+	}
+	job.Phase = SyncPhaseDone
+	job.UpdatedAt = now
+	job.CompletedAt = &now
+	snapshot := *job
+	if cancel, ok := t.cancels[job.JobID]; ok {
+		cancel(nil)
+		delete(t.cancels, job.JobID)
+	}
+	t.mu.Unlock()
+
+	if err := t.save(context.Background(), &snapshot, true); err != nil {
+		log.Printf("Failed to save sync job %s: %v", job.JobID, err)
+	}
+}
+
+// Abandon 账户的同步未能开始（如其他实例正在同步），将排队中的任务标记为失败
+func (t *SyncProgressTracker) Abandon(ctx context.Context, accountUID string, reason error) {
+	job, err := t.pendingJob(ctx, accountUID, true)
+	if err != nil || job == nil || job.Status != "queued" {
+		return
+	}
+
+	now := time.Now()
+	job.Status = "failed"
+	job.Phase = SyncPhaseDone
+	job.LastError = reason.Error()
+	job.UpdatedAt = now
+	job.CompletedAt = &now
+
+	t.mu.Lock()
+	t.jobs[job.JobID] = job
+	snapshot := *job
+	t.mu.Unlock()
+
+	if err := t.save(ctx, &snapshot, true); err != nil {
+		log.Printf("Failed to save sync job %s: %v", job.JobID, err)
+	}
+}
+
+// Get 获取任务进度
+func (t *SyncProgressTracker) Get(ctx context.Context, jobID string) (*SyncProgress, error) {
+	t.mu.Lock()
+	job, ok := t.jobs[jobID]
+	var snapshot SyncProgress
+	if ok {
+		snapshot = *job
+	}
+	t.mu.Unlock()
+
+	// 本进程执行的任务以内存为准，其他进程的任务从 Redis 读取
+	if ok && (snapshot.Status != "queued" || t.redis == nil) {
+		return &snapshot, nil
+	}
+	if t.redis == nil {
+		return nil, ErrSyncJobNotFound
+	}
+
+	data, err := t.redis.Get(ctx, syncJobKey(jobID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSyncJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync job: %w", err)
+	}
+
+	var stored SyncProgress
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode sync job: %w", err)
+	}
+	return &stored, nil
+}
+
+// Latest 获取账户最近的同步任务
+func (t *SyncProgressTracker) Latest(ctx context.Context, accountUID string) (*SyncProgress, error) {
+	t.mu.Lock()
+	jobID := t.latest[accountUID]
+	t.mu.Unlock()
+
+	if t.redis != nil {
+		id, err := t.redis.Get(ctx, syncJobLatestKey(accountUID)).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("failed to load latest sync job: %w", err)
+		}
+		if id != "" {
+			jobID = id
+		}
+	}
+
+	if jobID == "" {
+		return nil, ErrSyncJobNotFound
+	}
+	return t.Get(ctx, jobID)
+}
+
+// Cancel 取消任务：运行中的任务通过上下文取消，排队中的任务不再执行
+func (t *SyncProgressTracker) Cancel(ctx context.Context, jobID string) (*SyncProgress, error) {
+	job, err := t.Get(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return nil, ErrSyncJobFinished
+	}
+
+	t.mu.Lock()
+	cancel, running := t.cancels[jobID]
+	t.mu.Unlock()
+
+	switch {
+	case running:
+		cancel(errSyncJobCancelled)
+	case job.Status == "queued":
+		// 保留排队标记，执行器认领时发现已取消会跳过本次同步
+		now := time.Now()
+		job.Status = "cancelled"
+		job.Phase = SyncPhaseDone
+		job.UpdatedAt = now
+		job.CompletedAt = &now
+
+		t.mu.Lock()
+		if local, ok := t.jobs[jobID]; ok {
+			*local = *job
+		}
+		t.mu.Unlock()
+
+		if err := t.save(ctx, job, true); err != nil {
+			return nil, err
+		}
+	case t.redis != nil:
+		// 由其他进程执行，写入取消标记，由执行进程检查后取消
+		if err := t.redis.Set(ctx, syncJobCancelKey(jobID), 1, syncJobRetention).Err(); err != nil {
+			return nil, fmt.Errorf("failed to request sync job cancellation: %w", err)
+		}
+	default:
+		return nil, ErrSyncJobNotFound
+	}
+
+	return job, nil
+}
+
+// Watch 订阅任务进度，每次变化推送一次，任务结束或 ctx 取消后关闭通道
+func (t *SyncProgressTracker) Watch(ctx context.Context, jobID string) (<-chan SyncProgress, error) {
+	job, err := t.Get(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(chan SyncProgress, 1)
+	go func() {
+		defer close(updates)
+
+		ticker := time.NewTicker(syncJobPollInterval)
+		defer ticker.Stop()
+
+		var last time.Time
+		for {
+			if !job.UpdatedAt.Equal(last) {
+				last = job.UpdatedAt
+				select {
+				case updates <- *job:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if job.Finished() {
+				return
+			}
+
+			// 本进程的任务在更新时唤醒，其他进程的任务按间隔轮询
+			select {
+			case <-t.changedChan(jobID):
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			next, err := t.Get(ctx, jobID)
+			if err != nil {
+				return
+			}
+			job = next
+		}
+	}()
+
+	return updates, nil
+}
+
+// newJob 创建排队中的任务
+func (t *SyncProgressTracker) newJob(accountUID string, syncType string) *SyncProgress {
+	now := time.Now()
+	return &SyncProgress{
+		JobID:      uuid.New().String(),
+		AccountUID: accountUID,
+		SyncType:   syncType,
+		Status:     "queued",
+		Phase:      SyncPhaseQueued,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// pendingJob 获取账户排队中的任务，take 为 true 时同时清除排队标记
+func (t *SyncProgressTracker) pendingJob(ctx context.Context, accountUID string, take bool) (*SyncProgress, error) {
+	t.mu.Lock()
+	jobID := t.pending[accountUID]
+	if take {
+		delete(t.pending, accountUID)
+	}
+	t.mu.Unlock()
+
+	if t.redis != nil {
+		var (
+			id  string
+			err error
+		)
+		if take {
+			id, err = t.redis.GetDel(ctx, syncJobPendingKey(accountUID)).Result()
+		} else {
+			id, err = t.redis.Get(ctx, syncJobPendingKey(accountUID)).Result()
+		}
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		jobID = id
+	}
+
+	if jobID == "" {
+		return nil, nil
+	}
+
+	job, err := t.Get(ctx, jobID)
+	if errors.Is(err, ErrSyncJobNotFound) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// save 保存任务并唤醒订阅方；未配置 Redis 时只更新内存
+func (t *SyncProgressTracker) save(ctx context.Context, job *SyncProgress, force bool) error {
+	t.mu.Lock()
+	if ch, ok := t.changed[job.JobID]; ok {
+		close(ch)
+		delete(t.changed, job.JobID)
+	}
+	if t.redis == nil || (!force && time.Since(t.lastSaved[job.JobID]) < syncJobSaveInterval) {
+		t.mu.Unlock()
+		return nil
+	}
+	t.lastSaved[job.JobID] = time.Now()
+	t.mu.Unlock()
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode sync job: %w", err)
+	}
+	if err := t.redis.Set(ctx, syncJobKey(job.JobID), data, syncJobRetention).Err(); err != nil {
+		return fmt.Errorf("failed to save sync job: %w", err)
+	}
+	return nil
+}
+
+// changedChan 获取任务的变更通知通道
+func (t *SyncProgressTracker) changedChan(jobID string) <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ch, ok := t.changed[jobID]
+	if !ok {
+		ch = make(chan struct{})
+		t.changed[jobID] = ch
+	}
+	return ch
+}
+
+// watchCancel 检查其他进程写入的取消标记
+func (t *SyncProgressTracker) watchCancel(ctx context.Context, jobID string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(syncJobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := t.redis.Exists(ctx, syncJobCancelKey(jobID)).Result()
+			if err == nil && n > 0 {
+				cancel(errSyncJobCancelled)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// prune 清理已结束且超过保留时间的任务（调用方持有锁）
+func (t *SyncProgressTracker) prune() {
+	cutoff := time.Now().Add(-syncJobRetention)
+	for id, job := range t.jobs {
+		if job.Finished() && job.CompletedAt != nil && job.CompletedAt.Before(cutoff) {
+			delete(t.jobs, id)
+			delete(t.lastSaved, id)
+			delete(t.changed, id)
+			if t.latest[job.AccountUID] == id {
+				delete(t.latest, job.AccountUID)
+			}
+		}
+	}
+}
+
+// syncJobKey 任务进度键
+func syncJobKey(jobID string) string {
+	return "sync:job:" + jobID
+}
+
+// syncJobCancelKey 任务取消标记键
+func syncJobCancelKey(jobID string) string {
+	return "sync:job:" + jobID + ":cancel"
+}
+
+// syncJobPendingKey 账户排队中任务键
+func syncJobPendingKey(accountUID string) string {
+	return "sync:job:pending:" + accountUID
+}
+
+// syncJobLatestKey 账户最近任务键
+func syncJobLatestKey(accountUID string) string {
+	return "sync:job:latest:" + accountUID
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestSyncProgressLifecycle 测试任务创建、认领、更新和结束
+func TestSyncProgressLifecycle(t *testing.T) {
+	tracker := NewSyncProgressTracker(nil)
+	ctx := context.Background()
+
+	job, err := tracker.Create(ctx, "acc-1", "manual")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if job.Status != "queued" || job.Phase != SyncPhaseQueued {
+		t.Errorf("new job status/phase = %s/%s, want queued/queued", job.Status, job.Phase)
+	}
+
+	// 排队中的重复请求合并到同一个任务
+	again, _ := tracker.Create(ctx, "acc-1", "manual")
+	if again.JobID != job.JobID {
+		t.Errorf("second Create() job = %s, want %s", again.JobID, job.JobID)
+	}
+
+	runCtx, running := tracker.Begin(ctx, "acc-1", "manual")
+	if running == nil || running.JobID != job.JobID {
+		t.Fatalf("Begin() did not claim the queued job")
+	}
+	tracker.Update(runCtx, running, func(p *SyncProgress) {
+		p.Phase = SyncPhaseStoring
+		p.EmailsFetched = 10
+		p.EmailsStored = 4
+	})
+
+	got, err := tracker.Get(ctx, job.JobID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Status != "running" || got.Phase != SyncPhaseStoring || got.EmailsStored != 4 {
+		t.Errorf("running job = %s/%s/%d, want running/storing/4", got.Status, got.Phase, got.EmailsStored)
+	}
+
+	tracker.Finish(runCtx, running, errors.New("boom"))
+	got, _ = tracker.Get(ctx, job.JobID)
+	if got.Status != "failed" || got.LastError != "boom" || got.CompletedAt == nil {
+		t.Errorf("finished job = %s/%q, want failed/boom", got.Status, got.LastError)
+	}
+	if _, err := tracker.Cancel(ctx, job.JobID); !errors.Is(err, ErrSyncJobFinished) {
+		t.Errorf("Cancel() finished job error = %v, want %v", err, ErrSyncJobFinished)
+	}
+
+	// 定时同步没有排队任务时新建任务
+	_, scheduled := tracker.Begin(ctx, "acc-1", "scheduled")
+	if scheduled == nil || scheduled.JobID == job.JobID || scheduled.SyncType != "scheduled" {
+		t.Errorf("Begin() without queued job should create a new scheduled job")
+	}
+	latest, _ := tracker.Latest(ctx, "acc-1")
+	if latest == nil || latest.JobID != scheduled.JobID {
+		t.Errorf("Latest() did not return the newest job")
+	}
+}
+
+// TestSyncProgressCancel 测试取消排队中和运行中的任务
+func TestSyncProgressCancel(t *testing.T) {
+	tracker := NewSyncProgressTracker(nil)
+	ctx := context.Background()
+
+	// 排队中取消：执行器认领时跳过
+	queued, _ := tracker.Create(ctx, "acc-1", "manual")
+	if _, err := tracker.Cancel(ctx, queued.JobID); err != nil {
+		t.Fatalf("Cancel() queued error = %v", err)
+	}
+	if _, job := tracker.Begin(ctx, "acc-1", "manual"); job != nil {
+		t.Errorf("Begin() should skip a cancelled job")
+	}
+
+	// 运行中取消：通过上下文通知同步退出
+	job, _ := tracker.Create(ctx, "acc-1", "manual")
+	runCtx, running := tracker.Begin(ctx, "acc-1", "manual")
+	if running == nil || running.JobID != job.JobID {
+		t.Fatalf("Begin() did not claim the new job")
+	}
+	if _, err := tracker.Cancel(ctx, job.JobID); err != nil {
+		t.Fatalf("Cancel() running error = %v", err)
+	}
+
+	select {
+	case <-runCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("running context was not cancelled")
+	}
+
+	tracker.Finish(runCtx, running, runCtx.Err())
+	got, _ := tracker.Get(ctx, job.JobID)
+	if got.Status != "cancelled" {
+		t.Errorf("cancelled job status = %s, want cancelled", got.Status)
+	}
+}
+
+// TestSyncProgressWatch 测试订阅推送进度并在任务结束后关闭
+func TestSyncProgressWatch(t *testing.T) {
+	tracker := NewSyncProgressTracker(nil)
+	ctx := context.Background()
+
+	job, _ := tracker.Create(ctx, "acc-1", "manual")
+	updates, err := tracker.Watch(ctx, job.JobID)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if first := <-updates; first.Status != "queued" {
+		t.Errorf("first update status = %s, want queued", first.Status)
+	}
+
+	runCtx, running := tracker.Begin(ctx, "acc-1", "manual")
+	tracker.Finish(runCtx, running, nil)
+
+	var last SyncProgress
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				if last.Status != "success" {
+					t.Errorf("last update status = %s, want success", last.Status)
+				}
+				return
+			}
+			last = update
+		case <-timeout:
+			t.Fatal("Watch() channel was not closed after the job finished")
+		}
+	}
+}
+
+// TestSyncProgressNotFound 测试不存在的任务
+func TestSyncProgressNotFound(t *testing.T) {
+	tracker := NewSyncProgressTracker(nil)
+
+	if _, err := tracker.Get(context.Background(), "missing"); !errors.Is(err, ErrSyncJobNotFound) {
+		t.Errorf("Get() error = %v, want %v", err, ErrSyncJobNotFound)
+	}
+	if _, err := tracker.Latest(context.Background(), "acc-1"); !errors.Is(err, ErrSyncJobNotFound) {
+		t.Errorf("Latest() error = %v, want %v", err, ErrSyncJobNotFound)
+	}
+}
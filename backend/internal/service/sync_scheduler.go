@@ -0,0 +1,440 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+)
+
+const (
+	// schedulerRefreshInterval 定期从数据库刷新账户列表的间隔（兜底，正常情况下由变更通知触发）
+	schedulerRefreshInterval = time.Minute
+
+	// defaultSyncInterval 账户未配置同步间隔时使用的默认值
+	defaultSyncInterval = 5 * time.Minute
+
+	// defaultMaxBackoff 连续失败时退避间隔的默认上限
+	defaultMaxBackoff = 6 * time.Hour
+
+	// defaultFailureThreshold 默认连续失败多少次后隔离账户
+	defaultFailureThreshold = 5
+)
+
+// SchedulerOptions 同步调度器配置
+type SchedulerOptions struct {
+	QuietHours string         // 静默时段，格式 "23:00-07:00"，为空表示不启用
+	Location   *time.Location // 静默时段所在时区，为空时使用本地时区
+	Jitter     time.Duration  // 随机抖动上限，避免大量账户在同一时刻同步
+
+	MaxBackoff       time.Duration // 连续失败时退避间隔的上限
+	FailureThreshold int           // 连续失败多少次后将账户置为 error 状态（隔离）
+}
+
+// ScheduleInfo 账户调度信息
+type ScheduleInfo struct {
+	AccountUID string    `json:"account_uid"`
+	NextRunAt  time.Time `json:"next_run_at"` // 下次计划同步时间
+	Deferred   bool      `json:"deferred"`    // 是否因静默时段被推迟
+	Running    bool      `json:"running"`     // 是否正在同步
+}
+
+// scheduleEntry 调度表中的一项
+type scheduleEntry struct {
+	accountUID string
+	interval   time.Duration
+	lastSyncAt *time.Time // 账户记录中的 LastSyncAt（只在同步成功后推进）
+	lastRunAt  *time.Time // 本调度器最近一次执行同步的时间（含失败）
+	nextRunAt  time.Time
+	deferred   bool
+	running    bool
+}
+
+// SyncScheduler 按账户同步间隔调度的同步调度器
+// 根据 LastSyncAt 和 SyncInterval 为每个账户计算下次同步时间，
+// 账户新增或修改时通过 Notify 立即重新计算
+type SyncScheduler struct {
+	accountRepo repository.AccountRepository
+	run         func(ctx context.Context, accountUID string) error
+	options     SchedulerOptions
+	quietStart  time.Duration
+	quietEnd    time.Duration
+	quietActive bool
+
+	mu      sync.Mutex
+	entries map[string]*scheduleEntry
+	dirty   bool
+	rand    *rand.Rand
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewSyncScheduler 创建同步调度器
+// run 为到期账户执行同步的函数，由调度器在独立的 goroutine 中调用
+func NewSyncScheduler(
+	accountRepo repository.AccountRepository,
+	run func(ctx context.Context, accountUID string) error,
+	options SchedulerOptions,
+) (*SyncScheduler, error) {
+	if options.Location == nil {
+		options.Location = time.Local
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultMaxBackoff
+	}
+
+	s := &SyncScheduler{
+		accountRepo: accountRepo,
+		run:         run,
+		options:     options,
+		entries:     make(map[string]*scheduleEntry),
+		dirty:       true,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		wake:        make(chan struct{}, 1),
+	}
+
+	if options.QuietHours != "" {
+		start, end, err := parseQuietHours(options.QuietHours)
+		if err != nil {
+			return nil, err
+		}
+		s.quietStart, s.quietEnd, s.quietActive = start, end, true
+	}
+
+	return s, nil
+}
+
+// Start 启动调度循环
+func (s *SyncScheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+
+	go s.loop(ctx)
+}
+
+// Stop 停止调度循环（不会中断正在进行的同步）
+func (s *SyncScheduler) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Notify 通知调度器账户发生变化（新增、修改间隔、启用/禁用、删除）
+func (s *SyncScheduler) Notify(accountUID string) {
+	s.mu.Lock()
+	s.dirty = true
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Schedule 返回所有账户的调度信息
+func (s *SyncScheduler) Schedule() map[string]ScheduleInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]ScheduleInfo, len(s.entries))
+	for uid, entry := range s.entries {
+		result[uid] = ScheduleInfo{
+			AccountUID: uid,
+			NextRunAt:  entry.nextRunAt,
+			Deferred:   entry.deferred,
+			Running:    entry.running,
+		}
+	}
+	return result
+}
+
+// loop 调度主循环
+func (s *SyncScheduler) loop(ctx context.Context) {
+	defer close(s.done)
+
+	lastRefresh := time.Time{}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-s.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		now := time.Now()
+		if s.needsRefresh(now, lastRefresh) {
+			if err := s.refresh(ctx, now); err != nil {
+				log.Printf("Failed to refresh sync schedule: %v", err)
+			} else {
+				lastRefresh = now
+			}
+		}
+
+		for _, uid := range s.takeDue(now) {
+			go s.execute(ctx, uid)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(s.nextWait(time.Now()))
+	}
+}
+
+// needsRefresh 判断是否需要从数据库刷新账户列表
+func (s *SyncScheduler) needsRefresh(now, lastRefresh time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dirty || now.Sub(lastRefresh) >= schedulerRefreshInterval
+}
+
+// refresh 从数据库加载启用同步的账户并更新调度表
+func (s *SyncScheduler) refresh(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	s.dirty = false
+	s.mu.Unlock()
+
+	accounts, err := s.accountRepo.ListSyncEnabled(ctx)
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("failed to list sync enabled accounts: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		seen[account.UID] = true
+		interval := backoffInterval(accountSyncInterval(account), account.ConsecutiveFailures, s.options.MaxBackoff)
+
+		entry, ok := s.entries[account.UID]
+		if ok && entry.interval == interval && sameTime(entry.lastSyncAt, account.LastSyncAt) {
+			continue // 调度参数未变化，保留已计算的时间（含抖动）
+		}
+		if !ok {
+			entry = &scheduleEntry{accountUID: account.UID}
+			s.entries[account.UID] = entry
+		}
+
+		entry.interval = interval
+		entry.lastSyncAt = account.LastSyncAt
+		if !entry.running {
+			entry.nextRunAt, entry.deferred = s.nextRunAt(latestTime(account.LastSyncAt, entry.lastRunAt), interval, now)
+		}
+	}
+
+	// 移除已删除、禁用或停止同步的账户
+	for uid, entry := range s.entries {
+		if !seen[uid] && !entry.running {
+			delete(s.entries, uid)
+		}
+	}
+
+	return nil
+}
+
+// takeDue 取出已到期的账户并标记为运行中
+func (s *SyncScheduler) takeDue(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []string
+	for uid, entry := range s.entries {
+		if entry.running || entry.nextRunAt.After(now) {
+			continue
+		}
+		entry.running = true
+		due = append(due, uid)
+	}
+	sort.Strings(due)
+	return due
+}
+
+// execute 执行一次计划同步并重新计算下次时间
+func (s *SyncScheduler) execute(ctx context.Context, accountUID string) {
+	if err := s.run(ctx, accountUID); err != nil {
+		log.Printf("Scheduled sync failed for account %s: %v", accountUID, err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if entry, ok := s.entries[accountUID]; ok {
+		entry.running = false
+		entry.lastRunAt = &now
+		entry.nextRunAt, entry.deferred = s.nextRunAt(&now, entry.interval, now)
+	}
+	// 同步会更新账户记录，下一轮刷新时以数据库为准
+	s.dirty = true
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// nextWait 计算距离最早一次计划同步的等待时间
+func (s *SyncScheduler) nextWait(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := schedulerRefreshInterval
+	for _, entry := range s.entries {
+		if entry.running {
+			continue
+		}
+		if d := entry.nextRunAt.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// nextRunAt 根据上次同步时间和间隔计算下次同步时间（调用方需持有锁）
+// 返回值 deferred 表示时间因静默时段被推迟
+func (s *SyncScheduler) nextRunAt(lastSyncAt *time.Time, interval time.Duration, now time.Time) (time.Time, bool) {
+	next := now
+	if lastSyncAt != nil {
+		next = lastSyncAt.Add(interval)
+		if next.Before(now) {
+			next = now
+		}
+	}
+
+	if s.options.Jitter > 0 {
+		next = next.Add(time.Duration(s.rand.Int63n(int64(s.options.Jitter))))
+	}
+
+	if s.quietActive && inQuietHours(next, s.quietStart, s.quietEnd, s.options.Location) {
+		next = quietHoursEnd(next, s.quietStart, s.quietEnd, s.options.Location)
+		if s.options.Jitter > 0 {
+			next = next.Add(time.Duration(s.rand.Int63n(int64(s.options.Jitter))))
+		}
+		return next, true
+	}
+
+	return next, false
+}
+
+// accountSyncInterval 返回账户的同步间隔
+func accountSyncInterval(account *model.Account) time.Duration {
+	if account.SyncInterval <= 0 {
+		return defaultSyncInterval
+	}
+	return time.Duration(account.SyncInterval) * time.Minute
+}
+
+// backoffInterval 根据连续失败次数计算退避后的同步间隔（interval * 2^failures，不超过上限）
+func backoffInterval(interval time.Duration, failures int, maxBackoff time.Duration) time.Duration {
+	if maxBackoff < interval {
+		maxBackoff = interval
+	}
+	for i := 0; i < failures && interval < maxBackoff; i++ {
+		interval *= 2
+	}
+	if interval > maxBackoff {
+		interval = maxBackoff
+	}
+	return interval
+}
+
+// latestTime 返回两个时间中较晚的一个（nil 视为最早）
+func latestTime(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
+
+// sameTime 比较两个可能为空的时间
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// parseQuietHours 解析静默时段，返回相对于零点的起止偏移
+func parseQuietHours(value string) (time.Duration, time.Duration, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q, expected HH:MM-HH:MM", value)
+	}
+
+	start, err := parseClock(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid quiet hours start: %w", err)
+	}
+	end, err := parseClock(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid quiet hours end: %w", err)
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q, start equals end", value)
+	}
+
+	return start, end, nil
+}
+
+// parseClock 解析 HH:MM 格式的时刻
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// inQuietHours 判断时间是否处于静默时段（支持跨零点，如 23:00-07:00）
+func inQuietHours(t time.Time, start, end time.Duration, loc *time.Location) bool {
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	offset := local.Sub(midnight)
+
+	if start < end {
+		return offset >= start && offset < end
+	}
+	return offset >= start || offset < end
+}
+
+// quietHoursEnd 返回 t 所在静默时段的结束时间
+func quietHoursEnd(t time.Time, start, end time.Duration, loc *time.Location) time.Time {
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	if start > end && local.Sub(midnight) >= start {
+		// 跨零点的静默时段，结束时间在第二天
+		midnight = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return midnight.Add(end)
+}
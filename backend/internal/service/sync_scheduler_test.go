@@ -0,0 +1,143 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseQuietHours 测试静默时段解析
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantStart time.Duration
+		wantEnd   time.Duration
+		wantErr   bool
+	}{
+		{value: "23:00-07:00", wantStart: 23 * time.Hour, wantEnd: 7 * time.Hour},
+		{value: "12:30 - 13:45", wantStart: 12*time.Hour + 30*time.Minute, wantEnd: 13*time.Hour + 45*time.Minute},
+		{value: "23:00", wantErr: true},
+		{value: "25:00-07:00", wantErr: true},
+		{value: "08:00-08:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		start, end, err := parseQuietHours(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseQuietHours(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (start != tt.wantStart || end != tt.wantEnd) {
+			t.Errorf("parseQuietHours(%q) = %v, %v, want %v, %v", tt.value, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+// TestSchedulerNextRunAt 测试下次同步时间计算
+func TestSchedulerNextRunAt(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := NewSyncScheduler(nil, nil, SchedulerOptions{QuietHours: "23:00-07:00", Location: time.UTC})
+	if err != nil {
+		t.Fatalf("NewSyncScheduler() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		lastSyncAt   *time.Time
+		interval     time.Duration
+		now          time.Time
+		want         time.Time
+		wantDeferred bool
+	}{
+		{
+			name:     "从未同步 - 立即执行",
+			interval: 5 * time.Minute,
+			now:      now,
+			want:     now,
+		},
+		{
+			name:       "按账户间隔计算",
+			lastSyncAt: timePtr(now.Add(-10 * time.Minute)),
+			interval:   30 * time.Minute,
+			now:        now,
+			want:       now.Add(20 * time.Minute),
+		},
+		{
+			name:       "已过期 - 立即执行",
+			lastSyncAt: timePtr(now.Add(-time.Hour)),
+			interval:   15 * time.Minute,
+			now:        now,
+			want:       now,
+		},
+		{
+			name:         "落入跨零点的静默时段 - 推迟到次日结束",
+			lastSyncAt:   timePtr(time.Date(2025, 6, 1, 22, 50, 0, 0, time.UTC)),
+			interval:     30 * time.Minute,
+			now:          time.Date(2025, 6, 1, 22, 55, 0, 0, time.UTC),
+			want:         time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC),
+			wantDeferred: true,
+		},
+		{
+			name:         "凌晨处于静默时段 - 推迟到当日结束",
+			lastSyncAt:   timePtr(time.Date(2025, 6, 2, 1, 0, 0, 0, time.UTC)),
+			interval:     5 * time.Minute,
+			now:          time.Date(2025, 6, 2, 1, 10, 0, 0, time.UTC),
+			want:         time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC),
+			wantDeferred: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, deferred := s.nextRunAt(tt.lastSyncAt, tt.interval, tt.now)
+			if !got.Equal(tt.want) || deferred != tt.wantDeferred {
+				t.Errorf("nextRunAt() = %v, %v, want %v, %v", got, deferred, tt.want, tt.wantDeferred)
+			}
+		})
+	}
+}
+
+// TestSchedulerJitter 测试随机抖动不超过上限
+func TestSchedulerJitter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := NewSyncScheduler(nil, nil, SchedulerOptions{Jitter: 30 * time.Second})
+	if err != nil {
+		t.Fatalf("NewSyncScheduler() error = %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		got, _ := s.nextRunAt(nil, 5*time.Minute, now)
+		if got.Before(now) || !got.Before(now.Add(30*time.Second)) {
+			t.Fatalf("nextRunAt() = %v, want within [%v, %v)", got, now, now.Add(30*time.Second))
+		}
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// TestBackoffInterval 测试连续失败的指数退避
+func TestBackoffInterval(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 5 * time.Minute},
+		{1, 10 * time.Minute},
+		{3, 40 * time.Minute},
+		{10, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := backoffInterval(5*time.Minute, tt.failures, time.Hour); got != tt.want {
+			t.Errorf("backoffInterval(5m, %d, 1h) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	// 上限小于同步间隔时不缩短间隔
+	if got := backoffInterval(2*time.Hour, 3, time.Hour); got != 2*time.Hour {
+		t.Errorf("backoffInterval(2h, 3, 1h) = %v, want 2h", got)
+	}
+}
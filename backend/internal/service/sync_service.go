@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -11,21 +12,45 @@ import (
 	"fusionmail/internal/model"
 	"fusionmail/internal/repository"
 	"fusionmail/pkg/crypto"
+	"fusionmail/pkg/event"
+)
+
+// syncBatchSize 同步时每个写入事务包含的邮件数
+const syncBatchSize = 100
+
+var (
+	// ErrAccountNotFound 要同步的账户不存在
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrAccountNotSyncable 账户未启用、禁用了同步或配置无效，重试无法恢复
+	ErrAccountNotSyncable = errors.New("account cannot be synced")
 )
 
 // SyncService 邮件同步服务接口
 type SyncService interface {
-	// SyncAccount 同步指定账户的邮件
-	SyncAccount(ctx context.Context, accountUID string) error
+	// SyncAccount 提交指定账户的同步，立即返回同步任务（不等待同步完成）
+	SyncAccount(ctx context.Context, accountUID string) (*SyncProgress, error)
 
 	// SyncAllAccounts 同步所有启用的账户
 	SyncAllAccounts(ctx context.Context) error
 
+	// ExecuteSync 在当前进程直接执行一次同步（不经过执行器和任务队列，供 worker 使用）
+	ExecuteSync(ctx context.Context, accountUID string, syncType string) error
+
 	// StartScheduler 启动定时同步调度器
 	StartScheduler(ctx context.Context) error
 
 	// StopScheduler 停止定时同步调度器
 	StopScheduler() error
+
+	// NotifyAccountChanged 通知调度器账户已变更（新增、修改同步间隔、启用/禁用、删除）
+	NotifyAccountChanged(accountUID string)
+
+	// Schedule 获取各账户的调度信息（下次同步时间等）
+	Schedule() map[string]ScheduleInfo
+
+	// Progress 获取同步任务进度跟踪器
+	Progress() *SyncProgressTracker
 }
 
 // syncService 邮件同步服务实现
@@ -35,51 +60,187 @@ type syncService struct {
 	syncLogRepo    repository.SyncLogRepository
 	adapterFactory *adapter.Factory
 	encryptor      crypto.Encryptor
-	schedulerStop  chan struct{}
+	scheduler      *SyncScheduler
+	executor       *SyncExecutor
+	taskQueue      *SyncTaskQueue
+	progress       *SyncProgressTracker
+	events         EventPublisher
+	attachments    *AttachmentService
+	threads        ThreadService
+	rawMessages    *RawMessageService
+
+	failureThreshold int              // 连续失败多少次后隔离账户
+	reconcileOptions ReconcileOptions // 源邮箱对账配置
 }
 
 // NewSyncService 创建邮件同步服务实例
+// events 可以为 nil，此时不发布同步和新邮件事件；attachments 可以为 nil，此时不保存附件内容；
+// threads 可以为 nil，此时新邮件不归入会话；rawMessages 可以为 nil，此时不归档邮件原文
 func NewSyncService(
 	accountRepo repository.AccountRepository,
 	emailRepo repository.EmailRepository,
 	syncLogRepo repository.SyncLogRepository,
 	adapterFactory *adapter.Factory,
-) SyncService {
+	schedulerOptions SchedulerOptions,
+	executorOptions ExecutorOptions,
+	reconcileOptions ReconcileOptions,
+	events EventPublisher,
+	attachments *AttachmentService,
+	threads ThreadService,
+	rawMessages *RawMessageService,
+) (SyncService, error) {
+	if reconcileOptions.Policy == "" {
+		reconcileOptions.Policy = SourceDeletePolicyKeep
+	}
+	if !validSourceDeletePolicy(reconcileOptions.Policy) {
+		return nil, fmt.Errorf("invalid source delete policy: %s", reconcileOptions.Policy)
+	}
+
 	encryptor, _ := crypto.NewEncryptor()
-	return &syncService{
+	s := &syncService{
 		accountRepo:    accountRepo,
 		emailRepo:      emailRepo,
 		syncLogRepo:    syncLogRepo,
 		adapterFactory: adapterFactory,
 		encryptor:      encryptor,
+		events:         events,
+		attachments:    attachments,
+		threads:        threads,
+		rawMessages:    rawMessages,
+
+		failureThreshold: schedulerOptions.FailureThreshold,
+		reconcileOptions: reconcileOptions,
+	}
+	if s.failureThreshold <= 0 {
+		s.failureThreshold = defaultFailureThreshold
+	}
+
+	// 所有同步都经过执行器，保证并发有界且同一账户不会并发同步
+	s.executor = NewSyncExecutor(s.syncAccount, executorOptions)
+	s.taskQueue = executorOptions.TaskQueue
+	s.progress = executorOptions.Progress
+	if s.progress == nil {
+		s.progress = NewSyncProgressTracker(nil)
+	}
+
+	scheduler, err := NewSyncScheduler(accountRepo, func(ctx context.Context, accountUID string) error {
+		if s.taskQueue != nil {
+			return s.enqueue(ctx, accountUID, "scheduled")
+		}
+		return s.executor.Run(ctx, accountUID, "scheduled")
+	}, schedulerOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sync scheduler: %w", err)
+	}
+	s.scheduler = scheduler
+
+	return s, nil
+}
+
+// SyncAccount 提交指定账户的同步，返回可查询进度和取消的同步任务，账户不存在时返回 ErrAccountNotFound
+// 账户正在同步时不会并发执行，而是合并为一次后续同步；已有排队中的任务时返回该任务
+func (s *syncService) SyncAccount(ctx context.Context, accountUID string) (*SyncProgress, error) {
+	account, err := s.accountRepo.FindByUID(ctx, accountUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find account: %w", err)
+	}
+	if account == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAccountNotFound, accountUID)
+	}
+
+	job, err := s.progress.Create(ctx, accountUID, "manual")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sync job: %w", err)
+	}
+
+	if s.taskQueue != nil {
+		if err := s.enqueue(ctx, accountUID, "manual"); err != nil {
+			return nil, err
+		}
+		return job, nil
+	}
+
+	go func() {
+		// 同步未能开始（其他实例正在同步该账户、执行器已停止）时标记任务失败
+		err := s.executor.Run(context.Background(), accountUID, "manual")
+		if errors.Is(err, ErrSyncLocked) || errors.Is(err, ErrExecutorStopped) {
+			s.progress.Abandon(context.Background(), accountUID, err)
+		}
+	}()
+	return job, nil
+}
+
+// ExecuteSync 在当前进程直接执行一次同步
+func (s *syncService) ExecuteSync(ctx context.Context, accountUID string, syncType string) error {
+	return s.syncAccount(ctx, accountUID, syncType)
+}
+
+// exclusive 在账户同步锁保护下执行 fn（回填写入与同步互斥）
+func (s *syncService) exclusive(ctx context.Context, accountUID string, fn func(ctx context.Context) error) error {
+	return s.executor.Exclusive(ctx, accountUID, fn)
+}
+
+// enqueue 投递同步任务到 Redis 队列
+func (s *syncService) enqueue(ctx context.Context, accountUID string, syncType string) error {
+	enqueued, err := s.taskQueue.Enqueue(ctx, accountUID, syncType)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue sync task: %w", err)
+	}
+	if !enqueued {
+		log.Printf("Sync task for account %s is already pending, skipped", accountUID)
+	}
+	return nil
+}
+
+// syncAccount 同步指定账户的邮件，syncType 为 manual 或 scheduled
+// 同步过程登记为同步任务，可通过任务 ID 查看进度或取消
+func (s *syncService) syncAccount(ctx context.Context, accountUID string, syncType string) error {
+	ctx, job := s.progress.Begin(ctx, accountUID, syncType)
+	if job == nil {
+		log.Printf("Sync job for account %s was cancelled before it started", accountUID)
+		return nil
+	}
+
+	err := s.runSync(ctx, accountUID, syncType, job)
+	s.progress.Finish(ctx, job, err)
+
+	if syncType == "manual" {
+		// 手动同步可能更新了 LastSyncAt，据此重新计算下次定时同步时间
+		s.scheduler.Notify(accountUID)
+	}
+
+	// 用户取消不视为失败，不触发重试
+	if errors.Is(context.Cause(ctx), errSyncJobCancelled) {
+		return nil
 	}
+	return err
 }
 
-// SyncAccount 同步指定账户的邮件
-func (s *syncService) SyncAccount(ctx context.Context, accountUID string) error {
+// runSync 执行一次账户同步并记录同步日志和账户同步状态
+func (s *syncService) runSync(ctx context.Context, accountUID string, syncType string, job *SyncProgress) error {
 	// 获取账户信息
 	account, err := s.accountRepo.FindByUID(ctx, accountUID)
 	if err != nil {
 		return fmt.Errorf("failed to find account: %w", err)
 	}
 	if account == nil {
-		return fmt.Errorf("account not found: %s", accountUID)
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, accountUID)
 	}
 
-	// 检查账户状态
-	if account.Status != "active" {
-		return fmt.Errorf("account is not active (status: %s): %s", account.Status, accountUID)
+	// 检查账户状态（被自动隔离的账户允许手动同步，成功后自动恢复）
+	if account.Status != "active" && !(account.Status == "error" && syncType == "manual") {
+		return fmt.Errorf("%w: account is not active (status: %s): %s", ErrAccountNotSyncable, account.Status, accountUID)
 	}
 
 	// 检查是否启用同步
 	if !account.SyncEnabled {
-		return fmt.Errorf("sync is disabled for account: %s", accountUID)
+		return fmt.Errorf("%w: sync is disabled for account: %s", ErrAccountNotSyncable, accountUID)
 	}
 
 	// 创建同步日志
 	syncLog := &model.SyncLog{
 		AccountUID: accountUID,
-		SyncType:   "manual",
+		SyncType:   syncType,
 		Status:     "running",
 		StartedAt:  time.Now(),
 	}
@@ -87,12 +248,21 @@ func (s *syncService) SyncAccount(ctx context.Context, accountUID string) error
 	if err := s.syncLogRepo.Create(ctx, syncLog); err != nil {
 		log.Printf("Failed to create sync log: %v", err)
 	}
+	publishEvent(ctx, s.events, event.SyncEvent(event.EventSyncStarted, accountUID, syncType, map[string]interface{}{
+		"job_id":      job.JobID,
+		"sync_log_id": syncLog.ID,
+	}))
 
 	// 执行同步
-	err = s.doSync(ctx, account, syncLog)
+	err = s.doSync(ctx, account, syncLog, job)
+	cancelled := errors.Is(context.Cause(ctx), errSyncJobCancelled)
 
 	// 更新同步日志
-	if err != nil {
+	if cancelled {
+		syncLog.Status = "cancelled"
+		syncLog.ErrorMessage = "cancelled by user"
+		log.Printf("Sync cancelled for account %s", accountUID)
+	} else if err != nil {
 		syncLog.Status = "failed"
 		syncLog.ErrorMessage = err.Error()
 		log.Printf("Sync failed for account %s: %v", accountUID, err)
@@ -105,29 +275,87 @@ func (s *syncService) SyncAccount(ctx context.Context, accountUID string) error
 	syncLog.CompletedAt = &completedAt
 	syncLog.DurationMs = int(time.Since(syncLog.StartedAt).Milliseconds())
 
-	// 更新账户同步状态
-	account.LastSyncAt = &completedAt
+	// 更新账户同步状态（LastSyncAt 是下次增量同步的起点，只在成功后推进，失败时下次重新拉取这段时间的邮件）
+	if err == nil && !cancelled {
+		account.LastSyncAt = &completedAt
+	}
 	account.LastSyncStatus = syncLog.Status
 	account.LastSyncError = syncLog.ErrorMessage
-	if err := s.accountRepo.Update(ctx, account); err != nil {
+	if !cancelled {
+		s.recordSyncResult(account, err, completedAt)
+	}
+	if syncLog.ID != 0 {
+		if err := s.syncLogRepo.Update(context.WithoutCancel(ctx), syncLog); err != nil {
+			log.Printf("Failed to update sync log: %v", err)
+		}
+	}
+	if err := s.accountRepo.UpdateSyncResult(context.WithoutCancel(ctx), account); err != nil {
 		log.Printf("Failed to update account sync status: %v", err)
 	}
 
+	s.publishSyncResult(ctx, syncLog, job)
 	return err
 }
 
-// doSync 执行实际的同步逻辑
-func (s *syncService) doSync(ctx context.Context, account *model.Account, syncLog *model.SyncLog) error {
+// publishSyncResult 发布同步结束事件（成功为 sync.completed，失败和取消为 sync.failed）
+func (s *syncService) publishSyncResult(ctx context.Context, syncLog *model.SyncLog, job *SyncProgress) {
+	data := map[string]interface{}{
+		"job_id":                job.JobID,
+		"sync_log_id":           syncLog.ID,
+		"status":                syncLog.Status,
+		"emails_fetched":        syncLog.EmailsFetched,
+		"emails_new":            syncLog.EmailsNew,
+		"emails_updated":        syncLog.EmailsUpdated,
+		"emails_source_deleted": syncLog.EmailsSourceDeleted,
+		"emails_moved":          syncLog.EmailsMoved,
+		"duration_ms":           syncLog.DurationMs,
+	}
+
+	eventType := event.EventSyncCompleted
+	if syncLog.Status != "success" {
+		eventType = event.EventSyncFailed
+		data["error"] = syncLog.ErrorMessage
+	}
+	publishEvent(ctx, s.events, event.SyncEvent(eventType, syncLog.AccountUID, syncLog.SyncType, data))
+}
+
+// recordSyncResult 记录同步结果：失败时累加连续失败次数并分类原因，
+// 超过阈值后将账户隔离（status=error）；成功时清零并恢复被隔离的账户
+func (s *syncService) recordSyncResult(account *model.Account, syncErr error, now time.Time) {
+	if syncErr == nil {
+		if account.Status == "error" {
+			log.Printf("Account %s recovered after %d consecutive failures", account.UID, account.ConsecutiveFailures)
+			account.Status = "active"
+		}
+		account.ConsecutiveFailures = 0
+		account.SyncErrorCode = ""
+		account.QuarantinedAt = nil
+		return
+	}
+
+	account.ConsecutiveFailures++
+	account.SyncErrorCode = string(adapter.Classify(syncErr))
+
+	if account.Status == "active" && account.ConsecutiveFailures >= s.failureThreshold {
+		account.Status = "error"
+		account.QuarantinedAt = &now
+		log.Printf("Account %s quarantined after %d consecutive failures (%s)",
+			account.UID, account.ConsecutiveFailures, account.SyncErrorCode)
+	}
+}
+
+// newProvider 根据账户配置创建邮箱适配器（未连接）
+func (s *syncService) newProvider(account *model.Account) (adapter.MailProvider, error) {
 	// 解析认证凭证
 	credentials, err := s.parseCredentials(account)
 	if err != nil {
-		return fmt.Errorf("failed to parse credentials: %w", err)
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
 	}
 
 	// 解析代理配置
 	proxy, err := s.parseProxyConfig(account)
 	if err != nil {
-		return fmt.Errorf("failed to parse proxy config: %w", err)
+		return nil, fmt.Errorf("failed to parse proxy config: %w", err)
 	}
 
 	// 创建适配器
@@ -138,7 +366,17 @@ func (s *syncService) doSync(ctx context.Context, account *model.Account, syncLo
 		proxy,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create adapter: %w", err)
+		return nil, fmt.Errorf("failed to create adapter: %w", err)
+	}
+
+	return provider, nil
+}
+
+// doSync 执行实际的同步逻辑
+func (s *syncService) doSync(ctx context.Context, account *model.Account, syncLog *model.SyncLog, job *SyncProgress) error {
+	provider, err := s.newProvider(account)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrAccountNotSyncable, err)
 	}
 
 	// 连接到邮箱服务器
@@ -147,6 +385,10 @@ func (s *syncService) doSync(ctx context.Context, account *model.Account, syncLo
 	}
 	defer provider.Disconnect()
 
+	s.progress.Update(ctx, job, func(p *SyncProgress) {
+		p.Phase = SyncPhaseFetching
+	})
+
 	// 确定同步起始时间（增量同步）
 	since := time.Time{}
 	if account.LastSyncAt != nil {
@@ -166,45 +408,153 @@ func (s *syncService) doSync(ctx context.Context, account *model.Account, syncLo
 	}
 
 	syncLog.EmailsFetched = len(emails)
+	s.progress.Update(ctx, job, func(p *SyncProgress) {
+		p.Phase = SyncPhaseStoring
+		p.EmailsFetched = len(emails)
+	})
 
-	// 处理邮件
-	for _, email := range emails {
-		if err := s.processEmail(ctx, account.UID, email, syncLog); err != nil {
-			log.Printf("Failed to process email %s: %v", email.ProviderID, err)
-			continue
+	failed, err := s.storeEmails(ctx, account, emails, syncLog, job)
+	if err != nil {
+		return err
+	}
+
+	// 定期对账：发现已在源邮箱删除或移动的邮件
+	if lister, ok := provider.(adapter.SourceLister); ok && s.reconcileDue(account, time.Now()) {
+		s.progress.Update(ctx, job, func(p *SyncProgress) {
+			p.Phase = SyncPhaseReconciling
+		})
+		if err := s.reconcileSource(ctx, account, lister, syncLog); err != nil {
+			// 对账失败不影响本次同步结果，下次同步时重试
+			log.Printf("Failed to reconcile account %s: %v", account.UID, err)
 		}
 	}
 
+	if failed > 0 {
+		// 未写入的邮件需要下次同步重新拉取，因此本次同步视为失败，不推进 LastSyncAt
+		return fmt.Errorf("failed to store %d of %d emails", failed, len(emails))
+	}
 	return nil
 }
 
-// processEmail 处理单封邮件
-func (s *syncService) processEmail(ctx context.Context, accountUID string, adapterEmail *adapter.Email, syncLog *model.SyncLog) error {
-	// 检查邮件是否已存在
-	existingEmail, err := s.emailRepo.FindByProviderID(ctx, adapterEmail.ProviderID, accountUID)
+// storeEmails 分批写入邮件，每批一个事务，返回写入失败的邮件数
+// 整批写入失败（事务回滚）时逐封重试，避免一封有问题的邮件连累同批的其他邮件
+func (s *syncService) storeEmails(ctx context.Context, account *model.Account, emails []*adapter.Email, syncLog *model.SyncLog, job *SyncProgress) (int, error) {
+	failed := 0
+	for start := 0; start < len(emails); start += syncBatchSize {
+		if err := ctx.Err(); err != nil {
+			return failed, err
+		}
+
+		batch := emails[start:min(start+syncBatchSize, len(emails))]
+		var errs []error
+		if err := s.processEmails(ctx, account, batch, syncLog); err != nil {
+			log.Printf("Failed to process %d emails for account %s, retrying one by one: %v", len(batch), account.UID, err)
+			for _, email := range batch {
+				if err := s.processEmails(ctx, account, []*adapter.Email{email}, syncLog); err != nil {
+					log.Printf("Failed to store email %s for account %s: %v", email.ProviderID, account.UID, err)
+					errs = append(errs, err)
+				}
+			}
+		}
+		failed += len(errs)
+
+		s.progress.Update(ctx, job, func(p *SyncProgress) {
+			p.EmailsStored = syncLog.EmailsNew + syncLog.EmailsUpdated
+			p.EmailsNew = syncLog.EmailsNew
+			if len(errs) > 0 {
+				p.Errors += len(errs)
+				p.LastError = errs[len(errs)-1].Error()
+			}
+		})
+	}
+	return failed, nil
+}
+
+// processEmails 批量写入一批邮件
+// 一次查询墓碑、一次批量 upsert（同一事务中选定重复组），只为新邮件保存附件、归入会话和发布事件
+func (s *syncService) processEmails(ctx context.Context, account *model.Account, adapterEmails []*adapter.Email, syncLog *model.SyncLog) error {
+	// 跳过已永久删除的邮件，源邮箱中仍存在时不重新入库
+	providerIDs := make([]string, 0, len(adapterEmails))
+	for _, adapterEmail := range adapterEmails {
+		providerIDs = append(providerIDs, adapterEmail.ProviderID)
+	}
+	purged, err := s.emailRepo.FindPurgedProviderIDs(ctx, account.UID, providerIDs)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to find purged emails: %w", err)
 	}
 
-	if existingEmail != nil {
-		// 邮件已存在，更新
-		s.updateEmailFromAdapter(existingEmail, adapterEmail, accountUID)
-		if err := s.emailRepo.Update(ctx, existingEmail); err != nil {
-			return err
+	// 同一批中重复出现的邮件只保留最后一次（同一条 upsert 语句不能两次更新同一行）
+	sources := make(map[string]*adapter.Email, len(adapterEmails))
+	rows := make([]*model.Email, 0, len(adapterEmails))
+	index := make(map[string]int, len(adapterEmails))
+	for _, adapterEmail := range adapterEmails {
+		if purged[adapterEmail.ProviderID] {
+			continue
 		}
-		syncLog.EmailsUpdated++
-	} else {
-		// 新邮件，创建
-		newEmail := s.createEmailFromAdapter(adapterEmail, accountUID)
-		if err := s.emailRepo.Create(ctx, newEmail); err != nil {
-			return err
+		row := s.createEmailFromAdapter(adapterEmail, account.UID)
+		row.DedupKey = dedupKey(adapterEmail)
+		if i, ok := index[row.ProviderID]; ok {
+			rows[i] = row
+		} else {
+			index[row.ProviderID] = len(rows)
+			rows = append(rows, row)
 		}
+		sources[row.ProviderID] = adapterEmail
+	}
+
+	results, err := s.emailRepo.UpsertBatch(ctx, rows)
+	if err != nil {
+		return fmt.Errorf("failed to upsert emails: %w", err)
+	}
+
+	for _, result := range results {
+		row := rows[index[result.ProviderID]]
+		row.ID = result.ID
+
+		if !result.Inserted {
+			syncLog.EmailsUpdated++
+			// 会话功能上线前入库的邮件在内容变化或补全去重键时归入会话
+			if result.LocalThreadID == nil {
+				s.assignThread(ctx, row)
+			}
+			continue
+		}
+
 		syncLog.EmailsNew++
+
+		// 原文归档失败不影响邮件入库，只记录日志
+		if adapterEmail := sources[row.ProviderID]; s.rawMessages != nil && len(adapterEmail.RawMessage) > 0 {
+			if err := s.rawMessages.Archive(ctx, row, adapterEmail.RawMessage); err != nil {
+				log.Printf("Failed to archive raw message for email %d: %v", row.ID, err)
+			}
+		}
+
+		// 附件保存失败不影响邮件入库，只记录日志
+		if adapterEmail := sources[row.ProviderID]; s.attachments != nil && len(adapterEmail.Attachments) > 0 {
+			if err := s.attachments.SaveEmailAttachments(ctx, account, row.ID, adapterEmail.Attachments); err != nil {
+				log.Printf("Failed to save attachments for email %d: %v", row.ID, err)
+			}
+		}
+
+		s.assignThread(ctx, row)
+
+		// 入库后才有邮件 ID，规则和 Webhook 据此读取邮件
+		publishEvent(ctx, s.events, event.EmailReceivedEvent(row.ID, account.UID, row.Subject))
 	}
 
 	return nil
 }
 
+// assignThread 将邮件归入会话，失败不影响邮件入库，只记录日志
+func (s *syncService) assignThread(ctx context.Context, email *model.Email) {
+	if s.threads == nil {
+		return
+	}
+	if err := s.threads.AssignEmail(ctx, email); err != nil {
+		log.Printf("Failed to assign email %d to thread: %v", email.ID, err)
+	}
+}
+
 // createEmailFromAdapter 从适配器邮件创建数据库邮件模型
 func (s *syncService) createEmailFromAdapter(adapterEmail *adapter.Email, accountUID string) *model.Email {
 	return &model.Email{
@@ -214,15 +564,15 @@ func (s *syncService) createEmailFromAdapter(adapterEmail *adapter.Email, accoun
 		Subject:          adapterEmail.Subject,
 		FromAddress:      adapterEmail.FromAddress,
 		FromName:         adapterEmail.FromName,
-		ToAddresses:      s.joinAddresses(adapterEmail.ToAddresses),
-		CcAddresses:      s.joinAddresses(adapterEmail.CcAddresses),
-		BccAddresses:     s.joinAddresses(adapterEmail.BccAddresses),
+		ToAddresses:      joinAddresses(adapterEmail.ToAddresses),
+		CcAddresses:      joinAddresses(adapterEmail.CcAddresses),
+		BccAddresses:     joinAddresses(adapterEmail.BccAddresses),
 		ReplyTo:          adapterEmail.ReplyTo,
 		TextBody:         adapterEmail.TextBody,
 		HTMLBody:         adapterEmail.HTMLBody,
 		Snippet:          adapterEmail.Snippet,
 		SourceIsRead:     adapterEmail.SourceIsRead,
-		SourceLabels:     s.joinLabels(adapterEmail.SourceLabels),
+		SourceLabels:     joinLabels(adapterEmail.SourceLabels),
 		SourceFolder:     adapterEmail.SourceFolder,
 		HasAttachments:   adapterEmail.HasAttachments,
 		AttachmentsCount: adapterEmail.AttachmentsCount,
@@ -236,22 +586,6 @@ func (s *syncService) createEmailFromAdapter(adapterEmail *adapter.Email, accoun
 	}
 }
 
-// updateEmailFromAdapter 从适配器邮件更新数据库邮件模型
-func (s *syncService) updateEmailFromAdapter(dbEmail *model.Email, adapterEmail *adapter.Email, accountUID string) {
-	// 更新可能变化的字段
-	dbEmail.Subject = adapterEmail.Subject
-	dbEmail.TextBody = adapterEmail.TextBody
-	dbEmail.HTMLBody = adapterEmail.HTMLBody
-	dbEmail.Snippet = adapterEmail.Snippet
-	dbEmail.SourceIsRead = adapterEmail.SourceIsRead
-	dbEmail.SourceLabels = s.joinLabels(adapterEmail.SourceLabels)
-	dbEmail.SourceFolder = adapterEmail.SourceFolder
-	dbEmail.HasAttachments = adapterEmail.HasAttachments
-	dbEmail.AttachmentsCount = adapterEmail.AttachmentsCount
-	dbEmail.SizeBytes = adapterEmail.SizeBytes
-	dbEmail.SyncedAt = time.Now()
-}
-
 // SyncAllAccounts 同步所有启用的账户
 func (s *syncService) SyncAllAccounts(ctx context.Context) error {
 	// 获取所有启用同步的账户
@@ -262,54 +596,63 @@ func (s *syncService) SyncAllAccounts(ctx context.Context) error {
 
 	log.Printf("Starting sync for %d accounts", len(accounts))
 
-	// 并发同步账户
+	// 提交到执行器（或任务队列），由工作池按配置的并发数执行
 	for _, account := range accounts {
-		go func(accountUID string) {
-			if err := s.SyncAccount(ctx, accountUID); err != nil {
-				log.Printf("Failed to sync account %s: %v", accountUID, err)
+		if _, err := s.progress.Create(ctx, account.UID, "manual"); err != nil {
+			log.Printf("Failed to create sync job for account %s: %v", account.UID, err)
+		}
+		if s.taskQueue != nil {
+			if err := s.enqueue(ctx, account.UID, "manual"); err != nil {
+				return err
 			}
-		}(account.UID)
+			continue
+		}
+		if err := s.executor.Submit(account.UID, "manual"); err != nil {
+			return fmt.Errorf("failed to submit sync for account %s: %w", account.UID, err)
+		}
 	}
 
 	return nil
 }
 
 // StartScheduler 启动定时同步调度器
+// 调度器按每个账户的同步间隔计算下次同步时间，而不是统一周期同步所有账户
 func (s *syncService) StartScheduler(ctx context.Context) error {
-	s.schedulerStop = make(chan struct{})
-
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute) // 每 5 分钟检查一次
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				if err := s.SyncAllAccounts(ctx); err != nil {
-					log.Printf("Scheduled sync failed: %v", err)
-				}
-			case <-s.schedulerStop:
-				return
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
-
+	s.executor.Start(ctx)
+	s.scheduler.Start(ctx)
 	log.Println("Sync scheduler started")
 	return nil
 }
 
 // StopScheduler 停止定时同步调度器
 func (s *syncService) StopScheduler() error {
-	if s.schedulerStop != nil {
-		close(s.schedulerStop)
-		s.schedulerStop = nil
-		log.Println("Sync scheduler stopped")
-	}
+	s.scheduler.Stop()
+	s.executor.Stop()
+	log.Println("Sync scheduler stopped")
 	return nil
 }
 
+// NotifyAccountChanged 通知调度器账户已变更
+func (s *syncService) NotifyAccountChanged(accountUID string) {
+	s.scheduler.Notify(accountUID)
+}
+
+// Schedule 获取各账户的调度信息
+func (s *syncService) Schedule() map[string]ScheduleInfo {
+	schedule := s.scheduler.Schedule()
+	for uid, info := range schedule {
+		// 手动触发的同步不经过调度器，以执行器的状态为准
+		info.Running = s.executor.IsRunning(uid)
+		schedule[uid] = info
+	}
+	return schedule
+}
+
+// Progress 获取同步任务进度跟踪器
+func (s *syncService) Progress() *SyncProgressTracker {
+	return s.progress
+}
+
 // 辅助方法
 
 // parseCredentials 解析认证凭证
@@ -349,6 +692,8 @@ func (s *syncService) parseCredentials(account *model.Account) (*adapter.Credent
 		credentials.Host = "outlook.office365.com"
 		credentials.Port = 993
 		credentials.TLS = true
+	case "demo":
+		// 演示邮箱不连接服务器，无需主机配置
 	case "generic":
 		// 使用用户配置的服务器信息
 		if account.Protocol == "imap" {
@@ -406,7 +751,7 @@ func (s *syncService) parseProxyConfig(account *model.Account) (*adapter.ProxyCo
 }
 
 // joinAddresses 将地址列表转换为 JSON 字符串
-func (s *syncService) joinAddresses(addresses []string) string {
+func joinAddresses(addresses []string) string {
 	if len(addresses) == 0 {
 		return ""
 	}
@@ -415,7 +760,7 @@ func (s *syncService) joinAddresses(addresses []string) string {
 }
 
 // joinLabels 将标签列表转换为 JSON 字符串
-func (s *syncService) joinLabels(labels []string) string {
+func joinLabels(labels []string) string {
 	if len(labels) == 0 {
 		return ""
 	}
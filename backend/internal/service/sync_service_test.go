@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+)
+
+// TestStoreEmailsRetriesFailedBatch 测试整批写入失败后逐封重试，只有无法写入的邮件计为失败
+func TestStoreEmailsRetriesFailedBatch(t *testing.T) {
+	repo := &fakeUpsertRepo{stored: make(map[string]bool)}
+	s := &syncService{emailRepo: repo, progress: NewSyncProgressTracker(nil)}
+	ctx := context.Background()
+
+	var emails []*adapter.Email
+	for i := 0; i < syncBatchSize+3; i++ {
+		emails = append(emails, &adapter.Email{ProviderID: fmt.Sprintf("msg-%d", i), MessageID: fmt.Sprintf("<%d@example.com>", i)})
+	}
+	emails[1].ProviderID = "bad"
+
+	syncLog := &model.SyncLog{}
+	failed, err := s.storeEmails(ctx, &model.Account{UID: "acc-1"}, emails, syncLog, &SyncProgress{})
+	if err != nil {
+		t.Fatalf("storeEmails() error = %v", err)
+	}
+	if failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+	if syncLog.EmailsNew != len(emails)-1 || len(repo.stored) != len(emails)-1 {
+		t.Errorf("emails new = %d, stored = %d, want %d", syncLog.EmailsNew, len(repo.stored), len(emails)-1)
+	}
+	if repo.stored["bad"] {
+		t.Error("bad email stored")
+	}
+}
+
+// TestProcessEmailsSkipsPurged 测试已永久删除的邮件不会被同步重新写入
+func TestProcessEmailsSkipsPurged(t *testing.T) {
+	repo := &fakeUpsertRepo{stored: make(map[string]bool), purged: map[string]bool{"msg-1": true}}
+	s := &syncService{emailRepo: repo, progress: NewSyncProgressTracker(nil)}
+
+	emails := []*adapter.Email{
+		{ProviderID: "msg-1", MessageID: "<1@example.com>"},
+		{ProviderID: "msg-2", MessageID: "<2@example.com>"},
+	}
+	syncLog := &model.SyncLog{}
+	if err := s.processEmails(context.Background(), &model.Account{UID: "acc-1"}, emails, syncLog); err != nil {
+		t.Fatalf("processEmails() error = %v", err)
+	}
+	if repo.stored["msg-1"] || !repo.stored["msg-2"] || syncLog.EmailsNew != 1 {
+		t.Errorf("stored = %v, emails new = %d, want only msg-2", repo.stored, syncLog.EmailsNew)
+	}
+}
+
+// TestSyncAccountNotFound 测试不存在的账户不会创建同步任务
+func TestSyncAccountNotFound(t *testing.T) {
+	progress := NewSyncProgressTracker(nil)
+	s := &syncService{accountRepo: &fakeAccountRepo{account: &model.Account{UID: "acc-1"}}, progress: progress}
+	ctx := context.Background()
+
+	if _, err := s.SyncAccount(ctx, "missing"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("SyncAccount() error = %v, want ErrAccountNotFound", err)
+	}
+	if _, err := progress.Latest(ctx, "missing"); !errors.Is(err, ErrSyncJobNotFound) {
+		t.Errorf("Latest() error = %v, want ErrSyncJobNotFound", err)
+	}
+}
+
+// fakeUpsertRepo 模拟批量写入：包含 ProviderID 为 bad 的批次整体失败
+type fakeUpsertRepo struct {
+	repository.EmailRepository
+	stored map[string]bool
+	purged map[string]bool
+	nextID int64
+}
+
+func (r *fakeUpsertRepo) FindPurgedProviderIDs(ctx context.Context, accountUID string, providerIDs []string) (map[string]bool, error) {
+	purged := make(map[string]bool)
+	for _, id := range providerIDs {
+		if r.purged[id] {
+			purged[id] = true
+		}
+	}
+	return purged, nil
+}
+
+func (r *fakeUpsertRepo) UpsertBatch(ctx context.Context, emails []*model.Email) ([]repository.UpsertResult, error) {
+	for _, email := range emails {
+		if email.ProviderID == "bad" {
+			return nil, errors.New("invalid email")
+		}
+	}
+
+	var results []repository.UpsertResult
+	for _, email := range emails {
+		r.nextID++
+		r.stored[email.ProviderID] = true
+		results = append(results, repository.UpsertResult{ID: r.nextID, ProviderID: email.ProviderID, Inserted: true})
+	}
+	return results, nil
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/pkg/queue"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// SyncQueueName 同步任务队列名称（同时作为账户锁的前缀）
+	SyncQueueName = "sync"
+
+	// TaskTypeSyncAccount 账户同步任务类型
+	TaskTypeSyncAccount = "sync_account"
+
+	// 手动同步的优先级高于定时同步
+	syncPriorityManual    = 10
+	syncPriorityScheduled = 5
+
+	// syncTaskMaxRetries 同步任务最大重试次数，超过后进入死信队列
+	syncTaskMaxRetries = 3
+
+	// syncRetryBaseDelay 重试延迟基数（按 2^n 递增）
+	syncRetryBaseDelay = 30 * time.Second
+
+	// workerHeartbeatInterval worker 心跳间隔
+	workerHeartbeatInterval = 10 * time.Second
+
+	// workerDequeueTimeout 单次阻塞取任务的超时时间
+	workerDequeueTimeout = 5 * time.Second
+)
+
+// SyncTaskQueue 同步任务队列
+// API 进程只负责投递任务，由 cmd/worker 进程消费执行
+type SyncTaskQueue struct {
+	queue *queue.RedisQueue
+}
+
+// NewSyncTaskQueue 创建同步任务队列
+func NewSyncTaskQueue(q *queue.RedisQueue) *SyncTaskQueue {
+	return &SyncTaskQueue{queue: q}
+}
+
+// Enqueue 投递账户同步任务
+// 同一账户已有待执行的任务时不重复投递，返回 false；手动同步遇到待执行的定时任务时把它提升为手动同步
+func (q *SyncTaskQueue) Enqueue(ctx context.Context, accountUID string, syncType string) (bool, error) {
+	// 待执行标记，worker 取出任务时清除；标记带过期时间，worker 异常退出时不会永久阻塞投递
+	pending, err := q.queue.SetMarker(ctx, pendingSyncKey(accountUID))
+	if err != nil {
+		return false, err
+	}
+	if !pending {
+		if syncType != "manual" {
+			return false, nil
+		}
+		return q.promote(ctx, accountUID)
+	}
+
+	priority := syncPriorityScheduled
+	if syncType == "manual" {
+		priority = syncPriorityManual
+	}
+
+	task := &queue.Task{
+		Type: TaskTypeSyncAccount,
+		Payload: map[string]interface{}{
+			"account_uid": accountUID,
+			"sync_type":   syncType,
+		},
+		Priority:   priority,
+		MaxRetries: syncTaskMaxRetries,
+	}
+	if err := q.queue.Enqueue(ctx, task); err != nil {
+		_ = q.queue.ClearMarker(ctx, pendingSyncKey(accountUID))
+		return false, err
+	}
+
+	return true, nil
+}
+
+// promote 把账户待执行的定时同步任务改为手动同步，按手动同步的优先级重新排队
+func (q *SyncTaskQueue) promote(ctx context.Context, accountUID string) (bool, error) {
+	promoted, err := q.queue.Requeue(ctx, func(task *queue.Task) bool {
+		uid, _ := task.Payload["account_uid"].(string)
+		return task.Type == TaskTypeSyncAccount && uid == accountUID && task.Priority < syncPriorityManual
+	}, func(task *queue.Task) {
+		task.Priority = syncPriorityManual
+		task.Payload["sync_type"] = "manual"
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to promote sync task: %w", err)
+	}
+	return promoted, nil
+}
+
+// SyncWorkerOptions 同步 worker 配置
+type SyncWorkerOptions struct {
+	ID      string // worker 标识，用于心跳和日志
+	Workers int    // 最大并发同步数
+}
+
+// SyncWorker 从 Redis 队列消费同步任务的 worker
+// 同步通过 SyncExecutor 执行，执行期间由执行器续期账户锁作为心跳
+type SyncWorker struct {
+	syncService SyncService
+	queue       *queue.RedisQueue
+	redis       *redis.Client
+	executor    *SyncExecutor
+	options     SyncWorkerOptions
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSyncWorker 创建同步 worker
+func NewSyncWorker(syncService SyncService, q *queue.RedisQueue, redisClient *redis.Client, options SyncWorkerOptions) *SyncWorker {
+	if options.Workers <= 0 {
+		options.Workers = defaultSyncWorkers
+	}
+
+	return &SyncWorker{
+		syncService: syncService,
+		queue:       q,
+		redis:       redisClient,
+		executor: NewSyncExecutor(syncService.ExecuteSync, ExecutorOptions{
+			Workers: options.Workers,
+			Locker:  q,
+		}),
+		options: options,
+	}
+}
+
+// Start 启动 worker
+func (w *SyncWorker) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.executor.Start(ctx)
+
+	w.wg.Add(2)
+	go w.heartbeat(ctx)
+	go w.consume(ctx)
+
+	log.Printf("Sync worker %s started with %d workers", w.options.ID, w.options.Workers)
+}
+
+// Stop 停止 worker，等待正在执行的任务退出
+func (w *SyncWorker) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+	w.executor.Stop()
+
+	if err := w.redis.Del(context.Background(), w.heartbeatKey()).Err(); err != nil {
+		log.Printf("Failed to remove worker heartbeat: %v", err)
+	}
+	log.Printf("Sync worker %s stopped", w.options.ID)
+}
+
+// consume 任务消费循环，同时处理的任务数不超过 Workers
+func (w *SyncWorker) consume(ctx context.Context) {
+	defer w.wg.Done()
+
+	slots := make(chan struct{}, w.options.Workers)
+	var tasks sync.WaitGroup
+	defer tasks.Wait()
+
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		task, err := w.queue.Dequeue(ctx, workerDequeueTimeout)
+		if err != nil {
+			<-slots
+			if ctx.Err() != nil {
+				return
+			}
+			if !errors.Is(err, redis.Nil) {
+				log.Printf("Failed to dequeue sync task: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		tasks.Add(1)
+		go func() {
+			defer tasks.Done()
+			defer func() { <-slots }()
+			w.handle(ctx, task)
+		}()
+	}
+}
+
+// handle 执行单个任务，失败时按指数退避重试，超过重试次数或无法通过重试恢复的错误进入死信队列
+func (w *SyncWorker) handle(ctx context.Context, task *queue.Task) {
+	if task.Type != TaskTypeSyncAccount {
+		task.LastError = fmt.Sprintf("unknown task type: %s", task.Type)
+		if err := w.queue.DeadLetter(ctx, task); err != nil {
+			log.Printf("Failed to dead-letter task %s: %v", task.ID, err)
+		}
+		return
+	}
+
+	accountUID, _ := task.Payload["account_uid"].(string)
+	syncType, _ := task.Payload["sync_type"].(string)
+	if syncType == "" {
+		syncType = "scheduled"
+	}
+
+	// 任务已取出，允许重新投递
+	if err := w.queue.ClearMarker(ctx, pendingSyncKey(accountUID)); err != nil {
+		log.Printf("Failed to clear pending sync marker for account %s: %v", accountUID, err)
+	}
+
+	err := w.executor.Run(ctx, accountUID, syncType)
+	switch {
+	case err == nil:
+		return
+	case errors.Is(err, ErrSyncLocked):
+		// 其他 worker 正在同步该账户，本次任务合并到正在进行的同步，对应的同步任务标记为未执行
+		w.syncService.Progress().Abandon(ctx, accountUID, err)
+		return
+	case ctx.Err() != nil:
+		// worker 正在退出，重新投递任务，不计入重试次数
+		if err := w.queue.Enqueue(context.Background(), task); err != nil {
+			log.Printf("Failed to requeue sync task for account %s: %v", accountUID, err)
+		}
+		return
+	case isPermanentSyncError(err):
+		// 认证失败、账户不存在或配置无效时重试没有意义，直接进入死信队列
+		task.LastError = err.Error()
+		if err := w.queue.DeadLetter(context.Background(), task); err != nil {
+			log.Printf("Failed to dead-letter sync task for account %s: %v", accountUID, err)
+			return
+		}
+		log.Printf("Sync task for account %s moved to dead letter queue without retry: %v", accountUID, task.LastError)
+		return
+	}
+
+	delay := syncRetryBaseDelay * time.Duration(1<<task.RetryCount)
+	deadLettered, retryErr := w.queue.Retry(context.Background(), task, err, delay)
+	if retryErr != nil {
+		log.Printf("Failed to retry sync task for account %s: %v", accountUID, retryErr)
+		return
+	}
+	if deadLettered {
+		log.Printf("Sync task for account %s moved to dead letter queue after %d attempts: %v", accountUID, task.RetryCount, err)
+	} else {
+		log.Printf("Sync task for account %s failed, retry %d/%d in %s: %v", accountUID, task.RetryCount, task.MaxRetries, delay, err)
+	}
+}
+
+// isPermanentSyncError 判断同步错误是否无法通过重试恢复（认证失败、证书错误、账户不存在或不可同步）
+func isPermanentSyncError(err error) bool {
+	if errors.Is(err, ErrAccountNotFound) || errors.Is(err, ErrAccountNotSyncable) {
+		return true
+	}
+	switch adapter.Classify(err) {
+	case adapter.ErrorKindAuth, adapter.ErrorKindTLS:
+		return true
+	}
+	return false
+}
+
+// heartbeat 定期写入 worker 心跳，便于观察存活的 worker
+func (w *SyncWorker) heartbeat(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(workerHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.redis.Set(ctx, w.heartbeatKey(), time.Now().Unix(), 3*workerHeartbeatInterval).Err(); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to write worker heartbeat: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// heartbeatKey 获取 worker 心跳键
+func (w *SyncWorker) heartbeatKey() string {
+	return "sync:worker:" + w.options.ID
+}
+
+// pendingSyncKey 获取账户待执行标记的键
+func pendingSyncKey(accountUID string) string {
+	return "pending:" + accountUID
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"fusionmail/internal/adapter"
+)
+
+// TestIsPermanentSyncError 测试哪些同步错误直接进入死信队列而不重试
+func TestIsPermanentSyncError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"account not found", fmt.Errorf("%w: acc-1", ErrAccountNotFound), true},
+		{"account disabled", fmt.Errorf("%w: sync is disabled for account: acc-1", ErrAccountNotSyncable), true},
+		{"auth", fmt.Errorf("failed to connect: %w", adapter.NewError(adapter.ErrorKindAuth, "login", errors.New("invalid credentials"))), true},
+		{"tls", fmt.Errorf("failed to connect: %w", adapter.NewError(adapter.ErrorKindTLS, "dial", errors.New("x509: unknown authority"))), true},
+		{"network", fmt.Errorf("failed to connect: %w", adapter.NewError(adapter.ErrorKindNetwork, "dial", errors.New("connection refused"))), false},
+		{"quota", adapter.NewError(adapter.ErrorKindQuota, "fetch", errors.New("rate limit exceeded")), false},
+		{"store", errors.New("failed to store 3 of 100 emails"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isPermanentSyncError(tt.err); got != tt.want {
+			t.Errorf("%s: isPermanentSyncError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
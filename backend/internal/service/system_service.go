@@ -24,10 +24,16 @@ type SystemService struct {
 	ruleRepo    repository.RuleRepository
 	webhookRepo repository.WebhookRepository
 	syncLogRepo repository.SyncLogRepository
+	scheduler   SyncScheduleProvider
 	logger      *logger.Logger
 	startTime   time.Time
 }
 
+// SyncScheduleProvider 同步调度信息提供者（由 SyncManager 实现）
+type SyncScheduleProvider interface {
+	Schedule() map[string]ScheduleInfo
+}
+
 // NewSystemService 创建系统管理服务
 func NewSystemService(
 	db *gorm.DB,
@@ -37,6 +43,7 @@ func NewSystemService(
 	ruleRepo repository.RuleRepository,
 	webhookRepo repository.WebhookRepository,
 	syncLogRepo repository.SyncLogRepository,
+	scheduler SyncScheduleProvider,
 	logger *logger.Logger,
 ) *SystemService {
 	return &SystemService{
@@ -47,6 +54,7 @@ func NewSystemService(
 		ruleRepo:    ruleRepo,
 		webhookRepo: webhookRepo,
 		syncLogRepo: syncLogRepo,
+		scheduler:   scheduler,
 		logger:      logger,
 		startTime:   time.Now(),
 	}
@@ -209,6 +217,12 @@ func (s *SystemService) GetSyncStatus(ctx context.Context) ([]SyncStatusResponse
 		return nil, fmt.Errorf("获取账户列表失败: %w", err)
 	}
 
+	// 调度器中的下次同步时间（已包含抖动和静默时段）
+	var schedule map[string]ScheduleInfo
+	if s.scheduler != nil {
+		schedule = s.scheduler.Schedule()
+	}
+
 	var statusList []SyncStatusResponse
 	for _, account := range accounts {
 		status := SyncStatusResponse{
@@ -236,15 +250,30 @@ func (s *SystemService) GetSyncStatus(ctx context.Context) ([]SyncStatusResponse
 			status.Status = "idle"
 		}
 
-		// 计算下次同步时间
-		if status.LastSyncTime != nil && account.SyncEnabled {
+		// 连续失败信息（超过阈值的账户已被隔离，不再定时同步）
+		status.ConsecutiveFailures = account.ConsecutiveFailures
+		status.ErrorCode = account.SyncErrorCode
+		if account.Status == "error" {
+			status.Status = "quarantined"
+		}
+
+		// 计算下次同步时间：优先使用调度器的计划时间
+		if info, ok := schedule[account.UID]; ok {
+			if info.Running {
+				status.Status = "syncing"
+			} else {
+				nextSync := info.NextRunAt
+				status.NextSyncTime = &nextSync
+				status.Deferred = info.Deferred
+			}
+		} else if status.LastSyncTime != nil && account.SyncEnabled && account.Status == "active" {
 			nextSync := status.LastSyncTime.Add(time.Duration(account.SyncInterval) * time.Minute)
 			status.NextSyncTime = &nextSync
 		}
 
-		// 获取邮件统计
-		status.EmailCount, _ = s.emailRepo.CountByAccount(ctx, account.UID)
-		status.UnreadCount, _ = s.emailRepo.CountUnread(ctx, account.UID)
+		// 邮件统计（账户上维护的计数，不含已删除的邮件）
+		status.EmailCount = int64(account.TotalEmails)
+		status.UnreadCount = int64(account.UnreadCount)
 
 		statusList = append(statusList, status)
 	}
@@ -295,7 +324,7 @@ func (s *SystemService) GetSyncLogs(ctx context.Context, page, pageSize int, acc
 
 // GetSupportedProviders 获取支持的邮箱提供商列表
 func (s *SystemService) GetSupportedProviders(ctx context.Context) ([]ProviderInfo, error) {
-	factory := adapter.NewFactory()
+	factory := adapter.NewFactory(adapter.FactoryOptions{})
 	
 	// 获取所有支持的提供商
 	providerNames := factory.GetSupportedProviders()
@@ -459,13 +488,17 @@ type SyncStatusResponse struct {
 	AccountUID   string     `json:"account_uid"`    // 账户UID
 	AccountName  string     `json:"account_name"`   // 账户名称
 	Provider     string     `json:"provider"`       // 邮箱服务商
-	Status       string     `json:"status"`         // 同步状态：idle, syncing, failed
+	Status       string     `json:"status"`         // 同步状态：idle, syncing, failed, quarantined
 	LastSyncTime *time.Time `json:"last_sync_time"` // 最后同步时间
 	NextSyncTime *time.Time `json:"next_sync_time"` // 下次同步时间
+	Deferred     bool       `json:"deferred"`       // 下次同步是否因静默时段被推迟
 	SyncInterval int        `json:"sync_interval"`  // 同步间隔（分钟）
 	ErrorMessage string     `json:"error_message"`  // 错误信息
+	ErrorCode    string     `json:"error_code"`     // 失败原因分类：auth, network, tls, quota, parse, unknown
 	EmailCount   int64      `json:"email_count"`    // 邮件总数
 	UnreadCount  int64      `json:"unread_count"`   // 未读数
+
+	ConsecutiveFailures int `json:"consecutive_failures"` // 连续失败次数
 }
 
 // SyncLogItem 同步日志项
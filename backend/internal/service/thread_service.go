@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/pkg/event"
+)
+
+// ErrThreadNotFound 会话不存在
+var ErrThreadNotFound = errors.New("thread not found")
+
+// ThreadService 会话服务接口
+type ThreadService interface {
+	// 会话查询
+	ListThreads(ctx context.Context, filter *repository.ThreadFilter, page, pageSize int) (*ThreadListResponse, error)
+	GetThread(ctx context.Context, id int64) (*ThreadDetail, error)
+
+	// 会话级状态管理（本地）
+	MarkAsRead(ctx context.Context, id int64) error
+	MarkAsUnread(ctx context.Context, id int64) error
+	Archive(ctx context.Context, id int64) error
+
+	// AssignEmail 将新同步的邮件归入会话（必要时合并因这封邮件而关联起来的会话）
+	AssignEmail(ctx context.Context, email *model.Email) error
+
+	// RefreshForEmails 邮件状态变化后重新计算所属会话的统计
+	RefreshForEmails(ctx context.Context, emailIDs []int64) error
+
+	// Rebuild 清空并按 JWZ 算法重建全部会话，返回会话数
+	Rebuild(ctx context.Context) (int, error)
+}
+
+// ThreadListResponse 会话列表响应
+type ThreadListResponse struct {
+	Threads    []*model.Thread `json:"threads"`
+	Total      int64           `json:"total"`
+	Page       int             `json:"page"`
+	PageSize   int             `json:"page_size"`
+	TotalPages int             `json:"total_pages"`
+}
+
+// ThreadDetail 会话详情（会话信息和按时间排列的邮件）
+type ThreadDetail struct {
+	Thread *model.Thread  `json:"thread"`
+	Emails []*model.Email `json:"emails"`
+}
+
+// threadService 会话服务实现
+type threadService struct {
+	threadRepo repository.ThreadRepository
+	emailRepo  repository.EmailRepository
+	events     EventPublisher
+}
+
+// NewThreadService 创建会话服务实例
+// events 可以为 nil，此时会话级状态变更不发布邮件事件
+func NewThreadService(threadRepo repository.ThreadRepository, emailRepo repository.EmailRepository, events EventPublisher) ThreadService {
+	return &threadService{
+		threadRepo: threadRepo,
+		emailRepo:  emailRepo,
+		events:     events,
+	}
+}
+
+// ListThreads 获取会话列表（支持分页和筛选）
+func (s *threadService) ListThreads(ctx context.Context, filter *repository.ThreadFilter, page, pageSize int) (*ThreadListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	threads, total, err := s.threadRepo.List(ctx, filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list threads: %w", err)
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
+	return &ThreadListResponse{
+		Threads:    threads,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// GetThread 获取会话详情
+func (s *threadService) GetThread(ctx context.Context, id int64) (*ThreadDetail, error) {
+	thread, err := s.findThread(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	emails, err := s.threadRepo.ListEmails(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list thread emails: %w", err)
+	}
+
+	return &ThreadDetail{Thread: thread, Emails: emails}, nil
+}
+
+// MarkAsRead 将会话中的邮件全部标记为已读
+func (s *threadService) MarkAsRead(ctx context.Context, id int64) error {
+	return s.updateEmails(ctx, id, s.emailRepo.MarkAsRead, func(email *model.Email) *event.Event {
+		return event.EmailReadEvent(email.ID, email.AccountUID)
+	})
+}
+
+// MarkAsUnread 将会话中的邮件全部标记为未读
+func (s *threadService) MarkAsUnread(ctx context.Context, id int64) error {
+	return s.updateEmails(ctx, id, s.emailRepo.MarkAsUnread, func(email *model.Email) *event.Event {
+		return event.EmailUnreadEvent(email.ID, email.AccountUID)
+	})
+}
+
+// Archive 归档会话中的全部邮件
+func (s *threadService) Archive(ctx context.Context, id int64) error {
+	archive := func(ctx context.Context, ids []int64) error {
+		return s.emailRepo.SetArchived(ctx, ids, true)
+	}
+	return s.updateEmails(ctx, id, archive, func(email *model.Email) *event.Event {
+		return event.EmailArchivedEvent(email.ID, email.AccountUID)
+	})
+}
+
+// updateEmails 对会话中的邮件执行状态变更，刷新会话统计并为每封邮件发布事件
+func (s *threadService) updateEmails(
+	ctx context.Context,
+	id int64,
+	update func(ctx context.Context, ids []int64) error,
+	newEvent func(email *model.Email) *event.Event,
+) error {
+	if _, err := s.findThread(ctx, id); err != nil {
+		return err
+	}
+
+	ids, err := s.threadRepo.ListEmailIDs(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to list thread emails: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := update(ctx, ids); err != nil {
+		return err
+	}
+	if err := s.threadRepo.RefreshStats(ctx, id); err != nil {
+		return fmt.Errorf("failed to refresh thread: %w", err)
+	}
+
+	if s.events != nil {
+		emails, err := s.emailRepo.FindByIDs(ctx, ids)
+		if err != nil {
+			log.Printf("Failed to load emails for events: %v", err)
+			return nil
+		}
+		for _, email := range emails {
+			publishEvent(ctx, s.events, newEvent(email))
+		}
+	}
+	return nil
+}
+
+// findThread 查找会话，不存在时返回 ErrThreadNotFound
+func (s *threadService) findThread(ctx context.Context, id int64) (*model.Thread, error) {
+	thread, err := s.threadRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread: %w", err)
+	}
+	if thread == nil {
+		return nil, ErrThreadNotFound
+	}
+	return thread, nil
+}
+
+// AssignEmail 将邮件归入会话
+// 按邮件自身和引用的 Message-ID 查找已有会话；都没有时，回复类邮件按主题归入时间窗口内的会话；
+// 关联到多个会话时（晚到的父邮件把两个分支连起来）合并到最早创建的会话
+func (s *threadService) AssignEmail(ctx context.Context, email *model.Email) error {
+	msg := newThreadMessage(email)
+	keys := msg.keys()
+
+	threadIDs, err := s.threadRepo.FindIDsByMessageKeys(ctx, keys)
+	if err != nil {
+		return fmt.Errorf("failed to find threads by message id: %w", err)
+	}
+
+	subjectKey, isReply := normalizeSubject(email.Subject)
+	if len(threadIDs) == 0 && isReply && subjectKey != "" {
+		thread, err := s.threadRepo.FindRecentBySubjectKey(ctx, subjectKey, email.SentAt.Add(-threadSubjectWindow))
+		if err != nil {
+			return fmt.Errorf("failed to find thread by subject: %w", err)
+		}
+		if thread != nil {
+			threadIDs = []int64{thread.ID}
+		}
+	}
+
+	var threadID int64
+	if len(threadIDs) == 0 {
+		thread := &model.Thread{
+			Subject:       email.Subject,
+			SubjectKey:    subjectKey,
+			LastMessageAt: email.SentAt,
+		}
+		if err := s.threadRepo.Create(ctx, thread); err != nil {
+			return fmt.Errorf("failed to create thread: %w", err)
+		}
+		threadID = thread.ID
+	} else {
+		sort.Slice(threadIDs, func(i, j int) bool { return threadIDs[i] < threadIDs[j] })
+		threadID = threadIDs[0]
+		if err := s.threadRepo.Merge(ctx, threadID, threadIDs[1:]); err != nil {
+			return fmt.Errorf("failed to merge threads: %w", err)
+		}
+	}
+
+	if err := s.threadRepo.AddMessageKeys(ctx, threadID, keys); err != nil {
+		return fmt.Errorf("failed to save thread references: %w", err)
+	}
+	if err := s.threadRepo.AssignEmails(ctx, threadID, []int64{email.ID}); err != nil {
+		return fmt.Errorf("failed to assign email to thread: %w", err)
+	}
+	email.LocalThreadID = &threadID
+
+	if err := s.threadRepo.RefreshStats(ctx, threadID); err != nil {
+		return fmt.Errorf("failed to refresh thread: %w", err)
+	}
+	return nil
+}
+
+// RefreshForEmails 重新计算邮件所属会话的统计
+func (s *threadService) RefreshForEmails(ctx context.Context, emailIDs []int64) error {
+	threadIDs, err := s.threadRepo.FindIDsByEmailIDs(ctx, emailIDs)
+	if err != nil {
+		return fmt.Errorf("failed to find threads: %w", err)
+	}
+
+	for _, id := range threadIDs {
+		if err := s.threadRepo.RefreshStats(ctx, id); err != nil {
+			return fmt.Errorf("failed to refresh thread %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Rebuild 重建全部会话
+func (s *threadService) Rebuild(ctx context.Context) (int, error) {
+	emails, err := s.threadRepo.ListThreadingFields(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load emails: %w", err)
+	}
+
+	msgs := make([]threadMessage, 0, len(emails))
+	for _, email := range emails {
+		msgs = append(msgs, newThreadMessage(email))
+	}
+	groups := buildThreads(msgs)
+
+	if err := s.threadRepo.DeleteAll(ctx); err != nil {
+		return 0, fmt.Errorf("failed to clear threads: %w", err)
+	}
+
+	for _, group := range groups {
+		thread := &model.Thread{
+			Subject:       group.Subject,
+			SubjectKey:    group.SubjectKey,
+			LastMessageAt: group.latest,
+		}
+		if err := s.threadRepo.Create(ctx, thread); err != nil {
+			return 0, fmt.Errorf("failed to create thread: %w", err)
+		}
+		if err := s.threadRepo.AddMessageKeys(ctx, thread.ID, group.Keys); err != nil {
+			return 0, fmt.Errorf("failed to save thread references: %w", err)
+		}
+		if err := s.threadRepo.AssignEmails(ctx, thread.ID, group.EmailIDs); err != nil {
+			return 0, fmt.Errorf("failed to assign emails to thread: %w", err)
+		}
+		if err := s.threadRepo.RefreshStats(ctx, thread.ID); err != nil {
+			return 0, fmt.Errorf("failed to refresh thread: %w", err)
+		}
+	}
+
+	return len(groups), nil
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"fusionmail/internal/model"
+)
+
+// threadSubjectWindow 没有引用头的回复按主题归并时，与会话最新邮件的最大间隔
+const threadSubjectWindow = 30 * 24 * time.Hour
+
+// replyPrefixPattern 回复/转发主题前缀（Re:、Fwd:、回复：、Re[2]: 等）
+var replyPrefixPattern = regexp.MustCompile(`(?i)^\s*(re|fw|fwd|aw|wg|sv|回复|答复|转发)\s*(\[\d+\])?\s*[:：]\s*`)
+
+// messageIDPattern 匹配 References 等头部中的 <Message-ID>
+var messageIDPattern = regexp.MustCompile(`<[^<>]+>`)
+
+// normalizeSubject 去掉主题的回复/转发前缀并规范化，返回规范化主题和是否为回复
+func normalizeSubject(subject string) (string, bool) {
+	isReply := false
+	s := subject
+	for {
+		stripped := replyPrefixPattern.ReplaceAllString(s, "")
+		if stripped == s {
+			break
+		}
+		s = stripped
+		isReply = true
+	}
+
+	key := strings.ToLower(strings.Join(strings.Fields(s), " "))
+	if len(key) > maxMessageKeyLen {
+		key = hashString(key)
+	}
+	return key, isReply
+}
+
+// parseMessageIDs 解析 References/In-Reply-To 头部中的 Message-ID 列表（规范化、去重、保持顺序）
+func parseMessageIDs(header string) []string {
+	ids := messageIDPattern.FindAllString(header, -1)
+	if len(ids) == 0 {
+		// 部分客户端不加尖括号
+		ids = strings.Fields(header)
+	}
+
+	seen := make(map[string]bool, len(ids))
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		key := messageKey(id)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// threadMessage 参与会话归并的邮件
+type threadMessage struct {
+	ID         int64
+	MessageKey string   // 规范化的 Message-ID，可能为空
+	References []string // 规范化的引用列表，最后一个是直接父邮件
+	Subject    string
+	SentAt     time.Time
+}
+
+// newThreadMessage 从邮件提取会话归并字段（In-Reply-To 不在 References 末尾时补到末尾）
+func newThreadMessage(email *model.Email) threadMessage {
+	msg := threadMessage{
+		ID:         email.ID,
+		MessageKey: messageKey(email.MessageID),
+		References: parseMessageIDs(email.References),
+		Subject:    email.Subject,
+		SentAt:     email.SentAt,
+	}
+
+	if parents := parseMessageIDs(email.InReplyTo); len(parents) > 0 {
+		parent := parents[0]
+		if n := len(msg.References); n == 0 || msg.References[n-1] != parent {
+			refs := make([]string, 0, n+1)
+			for _, ref := range msg.References {
+				if ref != parent {
+					refs = append(refs, ref)
+				}
+			}
+			msg.References = append(refs, parent)
+		}
+	}
+
+	// 引用自身的 Message-ID 没有意义，还会造成环
+	if msg.MessageKey != "" {
+		refs := msg.References[:0]
+		for _, ref := range msg.References {
+			if ref != msg.MessageKey {
+				refs = append(refs, ref)
+			}
+		}
+		msg.References = refs
+	}
+	return msg
+}
+
+// keys 返回邮件自身及其引用的 Message-ID
+func (m threadMessage) keys() []string {
+	keys := make([]string, 0, len(m.References)+1)
+	if m.MessageKey != "" {
+		keys = append(keys, m.MessageKey)
+	}
+	return append(keys, m.References...)
+}
+
+// threadContainer JWZ 算法中的容器：对应一个 Message-ID，邮件尚未出现时为空容器
+type threadContainer struct {
+	key       string
+	synthetic bool // 没有 Message-ID 的邮件使用的占位容器，不记录到会话引用
+	messages  []threadMessage
+	parent    *threadContainer
+	children  []*threadContainer
+}
+
+// hasDescendant 判断 other 是否为容器自身或其后代
+func (c *threadContainer) hasDescendant(other *threadContainer) bool {
+	for p := other; p != nil; p = p.parent {
+		if p == c {
+			return true
+		}
+	}
+	return false
+}
+
+// setParent 将容器挂到新的父容器下（先从原父容器摘除）
+func (c *threadContainer) setParent(parent *threadContainer) {
+	if c.parent != nil {
+		siblings := c.parent.children
+		for i, child := range siblings {
+			if child == c {
+				c.parent.children = append(siblings[:i], siblings[i+1:]...)
+				break
+			}
+		}
+	}
+	c.parent = parent
+	if parent != nil {
+		parent.children = append(parent.children, c)
+	}
+}
+
+// threadGroup 归并得到的一个会话
+type threadGroup struct {
+	EmailIDs   []int64
+	Keys       []string // 会话包含或引用的 Message-ID
+	Subject    string   // 最早一封邮件的主题
+	SubjectKey string
+	isReply    bool
+	earliest   time.Time
+	latest     time.Time
+}
+
+// buildThreads 使用 JWZ 算法把邮件归并为会话
+// 先按 References/In-Reply-To 建立父子关系（同一 Message-ID 的跨账户副本落在同一容器），
+// 再把根容器中主题相同、且为回复并在时间窗口内的会话合并
+func buildThreads(msgs []threadMessage) []*threadGroup {
+	table := make(map[string]*threadContainer)
+	var containers []*threadContainer
+	get := func(key string, synthetic bool) *threadContainer {
+		if c, ok := table[key]; ok {
+			return c
+		}
+		c := &threadContainer{key: key, synthetic: synthetic}
+		table[key] = c
+		containers = append(containers, c)
+		return c
+	}
+
+	for _, msg := range msgs {
+		var c *threadContainer
+		if msg.MessageKey != "" {
+			c = get(msg.MessageKey, false)
+		} else {
+			c = get(fmt.Sprintf("#%d", msg.ID), true)
+		}
+		c.messages = append(c.messages, msg)
+
+		// 按引用顺序串起祖先链，已有父容器的不改动，避免形成环
+		var prev *threadContainer
+		for _, ref := range msg.References {
+			rc := get(ref, false)
+			if prev != nil && rc.parent == nil && !rc.hasDescendant(prev) {
+				rc.setParent(prev)
+			}
+			prev = rc
+		}
+
+		// 邮件自身的引用最权威：父容器设为最后一个引用
+		// 没有引用的邮件保留由其他邮件推断的父容器，与增量归并（引用过即同一会话）保持一致
+		if prev != nil && !c.hasDescendant(prev) {
+			c.setParent(prev)
+		}
+	}
+
+	// 收集每个根容器下的全部邮件
+	var groups []*threadGroup
+	for _, c := range containers {
+		if c.parent != nil {
+			continue
+		}
+		group := &threadGroup{}
+		collectThread(c, group)
+		if len(group.EmailIDs) == 0 {
+			continue
+		}
+		group.SubjectKey, group.isReply = normalizeSubject(group.Subject)
+		groups = append(groups, group)
+	}
+
+	// 主题归并：按时间顺序处理，回复合并到窗口内的同主题会话
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].earliest.Before(groups[j].earliest) })
+	bySubject := make(map[string]*threadGroup)
+	merged := groups[:0]
+	for _, group := range groups {
+		if group.SubjectKey != "" {
+			if target, ok := bySubject[group.SubjectKey]; ok && group.isReply && group.earliest.Sub(target.latest) <= threadSubjectWindow {
+				target.EmailIDs = append(target.EmailIDs, group.EmailIDs...)
+				target.Keys = append(target.Keys, group.Keys...)
+				if group.latest.After(target.latest) {
+					target.latest = group.latest
+				}
+				continue
+			}
+			bySubject[group.SubjectKey] = group
+		}
+		merged = append(merged, group)
+	}
+
+	for _, group := range merged {
+		sort.Slice(group.EmailIDs, func(i, j int) bool { return group.EmailIDs[i] < group.EmailIDs[j] })
+	}
+	return merged
+}
+
+// collectThread 收集容器子树中的邮件和 Message-ID
+func collectThread(c *threadContainer, group *threadGroup) {
+	if !c.synthetic {
+		group.Keys = append(group.Keys, c.key)
+	}
+	for _, msg := range c.messages {
+		group.EmailIDs = append(group.EmailIDs, msg.ID)
+		if group.earliest.IsZero() || msg.SentAt.Before(group.earliest) {
+			group.earliest = msg.SentAt
+			group.Subject = msg.Subject
+		}
+		if msg.SentAt.After(group.latest) {
+			group.latest = msg.SentAt
+		}
+	}
+	for _, child := range c.children {
+		collectThread(child, group)
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"fusionmail/internal/model"
+)
+
+// TestBuildThreads 测试按引用关系和主题归并会话
+func TestBuildThreads(t *testing.T) {
+	base := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	email := func(id int64, messageID, inReplyTo, references, subject string, sentAt time.Time) threadMessage {
+		return newThreadMessage(&model.Email{
+			ID: id, MessageID: messageID, InReplyTo: inReplyTo, References: references, Subject: subject, SentAt: sentAt,
+		})
+	}
+
+	msgs := []threadMessage{
+		// 回复链，4 是 2 在另一个账户中的副本
+		email(1, "<a@x>", "", "", "Plan", base),
+		email(2, "<b@x>", "<a@x>", "<a@x>", "Re: Plan", base.Add(time.Hour)),
+		email(3, "<c@x>", "<b@x>", "<a@x> <b@x>", "Re: Plan", base.Add(2*time.Hour)),
+		email(4, "<B@X>", "<a@x>", "<a@x>", "Re: Plan", base.Add(time.Hour)),
+		// 父邮件未同步，两封回复通过空容器归并
+		email(5, "<e@x>", "<missing@x>", "", "Re: Lost", base),
+		email(6, "<f@x>", "", "<missing@x>", "Re: Lost", base.Add(time.Hour)),
+		// 同主题但不是回复的邮件各自成为会话；没有引用头的回复归入最近的同主题会话
+		email(7, "<g@x>", "", "", "Weekly report", base),
+		email(8, "<h@x>", "", "", "Weekly report", base.Add(7*day)),
+		email(9, "", "", "", "RE: 回复：Weekly  Report", base.Add(9*day)),
+		// 超出时间窗口的回复不按主题归并
+		email(10, "<k@x>", "", "", "Old topic", base),
+		email(11, "<j@x>", "", "", "Re: Old topic", base.Add(60*day)),
+		// 互相引用不会形成环
+		email(12, "<l@x>", "", "<m@x>", "Loop", base),
+		email(13, "<m@x>", "", "<l@x>", "Loop", base),
+	}
+
+	groups := buildThreads(msgs)
+
+	var got [][]int64
+	for _, group := range groups {
+		got = append(got, group.EmailIDs)
+	}
+	want := [][]int64{{1, 2, 3, 4}, {5, 6}, {7}, {10}, {12, 13}, {8, 9}, {11}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("buildThreads() groups = %v, want %v", got, want)
+	}
+
+	if groups[0].Subject != "Plan" || groups[0].SubjectKey != "plan" {
+		t.Errorf("group subject = %q/%q, want Plan/plan", groups[0].Subject, groups[0].SubjectKey)
+	}
+	if !reflect.DeepEqual(groups[1].Keys, []string{"missing@x", "e@x", "f@x"}) {
+		t.Errorf("group keys = %v", groups[1].Keys)
+	}
+}
+
+// TestNewThreadMessage 测试 In-Reply-To 补到引用列表末尾并去掉自引用
+func TestNewThreadMessage(t *testing.T) {
+	msg := newThreadMessage(&model.Email{
+		MessageID:  "<self@x>",
+		InReplyTo:  "<parent@x>",
+		References: "<root@x> <parent@x> <other@x> <self@x>",
+	})
+
+	want := []string{"root@x", "other@x", "parent@x"}
+	if !reflect.DeepEqual(msg.References, want) {
+		t.Errorf("References = %v, want %v", msg.References, want)
+	}
+	if !reflect.DeepEqual(msg.keys(), append([]string{"self@x"}, want...)) {
+		t.Errorf("keys() = %v", msg.keys())
+	}
+}
@@ -0,0 +1,12 @@
+-- 添加账户同步失败跟踪字段
+-- Migration: 004_add_account_sync_failures
+-- Description: 记录连续失败次数和失败原因，用于指数退避和自动隔离
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS sync_error_code VARCHAR(20) DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS quarantined_at TIMESTAMP;
+
+-- 添加注释
+COMMENT ON COLUMN accounts.consecutive_failures IS '连续同步失败次数（成功后清零）';
+COMMENT ON COLUMN accounts.sync_error_code IS '同步失败原因：auth/network/tls/quota/parse/unknown';
+COMMENT ON COLUMN accounts.quarantined_at IS '连续失败超过阈值被自动隔离（status=error）的时间';
@@ -0,0 +1,30 @@
+-- 创建历史邮件回填任务表
+-- Migration: 005_create_backfill_jobs
+-- Description: 首次同步只导入最近 7 天的邮件，回填任务从最新邮件开始向前逐页导入更早的历史邮件
+
+CREATE TABLE IF NOT EXISTS backfill_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    account_uid VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    next_cursor TEXT,
+    last_error TEXT,
+    page_size INTEGER DEFAULT 100,
+    page_delay_ms INTEGER DEFAULT 2000,
+    emails_processed INTEGER DEFAULT 0,
+    emails_new INTEGER DEFAULT 0,
+    estimated INTEGER DEFAULT 0,
+    active_ms BIGINT DEFAULT 0,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (account_uid) REFERENCES accounts(uid) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_backfill_jobs_account_uid ON backfill_jobs(account_uid);
+CREATE INDEX idx_backfill_jobs_status ON backfill_jobs(status);
+
+-- 添加注释
+COMMENT ON COLUMN backfill_jobs.status IS '任务状态：pending/running/paused/completed/cancelled/failed';
+COMMENT ON COLUMN backfill_jobs.next_cursor IS '下一页游标，服务重启后从此处继续';
+COMMENT ON COLUMN backfill_jobs.active_ms IS '累计运行时间（不含暂停），用于估算剩余时间';
@@ -0,0 +1,18 @@
+-- 添加源邮箱对账字段
+-- Migration: 006_add_source_reconciliation
+-- Description: 定期比对源邮箱中现存的邮件，标记已在源邮箱删除的邮件并记录对账统计
+
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS source_deleted BOOLEAN DEFAULT FALSE;
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS source_deleted_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_emails_source_deleted ON emails(source_deleted);
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS last_reconciled_at TIMESTAMP;
+
+ALTER TABLE sync_logs ADD COLUMN IF NOT EXISTS emails_source_deleted INTEGER DEFAULT 0;
+ALTER TABLE sync_logs ADD COLUMN IF NOT EXISTS emails_moved INTEGER DEFAULT 0;
+
+-- 添加注释
+COMMENT ON COLUMN emails.source_deleted IS '对账时发现邮件已不在源邮箱中（删除或移出同步范围）';
+COMMENT ON COLUMN accounts.last_reconciled_at IS '上次源邮箱对账时间';
+COMMENT ON COLUMN sync_logs.emails_source_deleted IS '本次对账新发现已在源邮箱删除的邮件数';
+COMMENT ON COLUMN sync_logs.emails_moved IS '本次对账发现源邮箱文件夹发生变化的邮件数';
@@ -0,0 +1,9 @@
+-- 添加账户附件大小限制
+-- Migration: 007_add_account_attachment_limit
+-- Description: 同步时保存附件内容，超过账户大小上限的附件只保存元数据
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS max_attachment_mb INTEGER DEFAULT 0;
+
+-- 添加注释
+COMMENT ON COLUMN accounts.max_attachment_mb IS '单个附件大小上限（MB），0 表示使用全局配置 STORAGE_MAX_ATTACHMENT_MB';
+COMMENT ON COLUMN email_attachments.storage_type IS '存储类型（local/s3/oss），none 表示超过大小限制未保存内容';
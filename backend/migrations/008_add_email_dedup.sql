@@ -0,0 +1,12 @@
+-- 添加跨账户重复邮件检测字段
+-- Migration: 008_add_email_dedup
+-- Description: 同一封邮件出现在多个聚合账户中时按去重键分组，统一列表可只显示主副本
+
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS dedup_key VARCHAR(255);
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS canonical_id BIGINT;
+CREATE INDEX IF NOT EXISTS idx_emails_dedup_key ON emails(dedup_key);
+CREATE INDEX IF NOT EXISTS idx_emails_canonical_id ON emails(canonical_id);
+
+-- 添加注释
+COMMENT ON COLUMN emails.dedup_key IS '去重键：mid:规范化 Message-ID，缺失时为 hash:内容哈希';
+COMMENT ON COLUMN emails.canonical_id IS '重复组主副本的邮件 ID，为空表示本身是主副本';
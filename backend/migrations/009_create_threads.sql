@@ -0,0 +1,39 @@
+-- 创建会话表
+-- Migration: 009_create_threads
+-- Description: 根据 Message-ID、In-Reply-To 和 References 跨账户归并会话（JWZ 算法），维护参与者、最新邮件时间和未读数
+-- 已有邮件执行 go run cmd/migrate/main.go -action=threads 重建会话
+
+CREATE TABLE IF NOT EXISTS threads (
+    id BIGSERIAL PRIMARY KEY,
+    subject TEXT,
+    subject_key VARCHAR(255),
+    participants TEXT,
+    account_uids TEXT,
+    is_archived BOOLEAN DEFAULT FALSE,
+    message_count INTEGER DEFAULT 0,
+    unread_count INTEGER DEFAULT 0,
+    last_message_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_threads_subject_key ON threads(subject_key);
+CREATE INDEX IF NOT EXISTS idx_threads_is_archived ON threads(is_archived);
+CREATE INDEX IF NOT EXISTS idx_threads_unread_count ON threads(unread_count);
+CREATE INDEX IF NOT EXISTS idx_threads_last_message_at ON threads(last_message_at DESC);
+
+-- 会话包含或引用的 Message-ID（晚到的父邮件或回复据此找到所属会话）
+CREATE TABLE IF NOT EXISTS thread_refs (
+    message_key VARCHAR(255) PRIMARY KEY,
+    thread_id BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_refs_thread_id ON thread_refs(thread_id);
+
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS local_thread_id BIGINT;
+CREATE INDEX IF NOT EXISTS idx_emails_local_thread_id ON emails(local_thread_id);
+
+-- 添加注释
+COMMENT ON TABLE threads IS '跨账户归并的邮件会话';
+COMMENT ON COLUMN threads.message_count IS '会话邮件数（不含已删除和跨账户重复副本）';
+COMMENT ON COLUMN emails.local_thread_id IS '所属本地会话 ID（threads 表）';
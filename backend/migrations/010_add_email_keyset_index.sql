@@ -0,0 +1,9 @@
+-- 邮件列表游标分页索引
+-- Migration: 010_add_email_keyset_index
+-- Description: 邮件列表按 (sent_at, id) 倒序游标翻页，替换原来只有 sent_at 的索引
+
+CREATE INDEX IF NOT EXISTS idx_sent_at_id ON emails(sent_at DESC, id DESC);
+
+-- 新索引覆盖按 sent_at 排序的查询，删除旧索引
+DROP INDEX IF EXISTS idx_emails_sent_at;
+DROP INDEX IF EXISTS idx_sent_at;
@@ -0,0 +1,34 @@
+-- 创建邮件计数器表
+-- Migration: 011_create_mailbox_counters
+-- Description: 按账户和源邮箱文件夹维护邮件数、未读数、星标数和归档数，替代仪表盘刷新时的 COUNT 查询
+
+CREATE TABLE IF NOT EXISTS mailbox_counters (
+    account_uid VARCHAR(64) NOT NULL,
+    folder VARCHAR(255) NOT NULL DEFAULT '',
+    total_count BIGINT DEFAULT 0,
+    unread_count BIGINT DEFAULT 0,
+    starred_count BIGINT DEFAULT 0,
+    archived_count BIGINT DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_uid, folder)
+);
+
+-- 根据已有邮件初始化计数器（与 cmd/migrate -action=counters 相同）
+DELETE FROM mailbox_counters;
+INSERT INTO mailbox_counters (account_uid, folder, total_count, unread_count, starred_count, archived_count, updated_at)
+SELECT account_uid, COALESCE(source_folder, ''), COUNT(*),
+    COUNT(*) FILTER (WHERE NOT is_read),
+    COUNT(*) FILTER (WHERE is_starred),
+    COUNT(*) FILTER (WHERE is_archived),
+    CURRENT_TIMESTAMP
+FROM emails
+WHERE NOT is_deleted
+GROUP BY account_uid, COALESCE(source_folder, '');
+
+UPDATE accounts SET
+    total_emails = COALESCE((SELECT SUM(total_count) FROM mailbox_counters c WHERE c.account_uid = accounts.uid), 0),
+    unread_count = COALESCE((SELECT SUM(unread_count) FROM mailbox_counters c WHERE c.account_uid = accounts.uid), 0);
+
+-- 添加注释
+COMMENT ON TABLE mailbox_counters IS '邮件计数器：随邮件写入和本地状态变化在同一事务中维护，不含本地已删除的邮件';
+COMMENT ON COLUMN mailbox_counters.folder IS '源邮箱文件夹，为空表示服务商没有文件夹信息';
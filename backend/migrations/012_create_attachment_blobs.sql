@@ -0,0 +1,25 @@
+-- 创建附件内容表
+-- Migration: 012_create_attachment_blobs
+-- Description: 附件内容按 SHA-256 内容寻址存储，相同内容只保存一份，附件记录通过 blob_hash 引用并维护引用数
+-- 已有附件保持原存储路径（blob_hash 为空），删除时按原方式删除文件
+-- 没有引用的内容执行 go run cmd/storage/main.go -action=gc 回收
+
+CREATE TABLE IF NOT EXISTS attachment_blobs (
+    sha256 VARCHAR(64) PRIMARY KEY,
+    size_bytes BIGINT NOT NULL,
+    storage_type VARCHAR(20) NOT NULL,
+    storage_path TEXT NOT NULL,
+    ref_count BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachment_blobs_updated_at ON attachment_blobs(updated_at);
+
+ALTER TABLE email_attachments ADD COLUMN IF NOT EXISTS blob_hash VARCHAR(64);
+CREATE INDEX IF NOT EXISTS idx_email_attachments_blob_hash ON email_attachments(blob_hash);
+
+-- 添加注释
+COMMENT ON TABLE attachment_blobs IS '附件内容：按 SHA-256 去重，引用数随附件记录维护，邮件级联删除附件时由垃圾回收按实际引用修正';
+COMMENT ON COLUMN attachment_blobs.updated_at IS '最近一次引用或准备上传的时间，垃圾回收跳过宽限期内的内容';
+COMMENT ON COLUMN email_attachments.blob_hash IS '附件内容 SHA-256，为空表示去重上线前按邮件保存的文件';
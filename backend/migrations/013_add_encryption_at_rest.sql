@@ -0,0 +1,23 @@
+-- 添加静态加密支持
+-- Migration: 013_add_encryption_at_rest
+-- Description: 信封加密的数据密钥表和加密正文的搜索索引表
+-- 设置 ENCRYPTION_AT_REST=true 后新写入的邮件正文和附件文件加密保存
+-- 已有数据执行 go run cmd/migrate/main.go -action=encrypt 就地加密
+
+CREATE TABLE IF NOT EXISTS data_keys (
+    owner VARCHAR(64) PRIMARY KEY,
+    wrapped_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS email_search_tokens (
+    email_id BIGINT NOT NULL REFERENCES emails(id) ON DELETE CASCADE,
+    token_hash VARCHAR(32) NOT NULL,
+    PRIMARY KEY (email_id, token_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_search_tokens_token_hash ON email_search_tokens(token_hash);
+
+-- 添加注释
+COMMENT ON TABLE data_keys IS '数据密钥：每个账户一个，附件文件共用 owner 为 _storage 的密钥，均被主密钥 ENCRYPTION_KEY 加密后保存';
+COMMENT ON TABLE email_search_tokens IS '加密正文的盲索引：正文分词后用账户数据密钥计算的 HMAC，只保存哈希';
@@ -0,0 +1,10 @@
+-- 添加原始邮件归档
+-- Migration: 014_add_email_raw_path
+-- Description: 同步时把邮件 RFC 822 原文 gzip 压缩后保存到存储提供者（raw/{账户 UID}/...），邮件记录保存归档路径
+-- 归档上线前入库的邮件和无法取得原文的适配器（Gmail API、Microsoft Graph）没有原文
+-- 解析器修复后执行 go run cmd/migrate/main.go -action=reparse 从原文重建解析字段
+
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS raw_path VARCHAR(255);
+
+-- 添加注释
+COMMENT ON COLUMN emails.raw_path IS '原文归档的存储路径，为空表示没有归档原文';
@@ -0,0 +1,22 @@
+-- 添加保留策略
+-- Migration: 015_add_retention_policies
+-- Description: 定期清理任务按保留策略永久删除回收站中的邮件、把过期的归档邮件移入回收站、释放旧邮件的大附件内容
+-- 全局策略由 RETENTION_* 环境变量配置，账户可单独覆盖（0 表示使用全局配置，-1 表示该账户不清理）
+
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS trashed_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_emails_trashed_at ON emails(trashed_at);
+
+-- 已在回收站中的邮件从迁移时开始计算保留期
+UPDATE emails SET trashed_at = NOW() WHERE is_deleted = true AND trashed_at IS NULL;
+
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS trash_retention_days INTEGER DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS archive_retention_days INTEGER DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS attachment_retention_days INTEGER DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS attachment_retention_mb INTEGER DEFAULT 0;
+
+-- 添加注释
+COMMENT ON COLUMN emails.trashed_at IS '移入回收站的时间，回收站保留期从此时开始计算';
+COMMENT ON COLUMN accounts.trash_retention_days IS '回收站邮件保留天数，0 表示使用全局配置 RETENTION_TRASH_DAYS，-1 表示不清理';
+COMMENT ON COLUMN accounts.archive_retention_days IS '归档邮件保留天数（超过后移入回收站），0 表示使用全局配置 RETENTION_ARCHIVE_DAYS，-1 表示不清理';
+COMMENT ON COLUMN accounts.attachment_retention_days IS '大附件内容保留天数，0 表示使用全局配置 RETENTION_ATTACHMENT_DAYS，-1 表示不清理';
+COMMENT ON COLUMN accounts.attachment_retention_mb IS '大附件阈值（MB），0 表示使用全局配置 RETENTION_ATTACHMENT_MB';
@@ -0,0 +1,9 @@
+-- 添加邮件正文摘要
+-- Migration: 016_add_email_body_hash
+-- Description: 正文加密改为随机 nonce 后相同正文的密文不再相同，同步时改为比较正文摘要（账户数据密钥的 HMAC）判断正文是否变化
+-- 已加密但没有摘要的邮件在下次同步拉取到时重写一次正文并补全摘要
+
+ALTER TABLE emails ADD COLUMN IF NOT EXISTS body_hash VARCHAR(64);
+
+-- 添加注释
+COMMENT ON COLUMN emails.body_hash IS '正文摘要：启用静态加密时为账户数据密钥派生的 HMAC-SHA256，明文保存的正文为空';
@@ -0,0 +1,14 @@
+-- 添加永久删除邮件的墓碑记录
+-- Migration: 017_add_purged_emails
+-- Description: 永久删除邮件时记录 (account_uid, provider_id)，同步和历史回填跳过这些邮件，避免源邮箱中仍存在的邮件被重新入库
+
+CREATE TABLE IF NOT EXISTS purged_emails (
+    account_uid VARCHAR(64) NOT NULL,
+    provider_id VARCHAR(255) NOT NULL,
+    purged_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (account_uid, provider_id)
+);
+
+-- 添加注释
+COMMENT ON TABLE purged_emails IS '永久删除邮件的墓碑记录，同步时按账户和邮件 ID 跳过';
+COMMENT ON COLUMN purged_emails.purged_at IS '永久删除的时间';
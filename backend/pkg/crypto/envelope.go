@@ -0,0 +1,275 @@
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// StorageKeyOwner 不属于账户的存储文件（图片代理缓存）使用的数据密钥，早期加密的附件文件也用它直接加密
+const StorageKeyOwner = "_storage"
+
+// dataKeySize 数据密钥长度（AES-256）
+const dataKeySize = 32
+
+// fieldPrefix 加密字段值的前缀，没有前缀的值视为明文（加密上线前写入或未启用加密）
+const fieldPrefix = "enc:v1:"
+
+// blindIndexSize 盲索引哈希保留的字节数
+const blindIndexSize = 16
+
+// DataKeyStore 数据密钥存储（保存被主密钥加密后的数据密钥）
+type DataKeyStore interface {
+	// LoadWrappedKey 读取 owner 的数据密钥，不存在时返回空字符串
+	LoadWrappedKey(ctx context.Context, owner string) (string, error)
+	// SaveWrappedKey 保存 owner 的数据密钥；已存在时保留原密钥并返回它，避免并发创建出两个密钥
+	SaveWrappedKey(ctx context.Context, owner, wrapped string) (string, error)
+	// ListOwners 列出已有数据密钥的 owner
+	ListOwners(ctx context.Context) ([]string, error)
+}
+
+// Keyring 信封加密的数据密钥管理：每个 owner（账户 UID 或 StorageKeyOwner）一个随机数据密钥，
+// 数据密钥用主密钥加密后保存，解开后缓存在内存中
+type Keyring struct {
+	master Encryptor
+	store  DataKeyStore
+
+	mu    sync.RWMutex
+	cache map[string][]byte
+}
+
+// NewKeyring 创建数据密钥管理器
+func NewKeyring(master Encryptor, store DataKeyStore) *Keyring {
+	return &Keyring{
+		master: master,
+		store:  store,
+		cache:  make(map[string][]byte),
+	}
+}
+
+// DataKey 获取 owner 的数据密钥，不存在时生成并保存
+func (k *Keyring) DataKey(ctx context.Context, owner string) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.cache[owner]
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	wrapped, err := k.store.LoadWrappedKey(ctx, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data key: %w", err)
+	}
+	if wrapped == "" {
+		key := make([]byte, dataKeySize)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, fmt.Errorf("failed to generate data key: %w", err)
+		}
+		wrapped, err = k.master.Encrypt(base64.StdEncoding.EncodeToString(key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap data key: %w", err)
+		}
+		// 并发创建时以先保存的密钥为准
+		if wrapped, err = k.store.SaveWrappedKey(ctx, owner, wrapped); err != nil {
+			return nil, fmt.Errorf("failed to save data key: %w", err)
+		}
+	}
+
+	encoded, err := k.master.Decrypt(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key for %s: %w", owner, err)
+	}
+	key, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != dataKeySize {
+		return nil, fmt.Errorf("invalid data key for %s", owner)
+	}
+
+	k.mu.Lock()
+	k.cache[owner] = key
+	k.mu.Unlock()
+	return key, nil
+}
+
+// Owners 列出已有数据密钥的 owner
+func (k *Keyring) Owners(ctx context.Context) ([]string, error) {
+	return k.store.ListOwners(ctx)
+}
+
+// FieldCipher 数据库字段加密：按 owner 的数据密钥加密字符串（随机 nonce），输出带 enc:v1: 前缀的 base64
+// 相同明文每次加密得到不同的密文，判断加密字段是否变化需比较 Digest 的结果
+type FieldCipher struct {
+	keyring *Keyring
+	encrypt bool
+}
+
+// NewFieldCipher 创建字段加密器
+// encrypt 为 false 时新写入的数据保持明文，已加密的数据仍可解密（关闭加密后数据仍可读）
+func NewFieldCipher(keyring *Keyring, encrypt bool) *FieldCipher {
+	return &FieldCipher{keyring: keyring, encrypt: encrypt}
+}
+
+// Enabled 是否加密新写入的数据
+func (c *FieldCipher) Enabled() bool {
+	return c.encrypt
+}
+
+// Encrypt 加密字段值；未启用加密、空值或已加密的值原样返回
+func (c *FieldCipher) Encrypt(ctx context.Context, owner, plaintext string) (string, error) {
+	if !c.encrypt || plaintext == "" || IsEncryptedField(plaintext) {
+		return plaintext, nil
+	}
+
+	key, err := c.keyring.DataKey(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(owner))
+	return fieldPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密字段值；没有加密前缀的值视为明文原样返回
+func (c *FieldCipher) Decrypt(ctx context.Context, owner, value string) (string, error) {
+	if !IsEncryptedField(value) {
+		return value, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, fieldPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted field: %w", err)
+	}
+	key, err := c.keyring.DataKey(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted field too short")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(owner))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt field: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Digest 计算一组字段值的带密钥摘要（owner 数据密钥派生的 HMAC-SHA256，十六进制）
+// 摘要与密文分开保存，用于在不解密的情况下判断加密字段是否变化；相同的值在同一账户内摘要相同
+func (c *FieldCipher) Digest(ctx context.Context, owner string, values ...string) (string, error) {
+	key, err := c.keyring.DataKey(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, deriveKey(key, "field-digest", nil))
+	var length [8]byte
+	for _, value := range values {
+		binary.BigEndian.PutUint64(length[:], uint64(len(value)))
+		mac.Write(length[:])
+		mac.Write([]byte(value))
+	}
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// BlindIndex 计算搜索词的盲索引：用 owner 的数据密钥派生的索引密钥对每个词做 HMAC，
+// 索引中只保存哈希，不同账户的同一个词哈希不同
+func (c *FieldCipher) BlindIndex(ctx context.Context, owner string, tokens []string) ([]string, error) {
+	key, err := c.keyring.DataKey(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	indexKey := deriveKey(key, "search-index", nil)
+
+	hashes := make([]string, len(tokens))
+	for i, token := range tokens {
+		mac := hmac.New(sha256.New, indexKey)
+		mac.Write([]byte(token))
+		hashes[i] = hex.EncodeToString(mac.Sum(nil)[:blindIndexSize])
+	}
+	return hashes, nil
+}
+
+// Owners 列出已有数据密钥的账户（不含存储文件的数据密钥）
+func (c *FieldCipher) Owners(ctx context.Context) ([]string, error) {
+	owners, err := c.keyring.Owners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accounts := owners[:0]
+	for _, owner := range owners {
+		if owner != StorageKeyOwner {
+			accounts = append(accounts, owner)
+		}
+	}
+	return accounts, nil
+}
+
+// IsEncryptedField 判断字段值是否已加密
+func IsEncryptedField(value string) bool {
+	return strings.HasPrefix(value, fieldPrefix)
+}
+
+// SealBytes 使用数据密钥加密二进制数据（随机 nonce，输出 nonce + 密文）
+func SealBytes(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// OpenBytes 解密 SealBytes 的输出
+func OpenBytes(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+// newGCM 创建 AES-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey 从数据密钥派生用途不同的子密钥（HMAC-SHA256(key, purpose || data)）
+func deriveKey(key []byte, purpose string, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,145 @@
+package crypto
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryKeyStore 内存中的数据密钥存储
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+func (s *memoryKeyStore) LoadWrappedKey(ctx context.Context, owner string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[owner], nil
+}
+
+func (s *memoryKeyStore) SaveWrappedKey(ctx context.Context, owner, wrapped string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.keys[owner]; ok {
+		return existing, nil
+	}
+	s.keys[owner] = wrapped
+	return wrapped, nil
+}
+
+func (s *memoryKeyStore) ListOwners(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var owners []string
+	for owner := range s.keys {
+		owners = append(owners, owner)
+	}
+	return owners, nil
+}
+
+// newTestKeyring 创建使用固定主密钥和内存存储的数据密钥管理器
+func newTestKeyring(t *testing.T) (*Keyring, *memoryKeyStore) {
+	t.Helper()
+	t.Setenv("ENCRYPTION_KEY", "0123456789abcdef0123456789abcdef")
+	master, err := NewEncryptor()
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	store := &memoryKeyStore{keys: make(map[string]string)}
+	return NewKeyring(master, store), store
+}
+
+// TestFieldCipher 测试字段加密的往返、随机 nonce、摘要、明文兼容和账户隔离
+func TestFieldCipher(t *testing.T) {
+	keyring, store := newTestKeyring(t)
+	cipher := NewFieldCipher(keyring, true)
+	ctx := context.Background()
+
+	sealed, err := cipher.Encrypt(ctx, "acc-1", "hello 世界")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if !IsEncryptedField(sealed) || strings.Contains(sealed, "hello") {
+		t.Fatalf("Encrypt returned %q", sealed)
+	}
+	if again, _ := cipher.Encrypt(ctx, "acc-1", "hello 世界"); again == sealed {
+		t.Error("same plaintext should encrypt to different values")
+	}
+	digest, err := cipher.Digest(ctx, "acc-1", "hello 世界", "")
+	if err != nil || len(digest) != 64 {
+		t.Fatalf("Digest = %q, %v", digest, err)
+	}
+	if again, _ := cipher.Digest(ctx, "acc-1", "hello 世界", ""); again != digest {
+		t.Error("same values should have the same digest")
+	}
+	if shifted, _ := cipher.Digest(ctx, "acc-1", "hello ", "世界"); shifted == digest {
+		t.Error("digest should distinguish field boundaries")
+	}
+	if other, _ := cipher.Digest(ctx, "acc-2", "hello 世界", ""); other == digest {
+		t.Error("digest should differ between accounts")
+	}
+	if twice, _ := cipher.Encrypt(ctx, "acc-1", sealed); twice != sealed {
+		t.Error("encrypted value should not be encrypted again")
+	}
+	if other, _ := cipher.Encrypt(ctx, "acc-2", "hello 世界"); other == sealed {
+		t.Error("different accounts should use different keys")
+	}
+
+	opened, err := cipher.Decrypt(ctx, "acc-1", sealed)
+	if err != nil || opened != "hello 世界" {
+		t.Errorf("Decrypt = %q, %v", opened, err)
+	}
+	if _, err := cipher.Decrypt(ctx, "acc-2", sealed); err == nil {
+		t.Error("decrypting with another account's key should fail")
+	}
+	if plain, err := cipher.Decrypt(ctx, "acc-1", "legacy body"); err != nil || plain != "legacy body" {
+		t.Errorf("plaintext value Decrypt = %q, %v", plain, err)
+	}
+
+	// 新的管理器从存储中解开同一个数据密钥
+	reloaded := NewFieldCipher(NewKeyring(keyring.master, store), false)
+	if opened, err := reloaded.Decrypt(ctx, "acc-1", sealed); err != nil || opened != "hello 世界" {
+		t.Errorf("Decrypt with reloaded keyring = %q, %v", opened, err)
+	}
+	if plain, _ := reloaded.Encrypt(ctx, "acc-1", "new body"); plain != "new body" {
+		t.Error("disabled cipher should keep new values in plaintext")
+	}
+}
+
+// TestBlindIndex 测试盲索引按账户区分且结果稳定
+func TestBlindIndex(t *testing.T) {
+	keyring, _ := newTestKeyring(t)
+	cipher := NewFieldCipher(keyring, true)
+	ctx := context.Background()
+
+	first, err := cipher.BlindIndex(ctx, "acc-1", []string{"invoice", "2024"})
+	if err != nil {
+		t.Fatalf("BlindIndex failed: %v", err)
+	}
+	second, _ := cipher.BlindIndex(ctx, "acc-1", []string{"invoice"})
+	other, _ := cipher.BlindIndex(ctx, "acc-2", []string{"invoice"})
+	if len(first) != 2 || first[0] != second[0] {
+		t.Errorf("BlindIndex not stable: %v vs %v", first, second)
+	}
+	if first[0] == other[0] {
+		t.Error("BlindIndex should differ between accounts")
+	}
+	if len(first[0]) != blindIndexSize*2 {
+		t.Errorf("hash length = %d", len(first[0]))
+	}
+
+	if _, err := keyring.DataKey(ctx, StorageKeyOwner); err != nil {
+		t.Fatalf("DataKey failed: %v", err)
+	}
+	owners, _ := cipher.Owners(ctx)
+	for _, owner := range owners {
+		if owner == StorageKeyOwner {
+			t.Error("Owners should not include the storage key")
+		}
+	}
+	if len(owners) != 2 {
+		t.Errorf("Owners = %v, want 2 accounts", owners)
+	}
+}
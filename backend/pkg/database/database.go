@@ -61,12 +61,20 @@ func AutoMigrate() error {
 		&model.Account{},
 		&model.Email{},
 		&model.EmailAttachment{},
+		&model.AttachmentBlob{},
 		&model.EmailLabel{},
 		&model.EmailLabelRelation{},
 		&model.EmailRule{},
 		&model.Webhook{},
 		&model.WebhookLog{},
 		&model.SyncLog{},
+		&model.BackfillJob{},
+		&model.Thread{},
+		&model.ThreadRef{},
+		&model.MailboxCounter{},
+		&model.DataKey{},
+		&model.EmailSearchToken{},
+		&model.PurgedEmail{},
 		&model.APIKey{},
 	}
 
@@ -75,6 +83,11 @@ func AutoMigrate() error {
 		return fmt.Errorf("failed to auto migrate: %w", err)
 	}
 
+	// 保留策略上线前移入回收站的邮件没有移入时间，从升级时开始计算保留期（同 015 迁移）
+	if err := DB.Exec("UPDATE emails SET trashed_at = ? WHERE is_deleted = ? AND trashed_at IS NULL", time.Now(), true).Error; err != nil {
+		return fmt.Errorf("failed to backfill trashed_at: %w", err)
+	}
+
 	log.Println("Database auto migration completed successfully")
 
 	// 创建全文搜索索引（PostgreSQL 特定）
@@ -23,6 +23,7 @@ const (
 	EventEmailStarred  EventType = "email.starred"
 	EventEmailArchived EventType = "email.archived"
 	EventEmailDeleted  EventType = "email.deleted"
+	EventEmailRestored EventType = "email.restored"
 
 	// 账户事件
 	EventAccountAdded   EventType = "account.added"
@@ -312,3 +313,48 @@ func EmailDeletedEvent(emailID int64, accountUID string) *Event {
 		"account_uid": accountUID,
 	}, "email_service")
 }
+
+// EmailRestoredEvent 创建邮件移出回收站事件
+func EmailRestoredEvent(emailID int64, accountUID string) *Event {
+	return NewEvent(EventEmailRestored, map[string]interface{}{
+		"email_id":    emailID,
+		"account_uid": accountUID,
+	}, "email_service")
+}
+
+// EmailUnreadEvent 创建邮件未读事件
+func EmailUnreadEvent(emailID int64, accountUID string) *Event {
+	return NewEvent(EventEmailUnread, map[string]interface{}{
+		"email_id":    emailID,
+		"account_uid": accountUID,
+	}, "email_service")
+}
+
+// EmailStarredEvent 创建邮件星标变更事件
+func EmailStarredEvent(emailID int64, accountUID string, starred bool) *Event {
+	return NewEvent(EventEmailStarred, map[string]interface{}{
+		"email_id":    emailID,
+		"account_uid": accountUID,
+		"is_starred":  starred,
+	}, "email_service")
+}
+
+// AccountEvent 创建账户事件（account.added/updated/deleted）
+func AccountEvent(eventType EventType, accountUID string, email string) *Event {
+	return NewEvent(eventType, map[string]interface{}{
+		"account_uid": accountUID,
+		"email":       email,
+	}, "account_service")
+}
+
+// SyncEvent 创建同步事件（sync.started/completed/failed），data 为附加的统计或错误信息
+func SyncEvent(eventType EventType, accountUID string, syncType string, data map[string]interface{}) *Event {
+	payload := map[string]interface{}{
+		"account_uid": accountUID,
+		"sync_type":   syncType,
+	}
+	for key, value := range data {
+		payload[key] = value
+	}
+	return NewEvent(eventType, payload, "sync_service")
+}
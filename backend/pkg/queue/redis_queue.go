@@ -2,8 +2,13 @@ package queue
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -17,15 +22,39 @@ type Task struct {
 	Priority   int                    `json:"priority"`
 	RetryCount int                    `json:"retry_count"`
 	MaxRetries int                    `json:"max_retries"`
+	LastError  string                 `json:"last_error,omitempty"`
 	CreatedAt  time.Time              `json:"created_at"`
 }
 
+// ErrLockNotHeld 锁已过期或被其他持有者获取
+var ErrLockNotHeld = errors.New("lock not held")
+
+// 只有锁值等于本实例持有的令牌时才释放或续期，避免锁过期后被其他实例获取时误删或误续期
+var (
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// MaxPriority 最高优先级（Dequeue 按 MaxPriority..0 的顺序取任务）
+const MaxPriority = 10
+
 // RedisQueue Redis 任务队列
 type RedisQueue struct {
 	client      *redis.Client
 	queueName   string
 	lockPrefix  string
 	lockTimeout time.Duration
+
+	mu         sync.Mutex
+	lockTokens map[string]string // 本实例持有的锁 -> 锁令牌
 }
 
 // NewRedisQueue 创建 Redis 队列实例
@@ -35,6 +64,7 @@ func NewRedisQueue(client *redis.Client, queueName string) *RedisQueue {
 		queueName:   queueName,
 		lockPrefix:  "lock:" + queueName + ":",
 		lockTimeout: 5 * time.Minute, // 默认锁超时 5 分钟
+		lockTokens:  make(map[string]string),
 	}
 }
 
@@ -59,10 +89,7 @@ func (q *RedisQueue) Enqueue(ctx context.Context, task *Task) error {
 	}
 
 	// 根据优先级选择队列
-	queueKey := q.queueName
-	if task.Priority > 0 {
-		queueKey = fmt.Sprintf("%s:priority:%d", q.queueName, task.Priority)
-	}
+	queueKey := q.queueKey(task.Priority)
 
 	// 加入队列（右侧推入）
 	if err := q.client.RPush(ctx, queueKey, data).Err(); err != nil {
@@ -73,73 +100,286 @@ func (q *RedisQueue) Enqueue(ctx context.Context, task *Task) error {
 }
 
 // Dequeue 从队列中取出任务
+// 按优先级从高到低检查所有队列，全部为空时阻塞等待 timeout
 func (q *RedisQueue) Dequeue(ctx context.Context, timeout time.Duration) (*Task, error) {
-	// 尝试从高优先级队列获取
-	for priority := 10; priority >= 0; priority-- {
-		queueKey := q.queueName
-		if priority > 0 {
-			queueKey = fmt.Sprintf("%s:priority:%d", q.queueName, priority)
+	// 将到期的延迟任务（重试）移回就绪队列
+	if err := q.promoteDelayed(ctx); err != nil {
+		return nil, err
+	}
+
+	// BLPOP 按键的顺序检查，第一个非空队列优先弹出
+	keys := make([]string, 0, MaxPriority+1)
+	for priority := MaxPriority; priority >= 0; priority-- {
+		keys = append(keys, q.queueKey(priority))
+	}
+
+	result, err := q.client.BLPop(ctx, timeout, keys...).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, redis.Nil // 所有队列都为空
 		}
+		return nil, fmt.Errorf("failed to dequeue task: %w", err)
+	}
 
-		// 从左侧弹出（阻塞）
-		result, err := q.client.BLPop(ctx, timeout, queueKey).Result()
+	if len(result) < 2 {
+		return nil, redis.Nil
+	}
+
+	// 反序列化任务
+	var task Task
+	if err := json.Unmarshal([]byte(result[1]), &task); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+	}
+
+	return &task, nil
+}
+
+// Requeue 从就绪队列中取出第一个满足条件的任务，修改后按新的优先级重新投递
+// 没有满足条件的任务（或任务已被 worker 取出）时返回 false
+func (q *RedisQueue) Requeue(ctx context.Context, match func(*Task) bool, update func(*Task)) (bool, error) {
+	for priority := MaxPriority; priority >= 0; priority-- {
+		members, err := q.client.LRange(ctx, q.queueKey(priority), 0, -1).Result()
 		if err != nil {
-			if err == redis.Nil {
-				continue // 队列为空，尝试下一个优先级
+			return false, fmt.Errorf("failed to list tasks: %w", err)
+		}
+
+		for _, member := range members {
+			var task Task
+			if err := json.Unmarshal([]byte(member), &task); err != nil || !match(&task) {
+				continue
+			}
+
+			// LREM 是原子操作，与 Dequeue 竞争时只有一方能取到任务
+			removed, err := q.client.LRem(ctx, q.queueKey(priority), 1, member).Result()
+			if err != nil {
+				return false, fmt.Errorf("failed to remove task: %w", err)
+			}
+			if removed == 0 {
+				return false, nil
 			}
-			return nil, fmt.Errorf("failed to dequeue task: %w", err)
+
+			update(&task)
+			if err := q.Enqueue(ctx, &task); err != nil {
+				return false, err
+			}
+			return true, nil
 		}
+	}
+	return false, nil
+}
+
+// Retry 重新投递失败的任务
+// 延迟 delay 后重新进入队列；超过最大重试次数时移入死信队列，返回 true
+func (q *RedisQueue) Retry(ctx context.Context, task *Task, cause error, delay time.Duration) (bool, error) {
+	task.RetryCount++
+	if cause != nil {
+		task.LastError = cause.Error()
+	}
+
+	if task.RetryCount > task.MaxRetries {
+		return true, q.DeadLetter(ctx, task)
+	}
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	readyAt := float64(time.Now().Add(delay).UnixMilli())
+	if err := q.client.ZAdd(ctx, q.delayedKey(), redis.Z{Score: readyAt, Member: data}).Err(); err != nil {
+		return false, fmt.Errorf("failed to schedule task retry: %w", err)
+	}
+
+	return false, nil
+}
 
-		if len(result) < 2 {
+// DeadLetter 将任务移入死信队列
+func (q *RedisQueue) DeadLetter(ctx context.Context, task *Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	if err := q.client.RPush(ctx, q.deadLetterKey(), data).Err(); err != nil {
+		return fmt.Errorf("failed to dead-letter task: %w", err)
+	}
+	return nil
+}
+
+// DeadLetters 获取死信队列中的任务
+func (q *RedisQueue) DeadLetters(ctx context.Context, limit int64) ([]*Task, error) {
+	results, err := q.client.LRange(ctx, q.deadLetterKey(), 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	tasks := make([]*Task, 0, len(results))
+	for _, result := range results {
+		var task Task
+		if err := json.Unmarshal([]byte(result), &task); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+		}
+		tasks = append(tasks, &task)
+	}
+	return tasks, nil
+}
+
+// DeadLetterSize 获取死信队列大小
+func (q *RedisQueue) DeadLetterSize(ctx context.Context) (int64, error) {
+	return q.client.LLen(ctx, q.deadLetterKey()).Result()
+}
+
+// promoteDelayed 将到期的延迟任务移回对应优先级的队列
+func (q *RedisQueue) promoteDelayed(ctx context.Context) error {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	members, err := q.client.ZRangeByScore(ctx, q.delayedKey(), &redis.ZRangeBy{Min: "-inf", Max: now}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to load delayed tasks: %w", err)
+	}
+
+	for _, member := range members {
+		// ZREM 成功的实例负责投递，避免多个 worker 重复投递
+		removed, err := q.client.ZRem(ctx, q.delayedKey(), member).Result()
+		if err != nil {
+			return fmt.Errorf("failed to remove delayed task: %w", err)
+		}
+		if removed == 0 {
 			continue
 		}
 
-		// 反序列化任务
 		var task Task
-		if err := json.Unmarshal([]byte(result[1]), &task); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal task: %w", err)
+		if err := json.Unmarshal([]byte(member), &task); err != nil {
+			return fmt.Errorf("failed to unmarshal task: %w", err)
+		}
+		if err := q.client.RPush(ctx, q.queueKey(task.Priority), member).Err(); err != nil {
+			return fmt.Errorf("failed to enqueue delayed task: %w", err)
 		}
+	}
 
-		return &task, nil
+	return nil
+}
+
+// queueKey 获取指定优先级的队列键
+func (q *RedisQueue) queueKey(priority int) string {
+	if priority > 0 {
+		return fmt.Sprintf("%s:priority:%d", q.queueName, priority)
 	}
+	return q.queueName
+}
+
+// delayedKey 获取延迟任务集合的键
+func (q *RedisQueue) delayedKey() string {
+	return q.queueName + ":delayed"
+}
 
-	// 所有队列都为空
-	return nil, redis.Nil
+// deadLetterKey 获取死信队列的键
+func (q *RedisQueue) deadLetterKey() string {
+	return q.queueName + ":dead"
 }
 
-// AcquireLock 获取分布式锁
+// AcquireLock 获取分布式锁，锁值为随机令牌，只有持有令牌的本实例可以释放或续期
 func (q *RedisQueue) AcquireLock(ctx context.Context, key string) (bool, error) {
 	lockKey := q.lockPrefix + key
 
+	token, err := newLockToken()
+	if err != nil {
+		return false, fmt.Errorf("failed to generate lock token: %w", err)
+	}
+
 	// 使用 SET NX EX 命令获取锁
-	result, err := q.client.SetNX(ctx, lockKey, time.Now().Unix(), q.lockTimeout).Result()
+	result, err := q.client.SetNX(ctx, lockKey, token, q.lockTimeout).Result()
 	if err != nil {
 		return false, fmt.Errorf("failed to acquire lock: %w", err)
 	}
+	if result {
+		q.mu.Lock()
+		q.lockTokens[lockKey] = token
+		q.mu.Unlock()
+	}
 
 	return result, nil
 }
 
-// ReleaseLock 释放分布式锁
+// ReleaseLock 释放本实例持有的分布式锁（锁已过期并被其他实例获取时不删除）
 func (q *RedisQueue) ReleaseLock(ctx context.Context, key string) error {
 	lockKey := q.lockPrefix + key
-	return q.client.Del(ctx, lockKey).Err()
+
+	q.mu.Lock()
+	token, ok := q.lockTokens[lockKey]
+	delete(q.lockTokens, lockKey)
+	q.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	return releaseLockScript.Run(ctx, q.client, []string{lockKey}, token).Err()
 }
 
-// ExtendLock 延长锁的过期时间
+// ExtendLock 延长本实例持有的锁的过期时间，锁已过期或被其他实例获取时返回 ErrLockNotHeld
 func (q *RedisQueue) ExtendLock(ctx context.Context, key string) error {
 	lockKey := q.lockPrefix + key
-	return q.client.Expire(ctx, lockKey, q.lockTimeout).Err()
+
+	q.mu.Lock()
+	token, ok := q.lockTokens[lockKey]
+	q.mu.Unlock()
+	if !ok {
+		return ErrLockNotHeld
+	}
+
+	extended, err := extendLockScript.Run(ctx, q.client, []string{lockKey}, token, q.lockTimeout.Milliseconds()).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to extend lock: %w", err)
+	}
+	if extended == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// newLockToken 生成随机锁令牌
+func newLockToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// SetMarker 设置带过期时间的标记，标记已存在时返回 false
+// 与锁不同，标记没有持有者，任何实例都可以通过 ClearMarker 清除（如 API 进程投递、worker 取出时清除）
+func (q *RedisQueue) SetMarker(ctx context.Context, key string) (bool, error) {
+	result, err := q.client.SetNX(ctx, q.lockPrefix+key, time.Now().Unix(), q.lockTimeout).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set marker: %w", err)
+	}
+	return result, nil
 }
 
-// Size 获取队列大小
+// ClearMarker 清除标记
+func (q *RedisQueue) ClearMarker(ctx context.Context, key string) error {
+	return q.client.Del(ctx, q.lockPrefix+key).Err()
+}
+
+// Size 获取队列大小（所有优先级的就绪任务总数）
 func (q *RedisQueue) Size(ctx context.Context) (int64, error) {
-	return q.client.LLen(ctx, q.queueName).Result()
+	var total int64
+	for priority := MaxPriority; priority >= 0; priority-- {
+		size, err := q.client.LLen(ctx, q.queueKey(priority)).Result()
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
 }
 
-// Clear 清空队列
+// Clear 清空队列（包括所有优先级和延迟任务，不包括死信队列）
 func (q *RedisQueue) Clear(ctx context.Context) error {
-	return q.client.Del(ctx, q.queueName).Err()
+	keys := []string{q.delayedKey()}
+	for priority := MaxPriority; priority >= 0; priority-- {
+		keys = append(keys, q.queueKey(priority))
+	}
+	return q.client.Del(ctx, keys...).Err()
 }
 
 // Peek 查看队列头部的任务（不移除）
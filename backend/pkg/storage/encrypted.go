@@ -0,0 +1,381 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"fusionmail/pkg/crypto"
+)
+
+// 加密文件头，没有文件头的文件视为明文（加密上线前保存或未启用加密）
+// v1 文件直接用 crypto.StorageKeyOwner 的数据密钥加密；v2 文件用随机文件密钥加密，文件密钥按账户分别包装后保存在文件头中
+var (
+	encryptedFileMagic   = []byte("FMENC1\n")
+	encryptedFileMagicV2 = []byte("FMENC2\n")
+)
+
+// DataKeySource 数据密钥来源（crypto.Keyring）
+type DataKeySource interface {
+	DataKey(ctx context.Context, owner string) ([]byte, error)
+}
+
+// KeySharer 按账户包装文件密钥的存储：去重后被其他账户引用的已有文件需要为该账户补充包装的文件密钥
+type KeySharer interface {
+	ShareKey(ctx context.Context, path string) error
+}
+
+// keyOwnersKey 上下文中文件密钥所属账户的键
+type keyOwnersKey struct{}
+
+// WithKeyOwners 指定上传和就地加密时为哪些账户包装文件密钥
+// 没有指定时使用 crypto.StorageKeyOwner（图片代理缓存等不属于账户的文件）
+func WithKeyOwners(ctx context.Context, owners ...string) context.Context {
+	return context.WithValue(ctx, keyOwnersKey{}, owners)
+}
+
+// keyOwners 返回上下文中的文件密钥所属账户
+func keyOwners(ctx context.Context) []string {
+	if owners, _ := ctx.Value(keyOwnersKey{}).([]string); len(owners) > 0 {
+		return owners
+	}
+	return []string{crypto.StorageKeyOwner}
+}
+
+// EncryptedProvider 加密存储装饰器：上传时用随机文件密钥加密文件，下载时透明解密
+// 文件密钥用所属账户的数据密钥包装（去重后共享的附件为每个引用账户各包装一份），账户之间不共用密钥
+type EncryptedProvider struct {
+	inner   Provider
+	keys    DataKeySource
+	encrypt bool
+}
+
+// NewEncryptedProvider 创建加密存储装饰器
+// encrypt 为 false 时新上传的文件保持明文，已加密的文件仍可解密
+func NewEncryptedProvider(inner Provider, keys DataKeySource, encrypt bool) *EncryptedProvider {
+	return &EncryptedProvider{inner: inner, keys: keys, encrypt: encrypt}
+}
+
+// Upload 上传文件（启用加密时先加密）
+func (p *EncryptedProvider) Upload(ctx context.Context, path string, reader io.Reader, contentType string) (string, error) {
+	if !p.encrypt {
+		return p.inner.Upload(ctx, path, reader, contentType)
+	}
+
+	plaintext, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	sealed, err := p.seal(ctx, plaintext, keyOwners(ctx))
+	if err != nil {
+		return "", err
+	}
+	if _, err := p.inner.Upload(ctx, path, bytes.NewReader(sealed), "application/octet-stream"); err != nil {
+		return "", err
+	}
+	return p.GetURL(ctx, path)
+}
+
+// Download 下载文件，加密文件解密后返回，明文文件直接流式返回
+func (p *EncryptedProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	reader, err := p.inner.Download(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReader(reader)
+	header, _ := buffered.Peek(len(encryptedFileMagic))
+	if !isEncryptedFile(header) {
+		return struct {
+			io.Reader
+			io.Closer
+		}{buffered, reader}, nil
+	}
+
+	defer reader.Close()
+	sealed, err := io.ReadAll(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	plaintext, err := p.open(ctx, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", path, err)
+	}
+	return io.NopCloser(bytes.NewReader(plaintext)), nil
+}
+
+// Delete 删除文件
+func (p *EncryptedProvider) Delete(ctx context.Context, path string) error {
+	return p.inner.Delete(ctx, path)
+}
+
+// GetURL 获取文件访问 URL
+// 启用加密时存储中的文件是密文，不返回直接访问地址，需通过附件下载接口获取
+func (p *EncryptedProvider) GetURL(ctx context.Context, path string) (string, error) {
+	if p.encrypt {
+		return "", nil
+	}
+	return p.inner.GetURL(ctx, path)
+}
+
+// Exists 检查文件是否存在
+func (p *EncryptedProvider) Exists(ctx context.Context, path string) (bool, error) {
+	return p.inner.Exists(ctx, path)
+}
+
+// EncryptInPlace 将已保存的文件按上下文中的账户（WithKeyOwners）加密后写回原路径，返回是否改写了文件
+// 明文文件和 v1 文件重新加密；v2 文件只补充缺少的账户包装，已全部包装的文件跳过
+func (p *EncryptedProvider) EncryptInPlace(ctx context.Context, path string) (bool, error) {
+	data, err := p.readRaw(ctx, path)
+	if err != nil {
+		return false, err
+	}
+
+	owners := keyOwners(ctx)
+	var sealed []byte
+	if bytes.HasPrefix(data, encryptedFileMagicV2) {
+		sealed, err = p.addOwners(ctx, data, owners)
+	} else {
+		plaintext := data
+		if bytes.HasPrefix(data, encryptedFileMagic) {
+			if plaintext, err = p.open(ctx, data); err != nil {
+				return false, fmt.Errorf("failed to decrypt %s: %w", path, err)
+			}
+		}
+		sealed, err = p.seal(ctx, plaintext, owners)
+	}
+	if err != nil || sealed == nil {
+		return false, err
+	}
+
+	if _, err := p.inner.Upload(ctx, path, bytes.NewReader(sealed), "application/octet-stream"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ShareKey 为上下文中的账户（WithKeyOwners）补充包装已有加密文件的文件密钥
+// 未启用加密或上下文中没有指定账户时不处理；v1 文件改写为 v2 格式
+// 并发为同一文件补充包装时可能丢失其中一个（文件仍可用其他账户的包装解密），再次执行加密迁移可补全
+func (p *EncryptedProvider) ShareKey(ctx context.Context, path string) error {
+	if owners, _ := ctx.Value(keyOwnersKey{}).([]string); !p.encrypt || len(owners) == 0 {
+		return nil
+	}
+	_, err := p.EncryptInPlace(ctx, path)
+	return err
+}
+
+// readRaw 读取存储中的原始文件内容（不解密）
+func (p *EncryptedProvider) readRaw(ctx context.Context, path string) ([]byte, error) {
+	reader, err := p.inner.Download(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return data, nil
+}
+
+// wrappedFileKey 文件头中为一个账户包装的文件密钥
+type wrappedFileKey struct {
+	owner   string
+	wrapped []byte
+}
+
+// seal 用随机文件密钥加密文件内容，文件密钥分别用各账户的数据密钥包装后写入文件头
+// 文件格式：FMENC2\n | 包装数 (uint16) | 每个包装：账户长度 (uint16) 账户 | 密钥长度 (uint16) 包装的密钥 | 密文
+func (p *EncryptedProvider) seal(ctx context.Context, plaintext []byte, owners []string) ([]byte, error) {
+	fileKey := make([]byte, fileKeySize)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, fmt.Errorf("failed to generate file key: %w", err)
+	}
+	sealed, err := crypto.SealBytes(fileKey, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+	}
+
+	var keys []wrappedFileKey
+	for _, owner := range owners {
+		if keys, err = p.wrapFor(ctx, keys, fileKey, owner); err != nil {
+			return nil, err
+		}
+	}
+	return encodeEncryptedFile(keys, sealed)
+}
+
+// addOwners 为 v2 文件补充缺少的账户包装，不需要改写时返回 nil
+func (p *EncryptedProvider) addOwners(ctx context.Context, data []byte, owners []string) ([]byte, error) {
+	keys, sealed, err := decodeEncryptedFile(data)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, owner := range owners {
+		if findWrappedKey(keys, owner) == nil {
+			missing = append(missing, owner)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	fileKey, err := p.unwrap(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, owner := range missing {
+		if keys, err = p.wrapFor(ctx, keys, fileKey, owner); err != nil {
+			return nil, err
+		}
+	}
+	return encodeEncryptedFile(keys, sealed)
+}
+
+// wrapFor 用账户的数据密钥包装文件密钥并追加到包装列表
+func (p *EncryptedProvider) wrapFor(ctx context.Context, keys []wrappedFileKey, fileKey []byte, owner string) ([]wrappedFileKey, error) {
+	if findWrappedKey(keys, owner) != nil {
+		return keys, nil
+	}
+	dataKey, err := p.keys.DataKey(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := crypto.SealBytes(dataKey, fileKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap file key: %w", err)
+	}
+	return append(keys, wrappedFileKey{owner: owner, wrapped: wrapped}), nil
+}
+
+// unwrap 用任一账户的数据密钥解开文件密钥（优先使用上下文中指定的账户）
+func (p *EncryptedProvider) unwrap(ctx context.Context, keys []wrappedFileKey) ([]byte, error) {
+	if owners, _ := ctx.Value(keyOwnersKey{}).([]string); len(owners) > 0 {
+		var preferred []wrappedFileKey
+		for _, owner := range owners {
+			if key := findWrappedKey(keys, owner); key != nil {
+				preferred = append(preferred, *key)
+			}
+		}
+		keys = append(preferred, keys...)
+	}
+
+	var lastErr error = fmt.Errorf("no wrapped file key")
+	for _, key := range keys {
+		dataKey, err := p.keys.DataKey(ctx, key.owner)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		fileKey, err := crypto.OpenBytes(dataKey, key.wrapped)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to unwrap file key for %s: %w", key.owner, err)
+			continue
+		}
+		return fileKey, nil
+	}
+	return nil, lastErr
+}
+
+// open 去掉文件头并解密（v1 文件使用存储数据密钥，v2 文件先解开文件密钥）
+func (p *EncryptedProvider) open(ctx context.Context, data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, encryptedFileMagic) {
+		key, err := p.keys.DataKey(ctx, crypto.StorageKeyOwner)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.OpenBytes(key, bytes.TrimPrefix(data, encryptedFileMagic))
+	}
+
+	keys, sealed, err := decodeEncryptedFile(data)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := p.unwrap(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.OpenBytes(fileKey, sealed)
+}
+
+// fileKeySize 文件密钥长度（AES-256）
+const fileKeySize = 32
+
+// isEncryptedFile 判断文件开头是否为加密文件头
+func isEncryptedFile(header []byte) bool {
+	return bytes.Equal(header, encryptedFileMagic) || bytes.Equal(header, encryptedFileMagicV2)
+}
+
+// findWrappedKey 查找账户的包装
+func findWrappedKey(keys []wrappedFileKey, owner string) *wrappedFileKey {
+	for i := range keys {
+		if keys[i].owner == owner {
+			return &keys[i]
+		}
+	}
+	return nil
+}
+
+// encodeEncryptedFile 编码 v2 加密文件
+func encodeEncryptedFile(keys []wrappedFileKey, sealed []byte) ([]byte, error) {
+	if len(keys) == 0 || len(keys) > math.MaxUint16 {
+		return nil, fmt.Errorf("invalid number of wrapped file keys: %d", len(keys))
+	}
+	var buf bytes.Buffer
+	buf.Write(encryptedFileMagicV2)
+	binary.Write(&buf, binary.BigEndian, uint16(len(keys)))
+	for _, key := range keys {
+		for _, field := range [][]byte{[]byte(key.owner), key.wrapped} {
+			if len(field) > math.MaxUint16 {
+				return nil, fmt.Errorf("wrapped file key field too long")
+			}
+			binary.Write(&buf, binary.BigEndian, uint16(len(field)))
+			buf.Write(field)
+		}
+	}
+	buf.Write(sealed)
+	return buf.Bytes(), nil
+}
+
+// decodeEncryptedFile 解析 v2 加密文件，返回包装列表和密文
+func decodeEncryptedFile(data []byte) ([]wrappedFileKey, []byte, error) {
+	rest := bytes.TrimPrefix(data, encryptedFileMagicV2)
+	next := func() ([]byte, bool) {
+		if len(rest) < 2 {
+			return nil, false
+		}
+		n := int(binary.BigEndian.Uint16(rest))
+		if len(rest) < 2+n {
+			return nil, false
+		}
+		field := rest[2 : 2+n]
+		rest = rest[2+n:]
+		return field, true
+	}
+
+	if len(rest) < 2 {
+		return nil, nil, fmt.Errorf("encrypted file header truncated")
+	}
+	count := int(binary.BigEndian.Uint16(rest))
+	rest = rest[2:]
+	keys := make([]wrappedFileKey, 0, count)
+	for i := 0; i < count; i++ {
+		owner, ok := next()
+		if !ok {
+			return nil, nil, fmt.Errorf("encrypted file header truncated")
+		}
+		wrapped, ok := next()
+		if !ok {
+			return nil, nil, fmt.Errorf("encrypted file header truncated")
+		}
+		keys = append(keys, wrappedFileKey{owner: string(owner), wrapped: wrapped})
+	}
+	return keys, rest, nil
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fusionmail/pkg/crypto"
+)
+
+// ownerKeys 按账户派生的测试数据密钥，记录用到的账户
+type ownerKeys struct {
+	used map[string]bool
+}
+
+func (k *ownerKeys) DataKey(ctx context.Context, owner string) ([]byte, error) {
+	k.used[owner] = true
+	sum := sha256.Sum256([]byte("test-key:" + owner))
+	return sum[:], nil
+}
+
+// TestEncryptedProvider 测试加密上传、透明解密、明文兼容和就地加密
+func TestEncryptedProvider(t *testing.T) {
+	dir := t.TempDir()
+	local, err := NewLocalProvider(dir, "http://files.example.com")
+	if err != nil {
+		t.Fatalf("NewLocalProvider failed: %v", err)
+	}
+	keys := &ownerKeys{used: make(map[string]bool)}
+	provider := NewEncryptedProvider(local, keys, true)
+	ctx := context.Background()
+
+	read := func(path string) string {
+		t.Helper()
+		reader, err := provider.Download(ctx, path)
+		if err != nil {
+			t.Fatalf("Download %s failed: %v", path, err)
+		}
+		defer reader.Close()
+		data, _ := io.ReadAll(reader)
+		return string(data)
+	}
+
+	link, err := provider.Upload(ctx, "a/secret.txt", strings.NewReader("top secret"), "text/plain")
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if link != "" {
+		t.Errorf("encrypted upload should not expose a direct URL, got %q", link)
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, "a/secret.txt"))
+	if !bytes.HasPrefix(raw, encryptedFileMagicV2) || bytes.Contains(raw, []byte("secret")) {
+		t.Errorf("stored file is not encrypted: %q", raw)
+	}
+	if got := read("a/secret.txt"); got != "top secret" {
+		t.Errorf("Download = %q", got)
+	}
+
+	// 加密上线前保存的明文文件
+	if _, err := local.Upload(ctx, "a/legacy.txt", strings.NewReader("legacy"), "text/plain"); err != nil {
+		t.Fatalf("Upload legacy failed: %v", err)
+	}
+	if got := read("a/legacy.txt"); got != "legacy" {
+		t.Errorf("plaintext Download = %q", got)
+	}
+	done, err := provider.EncryptInPlace(ctx, "a/legacy.txt")
+	if err != nil || !done {
+		t.Fatalf("EncryptInPlace = %v, %v", done, err)
+	}
+	if done, _ := provider.EncryptInPlace(ctx, "a/legacy.txt"); done {
+		t.Error("already encrypted file should be skipped")
+	}
+	if got := read("a/legacy.txt"); got != "legacy" {
+		t.Errorf("Download after EncryptInPlace = %q", got)
+	}
+
+	// 加密上线初期按存储数据密钥加密的 v1 文件仍可读取，就地加密时改写为按账户包装
+	storageKey, _ := keys.DataKey(ctx, crypto.StorageKeyOwner)
+	v1, _ := crypto.SealBytes(storageKey, []byte("v1 content"))
+	local.Upload(ctx, "a/v1.txt", bytes.NewReader(append(append([]byte{}, encryptedFileMagic...), v1...)), "text/plain")
+	if got := read("a/v1.txt"); got != "v1 content" {
+		t.Errorf("v1 Download = %q", got)
+	}
+	if done, err := provider.EncryptInPlace(WithKeyOwners(ctx, "acc-1"), "a/v1.txt"); err != nil || !done {
+		t.Fatalf("EncryptInPlace v1 = %v, %v", done, err)
+	}
+	if keys, _, _ := decodeEncryptedFile(readRaw(t, dir, "a/v1.txt")); len(keys) != 1 || keys[0].owner != "acc-1" {
+		t.Errorf("v1 file rewrapped for %+v", keys)
+	}
+
+	// 关闭加密后已加密的文件仍可读取
+	disabled := NewEncryptedProvider(local, keys, false)
+	reader, err := disabled.Download(ctx, "a/secret.txt")
+	if err != nil {
+		t.Fatalf("Download with encryption disabled failed: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "top secret" {
+		t.Errorf("Download with encryption disabled = %q", data)
+	}
+}
+
+// TestEncryptedProviderKeyOwners 测试文件密钥按账户包装，共享的文件为新账户补充包装
+func TestEncryptedProviderKeyOwners(t *testing.T) {
+	dir := t.TempDir()
+	local, err := NewLocalProvider(dir, "")
+	if err != nil {
+		t.Fatalf("NewLocalProvider failed: %v", err)
+	}
+	keys := &ownerKeys{used: make(map[string]bool)}
+	provider := NewEncryptedProvider(local, keys, true)
+	ctx := context.Background()
+
+	if _, err := provider.Upload(WithKeyOwners(ctx, "acc-1"), "blob", strings.NewReader("shared"), "text/plain"); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if keys.used[crypto.StorageKeyOwner] {
+		t.Error("account file should not use the storage data key")
+	}
+	owners := func() []string {
+		wrapped, _, err := decodeEncryptedFile(readRaw(t, dir, "blob"))
+		if err != nil {
+			t.Fatalf("decodeEncryptedFile failed: %v", err)
+		}
+		var names []string
+		for _, key := range wrapped {
+			names = append(names, key.owner)
+		}
+		return names
+	}
+	if got := owners(); len(got) != 1 || got[0] != "acc-1" {
+		t.Errorf("owners = %v, want [acc-1]", got)
+	}
+
+	if err := provider.ShareKey(WithKeyOwners(ctx, "acc-2"), "blob"); err != nil {
+		t.Fatalf("ShareKey failed: %v", err)
+	}
+	if err := provider.ShareKey(WithKeyOwners(ctx, "acc-2"), "blob"); err != nil {
+		t.Fatalf("repeated ShareKey failed: %v", err)
+	}
+	if got := owners(); len(got) != 2 || got[1] != "acc-2" {
+		t.Errorf("owners after sharing = %v, want [acc-1 acc-2]", got)
+	}
+
+	reader, err := provider.Download(WithKeyOwners(ctx, "acc-2"), "blob")
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "shared" {
+		t.Errorf("Download = %q", data)
+	}
+}
+
+// readRaw 读取本地存储中的原始文件
+func readRaw(t *testing.T, dir, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, path))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	return data
+}
@@ -10,8 +10,7 @@ func NewProvider(config *Config) (Provider, error) {
 	case "local", "":
 		return NewLocalProvider(config.LocalPath, config.BaseURL)
 	case "s3":
-		// TODO: 实现 S3 存储
-		return nil, fmt.Errorf("S3 storage not implemented yet")
+		return NewS3Provider(config)
 	case "oss":
 		// TODO: 实现 OSS 存储
 		return nil, fmt.Errorf("OSS storage not implemented yet")
@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 默认参数
+const (
+	defaultS3Region     = "us-east-1"
+	defaultS3PartSize   = 8 << 20 // 分片上传每片大小，超过一片的文件使用分片上传
+	minS3PartSize       = 5 << 20 // S3 要求除最后一片外每片不小于 5MB
+	defaultS3URLExpiry  = 15 * time.Minute
+	maxS3URLExpiry      = 7 * 24 * time.Hour // SigV4 预签名 URL 的最长有效期
+	s3ErrorBodyMaxBytes = 4096
+)
+
+// S3Provider S3 兼容存储提供者（AWS S3、MinIO 等）
+// 使用 SigV4 签名直接调用 REST API，GetURL 返回预签名的下载地址
+type S3Provider struct {
+	client    *http.Client
+	signer    *sigV4Signer
+	endpoint  *url.URL
+	bucket    string
+	pathStyle bool
+	partSize  int64
+	urlExpiry time.Duration
+	now       func() time.Time
+}
+
+// NewS3Provider 创建 S3 兼容存储提供者
+// 未配置 Endpoint 时使用 AWS 区域地址；MinIO 等自建服务需配置 Endpoint 并启用路径风格
+func NewS3Provider(config *Config) (*S3Provider, error) {
+	if config.S3Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if config.S3AccessKey == "" || config.S3SecretKey == "" {
+		return nil, fmt.Errorf("S3 access key and secret key are required")
+	}
+
+	region := config.S3Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	rawEndpoint := config.S3Endpoint
+	if rawEndpoint == "" {
+		rawEndpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(rawEndpoint, "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint: %s", rawEndpoint)
+	}
+
+	partSize := config.S3PartSize
+	if partSize == 0 {
+		partSize = defaultS3PartSize
+	}
+	if partSize < minS3PartSize {
+		return nil, fmt.Errorf("S3 part size must be at least %d bytes", minS3PartSize)
+	}
+
+	urlExpiry := config.S3URLExpiry
+	if urlExpiry <= 0 {
+		urlExpiry = defaultS3URLExpiry
+	}
+	if urlExpiry > maxS3URLExpiry {
+		urlExpiry = maxS3URLExpiry
+	}
+
+	return &S3Provider{
+		client: &http.Client{},
+		signer: &sigV4Signer{
+			accessKey: config.S3AccessKey,
+			secretKey: config.S3SecretKey,
+			region:    region,
+		},
+		endpoint:  endpoint,
+		bucket:    config.S3Bucket,
+		pathStyle: config.S3PathStyle,
+		partSize:  partSize,
+		urlExpiry: urlExpiry,
+		now:       time.Now,
+	}, nil
+}
+
+// Upload 上传文件，不超过一个分片大小的文件直接 PUT，更大的文件使用分片上传
+func (p *S3Provider) Upload(ctx context.Context, path string, reader io.Reader, contentType string) (string, error) {
+	first := make([]byte, p.partSize)
+	n, err := io.ReadFull(reader, first)
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		if err := p.putObject(ctx, path, first[:n], contentType); err != nil {
+			return "", err
+		}
+	case err != nil:
+		return "", fmt.Errorf("failed to read file: %w", err)
+	default:
+		if err := p.multipartUpload(ctx, path, first, reader, contentType); err != nil {
+			return "", err
+		}
+	}
+
+	return p.GetURL(ctx, path)
+}
+
+// putObject 单次上传对象
+func (p *S3Provider) putObject(ctx context.Context, path string, data []byte, contentType string) error {
+	resp, err := p.do(ctx, http.MethodPut, path, nil, data, contentType)
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// multipartUpload 分片上传（first 为已读出的第一片），失败时中止上传以释放已上传的分片
+func (p *S3Provider) multipartUpload(ctx context.Context, path string, first []byte, reader io.Reader, contentType string) error {
+	uploadID, err := p.createMultipartUpload(ctx, path, contentType)
+	if err != nil {
+		return err
+	}
+
+	parts, err := p.uploadParts(ctx, path, uploadID, first, reader)
+	if err == nil {
+		err = p.completeMultipartUpload(ctx, path, uploadID, parts)
+	}
+	if err != nil {
+		// 使用独立的 context，请求被取消时也能中止上传
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if abortErr := p.abortMultipartUpload(abortCtx, path, uploadID); abortErr != nil {
+			return errors.Join(err, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// s3CompletedPart 已上传的分片
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// uploadParts 依次上传分片
+func (p *S3Provider) uploadParts(ctx context.Context, path, uploadID string, first []byte, reader io.Reader) ([]s3CompletedPart, error) {
+	var parts []s3CompletedPart
+	buf := first
+	for number := 1; ; number++ {
+		query := url.Values{}
+		query.Set("partNumber", strconv.Itoa(number))
+		query.Set("uploadId", uploadID)
+		resp, err := p.do(ctx, http.MethodPut, path, query, buf, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part %d: %w", number, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, s3CompletedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+
+		// 复用缓冲区读取下一片
+		n, err := io.ReadFull(reader, buf[:cap(buf)])
+		if n == 0 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			return parts, nil
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		buf = buf[:n]
+	}
+}
+
+// createMultipartUpload 初始化分片上传，返回 UploadId
+func (p *S3Provider) createMultipartUpload(ctx context.Context, path, contentType string) (string, error) {
+	resp, err := p.do(ctx, http.MethodPost, path, url.Values{"uploads": {""}}, nil, contentType)
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse multipart upload response: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", fmt.Errorf("multipart upload response has no upload id")
+	}
+	return result.UploadID, nil
+}
+
+// completeMultipartUpload 合并分片
+func (p *S3Provider) completeMultipartUpload(ctx context.Context, path, uploadID string, parts []s3CompletedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.do(ctx, http.MethodPost, path, url.Values{"uploadId": {uploadID}}, body, "application/xml")
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// S3 可能在 200 响应体中返回错误
+	data, err := io.ReadAll(io.LimitReader(resp.Body, s3ErrorBodyMaxBytes))
+	if err != nil {
+		return fmt.Errorf("failed to read multipart upload response: %w", err)
+	}
+	if bytes.Contains(data, []byte("<Error>")) {
+		return fmt.Errorf("failed to complete multipart upload: %s", parseS3Error(data))
+	}
+	return nil
+}
+
+// abortMultipartUpload 中止分片上传
+func (p *S3Provider) abortMultipartUpload(ctx context.Context, path, uploadID string) error {
+	resp, err := p.do(ctx, http.MethodDelete, path, url.Values{"uploadId": {uploadID}}, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Download 下载文件
+func (p *S3Provider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	resp, err := p.do(ctx, http.MethodGet, path, nil, nil, "")
+	if err != nil {
+		if errors.Is(err, errS3NotFound) {
+			return nil, fmt.Errorf("file not found: %s", path)
+		}
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Delete 删除文件（文件不存在视为删除成功）
+func (p *S3Provider) Delete(ctx context.Context, path string) error {
+	resp, err := p.do(ctx, http.MethodDelete, path, nil, nil, "")
+	if err != nil {
+		if errors.Is(err, errS3NotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetURL 获取预签名的下载 URL（有效期由 S3URLExpiry 配置）
+func (p *S3Provider) GetURL(ctx context.Context, path string) (string, error) {
+	return p.signer.presign(http.MethodGet, p.objectURL(path, nil), p.urlExpiry, p.now()), nil
+}
+
+// Exists 检查文件是否存在
+func (p *S3Provider) Exists(ctx context.Context, path string) (bool, error) {
+	resp, err := p.do(ctx, http.MethodHead, path, nil, nil, "")
+	if err != nil {
+		if errors.Is(err, errS3NotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// objectURL 生成对象地址：路径风格为 endpoint/bucket/key，虚拟主机风格为 bucket.endpoint/key
+func (p *S3Provider) objectURL(path string, query url.Values) *url.URL {
+	u := *p.endpoint
+	key := "/" + strings.TrimPrefix(path, "/")
+	if p.pathStyle {
+		u.Path = strings.TrimSuffix(p.endpoint.Path, "/") + "/" + p.bucket + key
+	} else {
+		u.Host = p.bucket + "." + p.endpoint.Host
+		u.Path = strings.TrimSuffix(p.endpoint.Path, "/") + key
+	}
+	// 按 SigV4 规则编码路径，保证发送的路径与签名一致
+	u.RawPath = awsURIEncode(u.Path, false)
+	if query != nil {
+		u.RawQuery = canonicalQuery(query)
+	}
+	return &u
+}
+
+// errS3NotFound 对象不存在
+var errS3NotFound = errors.New("object not found")
+
+// do 发送签名请求，非 2xx 响应转换为错误（404 返回 errS3NotFound）
+func (p *S3Provider) do(ctx context.Context, method, path string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, p.objectURL(path, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = sha256Hex(body)
+	}
+	p.signer.sign(req, payloadHash, p.now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errS3NotFound
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, s3ErrorBodyMaxBytes))
+	return nil, fmt.Errorf("S3 %s %s returned %d: %s", method, path, resp.StatusCode, parseS3Error(data))
+}
+
+// parseS3Error 从 S3 错误响应中提取错误码和说明
+func parseS3Error(data []byte) string {
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(data, &s3Err); err != nil || s3Err.Code == "" {
+		return strings.TrimSpace(string(data))
+	}
+	return s3Err.Code + ": " + s3Err.Message
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"io"
+	"time"
 )
 
 // Provider 存储提供者接口
@@ -40,10 +41,14 @@ type Config struct {
 	BaseURL   string // 基础 URL（用于生成访问链接）
 
 	// S3 配置
+	S3Endpoint  string // 自定义服务地址（MinIO 等），为空时使用 AWS 区域地址
 	S3Region    string
 	S3Bucket    string
 	S3AccessKey string
 	S3SecretKey string
+	S3PathStyle bool          // 使用路径风格地址（endpoint/bucket/key），MinIO 需要启用
+	S3PartSize  int64         // 分片上传每片大小（字节），超过一片的文件使用分片上传，0 表示默认 8MB
+	S3URLExpiry time.Duration // 预签名下载 URL 有效期，0 表示默认 15 分钟
 
 	// OSS 配置
 	OSSEndpoint  string
@@ -0,0 +1,102 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+	"fusionmail/pkg/crypto"
+)
+
+// TestSyncKeepsAccountCounters 同步结束保存账户状态时不能覆盖邮件写入事务维护的统计字段
+func TestSyncKeepsAccountCounters(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.AutoMigrate(&model.SyncLog{}); err != nil {
+		t.Fatalf("Failed to migrate sync logs: %v", err)
+	}
+	ctx := context.Background()
+
+	encryptor, err := crypto.NewEncryptor()
+	if err != nil {
+		t.Fatalf("Failed to create encryptor: %v", err)
+	}
+	credentials, err := encryptor.Encrypt("demo")
+	if err != nil {
+		t.Fatalf("Failed to encrypt credentials: %v", err)
+	}
+
+	accountUID := "sync-counter-account"
+	account := &model.Account{
+		UID: accountUID, Email: "demo@example.com", Provider: "demo", Protocol: "demo",
+		AuthType: "password", EncryptedCredentials: credentials, Status: "active", SyncEnabled: true,
+	}
+	if err := db.Create(account).Error; err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	accountRepo := repository.NewAccountRepository(db)
+	emailRepo := &createOnlyEmailRepo{EmailRepository: repository.NewEmailRepository(db, nil)}
+	factory := adapter.NewFactory(adapter.FactoryOptions{Demo: adapter.DemoOptions{Interval: 6 * time.Hour}})
+
+	syncService, err := service.NewSyncService(accountRepo, emailRepo, repository.NewSyncLogRepository(db), factory,
+		service.SchedulerOptions{}, service.ExecutorOptions{}, service.ReconcileOptions{}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create sync service: %v", err)
+	}
+	if err := syncService.ExecuteSync(ctx, accountUID, "scheduled"); err != nil {
+		t.Fatalf("ExecuteSync failed: %v", err)
+	}
+
+	var emails int64
+	db.Model(&model.Email{}).Where("account_uid = ?", accountUID).Count(&emails)
+	if emails == 0 {
+		t.Fatal("sync stored no emails")
+	}
+
+	reloaded, err := accountRepo.FindByUID(ctx, accountUID)
+	if err != nil || reloaded == nil {
+		t.Fatalf("Failed to reload account: %v", err)
+	}
+	if int64(reloaded.TotalEmails) != emails || int64(reloaded.UnreadCount) != emails {
+		t.Errorf("account counts after sync = %d/%d, want %d/%d", reloaded.TotalEmails, reloaded.UnreadCount, emails, emails)
+	}
+	if reloaded.LastSyncStatus != "success" || reloaded.LastSyncAt == nil {
+		t.Errorf("sync status = %q (last sync at %v), want success", reloaded.LastSyncStatus, reloaded.LastSyncAt)
+	}
+
+	// 用同步前读取的账户整行保存（如修改账户设置），统计字段同样保持不变
+	account.SyncInterval = 15
+	if err := accountRepo.Update(ctx, account); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	reloaded, err = accountRepo.FindByUID(ctx, accountUID)
+	if err != nil || reloaded == nil {
+		t.Fatalf("Failed to reload account: %v", err)
+	}
+	if reloaded.SyncInterval != 15 {
+		t.Errorf("sync interval = %d, want 15", reloaded.SyncInterval)
+	}
+	if int64(reloaded.TotalEmails) != emails {
+		t.Errorf("account total after update = %d, want %d", reloaded.TotalEmails, emails)
+	}
+}
+
+// createOnlyEmailRepo 用 CreateBatch 模拟 UpsertBatch（upsert 依赖 PostgreSQL 的 xmax，sqlite 不支持）
+type createOnlyEmailRepo struct {
+	repository.EmailRepository
+}
+
+func (r *createOnlyEmailRepo) UpsertBatch(ctx context.Context, emails []*model.Email) ([]repository.UpsertResult, error) {
+	if err := r.CreateBatch(ctx, emails); err != nil {
+		return nil, err
+	}
+	results := make([]repository.UpsertResult, 0, len(emails))
+	for _, email := range emails {
+		results = append(results, repository.UpsertResult{ID: email.ID, ProviderID: email.ProviderID, AccountUID: email.AccountUID, Inserted: true})
+	}
+	return results, nil
+}
@@ -0,0 +1,128 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+	"fusionmail/pkg/storage"
+)
+
+// TestAttachmentDeduplication 附件内容去重、引用计数和垃圾回收集成测试
+func TestAttachmentDeduplication(t *testing.T) {
+	db := setupTestDB(t)
+	storageDir := t.TempDir()
+	provider, err := storage.NewLocalProvider(storageDir, "")
+	if err != nil {
+		t.Fatalf("Failed to create storage provider: %v", err)
+	}
+	attachmentService := service.NewAttachmentService(repository.NewAttachmentRepository(db), provider, service.AttachmentOptions{})
+	ctx := context.Background()
+
+	content := []byte("%PDF-1.4 newsletter logo")
+	save := func(emailID int64, filename string) *model.EmailAttachment {
+		t.Helper()
+		attachment := &model.EmailAttachment{
+			EmailID:     emailID,
+			Filename:    filename,
+			ContentType: "application/pdf",
+			SizeBytes:   int64(len(content)),
+		}
+		if err := attachmentService.SaveAttachment(ctx, attachment, bytes.NewReader(content)); err != nil {
+			t.Fatalf("SaveAttachment failed: %v", err)
+		}
+		return attachment
+	}
+	// blobState 返回内容记录的引用数（不存在时为 -1）和存储对象是否存在
+	blobState := func(attachment *model.EmailAttachment) (int64, bool) {
+		t.Helper()
+		var blobs []model.AttachmentBlob
+		if err := db.Where("sha256 = ?", attachment.BlobHash).Find(&blobs).Error; err != nil {
+			t.Fatalf("Failed to load blob: %v", err)
+		}
+		_, statErr := os.Stat(filepath.Join(storageDir, attachment.StoragePath))
+		if len(blobs) == 0 {
+			return -1, statErr == nil
+		}
+		return blobs[0].RefCount, statErr == nil
+	}
+
+	first := save(1, "logo.pdf")
+	second := save(2, "copy.pdf")
+	if first.BlobHash == "" || first.StoragePath != second.StoragePath {
+		t.Fatalf("identical attachments stored separately: %q vs %q", first.StoragePath, second.StoragePath)
+	}
+	if refs, stored := blobState(first); refs != 2 || !stored {
+		t.Fatalf("after two saves: refs = %d, stored = %v; want 2, true", refs, stored)
+	}
+
+	reader, _, err := attachmentService.DownloadAttachment(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("DownloadAttachment failed: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(data, content) {
+		t.Errorf("downloaded %q, want %q", data, content)
+	}
+
+	// 删除一个引用后内容保留
+	if err := attachmentService.DeleteAttachment(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteAttachment failed: %v", err)
+	}
+	if refs, stored := blobState(second); refs != 1 || !stored {
+		t.Fatalf("after first delete: refs = %d, stored = %v; want 1, true", refs, stored)
+	}
+
+	// 删除最后一个引用后回收内容
+	if err := attachmentService.DeleteAttachment(ctx, second.ID); err != nil {
+		t.Fatalf("DeleteAttachment failed: %v", err)
+	}
+	if refs, stored := blobState(second); refs != -1 || stored {
+		t.Fatalf("after last delete: refs = %d, stored = %v; want blob reclaimed", refs, stored)
+	}
+
+	// 绕过服务删除附件记录（邮件级联删除），引用数偏大，由垃圾回收按实际引用回收
+	orphan := save(3, "orphan.pdf")
+	if err := db.Delete(&model.EmailAttachment{}, orphan.ID).Error; err != nil {
+		t.Fatalf("Failed to delete attachment row: %v", err)
+	}
+
+	// 宽限期内的内容不回收
+	result, err := attachmentService.CollectGarbage(ctx, time.Hour, false)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if result.Reclaimed != 0 {
+		t.Errorf("reclaimed %d blobs within grace period, want 0", result.Reclaimed)
+	}
+
+	result, err = attachmentService.CollectGarbage(ctx, -time.Second, true)
+	if err != nil {
+		t.Fatalf("CollectGarbage dry run failed: %v", err)
+	}
+	if result.Reclaimed != 1 || result.ReclaimedBytes != int64(len(content)) {
+		t.Errorf("dry run = %+v, want 1 blob of %d bytes", result, len(content))
+	}
+	if refs, stored := blobState(orphan); refs != 1 || !stored {
+		t.Fatalf("after dry run: refs = %d, stored = %v; want blob kept", refs, stored)
+	}
+
+	result, err = attachmentService.CollectGarbage(ctx, -time.Second, false)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if result.Recounted != 1 || result.Reclaimed != 1 || result.Failed != 0 {
+		t.Errorf("gc = %+v, want 1 recounted and 1 reclaimed", result)
+	}
+	if refs, stored := blobState(orphan); refs != -1 || stored {
+		t.Fatalf("after gc: refs = %d, stored = %v; want blob reclaimed", refs, stored)
+	}
+}
@@ -0,0 +1,118 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+)
+
+// TestMailboxCounters 邮件计数器维护集成测试
+func TestMailboxCounters(t *testing.T) {
+	db := setupTestDB(t)
+	emailRepo := repository.NewEmailRepository(db, nil)
+	counterRepo := repository.NewCounterRepository(db)
+	ctx := context.Background()
+
+	accountUID := "counter-account"
+	account := &model.Account{UID: accountUID, Email: "user@example.com", Provider: "imap", Protocol: "imap", AuthType: "password", EncryptedCredentials: "x"}
+	if err := db.Create(account).Error; err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+
+	newEmail := func(providerID, folder string) *model.Email {
+		return &model.Email{
+			ProviderID:   providerID,
+			AccountUID:   accountUID,
+			Subject:      providerID,
+			FromAddress:  "sender@example.com",
+			SourceFolder: folder,
+			SentAt:       time.Now(),
+			ReceivedAt:   time.Now(),
+		}
+	}
+	inbox1, inbox2, sent := newEmail("m1", "INBOX"), newEmail("m2", "INBOX"), newEmail("m3", "Sent")
+	if err := emailRepo.CreateBatch(ctx, []*model.Email{inbox1, inbox2, sent}); err != nil {
+		t.Fatalf("Failed to create emails: %v", err)
+	}
+
+	// expect 校验账户汇总、账户统计字段和未读数
+	expect := func(step string, total, unread, starred int64) {
+		t.Helper()
+		totals, err := counterRepo.Totals(ctx, accountUID)
+		if err != nil {
+			t.Fatalf("%s: Totals failed: %v", step, err)
+		}
+		if totals.TotalCount != total || totals.UnreadCount != unread || totals.StarredCount != starred {
+			t.Errorf("%s: totals = %d/%d/%d, want %d/%d/%d", step,
+				totals.TotalCount, totals.UnreadCount, totals.StarredCount, total, unread, starred)
+		}
+
+		var reloaded model.Account
+		if err := db.First(&reloaded, account.ID).Error; err != nil {
+			t.Fatalf("%s: failed to reload account: %v", step, err)
+		}
+		if int64(reloaded.TotalEmails) != total || int64(reloaded.UnreadCount) != unread {
+			t.Errorf("%s: account counts = %d/%d, want %d/%d", step, reloaded.TotalEmails, reloaded.UnreadCount, total, unread)
+		}
+
+		if count, err := emailRepo.CountUnread(ctx, accountUID); err != nil || count != unread {
+			t.Errorf("%s: CountUnread = %d, %v; want %d", step, count, err, unread)
+		}
+	}
+
+	expect("create", 3, 3, 0)
+
+	if err := emailRepo.MarkAsRead(ctx, []int64{inbox1.ID, inbox2.ID}); err != nil {
+		t.Fatalf("MarkAsRead failed: %v", err)
+	}
+	// 重复标记不应重复扣减
+	if err := emailRepo.MarkAsRead(ctx, []int64{inbox1.ID}); err != nil {
+		t.Fatalf("MarkAsRead failed: %v", err)
+	}
+	expect("mark read", 3, 1, 0)
+
+	if err := emailRepo.SetStarred(ctx, []int64{sent.ID}, true); err != nil {
+		t.Fatalf("SetStarred failed: %v", err)
+	}
+	expect("star", 3, 1, 1)
+
+	deleted := true
+	if err := emailRepo.UpdateLocalStatus(ctx, sent.ID, nil, nil, nil, &deleted); err != nil {
+		t.Fatalf("UpdateLocalStatus failed: %v", err)
+	}
+	expect("local delete", 2, 0, 0)
+
+	if err := emailRepo.UpdateSourceFolder(ctx, []int64{inbox2.ID}, "Archive"); err != nil {
+		t.Fatalf("UpdateSourceFolder failed: %v", err)
+	}
+	expect("move folder", 2, 0, 0)
+
+	folders, err := counterRepo.ListByAccount(ctx, accountUID)
+	if err != nil {
+		t.Fatalf("ListByAccount failed: %v", err)
+	}
+	got := make(map[string]int64)
+	for _, counter := range folders {
+		got[counter.Folder] = counter.TotalCount
+	}
+	if len(got) != 2 || got["INBOX"] != 1 || got["Archive"] != 1 {
+		t.Errorf("folder counters = %v, want INBOX:1 Archive:1", got)
+	}
+
+	if _, err := emailRepo.Purge(ctx, []int64{inbox1.ID}); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	expect("hard delete", 1, 0, 0)
+
+	// 永久删除的邮件留下墓碑，同步时跳过
+	purged, err := emailRepo.FindPurgedProviderIDs(ctx, accountUID, []string{inbox1.ProviderID, inbox2.ProviderID})
+	if err != nil {
+		t.Fatalf("FindPurgedProviderIDs failed: %v", err)
+	}
+	if len(purged) != 1 || !purged[inbox1.ProviderID] {
+		t.Errorf("purged = %v, want only %s", purged, inbox1.ProviderID)
+	}
+}
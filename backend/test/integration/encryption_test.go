@@ -0,0 +1,238 @@
+package integration
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"fusionmail/internal/adapter"
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+	"fusionmail/pkg/crypto"
+	"fusionmail/pkg/storage"
+)
+
+// TestEmailEncryptionAtRest 邮件正文静态加密和已有数据加密迁移集成测试
+func TestEmailEncryptionAtRest(t *testing.T) {
+	db := setupTestDB(t)
+	t.Setenv("ENCRYPTION_KEY", "integration-test-master-key-0001")
+	master, err := crypto.NewEncryptor()
+	if err != nil {
+		t.Fatalf("Failed to create encryptor: %v", err)
+	}
+	keyring := crypto.NewKeyring(master, repository.NewDataKeyRepository(db))
+	plainRepo := repository.NewEmailRepository(db, crypto.NewFieldCipher(keyring, false))
+	encryptedRepo := repository.NewEmailRepository(db, crypto.NewFieldCipher(keyring, true))
+	ctx := context.Background()
+
+	accountUID := "encrypted-account"
+	newEmail := func(providerID, body string) *model.Email {
+		return &model.Email{
+			ProviderID:  providerID,
+			AccountUID:  accountUID,
+			Subject:     providerID,
+			FromAddress: "sender@example.com",
+			TextBody:    body,
+			HTMLBody:    "<p>" + body + "</p>",
+			SentAt:      time.Now(),
+			ReceivedAt:  time.Now(),
+		}
+	}
+	// storedBody 读取数据库中实际保存的正文
+	storedBody := func(id int64) string {
+		t.Helper()
+		var email model.Email
+		if err := db.Select("text_body").Take(&email, id).Error; err != nil {
+			t.Fatalf("Failed to load email: %v", err)
+		}
+		return email.TextBody
+	}
+
+	legacy := newEmail("legacy", "Quarterly invoice attached")
+	if err := plainRepo.Create(ctx, legacy); err != nil {
+		t.Fatalf("Create plaintext email failed: %v", err)
+	}
+	secret := newEmail("secret", "Your verification code is 483920")
+	if err := encryptedRepo.Create(ctx, secret); err != nil {
+		t.Fatalf("Create encrypted email failed: %v", err)
+	}
+	if secret.TextBody != "Your verification code is 483920" {
+		t.Errorf("Create should leave the caller's email in plaintext, got %q", secret.TextBody)
+	}
+
+	if stored := storedBody(secret.ID); !crypto.IsEncryptedField(stored) || strings.Contains(stored, "483920") {
+		t.Errorf("body stored in plaintext: %q", stored)
+	}
+	if stored := storedBody(legacy.ID); stored != legacy.TextBody {
+		t.Errorf("plaintext body changed: %q", stored)
+	}
+
+	found, err := encryptedRepo.FindByID(ctx, secret.ID)
+	if err != nil || found == nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if found.TextBody != secret.TextBody || found.HTMLBody != secret.HTMLBody {
+		t.Errorf("FindByID returned %q / %q", found.TextBody, found.HTMLBody)
+	}
+
+	var tokens int64
+	db.Model(&model.EmailSearchToken{}).Where("email_id = ?", secret.ID).Count(&tokens)
+	if tokens != int64(len(strings.Fields(secret.TextBody))) {
+		t.Errorf("search index has %d tokens, want %d", tokens, len(strings.Fields(secret.TextBody)))
+	}
+
+	// 加密迁移：只处理明文邮件，重复执行不再改动
+	total := 0
+	var afterID int64
+	for {
+		lastID, count, err := encryptedRepo.EncryptExisting(ctx, afterID, 1)
+		if err != nil {
+			t.Fatalf("EncryptExisting failed: %v", err)
+		}
+		if lastID == 0 {
+			break
+		}
+		total += count
+		afterID = lastID
+	}
+	if total != 1 {
+		t.Errorf("EncryptExisting encrypted %d emails, want 1", total)
+	}
+	if stored := storedBody(legacy.ID); !crypto.IsEncryptedField(stored) {
+		t.Errorf("legacy body not encrypted: %q", stored)
+	}
+	if _, count, _ := encryptedRepo.EncryptExisting(ctx, 0, 10); count != 0 {
+		t.Errorf("second EncryptExisting encrypted %d emails, want 0", count)
+	}
+
+	// 关闭加密后已加密的正文仍可读取
+	emails, err := plainRepo.FindByIDs(ctx, []int64{legacy.ID, secret.ID})
+	if err != nil || len(emails) != 2 {
+		t.Fatalf("FindByIDs = %d, %v", len(emails), err)
+	}
+	for _, email := range emails {
+		if crypto.IsEncryptedField(email.TextBody) {
+			t.Errorf("email %d not decrypted", email.ID)
+		}
+	}
+
+	if _, err := encryptedRepo.Purge(ctx, []int64{secret.ID}); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	db.Model(&model.EmailSearchToken{}).Where("email_id = ?", secret.ID).Count(&tokens)
+	if tokens != 0 {
+		t.Errorf("search index not removed on delete: %d tokens", tokens)
+	}
+}
+
+// TestThreadEncryptedBodies 启用加密时会话详情返回解密后的正文
+func TestThreadEncryptedBodies(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.AutoMigrate(&model.Thread{}); err != nil {
+		t.Fatalf("Failed to migrate thread tables: %v", err)
+	}
+	t.Setenv("ENCRYPTION_KEY", "integration-test-master-key-0001")
+	master, err := crypto.NewEncryptor()
+	if err != nil {
+		t.Fatalf("Failed to create encryptor: %v", err)
+	}
+	cipher := crypto.NewFieldCipher(crypto.NewKeyring(master, repository.NewDataKeyRepository(db)), true)
+	emailRepo := repository.NewEmailRepository(db, cipher)
+	threads := service.NewThreadService(repository.NewThreadRepository(db, cipher), emailRepo, nil)
+	ctx := context.Background()
+
+	// 会话统计使用 PostgreSQL 聚合函数，这里直接创建会话并指定邮件所属会话
+	thread := &model.Thread{Subject: "Secret thread", MessageCount: 1, LastMessageAt: time.Now()}
+	if err := db.Create(thread).Error; err != nil {
+		t.Fatalf("Create thread failed: %v", err)
+	}
+	email := &model.Email{
+		LocalThreadID: &thread.ID,
+		ProviderID:    "thread-secret",
+		AccountUID:    "encrypted-account",
+		MessageID:     "<thread-secret@example.com>",
+		Subject:       "Secret thread",
+		TextBody:      "Launch code 0000",
+		HTMLBody:      "<p>Launch code 0000</p>",
+		SentAt:        time.Now(),
+		ReceivedAt:    time.Now(),
+	}
+	if err := emailRepo.Create(ctx, email); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	var stored model.Email
+	if err := db.Select("text_body").Take(&stored, email.ID).Error; err != nil || !crypto.IsEncryptedField(stored.TextBody) {
+		t.Fatalf("body not stored encrypted: %q, %v", stored.TextBody, err)
+	}
+
+	detail, err := threads.GetThread(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("GetThread failed: %v", err)
+	}
+	if len(detail.Emails) != 1 {
+		t.Fatalf("thread has %d emails, want 1", len(detail.Emails))
+	}
+	if got := detail.Emails[0]; got.TextBody != email.TextBody || got.HTMLBody != email.HTMLBody {
+		t.Errorf("GetThread returned %q / %q", got.TextBody, got.HTMLBody)
+	}
+}
+
+// TestEncryptedAttachmentKeyOwners 加密附件的文件密钥按账户包装，去重共享的内容为每个账户各包装一份
+func TestEncryptedAttachmentKeyOwners(t *testing.T) {
+	db := setupTestDB(t)
+	t.Setenv("ENCRYPTION_KEY", "integration-test-master-key-0001")
+	master, err := crypto.NewEncryptor()
+	if err != nil {
+		t.Fatalf("Failed to create encryptor: %v", err)
+	}
+	local, err := storage.NewLocalProvider(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Failed to create storage provider: %v", err)
+	}
+	encrypted := storage.NewEncryptedProvider(local, crypto.NewKeyring(master, repository.NewDataKeyRepository(db)), true)
+	emailRepo := repository.NewEmailRepository(db, nil)
+	attachments := service.NewAttachmentService(repository.NewAttachmentRepository(db), encrypted, service.AttachmentOptions{StorageType: "local"})
+	ctx := context.Background()
+
+	content := []byte("shared quarterly report")
+	var saved []*model.EmailAttachment
+	for _, accountUID := range []string{"account-a", "account-b"} {
+		email := &model.Email{ProviderID: "report", AccountUID: accountUID, SentAt: time.Now(), ReceivedAt: time.Now()}
+		if err := emailRepo.Create(ctx, email); err != nil {
+			t.Fatalf("Create email failed: %v", err)
+		}
+		err := attachments.SaveEmailAttachments(ctx, &model.Account{UID: accountUID}, email.ID, []adapter.Attachment{
+			{Filename: "report.txt", ContentType: "text/plain", Content: content},
+		})
+		if err != nil {
+			t.Fatalf("SaveEmailAttachments failed: %v", err)
+		}
+		stored, _ := attachments.GetAttachmentsByEmailID(ctx, email.ID)
+		saved = append(saved, stored...)
+	}
+	if len(saved) != 2 || saved[0].StoragePath != saved[1].StoragePath {
+		t.Fatalf("attachments not deduplicated: %+v", saved)
+	}
+
+	var owners []string
+	db.Model(&model.DataKey{}).Order("owner ASC").Pluck("owner", &owners)
+	if strings.Join(owners, ",") != "account-a,account-b" {
+		t.Errorf("data key owners = %v, want one per account and no shared storage key", owners)
+	}
+
+	// 两个账户都已包装，加密迁移不再改写
+	if files, err := attachments.EncryptStoredFiles(ctx, encrypted); err != nil || files != 0 {
+		t.Errorf("EncryptStoredFiles = %d, %v, want 0", files, err)
+	}
+	reader, _, err := attachments.DownloadAttachment(ctx, saved[1].ID)
+	if err != nil {
+		t.Fatalf("DownloadAttachment failed: %v", err)
+	}
+	defer reader.Close()
+	if data, _ := io.ReadAll(reader); string(data) != string(content) {
+		t.Errorf("DownloadAttachment = %q", data)
+	}
+}
@@ -0,0 +1,108 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+	"fusionmail/pkg/storage"
+)
+
+// TestRawMessageArchive 原始邮件归档、头部查看和重新解析集成测试
+func TestRawMessageArchive(t *testing.T) {
+	db := setupTestDB(t)
+	provider, err := storage.NewLocalProvider(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("Failed to create storage provider: %v", err)
+	}
+	emailRepo := repository.NewEmailRepository(db, nil)
+	rawMessages := service.NewRawMessageService(emailRepo, provider)
+	ctx := context.Background()
+
+	raw := strings.Join([]string{
+		"Received: from mx1.example.com by mx2.example.com",
+		"Received: from sender.example.com by mx1.example.com",
+		"From: Alice <alice@example.com>",
+		"To: bob@example.com",
+		"Subject: =?UTF-8?B?5Lya6K6u57qq6KaB?=",
+		"Message-ID: <minutes@example.com>",
+		"Date: Tue, 02 Jul 2024 09:30:00 +0000",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"Minutes attached below.",
+		"",
+	}, "\r\n")
+
+	// 模拟旧解析器丢失了正文和主题
+	email := &model.Email{
+		ProviderID:  "42",
+		AccountUID:  "raw-account",
+		Subject:     "No Subject",
+		FromAddress: "alice@example.com",
+		SentAt:      time.Now(),
+		ReceivedAt:  time.Now(),
+		IsStarred:   true,
+	}
+	if err := emailRepo.Create(ctx, email); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if _, err := rawMessages.Headers(ctx, email.ID); !errors.Is(err, service.ErrRawMessageNotArchived) {
+		t.Errorf("Headers before archive = %v, want ErrRawMessageNotArchived", err)
+	}
+	if err := rawMessages.Archive(ctx, email, []byte(raw)); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if !strings.HasPrefix(email.RawPath, "raw/raw-account/") || !strings.HasSuffix(email.RawPath, ".eml.gz") {
+		t.Errorf("RawPath = %q", email.RawPath)
+	}
+
+	reader, _, err := rawMessages.Open(ctx, email.ID)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != raw {
+		t.Errorf("Open returned %q", data)
+	}
+
+	headers, err := rawMessages.Headers(ctx, email.ID)
+	if err != nil {
+		t.Fatalf("Headers failed: %v", err)
+	}
+	if len(headers) != 8 || headers[0].Name != "Received" || !strings.Contains(headers[1].Value, "sender.example.com") {
+		t.Errorf("headers not in original order: %+v", headers)
+	}
+	if headers[4].Name != "Subject" || headers[4].Decoded != "会议纪要" {
+		t.Errorf("subject header = %+v", headers[4])
+	}
+	if headers[5].Name != "Message-ID" {
+		t.Errorf("header name case not kept: %q", headers[5].Name)
+	}
+
+	if _, _, err := rawMessages.Open(ctx, email.ID+100); !errors.Is(err, service.ErrEmailNotFound) {
+		t.Errorf("Open missing email = %v, want ErrEmailNotFound", err)
+	}
+
+	result, err := rawMessages.ReparseAll(ctx, "raw-account", nil)
+	if err != nil || result.Reparsed != 1 || result.Failed != 0 {
+		t.Fatalf("ReparseAll = %+v, %v", result, err)
+	}
+	reparsed, err := emailRepo.FindByID(ctx, email.ID)
+	if err != nil || reparsed == nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if reparsed.Subject != "会议纪要" || reparsed.TextBody != "Minutes attached below.\r\n" || reparsed.MessageID != "minutes@example.com" {
+		t.Errorf("reparsed email = %q / %q / %q", reparsed.Subject, reparsed.TextBody, reparsed.MessageID)
+	}
+	if !reparsed.IsStarred || reparsed.RawPath != email.RawPath {
+		t.Error("reparse should keep local state and raw path")
+	}
+}
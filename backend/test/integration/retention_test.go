@@ -0,0 +1,196 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+	"fusionmail/pkg/storage"
+)
+
+// TestRetentionPolicies 保留策略清理集成测试：试运行只统计，实际清理后删除邮件、附件、原文和旧日志
+func TestRetentionPolicies(t *testing.T) {
+	db := setupTestDB(t)
+	if err := db.AutoMigrate(&model.SyncLog{}, &model.WebhookLog{}); err != nil {
+		t.Fatalf("Failed to migrate log tables: %v", err)
+	}
+	dir := t.TempDir()
+	provider, err := storage.NewLocalProvider(dir, "")
+	if err != nil {
+		t.Fatalf("Failed to create storage provider: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+
+	accountRepo := repository.NewAccountRepository(db)
+	emailRepo := repository.NewEmailRepository(db, nil)
+	attachments := service.NewAttachmentService(repository.NewAttachmentRepository(db), provider, service.AttachmentOptions{StorageType: "local"})
+	rawMessages := service.NewRawMessageService(emailRepo, provider)
+	retention := service.NewRetentionService(
+		accountRepo, emailRepo, attachments,
+		service.NewEmailPurger(emailRepo, attachments, rawMessages, nil), nil,
+		repository.NewSyncLogRepository(db), repository.NewWebhookLogRepository(db),
+		service.RetentionOptions{
+			Policy:         service.RetentionPolicy{TrashDays: 30, ArchiveDays: 90, AttachmentDays: 30, AttachmentMB: 1},
+			SyncLogDays:    90,
+			WebhookLogDays: 30,
+		},
+	)
+
+	// 账户 B 不清理回收站，归档邮件 10 天后移入回收站
+	for _, account := range []*model.Account{
+		{UID: "account-a", Email: "a@example.com", Provider: "imap", Protocol: "imap", AuthType: "password", EncryptedCredentials: "x"},
+		{UID: "account-b", Email: "b@example.com", Provider: "imap", Protocol: "imap", AuthType: "password", EncryptedCredentials: "x",
+			TrashRetentionDays: -1, ArchiveRetentionDays: 10},
+	} {
+		if err := accountRepo.Create(ctx, account); err != nil {
+			t.Fatalf("Create account failed: %v", err)
+		}
+	}
+
+	createEmail := func(accountUID, providerID string, sentAt time.Time, canonicalID *int64) *model.Email {
+		email := &model.Email{ProviderID: providerID, AccountUID: accountUID, Subject: providerID,
+			DedupKey: "dedup-" + providerID, CanonicalID: canonicalID, SentAt: sentAt, ReceivedAt: sentAt}
+		if err := emailRepo.Create(ctx, email); err != nil {
+			t.Fatalf("Create email failed: %v", err)
+		}
+		return email
+	}
+	trash := func(email *model.Email, trashedAt time.Time) {
+		if err := emailRepo.SetDeleted(ctx, []int64{email.ID}, true); err != nil {
+			t.Fatalf("SetDeleted failed: %v", err)
+		}
+		db.Model(&model.Email{}).Where("id = ?", email.ID).UpdateColumn("trashed_at", trashedAt)
+	}
+
+	oldTrash := createEmail("account-a", "old-trash", daysAgo(50), nil)
+	if err := rawMessages.Archive(ctx, oldTrash, []byte("Subject: old\r\n\r\nBody\r\n")); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+	if err := attachments.SaveAttachment(ctx, &model.EmailAttachment{EmailID: oldTrash.ID, Filename: "a.txt", SizeBytes: 5}, strings.NewReader("hello")); err != nil {
+		t.Fatalf("SaveAttachment failed: %v", err)
+	}
+	trash(oldTrash, daysAgo(40))
+	recentTrash := createEmail("account-a", "recent-trash", daysAgo(50), nil)
+	trash(recentTrash, daysAgo(1))
+	// 保留策略上线前移入回收站、没有移入时间的邮件不按最后更新时间清理
+	legacyTrash := createEmail("account-a", "legacy-trash", daysAgo(50), nil)
+	trash(legacyTrash, daysAgo(40))
+	db.Model(&model.Email{}).Where("id = ?", legacyTrash.ID).UpdateColumns(map[string]interface{}{"trashed_at": nil, "updated_at": daysAgo(40)})
+
+	oldArchived := createEmail("account-a", "old-archived", daysAgo(100), nil)
+	starredArchived := createEmail("account-a", "starred-archived", daysAgo(100), nil)
+	emailRepo.SetArchived(ctx, []int64{oldArchived.ID, starredArchived.ID}, true)
+	emailRepo.SetStarred(ctx, []int64{starredArchived.ID}, true)
+
+	withAttachments := createEmail("account-a", "attachments", daysAgo(60), nil)
+	large := &model.EmailAttachment{EmailID: withAttachments.ID, Filename: "large.zip", SizeBytes: 2 << 20}
+	small := &model.EmailAttachment{EmailID: withAttachments.ID, Filename: "small.txt", SizeBytes: 5}
+	attachments.SaveAttachment(ctx, large, strings.NewReader("large content"))
+	attachments.SaveAttachment(ctx, small, strings.NewReader("small"))
+
+	duplicate := createEmail("account-b", "duplicate", daysAgo(1), &oldTrash.ID)
+	keptTrash := createEmail("account-b", "kept-trash", daysAgo(500), nil)
+	trash(keptTrash, daysAgo(400))
+	recentArchived := createEmail("account-b", "recent-archived", daysAgo(20), nil)
+	emailRepo.SetArchived(ctx, []int64{recentArchived.ID}, true)
+
+	db.Create(&model.SyncLog{AccountUID: "account-a", SyncType: "scheduled", Status: "success", StartedAt: daysAgo(100)})
+	db.Create(&model.SyncLog{AccountUID: "account-a", SyncType: "scheduled", Status: "success", StartedAt: daysAgo(1)})
+	db.Create(&model.WebhookLog{WebhookID: 1, RequestURL: "https://example.com", CreatedAt: daysAgo(40)})
+	db.Create(&model.WebhookLog{WebhookID: 1, RequestURL: "https://example.com", CreatedAt: now})
+
+	check := func(report *service.RetentionReport) {
+		t.Helper()
+		a, b := report.Accounts[0], report.Accounts[1]
+		if a.TrashPurged != 1 || a.ArchiveTrashed != 1 || a.AttachmentsReleased != 1 || a.AttachmentBytes != 2<<20 || a.Failed != 0 {
+			t.Errorf("account a report = %+v", a)
+		}
+		if b.Policy.TrashDays != 0 || b.Policy.ArchiveDays != 10 || b.TrashPurged != 0 || b.ArchiveTrashed != 1 {
+			t.Errorf("account b report = %+v", b)
+		}
+		if report.SyncLogsDeleted != 1 || report.WebhookLogsDeleted != 1 {
+			t.Errorf("logs deleted = %d, %d", report.SyncLogsDeleted, report.WebhookLogsDeleted)
+		}
+	}
+
+	// 试运行不修改任何数据
+	report, err := retention.Run(ctx, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	check(report)
+	if email, _ := emailRepo.FindByID(ctx, oldTrash.ID); email == nil {
+		t.Error("dry run purged email")
+	}
+	if email, _ := emailRepo.FindByID(ctx, oldArchived.ID); email.IsDeleted {
+		t.Error("dry run moved archived email to trash")
+	}
+
+	report, err = retention.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	check(report)
+
+	// 回收站邮件连同附件和原文一起删除，重复组中的副本成为主副本
+	if email, _ := emailRepo.FindByID(ctx, oldTrash.ID); email != nil {
+		t.Error("trashed email was not purged")
+	}
+	if _, err := os.Stat(filepath.Join(dir, oldTrash.RawPath)); !os.IsNotExist(err) {
+		t.Errorf("raw message not deleted: %v", err)
+	}
+	if remaining, _ := attachments.GetAttachmentsByEmailID(ctx, oldTrash.ID); len(remaining) != 0 {
+		t.Errorf("attachments of purged email = %d", len(remaining))
+	}
+	if email, _ := emailRepo.FindByID(ctx, duplicate.ID); email.CanonicalID != nil {
+		t.Errorf("duplicate canonical id = %v", *email.CanonicalID)
+	}
+	for _, kept := range []*model.Email{recentTrash, legacyTrash, keptTrash} {
+		if email, _ := emailRepo.FindByID(ctx, kept.ID); email == nil {
+			t.Errorf("email %s was purged", kept.ProviderID)
+		}
+	}
+
+	// 过期归档邮件移入回收站，星标邮件不受影响
+	for _, moved := range []*model.Email{oldArchived, recentArchived} {
+		if email, _ := emailRepo.FindByID(ctx, moved.ID); !email.IsDeleted || email.TrashedAt == nil {
+			t.Errorf("archived email %s not moved to trash", moved.ProviderID)
+		}
+	}
+	if email, _ := emailRepo.FindByID(ctx, starredArchived.ID); email.IsDeleted {
+		t.Error("starred archived email moved to trash")
+	}
+
+	// 大附件只保留元数据，内容文件被回收
+	released, _ := attachments.GetAttachment(ctx, large.ID)
+	if released.StorageType != "none" || released.BlobHash != "" {
+		t.Errorf("large attachment = %+v", released)
+	}
+	if _, err := os.Stat(filepath.Join(dir, large.StoragePath)); !os.IsNotExist(err) {
+		t.Errorf("large attachment content not reclaimed: %v", err)
+	}
+	if kept, _ := attachments.GetAttachment(ctx, small.ID); kept.StorageType != "local" {
+		t.Errorf("small attachment = %+v", kept)
+	}
+
+	var syncLogs, webhookLogs int64
+	db.Model(&model.SyncLog{}).Count(&syncLogs)
+	db.Model(&model.WebhookLog{}).Count(&webhookLogs)
+	if syncLogs != 1 || webhookLogs != 1 {
+		t.Errorf("remaining logs = %d, %d", syncLogs, webhookLogs)
+	}
+
+	// 再次执行时刚移入回收站的邮件还在保留期内
+	report, err = retention.Run(ctx, false)
+	if err != nil || report.Accounts[0].TrashPurged != 0 || report.Accounts[0].ArchiveTrashed != 0 {
+		t.Errorf("repeated run = %+v, %v", report.Accounts[0], err)
+	}
+}
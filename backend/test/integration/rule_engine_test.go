@@ -25,6 +25,12 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&model.EmailRule{},
 		&model.Email{},
 		&model.EmailAttachment{},
+		&model.AttachmentBlob{},
+		&model.Account{},
+		&model.MailboxCounter{},
+		&model.DataKey{},
+		&model.EmailSearchToken{},
+		&model.PurgedEmail{},
 	)
 	if err != nil {
 		t.Fatalf("Failed to migrate database: %v", err)
@@ -40,7 +46,7 @@ func TestRuleEngineIntegration(t *testing.T) {
 
 	// 创建仓库
 	ruleRepo := repository.NewRuleRepository(db)
-	emailRepo := repository.NewEmailRepository(db)
+	emailRepo := repository.NewEmailRepository(db, nil)
 
 	// 创建服务
 	ruleService := service.NewRuleService(ruleRepo, emailRepo)
@@ -502,7 +508,7 @@ func TestRuleEngineIntegration(t *testing.T) {
 func TestRuleValidation(t *testing.T) {
 	db := setupTestDB(t)
 	ruleRepo := repository.NewRuleRepository(db)
-	emailRepo := repository.NewEmailRepository(db)
+	emailRepo := repository.NewEmailRepository(db, nil)
 	ruleService := service.NewRuleService(ruleRepo, emailRepo)
 
 	ctx := context.Background()
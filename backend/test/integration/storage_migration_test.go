@@ -0,0 +1,119 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fusionmail/internal/model"
+	"fusionmail/internal/repository"
+	"fusionmail/internal/service"
+	"fusionmail/pkg/storage"
+)
+
+// TestStorageMigration 存储迁移（可重复执行）和完整性校验集成测试
+func TestStorageMigration(t *testing.T) {
+	db := setupTestDB(t)
+	sourceDir, targetDir := t.TempDir(), t.TempDir()
+	source, err := storage.NewLocalProvider(sourceDir, "")
+	if err != nil {
+		t.Fatalf("Failed to create source provider: %v", err)
+	}
+	target, err := storage.NewLocalProvider(targetDir, "")
+	if err != nil {
+		t.Fatalf("Failed to create target provider: %v", err)
+	}
+	ctx := context.Background()
+
+	attachmentRepo := repository.NewAttachmentRepository(db)
+	attachments := service.NewAttachmentService(attachmentRepo, source, service.AttachmentOptions{StorageType: "local"})
+	emailRepo := repository.NewEmailRepository(db, nil)
+	rawMessages := service.NewRawMessageService(emailRepo, source)
+
+	email := &model.Email{ProviderID: "1", AccountUID: "storage-account", Subject: "Report", SentAt: time.Now(), ReceivedAt: time.Now()}
+	if err := emailRepo.Create(ctx, email); err != nil {
+		t.Fatalf("Create email failed: %v", err)
+	}
+	if err := rawMessages.Archive(ctx, email, []byte("Subject: Report\r\n\r\nBody\r\n")); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	// 两个附件内容相同，只存储一份
+	for _, name := range []string{"a.txt", "b.txt"} {
+		attachment := &model.EmailAttachment{EmailID: email.ID, Filename: name, ContentType: "text/plain", SizeBytes: 5}
+		if err := attachments.SaveAttachment(ctx, attachment, strings.NewReader("hello")); err != nil {
+			t.Fatalf("SaveAttachment failed: %v", err)
+		}
+	}
+	// 去重上线前按邮件保存的附件
+	if _, err := source.Upload(ctx, "legacy/1/old.pdf", strings.NewReader("%PDF"), "application/pdf"); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	legacy := &model.EmailAttachment{EmailID: email.ID, Filename: "old.pdf", SizeBytes: 4, StorageType: "local", StoragePath: "legacy/1/old.pdf"}
+	if err := attachmentRepo.Create(ctx, legacy); err != nil {
+		t.Fatalf("Create legacy attachment failed: %v", err)
+	}
+
+	result, err := attachments.MigrateStoredFiles(ctx, target, "s3", nil)
+	if err != nil {
+		t.Fatalf("MigrateStoredFiles failed: %v", err)
+	}
+	if result.Copied != 2 || result.Failed != 0 || result.Bytes != 9 {
+		t.Errorf("attachment migration = %+v", result)
+	}
+	raw, err := rawMessages.MigrateStoredFiles(ctx, target, nil)
+	if err != nil || raw.Copied != 1 {
+		t.Errorf("raw migration = %+v, %v", raw, err)
+	}
+
+	var records []*model.EmailAttachment
+	db.Find(&records)
+	for _, record := range records {
+		if record.StorageType != "s3" {
+			t.Errorf("attachment %s storage type = %s", record.Filename, record.StorageType)
+		}
+		if _, err := os.Stat(filepath.Join(targetDir, record.StoragePath)); err != nil {
+			t.Errorf("attachment %s not copied: %v", record.Filename, err)
+		}
+	}
+
+	// 重新执行时已迁移的附件记录不再处理，目标中内容相同的原文跳过
+	result, err = attachments.MigrateStoredFiles(ctx, target, "s3", nil)
+	if err != nil || result.Copied+result.Skipped != 0 {
+		t.Errorf("repeated attachment migration = %+v, %v", result, err)
+	}
+	raw, err = rawMessages.MigrateStoredFiles(ctx, target, nil)
+	if err != nil || raw.Copied != 0 || raw.Skipped != 1 {
+		t.Errorf("repeated raw migration = %+v, %v", raw, err)
+	}
+
+	// 校验目标存储：篡改内容文件、删除原文
+	targetAttachments := service.NewAttachmentService(attachmentRepo, target, service.AttachmentOptions{StorageType: "s3"})
+	verified, err := targetAttachments.VerifyStoredFiles(ctx, nil)
+	if err != nil || verified.Checked != 2 || verified.Issues != 0 {
+		t.Errorf("verify before damage = %+v, %v", verified, err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, records[0].StoragePath), []byte("HELLO"), 0644); err != nil {
+		t.Fatalf("Failed to damage blob: %v", err)
+	}
+	if err := os.Remove(filepath.Join(targetDir, email.RawPath)); err != nil {
+		t.Fatalf("Failed to remove raw file: %v", err)
+	}
+
+	var issues []service.StorageIssue
+	report := func(issue service.StorageIssue) { issues = append(issues, issue) }
+	if _, err := targetAttachments.VerifyStoredFiles(ctx, report); err != nil {
+		t.Fatalf("VerifyStoredFiles failed: %v", err)
+	}
+	if _, err := service.NewRawMessageService(emailRepo, target).VerifyStoredFiles(ctx, report); err != nil {
+		t.Fatalf("VerifyStoredFiles failed: %v", err)
+	}
+	if len(issues) != 2 ||
+		issues[0].Kind != "attachment_blob" || issues[0].Problem != service.StorageIssueCorrupted ||
+		issues[1].Kind != "raw_message" || issues[1].Problem != service.StorageIssueMissing {
+		t.Errorf("issues = %+v", issues)
+	}
+}
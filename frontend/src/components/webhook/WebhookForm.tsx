@@ -25,6 +25,7 @@ const EVENT_OPTIONS = [
   { value: 'email.starred', label: '邮件星标' },
   { value: 'email.archived', label: '邮件归档' },
   { value: 'email.deleted', label: '邮件删除' },
+  { value: 'email.restored', label: '邮件恢复' },
   { value: 'account.sync.started', label: '同步开始' },
   { value: 'account.sync.completed', label: '同步完成' },
   { value: 'account.sync.failed', label: '同步失败' },
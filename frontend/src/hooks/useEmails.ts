@@ -43,9 +43,12 @@ export const useEmails = () => {
       setLoading(true);
       setError(null);
 
+      // 游标随每次加载更新，直接从 store 读取，避免作为依赖触发重复加载
+      const cursor = useEmailStore.getState().cursors[page - 1];
+      const pagination = { cursor: cursor || undefined, page_size: pageSize };
       const response = searchQuery
-        ? await emailService.search(searchQuery, filter.account_uid, { page, page_size: pageSize })
-        : await emailService.getList(filter, { page, page_size: pageSize });
+        ? await emailService.search(searchQuery, filter.account_uid, pagination)
+        : await emailService.getList(filter, pagination);
 
       setEmails(response);
     } catch (err) {
@@ -190,6 +193,7 @@ export const useEmails = () => {
     page,
     pageSize,
     totalPages,
+    cursors,
     filter,
     searchQuery,
     isLoading,
@@ -6,6 +6,8 @@ import { Email, PaginationParams } from '../types';
 interface SearchState {
   emails: Email[];
   total: number;
+  nextCursor?: string;
+  hasMore: boolean;
   isLoading: boolean;
   error: string | null;
   hasSearched: boolean;
@@ -21,6 +23,7 @@ export const useSearch = () => {
   const [state, setState] = useState<SearchState>({
     emails: [],
     total: 0,
+    hasMore: false,
     isLoading: false,
     error: null,
     hasSearched: false,
@@ -35,6 +38,8 @@ export const useSearch = () => {
         ...prev,
         emails: [],
         total: 0,
+        nextCursor: undefined,
+        hasMore: false,
         hasSearched: false,
         error: null,
       }));
@@ -46,11 +51,15 @@ export const useSearch = () => {
     setCurrentAccountUid(accountUid);
 
     try {
+      // 带游标时是加载更多，追加到已有结果；总数只在第一页返回
+      const isFirstPage = !pagination?.cursor;
       const result = await emailService.search(query, accountUid, pagination);
       setState(prev => ({
         ...prev,
-        emails: pagination?.page === 1 ? result.emails : [...prev.emails, ...result.emails],
-        total: result.total,
+        emails: isFirstPage ? result.emails : [...prev.emails, ...result.emails],
+        total: isFirstPage ? result.total : prev.total,
+        nextCursor: result.next_cursor,
+        hasMore: result.has_more,
         isLoading: false,
         hasSearched: true,
       }));
@@ -66,20 +75,21 @@ export const useSearch = () => {
     }
   }, []);
 
-  const loadMore = useCallback(async (page: number) => {
-    if (!currentQuery || state.isLoading) return;
+  const loadMore = useCallback(async () => {
+    if (!currentQuery || state.isLoading || !state.nextCursor) return;
 
     await search({
       query: currentQuery,
       accountUid: currentAccountUid,
-      pagination: { page, page_size: 20 },
+      pagination: { cursor: state.nextCursor, page_size: 20 },
     });
-  }, [currentQuery, currentAccountUid, state.isLoading, search]);
+  }, [currentQuery, currentAccountUid, state.isLoading, state.nextCursor, search]);
 
   const clearSearch = useCallback(() => {
     setState({
       emails: [],
       total: 0,
+      hasMore: false,
       isLoading: false,
       error: null,
       hasSearched: false,
@@ -17,6 +17,7 @@ export const SearchPage = () => {
   const { 
     emails, 
     total, 
+    hasMore, 
     isLoading, 
     error, 
     hasSearched, 
@@ -44,7 +45,7 @@ export const SearchPage = () => {
   });
 
   // 处理搜索
-  const handleSearch = useCallback(async (searchQuery?: string, page = 1) => {
+  const handleSearch = useCallback(async (searchQuery?: string) => {
     const finalQuery = searchQuery || query;
     if (!finalQuery.trim()) return;
 
@@ -79,22 +80,17 @@ export const SearchPage = () => {
     await search({
       query: fullQuery,
       accountUid: selectedAccountUid === 'all' ? undefined : selectedAccountUid,
-      pagination: { page, page_size: 20 },
+      pagination: { page_size: 20 },
     });
 
-    if (page === 1) {
-      addToHistory(finalQuery);
-      setCurrentPage(1);
-    } else {
-      setCurrentPage(page);
-    }
+    addToHistory(finalQuery);
+    setCurrentPage(1);
   }, [query, selectedAccountUid, advancedParams, search, addToHistory]);
 
   // 处理加载更多
   const handleLoadMore = useCallback(() => {
-    const nextPage = currentPage + 1;
-    loadMore(nextPage);
-    setCurrentPage(nextPage);
+    loadMore();
+    setCurrentPage(currentPage + 1);
   }, [currentPage, loadMore]);
 
   // 处理邮件点击
@@ -383,7 +379,7 @@ export const SearchPage = () => {
                 />
                 
                 {/* 加载更多 */}
-                {emails.length < total && (
+                {hasMore && (
                   <div className="mt-6 text-center">
                     <Button
                       variant="outline"
@@ -71,10 +71,10 @@ export const emailService = {
   }> => {
     // 使用多个请求来获取统计信息
     const [unreadResp, starredResp, archivedResp, deletedResp] = await Promise.all([
-      api.get<{ success: boolean; data: EmailListResponse }>('/emails', { params: { is_read: false, is_deleted: false, page: 1, page_size: 1 } }),
-      api.get<{ success: boolean; data: EmailListResponse }>('/emails', { params: { is_starred: true, is_deleted: false, page: 1, page_size: 1 } }),
-      api.get<{ success: boolean; data: EmailListResponse }>('/emails', { params: { is_archived: true, is_deleted: false, page: 1, page_size: 1 } }),
-      api.get<{ success: boolean; data: EmailListResponse }>('/emails', { params: { is_deleted: true, page: 1, page_size: 1 } }),
+      api.get<{ success: boolean; data: EmailListResponse }>('/emails', { params: { is_read: false, is_deleted: false, page_size: 1 } }),
+      api.get<{ success: boolean; data: EmailListResponse }>('/emails', { params: { is_starred: true, is_deleted: false, page_size: 1 } }),
+      api.get<{ success: boolean; data: EmailListResponse }>('/emails', { params: { is_archived: true, is_deleted: false, page_size: 1 } }),
+      api.get<{ success: boolean; data: EmailListResponse }>('/emails', { params: { is_deleted: true, page_size: 1 } }),
     ]);
 
     return {
@@ -16,9 +16,9 @@ export interface EmailFilter {
 export interface EmailListResponse {
   emails: Email[];
   total: number;
-  page: number;
   page_size: number;
-  total_pages: number;
+  next_cursor?: string;
+  has_more: boolean;
 }
 
 interface EmailState {
@@ -29,6 +29,7 @@ interface EmailState {
   page: number;
   pageSize: number;
   totalPages: number;
+  cursors: string[]; // 每一页的游标，cursors[0] 为第一页（空游标）
   
   // 筛选和搜索
   filter: EmailFilter;
@@ -75,6 +76,7 @@ const initialState = {
   page: 1,
   pageSize: 20,
   totalPages: 0,
+  cursors: [''],
   filter: {
     is_archived: false,
     is_deleted: false,
@@ -92,23 +94,36 @@ const initialState = {
 export const useEmailStore = create<EmailState>((set) => ({
   ...initialState,
 
-  setEmails: (response) => set({
-    emails: response.emails,
-    total: response.total,
-    page: response.page,
-    pageSize: response.page_size,
-    totalPages: response.total_pages,
+  // 游标分页只在第一页返回总数，后续页沿用第一页的统计
+  setEmails: (response) => set((state) => {
+    const total = state.page === 1 ? response.total : state.total;
+    const cursors = state.cursors.slice(0, state.page);
+    if (response.has_more && response.next_cursor) {
+      cursors.push(response.next_cursor);
+    }
+    return {
+      emails: response.emails,
+      total,
+      pageSize: response.page_size,
+      totalPages: response.has_more
+        ? Math.max(Math.ceil(total / response.page_size), state.page + 1)
+        : state.page,
+      cursors,
+    };
   }),
 
   setSelectedEmail: (email) => set({ selectedEmail: email }),
 
-  setFilter: (filter) => set({ filter, page: 1 }),
+  setFilter: (filter) => set({ filter, page: 1, cursors: [''] }),
 
-  setSearchQuery: (query) => set({ searchQuery: query, page: 1 }),
+  setSearchQuery: (query) => set({ searchQuery: query, page: 1, cursors: [''] }),
 
-  setPage: (page) => set({ page }),
+  // 只能翻到已知游标的页（上一页或下一页）
+  setPage: (page) => set((state) => (
+    page === 1 ? { page, cursors: [''] } : page <= state.cursors.length ? { page } : {}
+  )),
 
-  setPageSize: (pageSize) => set({ pageSize, page: 1 }),
+  setPageSize: (pageSize) => set({ pageSize, page: 1, cursors: [''] }),
 
   setLoading: (loading) => set({ isLoading: loading }),
 
@@ -83,12 +83,13 @@ export interface EmailFilter {
   end_date?: string;
 }
 
+// 邮件列表只返回摘要，不含正文（正文通过详情接口获取）
 export interface EmailListResponse {
   emails: Email[];
-  total: number;
-  page: number;
+  total: number; // 只在第一页（不带 cursor）返回
   page_size: number;
-  total_pages: number;
+  next_cursor?: string;
+  has_more: boolean;
 }
 
 // API 响应类型
@@ -100,7 +101,7 @@ export interface ApiResponse<T> {
 
 // 分页参数
 export interface PaginationParams {
-  page?: number;
+  cursor?: string;
   page_size?: number;
 }
 